@@ -126,7 +126,8 @@ function ControlsSidebar({
                 schemaNames: selectedSchemas, 
                 text: currentResult.text,
                 entities: entitiesToSave,
-                originalFilename: file.name
+                originalFilename: file.name,
+                promptVersion: currentResult.metadata?.prompt_version
             };
 
             const response = await fetch('/api/save-results', {
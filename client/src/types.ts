@@ -19,6 +19,7 @@ export interface EntityOccurrence {
 export interface ExtractionResult {
   text: string; // Matches Go backend's Text field
   entities: Record<string, EntityOccurrence[]>; // Matches Go backend's Entities field
+  metadata?: { prompt_version?: string }; // Subset of the Go backend's Metadata field
 }
 
 // Type for managing scrolling
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"syscall"
+	"time"
 
 	"github.com/andevellicus/med-ex/internal/config"
 	"github.com/andevellicus/med-ex/internal/extractor"
@@ -46,9 +51,12 @@ func main() {
 	}
 	defer log.Sync()
 
-	schemaDir := cfg.LLM.SchemaDir
-	if !filepath.IsAbs(schemaDir) {
-		schemaDir = filepath.Join(rootPath, schemaDir)
+	schemaDirs := make([]string, 0, len(cfg.LLM.SchemaDirs))
+	for _, schemaDir := range cfg.LLM.SchemaDirs {
+		if !filepath.IsAbs(schemaDir) {
+			schemaDir = filepath.Join(rootPath, schemaDir)
+		}
+		schemaDirs = append(schemaDirs, schemaDir)
 	}
 
 	resultsDir := cfg.Results.Dir
@@ -64,9 +72,21 @@ func main() {
 	log.Info("Extractor service initialized")
 
 	// --- Add Handler Initialization ---
-	schemaHandler := handlers.NewSchemaHandler(extractorService, log, schemaDir)
-	extractHandler := handlers.NewExtractHandler(extractorService, log)
-	saveResultsHandler := handlers.NewSaveResultsHandler(resultsDir, extractorService, log)
+	schemaHandler := handlers.NewSchemaHandler(extractorService, log, schemaDirs, cfg.LLM.LeafNameCollisions)
+	callbackClient := handlers.NewCallbackClient(cfg.Callbacks.AllowedHosts, cfg.Callbacks.MaxAttempts, time.Duration(cfg.Callbacks.TimeoutSeconds)*time.Second, log)
+	jobStore := handlers.NewJobStore(cfg.Jobs.Workers, cfg.Jobs.QueueSize, time.Duration(cfg.Jobs.TTLMinutes)*time.Minute, log)
+	eventsPath := cfg.Events.Path
+	if !filepath.IsAbs(eventsPath) {
+		eventsPath = filepath.Join(rootPath, eventsPath)
+	}
+	eventSink, err := handlers.NewEventSink(cfg.Events.Backend, eventsPath)
+	if err != nil {
+		log.Fatal("Failed to initialize event sink", zap.Error(err))
+	}
+	eventPublisher := handlers.NewEventPublisher(eventSink, cfg.Events.QueueSize, log)
+	extractHandler := handlers.NewExtractHandler(extractorService, log, cfg.Batch.Workers, cfg.Batch.PreserveOrder, callbackClient, jobStore, eventPublisher)
+	saveResultsHandler := handlers.NewSaveResultsHandler(resultsDir, cfg.Results.PathTemplate, cfg.Results.SaveLLMRaw, extractorService, log)
+	resultsHandler := handlers.NewResultsHandler(resultsDir, extractorService, log)
 	log.Info("Handlers initialized")
 
 	// Set Gin mode
@@ -79,8 +99,12 @@ func main() {
 	}
 
 	router := gin.New()
+	router.UseRawPath = true // Route on the escaped path, so a nested results :folder can carry %2F
 	router.Use(gin.Recovery())
 	router.Use(logger.LoggerMiddleware(log))
+	router.Use(handlers.Gzip(cfg.Server.GzipLevel, cfg.Server.GzipMinBytes))
+
+	limitBody := handlers.LimitRequestBody(cfg.Server.MaxBodyBytes)
 
 	// --- Add API Route ---
 	api := router.Group("/api") // Group API routes
@@ -88,23 +112,89 @@ func main() {
 
 		api.GET("/schemas", schemaHandler.GetSchemas)
 		api.GET("/schemas/details", schemaHandler.GetSchemaDetails)
+		api.GET("/schemas/compare", schemaHandler.CompareSchemas)
+		api.GET("/schemas/:schemaName/raw", schemaHandler.GetRawSchema)
+		api.POST("/schemas/lint", schemaHandler.LintSchemas)
 		//api.GET("/schemas/:schemaName/content", schemaHandler.GetSchemaContent)
-		api.POST("/extract", extractHandler.ExtractEntities)
-		api.POST("/save-results", saveResultsHandler.SaveResults)
+		api.POST("/extract", limitBody, extractHandler.ExtractEntities)
+		api.POST("/extract/batch", limitBody, extractHandler.BatchExtract)
+		api.POST("/extract/async", limitBody, extractHandler.ExtractAsync)
+		api.GET("/jobs/:id", extractHandler.GetJob)
+		api.DELETE("/jobs/:id", extractHandler.CancelJob)
+		api.POST("/prompt/estimate", limitBody, extractHandler.EstimatePrompt)
+		api.POST("/save-results", limitBody, saveResultsHandler.SaveResults)
+		api.GET("/results/:folder/text", resultsHandler.GetResultText)
+		api.GET("/results/:folder/schema", resultsHandler.GetResultSchema)
+		api.GET("/results/:folder/staleness", resultsHandler.GetResultStaleness)
+		api.GET("/results/:folder/conll", resultsHandler.GetResultCoNLL)
+		api.PATCH("/results/:folder/entities", limitBody, resultsHandler.PatchResultEntities)
+		api.GET("/ready", handlers.Ready(extractorService))
 		// Add other API routes here
 	}
+	registerDebugVars(api, cfg, log)
 
 	clientDistPath := filepath.Join(rootPath, "client", "dist")
-	router.Use(staticFS(clientDistPath))
+	router.Use(staticFS(clientDistPath, time.Duration(cfg.Server.StaticTimeoutSeconds)*time.Second, log))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := newHTTPServer(cfg, router)
+	log.Info("Starting server", zap.String("addr", server.Addr))
+	err = serveUntilDone(ctx, server, time.Duration(cfg.Server.ShutdownTimeoutSeconds)*time.Second, log)
+	// Flush the queued events before exiting, log.Fatal skips deferred calls
+	if closeErr := eventPublisher.Close(); closeErr != nil {
+		log.Error("Failed to close event publisher", zap.Error(closeErr))
+	}
+	if err != nil {
+		log.Fatal("Server failed", zap.Error(err))
+	}
+	log.Info("Server stopped")
+}
+
+// serveUntilDone serves until the listener fails or ctx is done, then waits
+// up to timeout for in-flight requests to finish.
+func serveUntilDone(ctx context.Context, server *http.Server, timeout time.Duration, log *zap.Logger) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	log.Info("Shutting down server", zap.Duration("timeout", timeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+	return nil
+}
 
-	router.Run(":" + cfg.Server.Port) // Use the port from the config
+// registerDebugVars serves process metrics, e.g. match counts, when
+// server.debug_vars is set. They include the command line and memory stats,
+// so they are not served by default.
+func registerDebugVars(api *gin.RouterGroup, cfg *config.Config, log *zap.Logger) {
+	if !cfg.Server.DebugVars {
+		return
+	}
+	log.Warn("Serving unauthenticated process metrics at /api/debug/vars")
+	api.GET("/debug/vars", gin.WrapH(expvar.Handler()))
 }
 
-func staticFS(root string) gin.HandlerFunc {
-	fileServer := http.FileServer(http.Dir(root))
-	return func(c *gin.Context) {
-		fileServer.ServeHTTP(c.Writer, c.Request)
-		c.Abort()
+// newHTTPServer applies the configured timeouts and header limit to the server.
+func newHTTPServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Server.Port, // Use the port from the config
+		Handler:           handler,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeoutSeconds) * time.Second,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
 	}
 }
 
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestNewHTTPServerClosesSlowHeaders(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	cfg.Server.ReadHeaderTimeoutSeconds = 1
+	server := newHTTPServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Start a request but never finish the headers
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	io.ReadAll(conn) // Returns once the server drops the connection
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("slow header connection held open for %v", elapsed)
+	}
+}
+
+func TestNewHTTPServerAppliesLimits(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	server := newHTTPServer(cfg, nil)
+	if server.ReadHeaderTimeout != 10*time.Second || server.WriteTimeout != 600*time.Second {
+		t.Errorf("timeouts = %v/%v, want the configured defaults", server.ReadHeaderTimeout, server.WriteTimeout)
+	}
+	if server.MaxHeaderBytes != 1<<20 {
+		t.Errorf("MaxHeaderBytes = %d, want 1 MiB", server.MaxHeaderBytes)
+	}
+}
+
+func TestRegisterDebugVars(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, enabled := range []bool{false, true} {
+		cfg := config.NewDefaultConfig()
+		cfg.Server.DebugVars = enabled
+		router := gin.New()
+		registerDebugVars(router.Group("/api"), cfg, zap.NewNop())
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/debug/vars", nil))
+		want := http.StatusNotFound
+		if enabled {
+			want = http.StatusOK
+		}
+		if w.Code != want {
+			t.Errorf("debug_vars %v: status = %d, want %d", enabled, w.Code, want)
+		}
+	}
+}
+
+func TestServeUntilDoneFinishesInFlightRequests(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serveUntilDone(ctx, server, 5*time.Second, zap.NewNop())
+	}()
+
+	response := make(chan string, 1)
+	go func() {
+		var resp *http.Response
+		var err error
+		for range 50 { // Until the listener is up
+			if resp, err = http.Get("http://" + addr); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if resp == nil {
+			response <- ""
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+
+	<-started
+	cancel()
+	time.Sleep(50 * time.Millisecond) // Shutdown has begun and waits on the request
+	close(release)
+
+	if body := <-response; body != "done" {
+		t.Errorf("in-flight response = %q, want it finished during shutdown", body)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serveUntilDone() = %v, want nil after a graceful shutdown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveUntilDone did not return after shutdown")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Types Go's built-in table does not know on every platform
+var staticMimeTypes = map[string]string{
+	".wasm": "application/wasm",
+	".map":  "application/json",
+}
+
+// staticFS serves the built client from root. Each response must be written
+// within timeout (0 disables), so a stalled download does not hold the
+// connection for the API's longer write timeout. When root does not exist
+// every request gets a 503 explaining that the client is not built.
+func staticFS(root string, timeout time.Duration, logger *zap.Logger) gin.HandlerFunc {
+	for ext, mimeType := range staticMimeTypes {
+		if err := mime.AddExtensionType(ext, mimeType); err != nil {
+			logger.Warn("Failed to register static file type", zap.String("extension", ext), zap.Error(err))
+		}
+	}
+
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		logger.Warn("Client build not found, serving the API only", zap.String("path", root))
+		return func(c *gin.Context) {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "The web client is not built; run the client build to create client/dist"})
+		}
+	}
+
+	fileServer := http.FileServer(http.Dir(root))
+	return func(c *gin.Context) {
+		if timeout > 0 {
+			if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+				logger.Debug("Could not set static file write deadline", zap.Error(err))
+			}
+		}
+		fileServer.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// serveStatic requests path from a router serving the client build in root.
+func serveStatic(root, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(staticFS(root, time.Minute, zap.NewNop()))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestStaticFSMissingClientBuild(t *testing.T) {
+	w := serveStatic(filepath.Join(t.TempDir(), "dist"), "/index.html")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "not built") {
+		t.Errorf("body = %s, want an explanation that the client is not built", w.Body)
+	}
+}
+
+func TestStaticFSContentTypes(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"app.wasm", "app.js.map"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string]string{
+		"/app.wasm":   "application/wasm",
+		"/app.js.map": "application/json",
+	}
+	for path, want := range tests {
+		w := serveStatic(root, path)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d", path, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != want {
+			t.Errorf("%s: content type = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -10,7 +10,21 @@ import (
 // Config holds the application configuration.
 type Config struct {
 	Server struct {
-		Port string `mapstructure:"port"`
+		Port                     string `mapstructure:"port"`
+		ReadTimeoutSeconds       int    `mapstructure:"read_timeout_seconds"`        // Whole request, including the body
+		ReadHeaderTimeoutSeconds int    `mapstructure:"read_header_timeout_seconds"` // Guards against slowloris clients
+		WriteTimeoutSeconds      int    `mapstructure:"write_timeout_seconds"`       // Must outlast the LLM call
+		IdleTimeoutSeconds       int    `mapstructure:"idle_timeout_seconds"`
+		ShutdownTimeoutSeconds   int    `mapstructure:"shutdown_timeout_seconds"` // In-flight requests get this long to finish on SIGINT/SIGTERM
+		StaticTimeoutSeconds     int    `mapstructure:"static_timeout_seconds"`   // Per client asset response, 0 disables
+		MaxHeaderBytes           int    `mapstructure:"max_header_bytes"`
+		MaxBodyBytes             int64  `mapstructure:"max_body_bytes"` // Larger extract/save bodies get 413
+		// Gzip level for responses, 1 (fastest) to 9 (smallest); 0 disables compression
+		GzipLevel    int `mapstructure:"gzip_level"`
+		GzipMinBytes int `mapstructure:"gzip_min_bytes"` // Smaller responses are sent uncompressed
+		// Serve process metrics (expvar, including cmdline and memstats) at
+		// /api/debug/vars; unauthenticated, so off unless explicitly enabled
+		DebugVars bool `mapstructure:"debug_vars"`
 	} `mapstructure:"server"`
 
 	Log struct {
@@ -24,50 +38,245 @@ type Config struct {
 
 	LLM struct {
 		ServerURL string `mapstructure:"server"`
-		SchemaDir string `mapstructure:"schema_dir"`
+		// Model requested from the server unless the schemas name one with _model; empty leaves it to the server
+		Model string `mapstructure:"model"`
+		// When combined schemas name different models: "default" uses model, "error" rejects the request
+		ModelConflicts string `mapstructure:"model_conflicts"`
+		// Other servers a request may route to with llm_server, e.g. a faster or larger model
+		AllowedServers []string `mapstructure:"allowed_servers"`
+		SchemaDirs     []string `mapstructure:"schema_dir"`   // Searched in order; later dirs override same-named schemas
+		MaxExamples    int      `mapstructure:"max_examples"` // Cap on few-shot examples injected into a prompt
+		// Give the model the server date when a request has no reference date
+		InjectReferenceDate bool `mapstructure:"inject_reference_date"`
+		// Convert relative date values ("3 days ago") into absolute dates using the reference date
+		ResolveRelativeDates bool `mapstructure:"resolve_relative_dates"`
+		// Send a tiny prompt at startup so the first extraction doesn't pay the cold-start cost
+		WarmUp               bool `mapstructure:"warm_up"`
+		WarmUpTimeoutSeconds int  `mapstructure:"warm_up_timeout_seconds"` // Upper bound on the startup warm-up
+		// Ping the LLM server this often and fail extractions fast while it is down, 0 disables
+		HealthCheckIntervalSeconds int      `mapstructure:"health_check_interval_seconds"`
+		HealthCheckTimeoutSeconds  int      `mapstructure:"health_check_timeout_seconds"`
+		DefaultSchemas             []string `mapstructure:"default_schemas"` // Applied when a request names no schemas; must exist
+		// Schema names one extract or save request may combine, as their prompt grows with each; 0 disables
+		MaxRequestSchemas int `mapstructure:"max_request_schemas"`
+		// Fail at startup on schema files that do not parse or are malformed, instead of skipping them
+		StrictSchemas bool `mapstructure:"strict_schemas"`
+		// Limit the schemas callers can list and use; an empty allow list allows all
+		AllowedSchemas []string `mapstructure:"allowed_schemas"`
+		DeniedSchemas  []string `mapstructure:"denied_schemas"` // Hidden even when allowed
+		// Schema details for entities sharing a leaf name at different paths:
+		// "report", "prefer_nested" or "prefer_flat"
+		LeafNameCollisions string `mapstructure:"leaf_name_collisions"`
+		// Prompt template: "verbose" spells out the output rules, "concise" suits instruction-tuned models
+		PromptStyle string `mapstructure:"prompt_style"`
+		// Language of the prompt instructions: "en" is built in, others are read from prompt_dir
+		PromptLang string `mapstructure:"prompt_lang"`
+		PromptDir  string `mapstructure:"prompt_dir"` // Translated prompt templates, one "<lang>.txt" per language
+		// Dotted paths tried in order to find the extraction JSON in the LLM server's response
+		ContentPaths []string `mapstructure:"content_paths"`
+		RepairJSON   bool     `mapstructure:"repair_json"`   // Fix trailing commas and surrounding text in LLM JSON
+		MaxReprompts int      `mapstructure:"max_reprompts"` // Extra LLM calls when the response cannot be parsed
+		// Extra LLM calls when the response content is blank, a usually transient failure
+		EmptyContentRetries int `mapstructure:"empty_content_retries"`
+		// Extract once more when less than this share of the schema's entities is found, 0 disables
+		LowYieldRatio       float64 `mapstructure:"low_yield_ratio"`
+		LowYieldTemperature float64 `mapstructure:"low_yield_temperature"` // Sampling temperature of that retry
+		// Joins nested entity names in prompts and output keys, for schemas whose names contain periods
+		NestingDelimiter string `mapstructure:"nesting_delimiter"`
+		MaxResponseBytes int64  `mapstructure:"max_response_bytes"` // Larger LLM responses are rejected instead of read into memory
+		ContextSize      int    `mapstructure:"context_size"`       // Model context window in tokens, for prompt size warnings
+		// Caps the response (sent as n_predict/max_tokens); 0 leaves it unbounded
+		MaxOutputTokens int `mapstructure:"max_output_tokens"`
+		// Response tokens prompt size warnings reserve out of context_size while max_output_tokens is 0
+		ResponseReserveTokens int `mapstructure:"response_reserve_tokens"`
 	} `mapstructure:"llm"`
 
 	Results struct {
 		Dir string `mapstructure:"dir"`
+		// Save subpath under dir built from {date}, {schema} and {filename}, e.g. "{date}/{schema}/{filename}"
+		PathTemplate string `mapstructure:"path_template"`
+		// Write llm_raw.json on every save that carries the debug LLM responses
+		SaveLLMRaw bool `mapstructure:"save_llm_raw"`
 	} `mapstructure:"results"`
+
+	Batch struct {
+		Workers int `mapstructure:"workers"` // Documents of a batch extracted concurrently
+		// Stream NDJSON results in input order instead of completion order
+		PreserveOrder bool `mapstructure:"preserve_order"`
+	} `mapstructure:"batch"`
+
+	Callbacks struct {
+		// host[:port] entries a request's callback_url may point at; empty disables callbacks
+		AllowedHosts   []string `mapstructure:"allowed_hosts"`
+		MaxAttempts    int      `mapstructure:"max_attempts"`    // Deliveries tried before giving up
+		TimeoutSeconds int      `mapstructure:"timeout_seconds"` // Per delivery attempt
+	} `mapstructure:"callbacks"`
+
+	OutputTemplates struct {
+		// Report templates requests may name in output_template, one "<name>.json" per template
+		Dir string `mapstructure:"dir"`
+	} `mapstructure:"output_templates"`
+
+	Events struct {
+		// Where finished extractions are announced: "" disables, "file" appends JSON lines to path
+		Backend   string `mapstructure:"backend"`
+		Path      string `mapstructure:"path"`
+		QueueSize int    `mapstructure:"queue_size"` // Events waiting for the backend before new ones are dropped
+	} `mapstructure:"events"`
+
+	Jobs struct {
+		Workers    int `mapstructure:"workers"`     // Async and callback extractions run concurrently
+		QueueSize  int `mapstructure:"queue_size"`  // Jobs waiting for a worker before submissions get 503
+		TTLMinutes int `mapstructure:"ttl_minutes"` // Finished jobs are kept this long for polling
+	} `mapstructure:"jobs"`
+
+	Input struct {
+		// Text that is not valid UTF-8: "decode" reads it as Windows-1252,
+		// "replace" substitutes U+FFFD for invalid bytes, "reject" fails the request
+		InvalidUTF8 string `mapstructure:"invalid_utf8"`
+		// Report a SHA-256 of the normalized text as metadata.text_hash, e.g. as a cache or audit key
+		HashText bool `mapstructure:"hash_text"`
+	} `mapstructure:"input"`
+
+	Header struct {
+		// "Key: Value" keys read from a document's leading lines when a request sets parse_header,
+		// matched case-insensitively; the header ends at the first other line
+		Keys     []string `mapstructure:"keys"`
+		MaxLines int      `mapstructure:"max_lines"` // Leading lines scanned, 0 for no limit
+	} `mapstructure:"header"`
+
+	Sections struct {
+		// Section headers results are grouped by with group_by=section when a request gives no markers
+		Markers []struct {
+			Name    string `mapstructure:"name"`
+			Pattern string `mapstructure:"pattern"` // Literal header matched case-insensitively at a line start
+			Regex   bool   `mapstructure:"regex"`   // Treat pattern as a regular expression instead
+		} `mapstructure:"markers"`
+	} `mapstructure:"sections"`
+
+	Matching struct {
+		// Replace the LLM context with the surrounding text slice when a value is only found by fallback search
+		FallbackContextFromText bool `mapstructure:"fallback_context_from_text"`
+		// Give fallback matches the enclosing sentence as context instead of a fixed window
+		SentenceFallbackContext bool `mapstructure:"sentence_fallback_context"`
+		// When a context occurs several times, keep only the match nearest the value's first direct match
+		NearestContextOnly bool `mapstructure:"nearest_context_only"`
+		// Match values of number entities whether written "11200", "11,200" or "11 200"
+		FlexibleThousandsSeparators bool `mapstructure:"flexible_thousands_separators"`
+		Workers                     int  `mapstructure:"workers"`          // Entities searched concurrently per request
+		RegexCacheSize              int  `mapstructure:"regex_cache_size"` // Compiled patterns kept across requests, 0 disables
+		// Extra search workers shared by all requests, 0 for no limit; each request always gets one
+		MaxTotalWorkers int `mapstructure:"max_total_workers"`
+		// Move a trailing unit of number entity values into a separate unit field, leaving the value numeric
+		SplitUnits bool     `mapstructure:"split_units"`
+		Units      []string `mapstructure:"units"` // Recognized by split_units, matched case-insensitively
+		// Collapse whitespace runs (tabs, NBSP, repeated spaces) before matching, e.g. for PDF-derived text
+		CollapseWhitespace bool `mapstructure:"collapse_whitespace"`
+		// Match smart quotes, dashes and ellipses as their ASCII forms, e.g. a model's "don't" against "don’t"
+		NormalizePunctuation bool `mapstructure:"normalize_punctuation"`
+		// Values up to this many characters are only matched as whole words inside their context, 0 disables
+		ShortValueLength int `mapstructure:"short_value_length"`
+		// Drop whitespace around string values before searching for them
+		TrimValues bool `mapstructure:"trim_values"`
+		// Also drop trailing punctuation (",;:.") from string values, e.g. "98.6,"
+		StripTrailingPunctuation bool `mapstructure:"strip_trailing_punctuation"`
+		// Return the cleaned value rather than the LLM's original string
+		KeepCleanedValues bool `mapstructure:"keep_cleaned_values"`
+		// Flag occurrences whose context matches more places in the text than this as ambiguous, 0 disables
+		MaxContextMatches int `mapstructure:"max_context_matches"`
+		// Trim LLM contexts longer than this many runes around the value before searching, 0 disables
+		MaxContextLength int `mapstructure:"max_context_length"`
+		// Reject context matches whose words agree less than this (0-1) with the LLM context, 0 disables
+		MinContextSimilarity float64 `mapstructure:"min_context_similarity"`
+		// Phrases coerced to true/false for `type: boolean` entities; entities may override them
+		BooleanTrueValues  []string `mapstructure:"boolean_true_values"`
+		BooleanFalseValues []string `mapstructure:"boolean_false_values"`
+		// Check LLM entity names against the schema: "lenient" keeps unknown names, "strict" drops them
+		EntityNameValidation string `mapstructure:"entity_name_validation"`
+		// Normalize output entity keys to "lower" or "upper" case, keeping the schema spelling per occurrence
+		EntityKeyCase string `mapstructure:"entity_key_case"`
+	} `mapstructure:"matching"`
+
+	PostProcessing struct {
+		// Named passes run in order over every extraction: "dedup", "sort_by_position", "collapse_repeats"
+		Processors []string `mapstructure:"processors"`
+	} `mapstructure:"post_processing"`
 }
 
 // NewDefaultConfig returns a Config struct with default values.
 func NewDefaultConfig() *Config {
-	return &Config{
-		Server: struct {
-			Port string `mapstructure:"port"`
-		}{
-			Port: "8080",
-		},
-		Log: struct {
-			Level      string `mapstructure:"level"`
-			MaxSizeMB  int    `mapstructure:"max_size_mb"`
-			MaxBackups int    `mapstructure:"max_backups"`
-			MaxAgeDays int    `mapstructure:"max_age_days"`
-			Compress   bool   `mapstructure:"compress"`
-			LogDir     string `mapstructure:"log_dir"`
-		}{
-			Level:      "info",
-			MaxSizeMB:  100,
-			MaxBackups: 5,
-			MaxAgeDays: 30,
-			Compress:   true,
-			LogDir:     "./logs",
-		},
-		LLM: struct {
-			ServerURL string "mapstructure:\"server\""
-			SchemaDir string `mapstructure:"schema_dir"`
-		}{
-			ServerURL: "http://127.0.0.1:5000",
-			SchemaDir: "config/",
-		},
-		Results: struct {
-			Dir string `mapstructure:"dir"`
-		}{
-			Dir: "./results",
-		},
-	}
+	cfg := &Config{}
+
+	cfg.Server.Port = "8080"
+	cfg.Server.ReadTimeoutSeconds = 60
+	cfg.Server.ReadHeaderTimeoutSeconds = 10
+	cfg.Server.WriteTimeoutSeconds = 600
+	cfg.Server.IdleTimeoutSeconds = 120
+	cfg.Server.ShutdownTimeoutSeconds = 30
+	cfg.Server.StaticTimeoutSeconds = 60
+	cfg.Server.MaxHeaderBytes = 1 << 20
+	cfg.Server.MaxBodyBytes = 5 * 1024 * 1024
+	cfg.Server.GzipLevel = 5
+	cfg.Server.GzipMinBytes = 1024
+
+	cfg.Log.Level = "info"
+	cfg.Log.MaxSizeMB = 100
+	cfg.Log.MaxBackups = 5
+	cfg.Log.MaxAgeDays = 30
+	cfg.Log.Compress = true
+	cfg.Log.LogDir = "./logs"
+
+	cfg.LLM.ServerURL = "http://127.0.0.1:5000"
+	cfg.LLM.SchemaDirs = []string{"config/"}
+	cfg.LLM.MaxExamples = 10
+	cfg.LLM.MaxRequestSchemas = 20
+	cfg.LLM.WarmUpTimeoutSeconds = 30
+	cfg.LLM.HealthCheckTimeoutSeconds = 10
+	cfg.LLM.RepairJSON = true
+	cfg.LLM.EmptyContentRetries = 1
+	cfg.LLM.LowYieldTemperature = 0.3
+	cfg.LLM.LeafNameCollisions = "report"
+	cfg.LLM.PromptStyle = "verbose"
+	cfg.LLM.ModelConflicts = "default"
+	cfg.LLM.PromptLang = "en"
+	cfg.LLM.PromptDir = "config/prompts"
+	cfg.OutputTemplates.Dir = "config/templates"
+	cfg.LLM.NestingDelimiter = "."
+	cfg.LLM.MaxResponseBytes = 64 * 1024 * 1024
+	cfg.LLM.ContextSize = 8192
+	cfg.LLM.ResponseReserveTokens = 2048
+	cfg.LLM.ContentPaths = []string{"content", "response", "choices.0.message.content", "choices.0.text"}
+
+	cfg.Results.Dir = "./results"
+	cfg.Results.PathTemplate = "{filename}"
+
+	cfg.Batch.Workers = 4
+
+	cfg.Callbacks.MaxAttempts = 3
+	cfg.Callbacks.TimeoutSeconds = 10
+
+	cfg.Events.Path = "events/extractions.jsonl"
+	cfg.Events.QueueSize = 256
+
+	cfg.Jobs.Workers = 2
+	cfg.Jobs.QueueSize = 100
+	cfg.Jobs.TTLMinutes = 60
+
+	cfg.Input.InvalidUTF8 = "decode"
+	cfg.Header.Keys = []string{"MRN", "Patient", "DOB", "Encounter Date", "Date of Service", "Author", "Provider"}
+	cfg.Header.MaxLines = 20
+
+	cfg.Matching.Workers = 4
+	cfg.Matching.RegexCacheSize = 1024
+	cfg.Matching.ShortValueLength = 3
+	cfg.Matching.MaxContextMatches = 1
+	cfg.Matching.TrimValues = true
+	cfg.Matching.FlexibleThousandsSeparators = true
+	cfg.Matching.Units = []string{"°F", "°C", "mmHg", "bpm", "/min", "%", "kg", "lb", "lbs", "cm", "mm", "mg", "mcg", "g", "mL", "L", "mg/dL", "g/dL", "mmol/L", "mEq/L", "U/L"}
+	cfg.Matching.BooleanTrueValues = []string{"true", "yes", "y", "present", "positive", "confirmed", "reports", "endorses", "admits"}
+	cfg.Matching.BooleanFalseValues = []string{"false", "no", "n", "absent", "negative", "denies", "denied", "none", "not present"}
+
+	return cfg
 }
 
 // LoadConfig loads the application configuration from the specified file.
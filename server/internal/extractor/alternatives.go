@@ -0,0 +1,54 @@
+package extractor
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// alternativeEntities returns the sorted names of entities whose definition
+// sets `alternatives: true`.
+func alternativeEntities(schema Schema, delimiter string) []string {
+	entities := []string{}
+	walkEntityDefinitions(schema, delimiter, func(fullName string, def map[string]any) {
+		if alternatives, _ := def["alternatives"].(bool); alternatives {
+			entities = append(entities, fullName)
+		}
+	})
+	sort.Strings(entities)
+	return entities
+}
+
+// formatAlternativesInstructions asks the model for a `candidates` array on
+// ambiguous occurrences of the given entities. It returns an empty string
+// when there are none.
+func formatAlternativesInstructions(entities []string) string {
+	if len(entities) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+- For the entities %s, when the text allows more than one reading, add a third key '"candidates"' to the occurrence object holding an array of the other plausible values, e.g. { "value": "...", "context": "...", "candidates": ["...", "..."] }. Keep the most likely reading in 'value'.`,
+		quoteEntityNames(entities))
+}
+
+// distinctAlternatives returns the candidates other than the primary value,
+// without duplicates, or nil when none remain.
+func distinctAlternatives(value any, candidates []any) []any {
+	var alternatives []any
+	for _, candidate := range candidates {
+		if candidate == nil || reflect.DeepEqual(candidate, value) {
+			continue
+		}
+		duplicate := false
+		for _, existing := range alternatives {
+			if reflect.DeepEqual(existing, candidate) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			alternatives = append(alternatives, candidate)
+		}
+	}
+	return alternatives
+}
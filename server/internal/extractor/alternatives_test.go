@@ -0,0 +1,56 @@
+package extractor
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ambiguousSchema = `
+Laterality:
+  type: string
+  alternatives: true
+Medication:
+  type: string
+`
+
+func TestProcessTextCapturesAlternatives(t *testing.T) {
+	text := "Pain in the L knee. Started ibuprofen."
+	llm := newStubLLM(t, completion(`{
+		"Laterality": [{"value": "L", "context": "Pain in the L knee", "candidates": ["left", "L", "lateral", "left"]}],
+		"Medication": [{"value": "ibuprofen", "context": "Started ibuprofen"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": ambiguousSchema})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, _ := llm.Requests()[0]["prompt"].(string)
+	if !strings.Contains(prompt, `"candidates"`) || !strings.Contains(prompt, `"Laterality"`) {
+		t.Error("prompt does not ask for candidates on the flagged entity")
+	}
+
+	laterality := output.Entities["Laterality"]
+	if len(laterality) != 1 {
+		t.Fatalf("got %d laterality occurrences, want 1", len(laterality))
+	}
+	if want := []any{"left", "lateral"}; !reflect.DeepEqual(laterality[0].Alternatives, want) {
+		t.Errorf("alternatives = %v, want %v", laterality[0].Alternatives, want)
+	}
+	// The position anchors on the primary value
+	if got := string([]rune(text)[laterality[0].Position.Start:laterality[0].Position.End]); got != "L" {
+		t.Errorf("position covers %q, want the primary value", got)
+	}
+	if meds := output.Entities["Medication"]; len(meds) != 1 || meds[0].Alternatives != nil {
+		t.Errorf("medication occurrences = %+v, want one without alternatives", meds)
+	}
+}
+
+func TestFormatAlternativesInstructionsWithoutEntities(t *testing.T) {
+	if got := formatAlternativesInstructions(nil); got != "" {
+		t.Errorf("instructions without flagged entities = %q, want empty", got)
+	}
+}
@@ -0,0 +1,40 @@
+package extractor
+
+import "sort"
+
+// AmbiguousContext reports an LLM occurrence whose context matched several
+// places in the text, so which of its highlights is right is uncertain.
+type AmbiguousContext struct {
+	Entity  string `json:"entity"`
+	GroupID string `json:"group_id"` // Of the occurrences placed from this context
+	Context string `json:"context"`
+	Matches int    `json:"matches"` // Places in the text the context matched
+}
+
+// ambiguousContexts lists the LLM occurrences of the output flagged with
+// ContextMatches, once per group, ordered by entity and group.
+func ambiguousContexts(output *ExtractionOutput) []AmbiguousContext {
+	ambiguous := []AmbiguousContext{}
+	for entityName, occurrences := range output.Entities {
+		seen := make(map[string]bool)
+		for _, occurrence := range occurrences {
+			if occurrence.ContextMatches == 0 || seen[occurrence.GroupID] {
+				continue
+			}
+			seen[occurrence.GroupID] = true
+			ambiguous = append(ambiguous, AmbiguousContext{
+				Entity:  entityName,
+				GroupID: occurrence.GroupID,
+				Context: occurrence.Context.Text,
+				Matches: occurrence.ContextMatches,
+			})
+		}
+	}
+	sort.Slice(ambiguous, func(i, j int) bool {
+		if ambiguous[i].Entity != ambiguous[j].Entity {
+			return ambiguous[i].Entity < ambiguous[j].Entity
+		}
+		return ambiguous[i].GroupID < ambiguous[j].GroupID
+	})
+	return ambiguous
+}
@@ -0,0 +1,52 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProcessTextFlagsAmbiguousContexts(t *testing.T) {
+	schema := `
+HeartRate:
+  type: number
+Medication:
+  type: string
+`
+	llm := newStubLLM(t, completion(`{
+		"HeartRate": [{"value": "80", "context": "HR 80"}],
+		"Medication": [{"value": "aspirin", "context": "on aspirin"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"note.yaml": schema})
+	text := "Morning: HR 80. Evening: HR 80 on aspirin. Night: HR 80."
+
+	output, err := s.ProcessText(context.Background(), []string{"note"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	heartRates := output.Entities["HeartRate"]
+	if len(heartRates) != 3 {
+		t.Fatalf("HeartRate = %+v, want the value placed at all 3 matches", heartRates)
+	}
+	for _, occurrence := range heartRates {
+		if occurrence.ContextMatches != 3 {
+			t.Errorf("context matches = %d, want 3", occurrence.ContextMatches)
+		}
+	}
+	if got := output.Entities["Medication"][0].ContextMatches; got != 0 {
+		t.Errorf("unique context flagged with %d matches", got)
+	}
+
+	ambiguous := output.Metadata.AmbiguousContexts
+	if len(ambiguous) != 1 || ambiguous[0].Entity != "HeartRate" || ambiguous[0].Context != "HR 80" || ambiguous[0].Matches != 3 {
+		t.Errorf("ambiguous contexts = %+v, want the HeartRate context once", ambiguous)
+	}
+
+	s.match.maxContextMatches = 3
+	output, err = s.ProcessText(context.Background(), []string{"note"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output.Metadata.AmbiguousContexts) != 0 {
+		t.Errorf("ambiguous contexts = %+v, want none within max_context_matches", output.Metadata.AmbiguousContexts)
+	}
+}
@@ -0,0 +1,151 @@
+package extractor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// isSchemaArchive reports whether a configured schema location is a bundle
+// rather than a directory, judged by its extension.
+func isSchemaArchive(schemaPath string) bool {
+	lower := strings.ToLower(schemaPath)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// isSchemaArchiveEntry reports whether an archive entry holds a schema.
+func isSchemaArchiveEntry(entryName string) bool {
+	lower := strings.ToLower(entryName)
+	return strings.HasSuffix(lower, ".yaml") || strings.HasSuffix(lower, ".yml") || strings.HasSuffix(lower, ".json")
+}
+
+// loadSchemasFromArchive loads every YAML/JSON entry of a .zip or .tar.gz
+// bundle in memory. Schemas are named after the entry's base name, like
+// files in a schema directory; nested folders inside the bundle are flattened.
+// With strict set, an unparseable entry fails the whole load.
+func loadSchemasFromArchive(archivePath string, strict bool, logger *zap.Logger) (map[string]Schema, []string, map[string]string, error) {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		logger.Error("Failed to read schema archive", zap.String("path", archivePath), zap.Error(err))
+		return nil, nil, nil, fmt.Errorf("failed to read schema archive %s: %w", archivePath, err)
+	}
+
+	var entries map[string][]byte
+	if strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		entries, err = readZipEntries(data)
+	} else {
+		entries, err = readTarGzEntries(data)
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read schema archive %s: %w", archivePath, err)
+	}
+	return loadSchemasFromEntries(archivePath, entries, strict, logger)
+}
+
+// loadSchemasFromEntries parses archive entries keyed by their path inside the archive.
+func loadSchemasFromEntries(archivePath string, entries map[string][]byte, strict bool, logger *zap.Logger) (map[string]Schema, []string, map[string]string, error) {
+	schemas := make(map[string]Schema)
+	schemaFiles := make(map[string]string)
+
+	// Sorted so duplicate names resolve the same way on every start
+	entryNames := make([]string, 0, len(entries))
+	for entryName := range entries {
+		entryNames = append(entryNames, entryName)
+	}
+	sort.Strings(entryNames)
+
+	for _, entryName := range entryNames {
+		entryPath := filepath.Join(archivePath, entryName)
+		schemaData, err := parseSchema(entries[entryName], entryPath)
+		if err != nil && strict {
+			logger.Error("Failed to load or parse schema file", zap.String("file", entryName), zap.String("path", entryPath), zap.Error(err))
+			return nil, nil, nil, err
+		}
+		if err != nil {
+			logger.Warn("Failed to load or parse schema file, skipping.",
+				zap.String("file", entryName), zap.String("path", entryPath), zap.Error(err))
+			continue
+		}
+
+		baseName := path.Base(entryName)
+		schemaName := strings.TrimSuffix(baseName, path.Ext(baseName))
+		if _, exists := schemas[schemaName]; exists {
+			logger.Warn("Duplicate schema name detected, overwriting previous definition.",
+				zap.String("schemaName", schemaName),
+				zap.String("previousFilePath", schemaFiles[schemaName]),
+				zap.String("newFilePath", entryPath))
+		}
+		schemas[schemaName] = schemaData
+		schemaFiles[schemaName] = entryPath
+	}
+
+	schemaNames := make([]string, 0, len(schemas))
+	for schemaName := range schemas {
+		schemaNames = append(schemaNames, schemaName)
+	}
+	sort.Strings(schemaNames)
+	return schemas, schemaNames, schemaFiles, nil
+}
+
+func readZipEntries(data []byte) (map[string][]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string][]byte)
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || !isSchemaArchiveEntry(file.Name) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		entries[file.Name] = content
+	}
+	return entries, nil
+}
+
+func readTarGzEntries(data []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	entries := make(map[string][]byte)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || !isSchemaArchiveEntry(header.Name) {
+			continue
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		entries[header.Name] = content
+	}
+	return entries, nil
+}
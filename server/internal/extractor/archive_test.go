@@ -0,0 +1,92 @@
+package extractor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+var archiveEntries = map[string]string{
+	"bundle/vitals.yaml":     "HeartRate:\n  type: number\n",
+	"bundle/labs/meds.json":  `{"Medication": {"type": "string"}}`,
+	"bundle/other/meds.yaml": "Dose:\n  type: string\n", // Same name as labs/meds.json; sorts later and wins
+	"bundle/README.md":       "not a schema",
+}
+
+func zipArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range archiveEntries {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for name, content := range archiveEntries {
+		w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		w.Write([]byte(content))
+	}
+	w.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestLoadSchemasFromArchive(t *testing.T) {
+	tests := map[string][]byte{
+		"schemas.zip":    zipArchive(t),
+		"schemas.tar.gz": tarGzArchive(t),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(archivePath, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			schemas, names, files, err := loadSchemasFromDirs([]string{archivePath}, false, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(names) != 2 || names[0] != "meds" || names[1] != "vitals" {
+				t.Fatalf("names = %v, want [meds vitals]", names)
+			}
+			if _, ok := schemas["meds"]["Dose"]; !ok {
+				t.Errorf("meds = %v, want the later duplicate", schemas["meds"])
+			}
+			if files["vitals"] != filepath.Join(archivePath, "bundle/vitals.yaml") {
+				t.Errorf("vitals file = %q", files["vitals"])
+			}
+		})
+	}
+}
+
+func TestReadZipEntriesInMemory(t *testing.T) {
+	entries, err := readZipEntries(zipArchive(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("got %d entries, want the 3 schema files", len(entries))
+	}
+	if _, ok := entries["bundle/README.md"]; ok {
+		t.Error("non-schema entry was read")
+	}
+}
@@ -0,0 +1,52 @@
+package extractor
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ArrayItemMarker follows the name of an array entity in the names of its
+// item properties, so "Medications[].name" (with the default delimiter) is
+// the name property of each item of Medications.
+const ArrayItemMarker = "[]"
+
+// itemProperties returns the properties of the items of an array-of-objects
+// definition.
+func itemProperties(def map[string]any) (map[string]any, bool) {
+	items, ok := asMap(def["items"])
+	if !ok {
+		return nil, false
+	}
+	return asMap(items["properties"])
+}
+
+// arrayItemEntities returns, sorted, an example item property name of every
+// array-of-objects entity in the schema, with nested names joined by
+// delimiter.
+func arrayItemEntities(schema Schema, delimiter string) []string {
+	entities := []string{}
+	walkEntityDefinitions(schema, delimiter, func(fullName string, def map[string]any) {
+		if props, ok := itemProperties(def); ok && len(props) > 0 {
+			names := make([]string, 0, len(props))
+			for name := range props {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			entities = append(entities, fullName+ArrayItemMarker+delimiter+names[0])
+		}
+	})
+	sort.Strings(entities)
+	return entities
+}
+
+// formatArrayItemInstructions tells the LLM how to name the item properties
+// of array-of-objects entities; entities are example keys from
+// arrayItemEntities. It is empty when the schema has none.
+func formatArrayItemInstructions(entities []string) string {
+	if len(entities) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+- For entities of type 'array' whose 'items' have properties, extract each item property as a separate key with "%s" after the entity name, e.g. %s. Add one occurrence per item to each of these keys.`,
+		ArrayItemMarker, quoteEntityNames(entities))
+}
@@ -0,0 +1,83 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const medicationListSchema = `
+Medications:
+  type: array
+  items:
+    type: object
+    properties:
+      name:
+        type: string
+      dose:
+        type: string
+`
+
+func TestArrayItemEntityNames(t *testing.T) {
+	s := newTestService(t, "http://127.0.0.1:1", map[string]string{"meds.yaml": medicationListSchema})
+	names := []string{}
+	walkEntityDefinitions(s.Schemas["meds"], ".", func(fullName string, def map[string]any) {
+		names = append(names, fullName)
+	})
+	for _, want := range []string{"Medications", "Medications[].name", "Medications[].dose"} {
+		if !strings.Contains(strings.Join(names, ","), want) {
+			t.Errorf("entity names = %v, want %q", names, want)
+		}
+	}
+	if got := arrayItemEntities(s.Schemas["meds"], "/"); len(got) != 1 || got[0] != "Medications[]/dose" {
+		t.Errorf("arrayItemEntities = %v, want the first item property with the delimiter", got)
+	}
+}
+
+func TestProcessTextArrayItemProperties(t *testing.T) {
+	llm := newStubLLM(t, completion(`{
+		"Medications[].name": [{"value": "aspirin", "context": "aspirin 81 mg"}, {"value": "metformin", "context": "metformin 500 mg"}],
+		"Medications[].dose": [{"value": "81 mg", "context": "aspirin 81 mg"}, {"value": "500 mg", "context": "metformin 500 mg"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"meds.yaml": medicationListSchema})
+	text := "Home meds: aspirin 81 mg, metformin 500 mg."
+
+	output, err := s.ProcessText(context.Background(), []string{"meds"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt, _ := llm.Requests()[0]["prompt"].(string)
+	if !strings.Contains(prompt, `"Medications[].dose"`) {
+		t.Errorf("prompt does not explain item property keys:\n%s", prompt)
+	}
+	for _, entity := range []string{"Medications[].name", "Medications[].dose"} {
+		occurrences := output.Entities[entity]
+		if len(occurrences) != 2 {
+			t.Errorf("%s = %+v, want 2 occurrences", entity, occurrences)
+			continue
+		}
+		for _, occurrence := range occurrences {
+			if occurrence.Schema != "meds" {
+				t.Errorf("%s attributed to schema %q, want meds", entity, occurrence.Schema)
+			}
+		}
+	}
+}
+
+func TestSchemaSourceOfArrayItems(t *testing.T) {
+	sources := map[string]string{"Medications": "meds", "Vital signs.Temp": "vitals"}
+	tests := []struct {
+		entity string
+		want   string
+	}{
+		{"Medications[].name", "meds"},
+		{"Medications", "meds"},
+		{"Vital signs.Temp", "vitals"},
+		{"Allergies[].substance", ""},
+	}
+	for _, tt := range tests {
+		if got := schemaSourceOf(tt.entity, sources, "."); got != tt.want {
+			t.Errorf("schemaSourceOf(%q) = %q, want %q", tt.entity, got, tt.want)
+		}
+	}
+}
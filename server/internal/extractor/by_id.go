@@ -0,0 +1,32 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDuplicateOccurrenceID is returned by OccurrencesByID when two
+// occurrences of the output share an ID.
+var ErrDuplicateOccurrenceID = errors.New("duplicate occurrence ID")
+
+// IdentifiedOccurrence is an occurrence carrying the name of its entity, for
+// outputs keyed by occurrence ID.
+type IdentifiedOccurrence struct {
+	Entity string `json:"entity"`
+	EntityOccurrence
+}
+
+// OccurrencesByID keys every occurrence of the output by its ID, so clients
+// editing single occurrences can look them up directly.
+func OccurrencesByID(output *ExtractionOutput) (map[string]IdentifiedOccurrence, error) {
+	byID := make(map[string]IdentifiedOccurrence)
+	for entityName, occurrences := range output.Entities {
+		for _, occurrence := range occurrences {
+			if existing, exists := byID[occurrence.ID]; exists {
+				return nil, fmt.Errorf("%w %q in %q and %q", ErrDuplicateOccurrenceID, occurrence.ID, existing.Entity, entityName)
+			}
+			byID[occurrence.ID] = IdentifiedOccurrence{Entity: entityName, EntityOccurrence: occurrence}
+		}
+	}
+	return byID, nil
+}
@@ -0,0 +1,42 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOccurrencesByID(t *testing.T) {
+	llm := newStubLLM(t, completion(`{
+		"HeartRate": [{"value": "80", "context": "HR 80"}, {"value": "92", "context": "HR 92"}],
+		"Medication": [{"value": "aspirin", "context": "on aspirin"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"note.yaml": "HeartRate:\n  type: number\nMedication:\n  type: string\n"})
+	output, err := s.ProcessText(context.Background(), []string{"note"}, "HR 80 on aspirin, later HR 92.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byID, err := OccurrencesByID(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for entityName, occurrences := range output.Entities {
+		for _, occurrence := range occurrences {
+			total++
+			got, ok := byID[occurrence.ID]
+			if !ok || got.Entity != entityName || got.Value != occurrence.Value || got.Position != occurrence.Position {
+				t.Errorf("byID[%q] = %+v, want the %s occurrence %+v", occurrence.ID, got, entityName, occurrence)
+			}
+		}
+	}
+	if total != 3 || len(byID) != total {
+		t.Errorf("byID has %d entries for %d occurrences, want 3 each", len(byID), total)
+	}
+
+	output.Entities["Medication"][0].ID = output.Entities["HeartRate"][0].ID
+	if _, err := OccurrencesByID(output); !errors.Is(err, ErrDuplicateOccurrenceID) {
+		t.Errorf("err = %v, want ErrDuplicateOccurrenceID", err)
+	}
+}
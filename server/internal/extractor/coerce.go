@@ -0,0 +1,110 @@
+package extractor
+
+import (
+	"strings"
+)
+
+// booleanVocabulary maps lower-cased phrases (configured under matching) to the boolean they stand for.
+type booleanVocabulary map[string]bool
+
+// newBooleanVocabulary builds a vocabulary from affirmative and negative phrases.
+func newBooleanVocabulary(trueValues, falseValues []string) booleanVocabulary {
+	vocabulary := make(booleanVocabulary, len(trueValues)+len(falseValues))
+	for _, phrase := range trueValues {
+		vocabulary[normalizeBooleanPhrase(phrase)] = true
+	}
+	for _, phrase := range falseValues {
+		vocabulary[normalizeBooleanPhrase(phrase)] = false
+	}
+	return vocabulary
+}
+
+func normalizeBooleanPhrase(phrase string) string {
+	return strings.Join(strings.Fields(strings.ToLower(phrase)), " ")
+}
+
+// booleanEntities returns the vocabulary for every `type: boolean` entity of
+// the schema, keyed by entity name with nested names joined by delimiter. An entity may replace the default
+// phrases with its own `true_values` and/or `false_values` lists.
+func booleanEntities(schema Schema, delimiter string, defaults booleanVocabulary) map[string]booleanVocabulary {
+	entities := make(map[string]booleanVocabulary)
+
+	var recurse func(defs map[string]any, prefix string)
+	recurse = func(defs map[string]any, prefix string) {
+		for name, value := range defs {
+			def, ok := asMap(value)
+			if !ok {
+				continue
+			}
+			fullName := name
+			if prefix != "" {
+				fullName = prefix + delimiter + name
+			}
+			if def["type"] == "boolean" {
+				entities[fullName] = entityBooleanVocabulary(def, defaults)
+			}
+			if props, ok := asMap(def["properties"]); ok {
+				recurse(props, fullName)
+			}
+			if columns, ok := asMap(def["columns"]); ok && def["type"] == tableEntityType {
+				recurse(columns, fullName)
+			}
+		}
+	}
+	recurse(schema, "")
+	return entities
+}
+
+// entityBooleanVocabulary applies the definition's overrides, if any, to the defaults.
+func entityBooleanVocabulary(def map[string]any, defaults booleanVocabulary) booleanVocabulary {
+	trueValues, hasTrue := stringList(def["true_values"])
+	falseValues, hasFalse := stringList(def["false_values"])
+	if !hasTrue && !hasFalse {
+		return defaults
+	}
+	for phrase, b := range defaults {
+		if b && !hasTrue {
+			trueValues = append(trueValues, phrase)
+		} else if !b && !hasFalse {
+			falseValues = append(falseValues, phrase)
+		}
+	}
+	return newBooleanVocabulary(trueValues, falseValues)
+}
+
+func stringList(raw any) ([]string, bool) {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, false
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values, true
+}
+
+// coerceBooleans replaces string values of boolean entities with the boolean
+// they express. The original string is kept as the search value so the text
+// the LLM quoted is still highlighted.
+func coerceBooleans(rawExtraction RawLLMExtraction, entities map[string]booleanVocabulary) int {
+	coerced := 0
+	for entityName, vocabulary := range entities {
+		for i, occurrence := range rawExtraction[entityName] {
+			str, ok := occurrence.Value.(string)
+			if !ok {
+				continue
+			}
+			b, known := vocabulary[normalizeBooleanPhrase(str)]
+			if !known {
+				continue
+			}
+			rawExtraction[entityName][i].Value = b
+			rawExtraction[entityName][i].SearchValue = str
+			coerced++
+		}
+	}
+	return coerced
+}
@@ -0,0 +1,67 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+const booleanSchema = `
+Fever:
+  type: boolean
+Chest pain:
+  type: boolean
+Smoker:
+  type: boolean
+  true_values: ["current smoker"]
+`
+
+func TestProcessTextCoercesBooleans(t *testing.T) {
+	text := "Blood culture positive. Patient denies chest pain. Current smoker."
+	llm := newStubLLM(t, completion(`{
+		"Fever": [{"value": "positive", "context": "Blood culture positive"}],
+		"Chest pain": [{"value": "denies", "context": "Patient denies chest pain"}],
+		"Smoker": [{"value": "current smoker", "context": "Current smoker"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": booleanSchema})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runes := []rune(text)
+	tests := []struct {
+		entity string
+		value  bool
+		span   string
+	}{
+		{"Fever", true, "positive"},
+		{"Chest pain", false, "denies"},
+		{"Smoker", true, "Current smoker"},
+	}
+	for _, tt := range tests {
+		occs := output.Entities[tt.entity]
+		if len(occs) != 1 {
+			t.Fatalf("%s: got %d occurrences, want 1", tt.entity, len(occs))
+		}
+		if occs[0].Value != tt.value {
+			t.Errorf("%s: value = %v, want %v", tt.entity, occs[0].Value, tt.value)
+		}
+		if got := string(runes[occs[0].Position.Start:occs[0].Position.End]); got != tt.span {
+			t.Errorf("%s: highlighted %q, want %q", tt.entity, got, tt.span)
+		}
+	}
+}
+
+func TestEntityBooleanVocabularyOverrides(t *testing.T) {
+	defaults := newBooleanVocabulary([]string{"yes"}, []string{"no"})
+	vocabulary := entityBooleanVocabulary(map[string]any{"true_values": []any{"current smoker"}}, defaults)
+	if b, ok := vocabulary["current smoker"]; !ok || !b {
+		t.Error("override phrase not mapped to true")
+	}
+	if _, ok := vocabulary["yes"]; ok {
+		t.Error("default true phrases kept despite the override")
+	}
+	if b, ok := vocabulary["no"]; !ok || b {
+		t.Error("default false phrases dropped without a false override")
+	}
+}
@@ -0,0 +1,30 @@
+package extractor
+
+// Confidence of an occurrence, by how it was located in the text
+const (
+	contextMatchConfidence  = 1.0 // Value found inside a match of its context
+	fallbackMatchConfidence = 0.5 // Value found by searching the whole text
+)
+
+// filterByConfidence drops occurrences whose confidence is below
+// minConfidence, along with entities left without occurrences, and returns
+// how many occurrences were dropped.
+func filterByConfidence(output *ExtractionOutput, minConfidence float64) int {
+	dropped := 0
+	for entityName, occurrences := range output.Entities {
+		kept := occurrences[:0]
+		for _, occurrence := range occurrences {
+			if occurrence.Confidence < minConfidence {
+				dropped++
+				continue
+			}
+			kept = append(kept, occurrence)
+		}
+		if len(kept) == 0 {
+			delete(output.Entities, entityName)
+			continue
+		}
+		output.Entities[entityName] = kept
+	}
+	return dropped
+}
@@ -0,0 +1,53 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProcessTextMinConfidence(t *testing.T) {
+	text := "Patient on aspirin daily. Takes metoprolol at night. Allergic to penicillin."
+	// Only the aspirin context is in the text; the others are found by fallback
+	llm := newStubLLM(t, completion(`{
+		"Medication": [
+			{"value": "aspirin", "context": "Patient on aspirin daily"},
+			{"value": "metoprolol", "context": "metoprolol 25 mg twice daily"}
+		],
+		"Allergy": [{"value": "penicillin", "context": "penicillin allergy noted"}]
+	}`))
+	schemas := map[string]string{"demo.yaml": "Medication:\n  type: string\nAllergy:\n  type: string\n"}
+
+	tests := map[string]struct {
+		minConfidence float64
+		wantMeds      int
+		wantAllergy   bool
+		wantDropped   int
+	}{
+		"no threshold":           {0, 2, true, 0},
+		"at fallback confidence": {fallbackMatchConfidence, 2, true, 0},
+		"above fallback":         {0.8, 1, false, 2},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestService(t, llm.URL, schemas)
+			output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{MinConfidence: tt.minConfidence})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(output.Entities["Medication"]); got != tt.wantMeds {
+				t.Errorf("got %d medications, want %d", got, tt.wantMeds)
+			}
+			if _, ok := output.Entities["Allergy"]; ok != tt.wantAllergy {
+				t.Errorf("allergy present = %v, want %v", ok, tt.wantAllergy)
+			}
+			if output.Metadata.LowConfidenceDropped != tt.wantDropped {
+				t.Errorf("dropped = %d, want %d", output.Metadata.LowConfidenceDropped, tt.wantDropped)
+			}
+			for _, occurrence := range output.Entities["Medication"] {
+				if occurrence.Confidence < tt.minConfidence {
+					t.Errorf("kept %v with confidence %v", occurrence.Value, occurrence.Confidence)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package extractor
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// conllToken is a token of the source text with its rune span.
+type conllToken struct {
+	Text  string
+	Start int // Rune index, inclusive
+	End   int // Rune index, exclusive
+	Line  int
+}
+
+// tokenizeForCoNLL splits text into runs of letters/digits and single
+// punctuation runes, recording rune offsets and the line of each token.
+func tokenizeForCoNLL(text string) []conllToken {
+	tokens := []conllToken{}
+	runes := []rune(text)
+	line := 0
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, conllToken{Text: string(runes[start:i]), Start: start, End: i, Line: line})
+		default:
+			tokens = append(tokens, conllToken{Text: string(r), Start: i, End: i + 1, Line: line})
+			i++
+		}
+	}
+	return tokens
+}
+
+// FormatCoNLL renders the entities as BIO-tagged tokens, one "token\ttag" line
+// per token and a blank line between lines of the source text. The tag type is
+// the entity name with spaces replaced by underscores.
+//
+// Overlapping entities are resolved outermost-wins: longer spans are assigned
+// first and a token already tagged keeps its tag. Spans that only partially
+// cover a token still tag it.
+func FormatCoNLL(output *ExtractionOutput) string {
+	type span struct {
+		entity     string
+		start, end int
+	}
+	spans := []span{}
+	for entityName, occurrences := range output.Entities {
+		for _, occurrence := range occurrences {
+			if occurrence.Position.End > occurrence.Position.Start {
+				spans = append(spans, span{entityName, occurrence.Position.Start, occurrence.Position.End})
+			}
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		li, lj := spans[i].end-spans[i].start, spans[j].end-spans[j].start
+		if li != lj {
+			return li > lj
+		}
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].entity < spans[j].entity
+	})
+
+	tokens := tokenizeForCoNLL(output.Text)
+	tags := make([]string, len(tokens))
+	for _, sp := range spans {
+		first := sort.Search(len(tokens), func(i int) bool { return tokens[i].End > sp.start })
+		last := first
+		for last < len(tokens) && tokens[last].Start < sp.end {
+			last++
+		}
+		if first == last {
+			continue
+		}
+		free := true
+		for i := first; i < last; i++ {
+			if tags[i] != "" {
+				free = false
+				break
+			}
+		}
+		if !free {
+			continue // Overlaps an outer entity
+		}
+		tagType := strings.ReplaceAll(sp.entity, " ", "_")
+		for i := first; i < last; i++ {
+			if i == first {
+				tags[i] = "B-" + tagType
+			} else {
+				tags[i] = "I-" + tagType
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for i, token := range tokens {
+		if i > 0 && token.Line != tokens[i-1].Line {
+			sb.WriteByte('\n')
+		}
+		tag := tags[i]
+		if tag == "" {
+			tag = "O"
+		}
+		sb.WriteString(token.Text)
+		sb.WriteByte('\t')
+		sb.WriteString(tag)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
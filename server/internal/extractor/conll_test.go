@@ -0,0 +1,21 @@
+package extractor
+
+import "testing"
+
+func TestFormatCoNLLTagsMultiTokenEntity(t *testing.T) {
+	text := "Started on low dose aspirin.\nBP 120/80"
+	output := &ExtractionOutput{
+		Text: text,
+		Entities: map[string][]EntityOccurrence{
+			"Medication":     {{Position: Position{Start: 11, End: 27}}}, // "low dose aspirin"
+			"Drug name":      {{Position: Position{Start: 20, End: 27}}}, // "aspirin", inside the medication
+			"Blood pressure": {{Position: Position{Start: 32, End: 38}}}, // "120/80"
+		},
+	}
+	want := "Started\tO\non\tO\nlow\tB-Medication\ndose\tI-Medication\naspirin\tI-Medication\n.\tO\n" +
+		"\n" +
+		"BP\tO\n120\tB-Blood_pressure\n/\tI-Blood_pressure\n80\tI-Blood_pressure\n"
+	if got := FormatCoNLL(output); got != want {
+		t.Errorf("FormatCoNLL() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestExtractResponseContentWrapperShapes(t *testing.T) {
+	paths := []string{"content", "response", "choices.0.message.content", "choices.0.text"}
+	extraction := `{"Age": [{"value": "62", "context": "62 year old"}]}`
+	tests := map[string]struct {
+		body, wantPath string
+	}{
+		"llama.cpp":     {`{"content": ` + quote(extraction) + `}`, "content"},
+		"ollama":        {`{"response": ` + quote(extraction) + `, "content": ""}`, "response"},
+		"chat":          {`{"choices": [{"message": {"content": ` + quote(extraction) + `}}]}`, "choices.0.message.content"},
+		"completions":   {`{"choices": [{"text": ` + quote(extraction) + `}]}`, "choices.0.text"},
+		"unwrapped obj": {`{"content": {"Age": [{"value": "62", "context": "62 year old"}]}}`, "content"},
+	}
+	for name, tt := range tests {
+		content, path, found := extractResponseContent([]byte(tt.body), paths)
+		if !found || path != tt.wantPath {
+			t.Errorf("%s: path = %q (found %v), want %q", name, path, found, tt.wantPath)
+			continue
+		}
+		if content != extraction && name != "unwrapped obj" {
+			t.Errorf("%s: content = %q", name, content)
+		}
+	}
+
+	if _, _, found := extractResponseContent([]byte(`{"choices": []}`), paths); found {
+		t.Error("found content in a response without any")
+	}
+}
+
+func TestProcessTextReadsConfiguredContentPath(t *testing.T) {
+	llm := newStubLLM(t, `{"choices": [{"text": "{\"Age\": [{\"value\": \"62\", \"context\": \"62 year old\"}]}"}]}`)
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Age:\n  type: number\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient is a 62 year old man.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output.Entities["Age"]) != 1 {
+		t.Errorf("Age = %v, want one occurrence", output.Entities["Age"])
+	}
+}
+
+// quote encodes s as a JSON string.
+func quote(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
@@ -0,0 +1,46 @@
+package extractor
+
+import (
+	"strings"
+)
+
+// contextHighlightEntities returns the entities whose definition sets
+// `highlight_context: true`, keyed by entity name with nested names joined by
+// delimiter. For these, an occurrence without a value highlights its context.
+func contextHighlightEntities(schema Schema, delimiter string) map[string]bool {
+	entities := make(map[string]bool)
+	walkEntityDefinitions(schema, delimiter, func(fullName string, def map[string]any) {
+		if highlight, _ := def["highlight_context"].(bool); highlight {
+			entities[fullName] = true
+		}
+	})
+	return entities
+}
+
+// markContextHighlights flags empty-valued occurrences of the given entities
+// (or of every entity when all is set) so their context is highlighted
+// instead of the occurrence being dropped.
+func markContextHighlights(rawExtraction RawLLMExtraction, entities map[string]bool, all bool) int {
+	marked := 0
+	for entityName, occurrences := range rawExtraction {
+		if !all && !entities[entityName] {
+			continue
+		}
+		for i, occurrence := range occurrences {
+			if occurrence.Context == "" || !isEmptyValue(occurrence.Value) {
+				continue
+			}
+			rawExtraction[entityName][i].HighlightContext = true
+			marked++
+		}
+	}
+	return marked
+}
+
+func isEmptyValue(value any) bool {
+	if value == nil {
+		return true
+	}
+	str, ok := value.(string)
+	return ok && strings.TrimSpace(str) == ""
+}
@@ -0,0 +1,53 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+const findingSchema = `
+Finding:
+  type: string
+  highlight_context: true
+Medication:
+  type: string
+`
+
+func TestProcessTextHighlightsContextForEmptyValues(t *testing.T) {
+	text := "Mild cardiomegaly without effusion. No medications."
+	llm := newStubLLM(t, completion(`{
+		"Finding": [{"value": "", "context": "Mild cardiomegaly without effusion"}],
+		"Medication": [{"value": null, "context": "No medications"}]
+	}`))
+
+	tests := map[string]struct {
+		opts           ProcessOptions
+		wantMedication int
+	}{
+		"schema flag only": {ProcessOptions{}, 0},
+		"request flag":     {ProcessOptions{HighlightContextWhenEmpty: true}, 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestService(t, llm.URL, map[string]string{"demo.yaml": findingSchema})
+			output, err := s.ProcessText(context.Background(), []string{"demo"}, text, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			findings := output.Entities["Finding"]
+			if len(findings) != 1 {
+				t.Fatalf("got %d findings, want 1", len(findings))
+			}
+			f := findings[0]
+			if f.Position != f.Context.Position || f.Position != (Position{Start: 0, End: 34}) {
+				t.Errorf("finding highlights %v (context %v), want the whole context span", f.Position, f.Context.Position)
+			}
+			if f.Value != "Mild cardiomegaly without effusion" {
+				t.Errorf("finding value = %v, want the context", f.Value)
+			}
+			if got := len(output.Entities["Medication"]); got != tt.wantMedication {
+				t.Errorf("got %d medications, want %d", got, tt.wantMedication)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package extractor
+
+import (
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestMinContextSimilarityPrefersFallback(t *testing.T) {
+	// The LLM's garbled context "ine 12" only matches inside "Creatinine 120"
+	text := "Creatinine 120 noted. Vitamin D level 12 ng/mL."
+	raw := RawLLMExtraction{"Vitamin D": {{Value: "12", Context: "ine 12"}}}
+	correctStart := len([]rune("Creatinine 120 noted. Vitamin D level "))
+
+	for _, threshold := range []float64{0, 0.5} {
+		s := newTestService(t, "http://127.0.0.1:1", nil)
+		s.match.shortValueLength = 0
+		s.match.minContextSimilarity = threshold
+
+		output, err := s.findEntityPositions(text, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		foundCorrect := false
+		for _, occ := range output.Entities["Vitamin D"] {
+			foundCorrect = foundCorrect || occ.Position.Start == correctStart
+		}
+		if threshold == 0 && foundCorrect {
+			t.Error("without a threshold the mid-word context match should be used")
+		}
+		if threshold > 0 && !foundCorrect {
+			t.Errorf("threshold %v: weak context match not rejected in favor of the fallback", threshold)
+		}
+	}
+}
+
+func TestMinContextSimilarityKeepsExactMatchInLongLine(t *testing.T) {
+	text := "Assessment: stable overnight, afebrile, tolerating diet, ambulating, BP 118/76 this morning, plan discharge tomorrow."
+	raw := RawLLMExtraction{"BP": {{Value: "118/76", Context: "BP 118/76"}}}
+	s := newTestService(t, "http://127.0.0.1:1", nil)
+	s.match.minContextSimilarity = 0.8
+
+	output, err := s.findEntityPositions(text, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	occs := output.Entities["BP"]
+	if len(occs) != 1 || occs[0].Context.Text != "BP 118/76" {
+		t.Fatalf("got %+v, want the exact context match", occs)
+	}
+	if occs[0].Context.Position.End-occs[0].Context.Position.Start != len("BP 118/76") {
+		t.Error("exact context match was replaced by the approximate fallback")
+	}
+}
+
+func TestContextMatchSimilarity(t *testing.T) {
+	text := "Creatinine 120 noted."
+	if got := contextMatchSimilarity(text, 7, 13, "ine 12"); got != 0 {
+		t.Errorf("mid-word match scored %v, want 0", got)
+	}
+	if got := contextMatchSimilarity(text, 0, 14, "creatinine 120"); got != 1 {
+		t.Errorf("exact match scored %v, want 1", got)
+	}
+}
+
+func TestNewExtractorServiceWiresMinContextSimilarity(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	cfg.LLM.SchemaDirs = []string{t.TempDir()}
+	cfg.Matching.MinContextSimilarity = 0.6
+
+	s, err := NewExtractorService(cfg, zap.NewNop(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.match.minContextSimilarity != 0.6 {
+		t.Errorf("minContextSimilarity = %v, want the configured 0.6", s.match.minContextSimilarity)
+	}
+}
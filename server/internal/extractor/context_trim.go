@@ -0,0 +1,37 @@
+package extractor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// trimContext cuts an LLM context longer than maxLength runes down to a
+// window of that length centered on the value, so a whole returned paragraph
+// does not become a slow, loosely anchored search pattern. The window always
+// holds the whole value. A context the value is not literally in (it may only
+// match after search normalization) is kept whole rather than cut blindly.
+// A maxLength of 0 disables trimming.
+func trimContext(context, value string, maxLength int) string {
+	contextLength := utf8.RuneCountInString(context)
+	if maxLength <= 0 || contextLength <= maxLength {
+		return context
+	}
+	runes := []rune(context)
+
+	// Mapping rune by rune keeps rune offsets of the lowered copy valid
+	lowerContext := strings.Map(unicode.ToLower, context)
+	i := strings.Index(lowerContext, strings.Map(unicode.ToLower, value))
+	if i < 0 {
+		return context
+	}
+	valueStart := utf8.RuneCountInString(lowerContext[:i])
+	valueLength := utf8.RuneCountInString(value)
+	if valueLength >= maxLength {
+		return string(runes[valueStart : valueStart+valueLength])
+	}
+
+	start := max(0, valueStart-(maxLength-valueLength)/2)
+	start = min(start, contextLength-maxLength)
+	return string(runes[start : start+maxLength])
+}
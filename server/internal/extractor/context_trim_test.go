@@ -0,0 +1,84 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTrimContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		context   string
+		value     string
+		maxLength int
+		want      string
+	}{
+		{"short enough", "HR 80 at rest", "80", 20, "HR 80 at rest"},
+		{"disabled", "HR 80 at rest", "80", 0, "HR 80 at rest"},
+		{"centered on value", "aaaa HR 80 at bbbb", "80", 8, "HR 80 at"},
+		{"value at start", "80 bpm and more words", "80", 6, "80 bpm"},
+		{"value at end", "many words then 80", "80", 6, "hen 80"},
+		{"case-insensitive", "ÉÉÉÉ Aspirin ÉÉÉÉ", "aspirin", 9, " Aspirin "},
+		{"value not found", "some long context", "80", 4, "some long context"},
+		{"value longer than max", "on aspirin daily", "aspirin", 4, "aspirin"},
+	}
+	for _, tt := range tests {
+		if got := trimContext(tt.context, tt.value, tt.maxLength); got != tt.want {
+			t.Errorf("%s: trimContext = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessTextTrimsOversizedContext(t *testing.T) {
+	paraphrase := strings.Repeat("the patient was reviewed on the ward ", 5)
+	llm := newStubLLM(t, completion(`{"HeartRate": [{"value": "80", "context": "`+paraphrase+`resting HR 80 on monitor`+paraphrase+`"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"vitals.yaml": "HeartRate:\n  type: number\n"})
+	text := "Seen today. Temp 37.1, resting HR 80 on monitor, BP 120/80."
+
+	s.match.maxContextLength = 0
+	output, err := s.ProcessText(context.Background(), []string{"vitals"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if occurrences := output.Entities["HeartRate"]; len(occurrences) == 1 && occurrences[0].Confidence == 1 {
+		t.Fatalf("untrimmed context matched (%+v), the test needs one that does not", occurrences)
+	}
+
+	s.match.maxContextLength = 20
+	output, err = s.ProcessText(context.Background(), []string{"vitals"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	occurrences := output.Entities["HeartRate"]
+	if len(occurrences) != 1 {
+		t.Fatalf("HeartRate = %+v, want the value found through the trimmed context", occurrences)
+	}
+	if start := strings.Index(text, "HR 80") + len("HR "); occurrences[0].Position.Start != start {
+		t.Errorf("position = %+v, want the 80 of the heart rate at %d, not of the blood pressure", occurrences[0].Position, start)
+	}
+	if occurrences[0].Confidence != 1 {
+		t.Errorf("confidence = %v, want 1 for a context match", occurrences[0].Confidence)
+	}
+}
+
+func TestTrimContextKeepsValueOnlyFoundAfterNormalizing(t *testing.T) {
+	text := "Pt says “I don’t smoke” – quit 2019."
+	// The LLM straightened the value's apostrophe but quoted the context verbatim
+	llm := newStubLLM(t, completion(`{"Smoking": [{"value": "don't smoke", "context": "Pt says “I don’t smoke” – quit 2019."}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"social.yaml": "Smoking:\n  type: string\n"})
+	s.match.normalizePunctuation = true
+	s.match.maxContextLength = 20
+
+	output, err := s.ProcessText(context.Background(), []string{"social"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	occurrences := output.Entities["Smoking"]
+	if len(occurrences) != 1 || occurrences[0].Confidence != 1 {
+		t.Fatalf("Smoking = %+v, want a context match", occurrences)
+	}
+	if got := string([]rune(text)[occurrences[0].Position.Start:occurrences[0].Position.End]); got != "don’t smoke" {
+		t.Errorf("value span = %q, want don’t smoke", got)
+	}
+}
@@ -0,0 +1,84 @@
+package extractor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout used for reference dates in requests, prompts and resolved values
+const referenceDateLayout = "2006-01-02"
+
+// Matches relative expressions such as "3 days ago" or "in 2 weeks"
+var relativeDatePattern = regexp.MustCompile(`^(?:(in)\s+)?(\d+)\s+(day|week|month|year)s?(?:\s+(ago|prior|earlier|later))?$`)
+
+// formatReferenceDateInstructions renders the prompt lines that anchor
+// relative dates. It returns an empty string when no date is set.
+func formatReferenceDateInstructions(referenceDate *time.Time) string {
+	if referenceDate == nil {
+		return ""
+	}
+	return fmt.Sprintf("\nReference date: the document was written on %s. Use it to interpret relative dates in the text (e.g. \"3 days ago\", \"yesterday\").\n",
+		referenceDate.Format(referenceDateLayout))
+}
+
+// resolveRelativeDate converts a relative date expression into an absolute
+// date based on the reference date. It reports false for anything else.
+func resolveRelativeDate(value string, referenceDate time.Time) (time.Time, bool) {
+	expression := strings.ToLower(strings.TrimSpace(value))
+	switch expression {
+	case "today":
+		return referenceDate, true
+	case "yesterday":
+		return referenceDate.AddDate(0, 0, -1), true
+	case "tomorrow":
+		return referenceDate.AddDate(0, 0, 1), true
+	}
+
+	match := relativeDatePattern.FindStringSubmatch(expression)
+	if match == nil {
+		return time.Time{}, false
+	}
+	direction := match[4]
+	past := direction == "ago" || direction == "prior" || direction == "earlier"
+	future := match[1] == "in" || direction == "later"
+	if past == future {
+		return time.Time{}, false // Contradictory ("in 3 days ago") or no direction ("3 days")
+	}
+	amount, err := strconv.Atoi(match[2])
+	if err != nil {
+		return time.Time{}, false
+	}
+	if past {
+		amount = -amount
+	}
+
+	switch match[3] {
+	case "day":
+		return referenceDate.AddDate(0, 0, amount), true
+	case "week":
+		return referenceDate.AddDate(0, 0, 7*amount), true
+	case "month":
+		return referenceDate.AddDate(0, amount, 0), true
+	default:
+		return referenceDate.AddDate(amount, 0, 0), true
+	}
+}
+
+// resolveRelativeDates sets ResolvedDate on every occurrence whose value is a
+// relative date expression.
+func resolveRelativeDates(output *ExtractionOutput, referenceDate time.Time) {
+	for entityName, occurrences := range output.Entities {
+		for i, occurrence := range occurrences {
+			value, ok := occurrence.Value.(string)
+			if !ok {
+				continue
+			}
+			if resolved, ok := resolveRelativeDate(value, referenceDate); ok {
+				output.Entities[entityName][i].ResolvedDate = resolved.Format(referenceDateLayout)
+			}
+		}
+	}
+}
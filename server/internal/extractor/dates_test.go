@@ -0,0 +1,68 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatExtractionPromptIncludesReferenceDate(t *testing.T) {
+	s := newTestService(t, "http://127.0.0.1:1", nil)
+	schema := Schema{"Admission date": map[string]any{"type": "string"}}
+	referenceDate := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	prompt, err := s.formatExtractionPrompt(schema, "Admitted 3 days ago.", ProcessOptions{ReferenceDate: &referenceDate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "Reference date: the document was written on 2024-03-15.") {
+		t.Error("reference date missing from prompt")
+	}
+
+	prompt, err = s.formatExtractionPrompt(schema, "Admitted 3 days ago.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(prompt, "Reference date:") {
+		t.Error("reference date rendered without being set")
+	}
+}
+
+func TestProcessTextInjectsServerDate(t *testing.T) {
+	llm := newStubLLM(t, completion(`{}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Admission date:\n  type: string\n"})
+	s.injectReferenceDate = true
+
+	if _, err := s.ProcessText(context.Background(), []string{"demo"}, "Admitted yesterday.", ProcessOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	prompt, _ := llm.Requests()[0]["prompt"].(string)
+	if !strings.Contains(prompt, "written on "+time.Now().Format(referenceDateLayout)) {
+		t.Error("server date missing from prompt")
+	}
+}
+
+func TestResolveRelativeDate(t *testing.T) {
+	reference := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		"yesterday":     "2024-03-14",
+		"3 days ago":    "2024-03-12",
+		"2 weeks prior": "2024-03-01",
+		"in 1 month":    "2024-04-15",
+		"1 year later":  "2025-03-15",
+		"3 days":        "",
+		"in 3 days ago": "",
+		"March 3rd":     "",
+	}
+	for value, want := range tests {
+		resolved, ok := resolveRelativeDate(value, reference)
+		got := ""
+		if ok {
+			got = resolved.Format(referenceDateLayout)
+		}
+		if got != want {
+			t.Errorf("resolveRelativeDate(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
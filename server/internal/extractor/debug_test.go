@@ -0,0 +1,50 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const debugSchema = `
+_name: Medications
+Medication:
+  type: string
+  examples:
+    - aspirin
+`
+
+func TestProcessTextDebugReturnsPromptSchema(t *testing.T) {
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"meds.yaml": debugSchema})
+
+	output, err := s.ProcessText(context.Background(), []string{"meds"}, "Patient on aspirin.", ProcessOptions{Debug: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Debug == nil || len(output.Debug.PromptSchemas) != 1 {
+		t.Fatalf("debug = %+v, want one prompt schema", output.Debug)
+	}
+	schemaJSON := string(output.Debug.PromptSchemas[0])
+	prompt, _ := llm.Requests()[0]["prompt"].(string)
+	if !strings.Contains(prompt, schemaJSON) {
+		t.Errorf("prompt does not contain the returned schema:\n%s", schemaJSON)
+	}
+	if strings.Contains(schemaJSON, "_name") || strings.Contains(schemaJSON, "examples") {
+		t.Errorf("returned schema keeps what the prompt strips:\n%s", schemaJSON)
+	}
+	if len(output.Debug.Prompts) != 1 || output.Debug.Prompts[0] != promptUserContent(prompt) {
+		t.Errorf("debug prompts = %q, want the sent prompt's user turn", output.Debug.Prompts)
+	}
+	if !strings.HasPrefix(prompt, "<|im_start|>system") {
+		t.Errorf("the LLM was sent an unwrapped prompt:\n%s", prompt)
+	}
+
+	output, err = s.ProcessText(context.Background(), []string{"meds"}, "Patient on aspirin.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Debug != nil {
+		t.Error("debug output returned without the debug option")
+	}
+}
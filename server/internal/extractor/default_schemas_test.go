@@ -0,0 +1,56 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestNewExtractorServiceValidatesDefaultSchemas(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "vitals.yaml"), []byte("HeartRate:\n  type: number\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for defaults, wantErr := range map[string]bool{"vitals": false, "missing": true} {
+		cfg := config.NewDefaultConfig()
+		cfg.LLM.SchemaDirs = []string{dir}
+		cfg.LLM.DefaultSchemas = []string{defaults}
+
+		s, err := NewExtractorService(cfg, zap.NewNop(), dir)
+		if (err != nil) != wantErr {
+			t.Errorf("defaults %q: err = %v, want error %v", defaults, err, wantErr)
+		}
+		if err == nil && s.DefaultSchemas()[0] != "vitals" {
+			t.Errorf("DefaultSchemas() = %v", s.DefaultSchemas())
+		}
+	}
+}
+
+func TestNewExtractorServiceCapsDefaultSchemas(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"vitals", "meds", "labs"} {
+		if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(name+"Value:\n  type: string\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		maxRequestSchemas int
+		wantErr           bool
+	}{
+		{0, false},
+		{3, false},
+		{2, true},
+	} {
+		cfg := config.NewDefaultConfig()
+		cfg.LLM.SchemaDirs = []string{dir}
+		cfg.LLM.DefaultSchemas = []string{"vitals", "meds", "labs"}
+		cfg.LLM.MaxRequestSchemas = tt.maxRequestSchemas
+
+		if _, err := NewExtractorService(cfg, zap.NewNop(), dir); (err != nil) != tt.wantErr {
+			t.Errorf("max_request_schemas %d: err = %v, want error %v", tt.maxRequestSchemas, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+const periodNamedSchema = `
+Labs:
+  type: object
+  properties:
+    Vit. D:
+      type: number
+`
+
+func TestNestingDelimiterWithPeriodInEntityName(t *testing.T) {
+	text := "Vit. D 32 ng/mL"
+	for _, delimiter := range []string{".", "/", ">"} {
+		t.Run(delimiter, func(t *testing.T) {
+			entity := "Labs" + delimiter + "Vit. D"
+			llm := newStubLLM(t, completion(fmt.Sprintf(`{%q: [{"value": "32", "context": "Vit. D 32"}]}`, entity)))
+			s := newTestService(t, llm.URL, map[string]string{"labs.yaml": periodNamedSchema})
+			s.nestingDelimiter = delimiter
+
+			output, err := s.ProcessText(context.Background(), []string{"labs"}, text, ProcessOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			occs := output.Entities[entity]
+			if len(occs) != 1 || occs[0].Schema != "labs" {
+				t.Fatalf("%q = %+v, want one occurrence attributed to labs", entity, occs)
+			}
+
+			prompt, _ := llm.Requests()[0]["prompt"].(string)
+			if !strings.Contains(prompt, "'"+delimiter+"' notation") || !strings.Contains(prompt, "Vital signs"+delimiter+"Temperature") {
+				t.Error("prompt instructions not written for the delimiter")
+			}
+		})
+	}
+}
+
+func TestPromptTemplatesFillDelimiter(t *testing.T) {
+	for _, style := range []string{PromptStyleVerbose, PromptStyleConcise} {
+		s := newTestService(t, "http://127.0.0.1:1", nil)
+		s.nestingDelimiter = "/"
+		s.promptStyle = style
+		prompt, err := s.formatExtractionPrompt(Schema{"Labs": map[string]any{"type": "string"}}, "Patient text {delimiter}", ProcessOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Count(prompt, promptPlaceholderDelimiter) != 1 {
+			t.Errorf("%s: placeholder left in the template or filled in the text", style)
+		}
+		if !strings.Contains(prompt, "'/' notation") || !strings.Contains(prompt, `"Vital signs/Temperature"`) {
+			t.Errorf("%s: prompt does not use the delimiter", style)
+		}
+	}
+}
+
+func TestNewExtractorServiceRejectsInvalidDelimiter(t *testing.T) {
+	for _, delimiter := range []string{"%", `"`, " / "} {
+		cfg := config.NewDefaultConfig()
+		cfg.LLM.SchemaDirs = []string{t.TempDir()}
+		cfg.LLM.NestingDelimiter = delimiter
+		if _, err := NewExtractorService(cfg, zap.NewNop(), ""); err == nil {
+			t.Errorf("delimiter %q accepted", delimiter)
+		}
+	}
+}
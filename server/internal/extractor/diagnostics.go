@@ -0,0 +1,72 @@
+package extractor
+
+import "sort"
+
+// EntityDiagnostics sorts the schema's entities by how far extraction got
+// with them, to tell a prompt or model problem from a matcher problem.
+type EntityDiagnostics struct {
+	Placed      []string `json:"placed"`       // Returned by the LLM and located in the text
+	Unplaceable []string `json:"unplaceable"`  // Returned by the LLM but never located in the text
+	NotReturned []string `json:"not_returned"` // In the schema but absent from the LLM output
+}
+
+// diagnoseEntities compares the schema's leaf entities and the entities the
+// LLM returned with those that were located. Entities returned outside the
+// schema are reported by validateEntityNames instead.
+func diagnoseEntities(schema Schema, delimiter string, rawExtraction RawLLMExtraction, output *ExtractionOutput) *EntityDiagnostics {
+	diagnostics := &EntityDiagnostics{Placed: []string{}, Unplaceable: []string{}, NotReturned: []string{}}
+	walkEntityDefinitions(stripSchemaMeta(schema), delimiter, func(fullName string, def map[string]any) {
+		if _, ok := asMap(def["properties"]); ok || def["type"] == tableEntityType {
+			return // Only leaves are returned by the LLM
+		}
+		if _, ok := itemProperties(def); ok {
+			return
+		}
+		switch {
+		case len(output.Entities[fullName]) > 0:
+			diagnostics.Placed = append(diagnostics.Placed, fullName)
+		case len(rawExtraction[fullName]) > 0:
+			diagnostics.Unplaceable = append(diagnostics.Unplaceable, fullName)
+		default:
+			diagnostics.NotReturned = append(diagnostics.NotReturned, fullName)
+		}
+	})
+	sort.Strings(diagnostics.Placed)
+	sort.Strings(diagnostics.Unplaceable)
+	sort.Strings(diagnostics.NotReturned)
+	return diagnostics
+}
+
+// mergeDiagnostics combines the diagnostics of separately extracted
+// segments. An entity takes the best outcome any segment had for it.
+func mergeDiagnostics(total, segment *EntityDiagnostics) *EntityDiagnostics {
+	if total == nil {
+		return segment
+	}
+	outcome := make(map[string]int)
+	record := func(d *EntityDiagnostics) {
+		for rank, names := range [][]string{d.NotReturned, d.Unplaceable, d.Placed} {
+			for _, name := range names {
+				outcome[name] = max(outcome[name], rank+1)
+			}
+		}
+	}
+	record(total)
+	record(segment)
+
+	merged := &EntityDiagnostics{Placed: []string{}, Unplaceable: []string{}, NotReturned: []string{}}
+	for name, rank := range outcome {
+		switch rank {
+		case 3:
+			merged.Placed = append(merged.Placed, name)
+		case 2:
+			merged.Unplaceable = append(merged.Unplaceable, name)
+		default:
+			merged.NotReturned = append(merged.NotReturned, name)
+		}
+	}
+	sort.Strings(merged.Placed)
+	sort.Strings(merged.Unplaceable)
+	sort.Strings(merged.NotReturned)
+	return merged
+}
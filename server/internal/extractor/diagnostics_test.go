@@ -0,0 +1,49 @@
+package extractor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestProcessTextDiagnostics(t *testing.T) {
+	text := "Patient on aspirin daily. HR 80."
+	llm := newStubLLM(t, completion(`{
+		"Medication": [{"value": "aspirin", "context": "Patient on aspirin daily"}],
+		"Vitals.HeartRate": [{"value": "95", "context": "HR 95"}]
+	}`))
+	schema := `
+Medication:
+  type: string
+Allergy:
+  type: string
+Vitals:
+  type: object
+  properties:
+    HeartRate:
+      type: number
+`
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": schema})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{Diagnostics: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &EntityDiagnostics{
+		Placed:      []string{"Medication"},
+		Unplaceable: []string{"Vitals.HeartRate"}, // 95 is not in the text
+		NotReturned: []string{"Allergy"},
+	}
+	if !reflect.DeepEqual(output.Diagnostics, want) {
+		t.Errorf("diagnostics = %+v, want %+v", output.Diagnostics, want)
+	}
+}
+
+func TestMergeDiagnosticsTakesBestOutcome(t *testing.T) {
+	a := &EntityDiagnostics{Placed: []string{"A"}, Unplaceable: []string{"B"}, NotReturned: []string{"C"}}
+	b := &EntityDiagnostics{Placed: []string{"B"}, Unplaceable: []string{"C"}, NotReturned: []string{"A"}}
+	want := &EntityDiagnostics{Placed: []string{"A", "B"}, Unplaceable: []string{"C"}, NotReturned: []string{}}
+	if got := mergeDiagnostics(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// DocumentSplit splits pasted input into documents that are extracted
+// independently, so context from one note cannot bleed into another.
+type DocumentSplit struct {
+	// Line that separates documents (e.g. "---"), matched literally on a line
+	// of its own unless Regex is set
+	Separator string `json:"separator"`
+	Regex     bool   `json:"regex"` // Treat Separator as a regular expression instead
+	// Report positions against the whole input rather than each document
+	WholePositions bool `json:"whole_positions"`
+}
+
+// DocumentExtraction is the result for one document of a split input.
+type DocumentExtraction struct {
+	Index int      `json:"index"` // Among the non-blank documents, in input order
+	Span  Position `json:"span"`  // Rune span of the document in the whole input
+	*ExtractionOutput
+}
+
+// Validate checks that the separator is set and compiles.
+func (d *DocumentSplit) Validate() error {
+	if d.Separator == "" {
+		return fmt.Errorf("document separator is required")
+	}
+	if _, err := d.compile(); err != nil {
+		return fmt.Errorf("invalid document separator: %w", err)
+	}
+	return nil
+}
+
+func (d *DocumentSplit) compile() (*regexp.Regexp, error) {
+	if d.Regex {
+		return regexp.Compile(`(?m)` + d.Separator)
+	}
+	return regexp.Compile(`(?m)^[ \t]*` + regexp.QuoteMeta(d.Separator) + `[ \t]*$`)
+}
+
+// split returns the byte ranges of text between separators, skipping blank ones.
+func (d *DocumentSplit) split(text string) ([]textRange, error) {
+	separator, err := d.compile()
+	if err != nil {
+		return nil, err
+	}
+	ranges := []textRange{}
+	start := 0
+	addRange := func(end int) {
+		if strings.TrimSpace(text[start:end]) != "" {
+			ranges = append(ranges, textRange{start, end})
+		}
+	}
+	for _, match := range separator.FindAllStringIndex(text, -1) {
+		if match[1] == match[0] {
+			continue // An empty match separates nothing
+		}
+		addRange(match[0])
+		start = match[1]
+	}
+	addRange(len(text))
+	return ranges, nil
+}
+
+// processDocuments splits the normalized text into documents and runs the
+// whole extraction over each one. The combined output keeps the whole text,
+// no entities of its own and the summed LLM usage.
+func (s *ExtractorService) processDocuments(ctx context.Context, schemaNames []string, text string, opts ProcessOptions) (*ExtractionOutput, error) {
+	ranges, err := opts.Split.split(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document separator: %w", err)
+	}
+	s.logger.Info("Split input into documents", zap.Int("documents", len(ranges)))
+
+	documentOpts := opts
+	documentOpts.Split = nil
+	merged := &ExtractionOutput{Text: text, Entities: make(map[string][]EntityOccurrence), Documents: []DocumentExtraction{}}
+	for i, r := range ranges {
+		output, err := s.ProcessText(ctx, schemaNames, text[r.start:r.end], documentOpts)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		runeStart := utf8.RuneCountInString(text[:r.start])
+		span := Position{Start: runeStart, End: runeStart + utf8.RuneCountInString(output.Text)}
+		if opts.Split.WholePositions {
+			shiftOccurrences(output.Entities, runeStart)
+		}
+		merged.Documents = append(merged.Documents, DocumentExtraction{Index: i, Span: span, ExtractionOutput: output})
+
+		mergeMetadata(&merged.Metadata, output.Metadata)
+		merged.Metadata.SchemaHash = output.Metadata.SchemaHash
+		merged.Metadata.PromptVersion = output.Metadata.PromptVersion
+		merged.Metadata.Model = output.Metadata.Model
+	}
+	return merged, nil
+}
+
+// shiftOccurrences moves every position of the occurrences by delta runes.
+func shiftOccurrences(entities map[string][]EntityOccurrence, delta int) {
+	shift := func(position *Position) {
+		position.Start += delta
+		position.End += delta
+	}
+	for _, occurrences := range entities {
+		for i := range occurrences {
+			shift(&occurrences[i].Position)
+			shift(&occurrences[i].Context.Position)
+			for j := range occurrences[i].Positions {
+				shift(&occurrences[i].Positions[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProcessTextSplitsDocuments(t *testing.T) {
+	first := "Note 1: patient on aspirin."
+	second := "Note 2: patient on warfarin."
+	text := first + "\n---\n" + second
+	schema := map[string]string{"demo.yaml": "Medication:\n  type: string\n"}
+	responses := []string{
+		completion(`{"Medication": [{"value": "aspirin", "context": "patient on aspirin"}]}`),
+		completion(`{"Medication": [{"value": "warfarin", "context": "patient on warfarin"}]}`),
+	}
+
+	for _, whole := range []bool{false, true} {
+		llm := newStubLLM(t, responses...)
+		s := newTestService(t, llm.URL, schema)
+		output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{
+			Split: &DocumentSplit{Separator: "---", WholePositions: whole},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requests := llm.Requests()
+		if len(requests) != 2 {
+			t.Fatalf("sent %d prompts, want one per document", len(requests))
+		}
+		if prompt, _ := requests[0]["prompt"].(string); strings.Contains(prompt, "warfarin") {
+			t.Error("first document's prompt contains the second document")
+		}
+
+		if len(output.Entities) != 0 || len(output.Documents) != 2 {
+			t.Fatalf("output = %+v, want two documents and no top-level entities", output)
+		}
+		// Documents keep the line breaks around the separator line
+		firstDoc, secondDoc := first+"\n", "\n"+second
+		secondStart := strings.Index(text, secondDoc)
+		for i, want := range []struct {
+			text, value string
+			span        Position
+		}{
+			{firstDoc, "aspirin", Position{Start: 0, End: len(firstDoc)}},
+			{secondDoc, "warfarin", Position{Start: secondStart, End: len(text)}},
+		} {
+			document := output.Documents[i]
+			if document.Index != i || document.Span != want.span || document.Text != want.text {
+				t.Errorf("document %d = index %d, span %+v, text %q", i, document.Index, document.Span, document.Text)
+			}
+			meds := document.Entities["Medication"]
+			if len(meds) != 1 || meds[0].Value != want.value {
+				t.Fatalf("document %d: Medication = %+v, want %s", i, meds, want.value)
+			}
+			start := strings.Index(want.text, want.value)
+			if whole {
+				start += want.span.Start
+			}
+			if meds[0].Position.Start != start {
+				t.Errorf("document %d (whole positions %v): %s at %d, want %d", i, whole, want.value, meds[0].Position.Start, start)
+			}
+		}
+	}
+}
+
+func TestDocumentSplitRegex(t *testing.T) {
+	split := &DocumentSplit{Separator: `^=+ NOTE \d+ =+$`, Regex: true}
+	if err := split.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := split.split("=== NOTE 1 ===\nfirst\n=== NOTE 2 ===\nsecond\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 2 {
+		t.Errorf("ranges = %v, want two documents", ranges)
+	}
+	if err := (&DocumentSplit{Separator: "(", Regex: true}).Validate(); err == nil {
+		t.Error("invalid separator regex accepted")
+	}
+}
+
+func TestCountEntitiesAcrossDocuments(t *testing.T) {
+	occurrence := EntityOccurrence{Value: "aspirin"}
+	output := &ExtractionOutput{
+		Entities: map[string][]EntityOccurrence{},
+		Documents: []DocumentExtraction{
+			{Index: 0, ExtractionOutput: &ExtractionOutput{Entities: map[string][]EntityOccurrence{"Medication": {occurrence}}}},
+			{Index: 1, ExtractionOutput: &ExtractionOutput{Entities: map[string][]EntityOccurrence{"Medication": {occurrence, occurrence}, "Allergy": {occurrence}}}},
+		},
+	}
+	counts := CountEntities(output)
+	if counts["Medication"] != 3 || counts["Allergy"] != 1 || len(counts) != 2 {
+		t.Errorf("counts = %v, want Medication 3 and Allergy 1", counts)
+	}
+}
+
+func TestProcessTextRejectsSplitWithPrevious(t *testing.T) {
+	llm := newStubLLM(t, completion(`{}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+	_, err := s.ProcessText(context.Background(), []string{"demo"}, "Note 1\n---\nNote 2", ProcessOptions{
+		Split:    &DocumentSplit{Separator: "---"},
+		Previous: &PriorExtraction{Text: "Note 1"},
+	})
+	if err == nil {
+		t.Error("split with a previous result succeeded")
+	}
+	if len(llm.Requests()) != 0 {
+		t.Error("LLM called for a rejected request")
+	}
+}
@@ -0,0 +1,65 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProcessTextRetriesEmptyContent(t *testing.T) {
+	tests := map[string]struct {
+		retries     int
+		wantErr     bool
+		wantRetries int
+	}{
+		"retry enabled":  {1, false, 1},
+		"retry disabled": {0, true, 0},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			llm := newStubLLM(t,
+				completion(""),
+				completion(`{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`),
+			)
+			s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+			s.emptyContentRetries = tt.retries
+
+			output, err := s.ProcessText(context.Background(), []string{"meds"}, "Patient on aspirin.", ProcessOptions{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ProcessText succeeded on empty content without retries")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(llm.Requests()) != 2 {
+				t.Errorf("LLM called %d times, want 2", len(llm.Requests()))
+			}
+			if output.Metadata.Parse.EmptyRetries != tt.wantRetries {
+				t.Errorf("empty retries = %d, want %d", output.Metadata.Parse.EmptyRetries, tt.wantRetries)
+			}
+			if len(output.Entities["Medication"]) != 1 {
+				t.Errorf("entities = %v, want the medication from the retry", output.Entities)
+			}
+		})
+	}
+}
+
+func TestEmptyContentRetryCountsTokens(t *testing.T) {
+	llm := newStubLLM(t,
+		`{"content": "", "tokens_evaluated": 100, "tokens_predicted": 1}`,
+		`{"content": "{\"Medication\": [{\"value\": \"aspirin\", \"context\": \"on aspirin\"}]}", "tokens_evaluated": 100, "tokens_predicted": 20}`,
+	)
+	s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+	s.emptyContentRetries = 1
+
+	output, err := s.ProcessText(context.Background(), []string{"meds"}, "Patient on aspirin.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := output.Metadata.TokenUsage
+	if usage.TokensEvaluated != 200 || usage.TokensPredicted != 21 {
+		t.Errorf("token usage = %+v, want the empty response's tokens included", usage)
+	}
+}
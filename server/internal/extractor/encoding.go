@@ -0,0 +1,97 @@
+package extractor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encodings reported in ExtractionMetadata.Encoding
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF8BOM     = "utf-8-bom"    // UTF-8 with its byte order mark stripped
+	EncodingWindows1252 = "windows-1252" // Also covers Latin-1, which it extends
+)
+
+// Policies for text that is not valid UTF-8, set with input.invalid_utf8
+const (
+	InvalidUTF8Decode  = "decode"  // Read the text as Windows-1252
+	InvalidUTF8Replace = "replace" // Replace each invalid sequence with U+FFFD
+	InvalidUTF8Reject  = "reject"  // Fail with ErrInvalidUTF8
+)
+
+// ErrInvalidUTF8 is returned for text that is not valid UTF-8 when
+// input.invalid_utf8 is "reject".
+var ErrInvalidUTF8 = errors.New("text is not valid UTF-8")
+
+func validateInvalidUTF8Policy(policy string) error {
+	switch policy {
+	case "", InvalidUTF8Decode, InvalidUTF8Replace, InvalidUTF8Reject:
+		return nil
+	default:
+		return fmt.Errorf("invalid UTF-8 policy %q, expected %q, %q or %q", policy, InvalidUTF8Decode, InvalidUTF8Replace, InvalidUTF8Reject)
+	}
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Characters Windows-1252 puts at 0x80-0x9F, where Latin-1 has C1 controls.
+// The five bytes it leaves undefined keep their Latin-1 meaning.
+var windows1252High = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// DecodeText turns document bytes into UTF-8 text and names the encoding they
+// were found in. A leading UTF-8 byte order mark is stripped; bytes that are
+// not valid UTF-8 are read as Windows-1252, the usual encoding of documents
+// exported from Windows tools.
+func DecodeText(data []byte) (string, string) {
+	if bytes.HasPrefix(data, utf8BOM) && utf8.Valid(data) {
+		return string(data[len(utf8BOM):]), EncodingUTF8BOM
+	}
+	if utf8.Valid(data) {
+		return string(data), EncodingUTF8
+	}
+
+	var decoded strings.Builder
+	decoded.Grow(len(data) + len(data)/2)
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9F {
+			decoded.WriteRune(windows1252High[b-0x80])
+		} else {
+			decoded.WriteRune(rune(b)) // Latin-1 bytes equal their code points
+		}
+	}
+	return decoded.String(), EncodingWindows1252
+}
+
+// decodeInput is DecodeText with text that is not valid UTF-8 handled by the
+// policy, which is returned when it applied and "" for valid text.
+func decodeInput(data []byte, policy string) (string, string, string, error) {
+	if utf8.Valid(data) {
+		text, encoding := DecodeText(data)
+		return text, encoding, "", nil
+	}
+	switch policy {
+	case InvalidUTF8Reject:
+		return "", "", policy, ErrInvalidUTF8
+	case InvalidUTF8Replace:
+		data = bytes.TrimPrefix(data, utf8BOM)
+		return strings.ToValidUTF8(string(data), string(utf8.RuneError)), EncodingUTF8, policy, nil
+	default:
+		text, encoding := DecodeText(data)
+		return text, encoding, InvalidUTF8Decode, nil
+	}
+}
+
+// HashText returns a hex SHA-256 of the text, identifying the exact text an
+// extraction processed for caching, deduplication and audit.
+func HashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
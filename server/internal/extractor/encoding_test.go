@@ -0,0 +1,138 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		wantText     string
+		wantEncoding string
+	}{
+		{"utf-8", []byte("café"), "café", EncodingUTF8},
+		{"byte order mark", append([]byte{0xEF, 0xBB, 0xBF}, "café"...), "café", EncodingUTF8BOM},
+		{"windows-1252 smart quotes", []byte("the \x93worst\x94 pain"), "the “worst” pain", EncodingWindows1252},
+		{"latin-1", []byte("caf\xe9"), "café", EncodingWindows1252},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, encoding := DecodeText(tt.data)
+			if text != tt.wantText || encoding != tt.wantEncoding {
+				t.Errorf("DecodeText = %q, %q; want %q, %q", text, encoding, tt.wantText, tt.wantEncoding)
+			}
+		})
+	}
+}
+
+func TestProcessTextDecodesInput(t *testing.T) {
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+
+	tests := []struct {
+		name         string
+		text         string
+		wantStart    int
+		wantEncoding string
+	}{
+		{"byte order mark", "\xEF\xBB\xBFPatient on aspirin.", 11, EncodingUTF8BOM},
+		{"windows-1252", "\x93Pain\x94 on aspirin.", 10, EncodingWindows1252},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := s.ProcessText(context.Background(), []string{"demo"}, tt.text, ProcessOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output.Metadata.Encoding != tt.wantEncoding {
+				t.Errorf("encoding = %q, want %q", output.Metadata.Encoding, tt.wantEncoding)
+			}
+			occurrences := output.Entities["Medication"]
+			if len(occurrences) != 1 || occurrences[0].Position.Start != tt.wantStart {
+				t.Fatalf("occurrences = %+v, want aspirin at %d", occurrences, tt.wantStart)
+			}
+			runes := []rune(output.Text)
+			if got := string(runes[tt.wantStart : tt.wantStart+len("aspirin")]); got != "aspirin" {
+				t.Errorf("text at position = %q, want aspirin", got)
+			}
+		})
+	}
+}
+
+func TestProcessTextInvalidUTF8Policies(t *testing.T) {
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+	text := "Pain \xff\xfe on aspirin." // Not UTF-8, and not sensible Windows-1252 either
+
+	tests := []struct {
+		policy       string
+		wantText     string
+		wantEncoding string
+		wantStart    int
+	}{
+		{InvalidUTF8Decode, "Pain ÿþ on aspirin.", EncodingWindows1252, 11},
+		{InvalidUTF8Replace, "Pain \uFFFD on aspirin.", EncodingUTF8, 10}, // One replacement per invalid run
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			s.invalidUTF8 = tt.policy
+			output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output.Text != tt.wantText || output.Metadata.Encoding != tt.wantEncoding || output.Metadata.InvalidUTF8 != tt.policy {
+				t.Errorf("text = %q, encoding = %q, policy = %q; want %q, %q, %q",
+					output.Text, output.Metadata.Encoding, output.Metadata.InvalidUTF8, tt.wantText, tt.wantEncoding, tt.policy)
+			}
+			occurrences := output.Entities["Medication"]
+			if len(occurrences) != 1 || occurrences[0].Position.Start != tt.wantStart {
+				t.Errorf("occurrences = %+v, want aspirin at %d", occurrences, tt.wantStart)
+			}
+		})
+	}
+
+	s.invalidUTF8 = InvalidUTF8Reject
+	calls := len(llm.Requests())
+	if _, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{}); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("reject: err = %v, want ErrInvalidUTF8", err)
+	}
+	if len(llm.Requests()) != calls {
+		t.Error("LLM called for rejected text")
+	}
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Pain on aspirin.", ProcessOptions{})
+	if err != nil || output.Metadata.InvalidUTF8 != "" {
+		t.Errorf("valid text: policy = %q (%v), want none reported", output.Metadata.InvalidUTF8, err)
+	}
+}
+
+func TestProcessTextHashesText(t *testing.T) {
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+	hash := func(text string) string {
+		t.Helper()
+		output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return output.Metadata.TextHash
+	}
+
+	if got := hash("Patient on aspirin."); got != "" {
+		t.Errorf("text_hash = %q without input.hash_text, want none", got)
+	}
+
+	s.hashText = true
+	first := hash("Patient on aspirin.")
+	if first != HashText("Patient on aspirin.") {
+		t.Errorf("text_hash = %q, want %q", first, HashText("Patient on aspirin."))
+	}
+	if again := hash("Patient on aspirin."); again != first {
+		t.Errorf("identical text hashed to %q and %q", first, again)
+	}
+	if other := hash("Patient on aspirin daily."); other == first {
+		t.Errorf("different text hashed to the same %q", other)
+	}
+}
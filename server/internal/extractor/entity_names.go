@@ -0,0 +1,117 @@
+package extractor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Modes for checking the entity names returned by the LLM against the schema
+const (
+	entityNamesLenient = "lenient" // Keep unknown names, listing them in the metadata
+	entityNamesStrict  = "strict"  // Drop unknown names, listing them in the metadata
+)
+
+// validateEntityNameMode rejects unsupported matching.entity_name_validation
+// values. An empty mode disables the check.
+func validateEntityNameMode(mode string) error {
+	switch mode {
+	case "", entityNamesLenient, entityNamesStrict:
+		return nil
+	default:
+		return fmt.Errorf("invalid entity name validation %q, expected %q or %q", mode, entityNamesLenient, entityNamesStrict)
+	}
+}
+
+// entityNameKey folds the differences in case and whitespace the LLM tends
+// to introduce when repeating an entity name.
+func entityNameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// validateEntityNames checks the entity names in the raw extraction against
+// the schema. Names differing from a schema entity only in case or whitespace
+// are renamed to it; other unknown names are dropped when strict is set. It
+// returns the unknown names in sorted order.
+func validateEntityNames(rawExtraction RawLLMExtraction, schema Schema, delimiter string, strict bool) []string {
+	canonical := make(map[string]string)
+	known := make(map[string]bool)
+	walkSchemaEntities(stripSchemaMeta(schema), delimiter, func(fullName string, _ map[string]any) {
+		known[fullName] = true
+		canonical[entityNameKey(fullName)] = fullName
+	})
+
+	names := make([]string, 0, len(rawExtraction))
+	for name := range rawExtraction {
+		names = append(names, name)
+	}
+	sort.Strings(names) // Corrected occurrences are appended in a stable order
+
+	unknown := []string{}
+	for _, name := range names {
+		occurrences := rawExtraction[name]
+		if known[name] {
+			continue
+		}
+		if fullName, ok := canonical[entityNameKey(name)]; ok {
+			delete(rawExtraction, name)
+			rawExtraction[fullName] = append(rawExtraction[fullName], occurrences...)
+			continue
+		}
+		unknown = append(unknown, name)
+		if strict {
+			delete(rawExtraction, name)
+		}
+	}
+	return unknown
+}
+
+// Cases output entity keys can be normalized to
+const (
+	EntityKeyCaseLower = "lower"
+	EntityKeyCaseUpper = "upper"
+)
+
+// validateEntityKeyCase rejects unsupported matching.entity_key_case values.
+// An empty case keeps keys as the schema spells them.
+func validateEntityKeyCase(keyCase string) error {
+	switch keyCase {
+	case "", EntityKeyCaseLower, EntityKeyCaseUpper:
+		return nil
+	default:
+		return fmt.Errorf("invalid entity key case %q, expected %q or %q", keyCase, EntityKeyCaseLower, EntityKeyCaseUpper)
+	}
+}
+
+// canonicalizeEntityKeys rewrites the entity keys of the output to keyCase,
+// so "Labs.WBC" from one schema and "labs.wbc" from another aggregate under
+// one key. Occurrences whose key changed keep the schema spelling in
+// OriginalEntity. Keys are merged in sorted order, for a stable result.
+func canonicalizeEntityKeys(output *ExtractionOutput, keyCase string) {
+	toCase := strings.ToLower
+	if keyCase == EntityKeyCaseUpper {
+		toCase = strings.ToUpper
+	}
+	names := make([]string, 0, len(output.Entities))
+	for name := range output.Entities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonical := make(map[string][]EntityOccurrence, len(names))
+	for _, name := range names {
+		key := toCase(name)
+		occurrences := output.Entities[name]
+		if key != name {
+			for i := range occurrences {
+				occurrences[i].OriginalEntity = name
+			}
+		}
+		if merged, exists := canonical[key]; exists {
+			canonical[key] = append(merged, occurrences...)
+		} else {
+			canonical[key] = occurrences
+		}
+	}
+	output.Entities = canonical
+}
@@ -0,0 +1,151 @@
+package extractor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestValidateEntityNames(t *testing.T) {
+	schema := Schema{
+		"Medication": map[string]any{"type": "string"},
+		"Heart Rate": map[string]any{"type": "number"},
+	}
+	tests := map[string]struct {
+		strict    bool
+		wantNames []string
+	}{
+		"lenient keeps unknown names": {false, []string{"Diagnosis", "Heart Rate", "Medication"}},
+		"strict drops unknown names":  {true, []string{"Heart Rate", "Medication"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			raw := RawLLMExtraction{
+				"Medication":  {{Value: "aspirin", Context: "on aspirin"}},
+				"heart  rate": {{Value: "80", Context: "HR 80"}},    // Case and whitespace variant
+				"Diagnosis":   {{Value: "flu", Context: "has flu"}}, // Not in the schema
+			}
+			unknown := validateEntityNames(raw, schema, ".", tt.strict)
+
+			if !reflect.DeepEqual(unknown, []string{"Diagnosis"}) {
+				t.Errorf("unknown = %v, want [Diagnosis]", unknown)
+			}
+			names := make([]string, 0, len(raw))
+			for entityName := range raw {
+				names = append(names, entityName)
+			}
+			if !sameNames(names, tt.wantNames) {
+				t.Errorf("entities = %v, want %v", names, tt.wantNames)
+			}
+			if got := raw["Heart Rate"]; len(got) != 1 || got[0].Value != "80" {
+				t.Errorf("Heart Rate = %v, want the case variant's occurrence", got)
+			}
+		})
+	}
+}
+
+// sameNames compares two sets of names regardless of order.
+func sameNames(got, want []string) bool {
+	set := make(map[string]bool, len(got))
+	for _, name := range got {
+		set[name] = true
+	}
+	if len(set) != len(want) {
+		return false
+	}
+	for _, name := range want {
+		if !set[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProcessTextReportsUnknownEntities(t *testing.T) {
+	llm := newStubLLM(t, completion(`{
+		"medication": [{"value": "aspirin", "context": "on aspirin"}],
+		"Allergy": [{"value": "penicillin", "context": "allergic to penicillin"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+	s.entityNameValidation = entityNamesStrict
+
+	output, err := s.ProcessText(context.Background(), []string{"meds"}, "Patient on aspirin, allergic to penicillin.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output.Entities["Medication"]) != 1 {
+		t.Errorf("entities = %v, want the case variant under Medication", output.Entities)
+	}
+	if _, ok := output.Entities["Allergy"]; ok {
+		t.Error("strict validation kept the unknown entity")
+	}
+	if !reflect.DeepEqual(output.Metadata.UnknownEntities, []string{"Allergy"}) {
+		t.Errorf("unknown entities = %v, want [Allergy]", output.Metadata.UnknownEntities)
+	}
+}
+
+func TestProcessTextCanonicalizesEntityKeys(t *testing.T) {
+	text := "Admission WBC 7.1. Discharge wbc 6.8."
+	schemas := map[string]string{
+		"admission.yaml": "Labs:\n  type: object\n  properties:\n    WBC:\n      type: number\n",
+		"discharge.yaml": "labs:\n  type: object\n  properties:\n    wbc:\n      type: number\n",
+	}
+	llm := newStubLLM(t, completion(`{
+		"Labs.WBC": [{"value": "7.1", "context": "Admission WBC 7.1"}],
+		"labs.wbc": [{"value": "6.8", "context": "Discharge wbc 6.8"}]
+	}`))
+
+	for _, keyCase := range []string{"", EntityKeyCaseLower} {
+		s := newTestService(t, llm.URL, schemas)
+		s.entityKeyCase = keyCase
+		output, err := s.ProcessText(context.Background(), []string{"admission", "discharge"}, text, ProcessOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if keyCase == "" {
+			if len(output.Entities["Labs.WBC"]) != 1 || len(output.Entities["labs.wbc"]) != 1 {
+				t.Errorf("entities = %v, want the schema spellings kept by default", output.Entities)
+			}
+			continue
+		}
+		if _, ok := output.Entities["Labs.WBC"]; ok || len(output.Entities) != 1 {
+			t.Fatalf("entities = %v, want a single lowercase key", output.Entities)
+		}
+		wbc := output.Entities["labs.wbc"]
+		if len(wbc) != 2 {
+			t.Fatalf("labs.wbc = %+v, want both occurrences", wbc)
+		}
+		originals := map[any]string{}
+		for _, occurrence := range wbc {
+			originals[occurrence.Value] = occurrence.OriginalEntity
+		}
+		if originals["7.1"] != "Labs.WBC" || originals["6.8"] != "" {
+			t.Errorf("original entities = %v, want Labs.WBC kept for the renamed key only", originals)
+		}
+		groups := GroupEntitiesBySchema(output)
+		if len(groups["admission"].Entities["labs.wbc"]) != 1 || len(groups["discharge"].Entities["labs.wbc"]) != 1 {
+			t.Errorf("grouped = %+v, want each occurrence under its own schema", groups)
+		}
+	}
+}
+
+func TestValidateEntityNamesKnowsPlainValues(t *testing.T) {
+	schema := Schema{
+		"Smoker": "yes or no",
+		"Vitals": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"Temperature": "number"},
+		},
+	}
+	raw := RawLLMExtraction{
+		"Smoker":             {{Value: "no", Context: "denies smoking"}},
+		"Vitals.Temperature": {{Value: "37", Context: "T 37"}},
+	}
+	if unknown := validateEntityNames(raw, schema, ".", true); len(unknown) != 0 {
+		t.Errorf("unknown = %v, want the plain value entities known", unknown)
+	}
+	if len(raw) != 2 {
+		t.Errorf("strict validation dropped plain value entities: %v", raw)
+	}
+}
@@ -0,0 +1,176 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaExample is a single few-shot demonstration attached to an entity
+// definition under its optional `examples` key.
+type SchemaExample struct {
+	Input   string `json:"input"`   // Snippet of source text
+	Value   any    `json:"value"`   // Expected extracted value
+	Context string `json:"context"` // Expected context string
+}
+
+// entityExamples pairs an entity name with the examples declared for it.
+type entityExamples struct {
+	Entity   string
+	Examples []SchemaExample
+}
+
+// collectSchemaExamples gathers the examples declared on every entity of the
+// schema (including nested properties), keyed by entity name with nested names
+// joined by delimiter.
+func collectSchemaExamples(schema Schema, delimiter string) []entityExamples {
+	collected := []entityExamples{}
+
+	var recurse func(defs map[string]any, prefix string)
+	recurse = func(defs map[string]any, prefix string) {
+		for name, value := range defs {
+			def, ok := asMap(value)
+			if !ok {
+				continue
+			}
+			fullName := name
+			if prefix != "" {
+				fullName = prefix + delimiter + name
+			}
+			if examples := parseSchemaExamples(def["examples"]); len(examples) > 0 {
+				collected = append(collected, entityExamples{Entity: fullName, Examples: examples})
+			}
+			if props, ok := asMap(def["properties"]); ok {
+				recurse(props, fullName)
+			}
+			if props, ok := itemProperties(def); ok {
+				recurse(props, fullName+ArrayItemMarker)
+			}
+		}
+	}
+	recurse(schema, "")
+
+	sort.Slice(collected, func(i, j int) bool { return collected[i].Entity < collected[j].Entity })
+	return collected
+}
+
+// parseSchemaExamples converts the raw YAML list under an `examples` key into
+// SchemaExamples, skipping entries without an input snippet.
+func parseSchemaExamples(raw any) []SchemaExample {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil
+	}
+	examples := []SchemaExample{}
+	for _, item := range list {
+		entry, ok := asMap(item)
+		if !ok {
+			continue
+		}
+		input, _ := entry["input"].(string)
+		if input == "" {
+			continue
+		}
+		context, _ := entry["context"].(string)
+		examples = append(examples, SchemaExample{Input: input, Value: entry["value"], Context: context})
+	}
+	return examples
+}
+
+// limitExamples caps the total number of examples, taking them round-robin
+// across entities so a single entity cannot use up the whole budget.
+func limitExamples(all []entityExamples, maxExamples int) []entityExamples {
+	if maxExamples <= 0 {
+		return nil
+	}
+	limited := make([]entityExamples, len(all))
+	for i, e := range all {
+		limited[i].Entity = e.Entity
+	}
+
+	taken := 0
+	for round := 0; taken < maxExamples; round++ {
+		progressed := false
+		for i, e := range all {
+			if round >= len(e.Examples) || taken >= maxExamples {
+				continue
+			}
+			limited[i].Examples = append(limited[i].Examples, e.Examples[round])
+			taken++
+			progressed = true
+		}
+		if !progressed {
+			break
+		}
+	}
+
+	result := []entityExamples{}
+	for _, e := range limited {
+		if len(e.Examples) > 0 {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// formatExamplesSection renders few-shot examples as a prompt section. It
+// returns an empty string when there are no examples.
+func formatExamplesSection(examples []entityExamples) (string, error) {
+	if len(examples) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\nExamples of correct extractions for specific entities:\n")
+	for _, e := range examples {
+		for _, ex := range e.Examples {
+			output, err := json.Marshal(map[string][]LLMOutputValueContext{
+				e.Entity: {{Value: ex.Value, Context: ex.Context}},
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal example for entity '%s': %w", e.Entity, err)
+			}
+			fmt.Fprintf(&sb, "\nText: %q\nOutput: %s\n", ex.Input, output)
+		}
+	}
+	return sb.String(), nil
+}
+
+// stripSchemaExamples returns a copy of the schema with the `examples` key
+// removed from every entity definition, since examples are rendered separately.
+func stripSchemaExamples(schema Schema) Schema {
+	stripped := make(Schema, len(schema))
+	for name, value := range schema {
+		stripped[name] = stripDefinitionExamples(value)
+	}
+	return stripped
+}
+
+func stripDefinitionExamples(value any) any {
+	def, ok := asMap(value)
+	if !ok {
+		return value
+	}
+	result := make(map[string]any, len(def))
+	for key, v := range def {
+		switch key {
+		case "examples":
+			continue
+		case "properties":
+			if props, ok := asMap(v); ok {
+				strippedProps := make(map[string]any, len(props))
+				for propName, propDef := range props {
+					strippedProps[propName] = stripDefinitionExamples(propDef)
+				}
+				result[key] = strippedProps
+				continue
+			}
+		case "items":
+			result[key] = stripDefinitionExamples(v)
+			continue
+		}
+		result[key] = v
+	}
+	return result
+}
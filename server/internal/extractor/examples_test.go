@@ -0,0 +1,74 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+const examplesSchema = `
+Medication:
+  type: string
+  examples:
+    - input: "Started aspirin 81 mg daily."
+      value: "aspirin"
+      context: "Started aspirin 81 mg daily."
+    - input: "Continue metoprolol."
+      value: "metoprolol"
+      context: "Continue metoprolol."
+Vitals:
+  type: object
+  properties:
+    HeartRate:
+      type: number
+      examples:
+        - input: "HR 72"
+          value: 72
+          context: "HR 72"
+`
+
+func TestFormatExtractionPromptRendersExamples(t *testing.T) {
+	s := newTestService(t, "http://127.0.0.1:1", map[string]string{"demo.yaml": examplesSchema})
+	prompt, err := s.formatExtractionPrompt(s.Schemas["demo"], "Patient takes aspirin.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`Text: "Started aspirin 81 mg daily."`,
+		`Output: {"Medication":[{"value":"aspirin","context":"Started aspirin 81 mg daily."}]}`,
+		`Output: {"Vitals.HeartRate":[{"value":72,"context":"HR 72"}]}`,
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q", want)
+		}
+	}
+	if strings.Contains(prompt, `"examples"`) {
+		t.Error("examples left in the schema block")
+	}
+}
+
+func TestLimitExamplesRespectsCap(t *testing.T) {
+	s := newTestService(t, "http://127.0.0.1:1", map[string]string{"demo.yaml": examplesSchema})
+	all := collectSchemaExamples(s.Schemas["demo"], ".")
+
+	limited := limitExamples(all, 2)
+	total := 0
+	for _, e := range limited {
+		total += len(e.Examples)
+	}
+	if total != 2 || len(limited) != 2 {
+		t.Fatalf("limitExamples(2) = %+v, want one example from each entity", limited)
+	}
+
+	if got := limitExamples(all, 0); len(got) != 0 {
+		t.Errorf("limitExamples(0) = %+v, want none", got)
+	}
+
+	s.maxExamples = 1
+	prompt, err := s.formatExtractionPrompt(s.Schemas["demo"], "text", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(prompt, "\nOutput: "); n != 1 {
+		t.Errorf("prompt has %d examples, want 1", n)
+	}
+}
@@ -1,13 +1,21 @@
 package extractor
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/andevellicus/med-ex/internal/config"
@@ -31,9 +39,39 @@ type EntityOccurrence struct {
 	// Value can be string, number, bool, or even a slice based on schema.
 	// Using 'any' (any) provides flexibility.
 	Value    any      `json:"value"`
-	Position Position `json:"position"` // Position of the Value in the original text
-	Context  Context  `json:"context"`  // Surrounding context and its position
-	ID       string   `json:"id"`       // Unique identifier for the occurrence
+	Position Position `json:"position"`         // Position of the Value in the original text
+	Context  Context  `json:"context"`          // Surrounding context and its position
+	ID       string   `json:"id"`               // Unique identifier for the occurrence
+	GroupID  string   `json:"group_id"`         // Shared by all matches of the same LLM occurrence
+	RowID    string   `json:"row_id,omitempty"` // Links the cells of one table row
+	Schema   string   `json:"schema,omitempty"` // Schema that defined the entity
+	Track    int      `json:"track"`            // Row on which the highlight can be drawn without overlaps
+	// Position of the Value relative to the start of its context span; nil
+	// when the value lies outside the context
+	ValueInContext *Position `json:"value_in_context,omitempty"`
+	// Other plausible values the LLM offered; positions anchor on Value
+	Alternatives []any `json:"alternatives,omitempty"`
+	// Absolute date (YYYY-MM-DD) for relative date values such as "3 days ago"
+	ResolvedDate string `json:"resolved_date,omitempty"`
+	// Set when the collapse_repeats post-processor merged identical
+	// occurrences into this one: how many there were and where their values are
+	Count     int        `json:"count,omitempty"`
+	Positions []Position `json:"positions,omitempty"`
+	// How reliably the value was placed, from 0 to 1; see contextMatchConfidence
+	Confidence float64 `json:"confidence"`
+	// JSON kind of Value ("string", "number", "boolean", "array" or
+	// "object"), when ProcessOptions.ValueTypes is set
+	ValueType string `json:"value_type,omitempty"`
+	// Unit split off a number value, e.g. "°F" of "98.7°F", when
+	// matching.split_units is set; Position still covers value and unit
+	Unit string `json:"unit,omitempty"`
+	// Entity name as the schema spells it, when matching.entity_key_case changed the key
+	OriginalEntity string `json:"original_entity,omitempty"`
+	// Places the LLM context matched in the text, set when more than
+	// matching.max_context_matches made the highlight ambiguous
+	ContextMatches int `json:"context_matches,omitempty"`
+
+	fallback bool // Located by searching for the value alone, not within its context
 }
 
 // ExtractionOutput maps an entity name (e.g., "Age", "Vital signs.Temperature")
@@ -41,57 +79,426 @@ type EntityOccurrence struct {
 type ExtractionOutput struct {
 	Text     string                        `json:"text"` // The original text used for extraction
 	Entities map[string][]EntityOccurrence `json:"entities"`
+	Metadata ExtractionMetadata            `json:"metadata"`
+	Debug    *ExtractionDebug              `json:"debug,omitempty"` // Set when ProcessOptions.Debug is
+	// Set when ProcessOptions.Diagnostics is
+	Diagnostics *EntityDiagnostics `json:"diagnostics,omitempty"`
+	// Header values read without the LLM, when ProcessOptions.ParseHeader is set
+	DocumentMetadata DocumentMetadata `json:"document_metadata,omitempty"`
+	// Per-document results when ProcessOptions.Split is set; Entities is then empty
+	Documents []DocumentExtraction `json:"documents,omitempty"`
+
+	schemaSources    map[string]string // Top-level schema key to the schema that supplied it
+	nestingDelimiter string
+}
+
+// ProcessOptions holds per-request extraction settings.
+type ProcessOptions struct {
+	ReferenceDate *time.Time // Date the document was written; anchors relative dates
+	// Highlight the context of every empty-valued occurrence, not only for
+	// entities marked `highlight_context: true` in the schema
+	HighlightContextWhenEmpty bool
+	Sections                  *SectionOptions // Confine schemas to labeled sections of the document
+	// Report positions against the text as submitted rather than the
+	// line-ending-normalized Text of the output. The submitted text is taken
+	// after DecodeText, so a stripped byte order mark does not count.
+	OriginalPositions bool
+	Debug             bool // Return what was sent to the LLM in ExtractionOutput.Debug
+	Diagnostics       bool // Report which schema entities were placed in ExtractionOutput.Diagnostics
+	// Schema supplied with the request, merged after the named schemas; see
+	// ParseInlineSchema
+	InlineSchema Schema
+	// Drop occurrences with a lower Confidence, counting them in
+	// Metadata.LowConfidenceDropped; 0 keeps everything
+	MinConfidence float64
+	// LLM server to call instead of the configured one; must pass
+	// ValidateLLMServer
+	LLMServer string
+	// Result for an earlier version of the text; only the sentences that
+	// changed since are sent to the LLM, see extractIncremental
+	Previous *PriorExtraction
+	// Read the configured "Key: Value" header lines into
+	// ExtractionOutput.DocumentMetadata and extract entities from the rest;
+	// rejected with schema sections or Previous
+	ParseHeader bool
+	// Extract each separated document of the text on its own; see
+	// DocumentSplit
+	Split *DocumentSplit
+	// Label each occurrence with the JSON kind of its value
+	ValueTypes bool
+	// Language of the prompt instructions, overriding llm.prompt_lang; must
+	// pass ValidatePromptLang
+	PromptLang string
+
+	model       string   // Resolved from the schemas' _model by ProcessText
+	temperature *float64 // Set for a low-yield retry, nil for defaultTemperature
+}
+
+// ExtractionDebug records what the LLM was actually given and what it answered.
+type ExtractionDebug struct {
+	// Schema JSON embedded in each prompt, after meta keys and examples were
+	// stripped; one per LLM prompt (section extraction may send several)
+	PromptSchemas []json.RawMessage `json:"prompt_schemas"`
+	// JSON the LLM answered each prompt with, after fence cleaning and any
+	// repair; parallel to PromptSchemas
+	LLMResponses []string `json:"llm_responses,omitempty"`
+	// Each prompt's instructions, schema and text without the chat control
+	// tokens, see promptUserContent; parallel to PromptSchemas
+	Prompts []string `json:"prompts,omitempty"`
+}
+
+// ExtractionMetadata holds details about how an extraction was produced.
+type ExtractionMetadata struct {
+	Timings    *LLMTimings `json:"timings,omitempty"` // nil when the backend reports no timings
+	TokenUsage TokenUsage  `json:"token_usage"`
+	// Identify the definitions the result was produced with, to detect stale results
+	SchemaHash    string        `json:"schema_hash"`
+	PromptVersion string        `json:"prompt_version"`
+	Parse         ParseAttempts `json:"parse"`
+	// Entity names the LLM returned that are not in the schema, when validated
+	UnknownEntities []string   `json:"unknown_entities,omitempty"`
+	Matches         MatchStats `json:"matches"`
+	// Occurrences removed for falling below the requested min_confidence
+	LowConfidenceDropped int `json:"low_confidence_dropped,omitempty"`
+	// Encoding the submitted text was decoded from; see DecodeText
+	Encoding string `json:"encoding"`
+	// input.invalid_utf8 policy applied to text that was not valid UTF-8
+	InvalidUTF8 string `json:"invalid_utf8,omitempty"`
+	// Set when the first attempt found fewer entities than llm.low_yield_ratio
+	LowYieldRetry *LowYieldRetry `json:"low_yield_retry,omitempty"`
+	// HashText of the returned text, when input.hash_text is set
+	TextHash string `json:"text_hash,omitempty"`
+	// Set when ProcessOptions.Previous is
+	Incremental *IncrementalStats `json:"incremental,omitempty"`
+	// Occurrences whose context was not unique enough to trust their highlight
+	AmbiguousContexts []AmbiguousContext `json:"ambiguous_contexts,omitempty"`
+	// Model requested from the LLM server, from the schemas' _model or
+	// llm.model; empty when the server's default was used
+	Model string `json:"model,omitempty"`
+}
+
+// TokenUsage accounts for the tokens spent on an extraction.
+type TokenUsage struct {
+	PromptTokens    int `json:"prompt_tokens"`    // Estimated from the prompt sent
+	TokensEvaluated int `json:"tokens_evaluated"` // As reported by the LLM server
+	TokensPredicted int `json:"tokens_predicted"` // As reported by the LLM server
+	Total           int `json:"total"`            // Evaluated plus predicted
 }
 
 // ExtractorService holds dependencies
 type ExtractorService struct {
-	llmServerURL string
-	httpClient   *http.Client
-	logger       *zap.Logger
-	Schemas      map[string]Schema
-	schemaNames  []string
-	SchemaFiles  map[string]string
+	llmServerURL         string
+	allowedLLMServers    map[string]bool // Servers a request may pick instead of llmServerURL
+	health               llmHealthState
+	httpClient           *http.Client
+	logger               *zap.Logger
+	Schemas              map[string]Schema
+	schemaNames          []string
+	SchemaFiles          map[string]string // Schema name to the file (and so directory) it was loaded from
+	maxExamples          int
+	injectReferenceDate  bool // Use the server date when a request has no reference date
+	resolveRelativeDates bool // Fill ResolvedDate on relative date values
+	match                matchOptions
+	regexes              *regexCache   // Shared across requests
+	searchSlots          chan struct{} // Extra search workers free across requests, nil for no limit
+	booleanVocabulary    booleanVocabulary
+	contentPaths         []string                  // Response paths tried in order for the extraction JSON
+	defaultSchemas       []string                  // Used when a request names no schemas
+	hiddenSchemas        map[string]bool           // Loaded schemas callers can neither list nor use
+	repairJSON           bool                      // Try to fix malformed JSON before re-prompting
+	maxReprompts         int                       // Extra LLM calls allowed for unparseable responses
+	emptyContentRetries  int                       // Extra LLM calls allowed for empty responses
+	entityNameValidation string                    // "", "lenient" or "strict"
+	entityKeyCase        string                    // "", EntityKeyCaseLower or EntityKeyCaseUpper
+	promptStyle          string                    // PromptStyleVerbose or PromptStyleConcise
+	promptLang           string                    // PromptLangEnglish or a key of promptTemplates
+	promptTemplates      map[string]promptTemplate // Translated prompt templates by language
+	model                string                    // Requested when the schemas name no model, "" for the server default
+	modelConflicts       string                    // ModelConflictDefault or ModelConflictError
+	invalidUTF8          string                    // Policy for text that is not valid UTF-8
+	hashText             bool                      // Report HashText of the processed text in the metadata
+	lowYieldRatio        float64                   // Retry when a smaller share of the schema's entities is found, 0 disables
+	lowYieldTemperature  float64                   // Sampling temperature of the low-yield retry
+	nestingDelimiter     string                    // Joins nested entity names ("Vital signs.Temperature")
+	maxResponseBytes     int64                     // Cap on the LLM response body read into memory
+	contextSize          int                       // Model context window in tokens, 0 if unknown
+	maxOutputTokens      int                       // Response budget per LLM call, 0 for unbounded
+	responseReserve      int                       // Response tokens assumed by estimates while unbounded
+	maxRequestSchemas    int                       // Schema names one request may combine, 0 for no limit
+	postProcessors       []PostProcessor
+	postProcessorNames   []string // Parallel to postProcessors, for errors
+	header               headerOptions
+	sectionMarkers       []SectionMarker // Default headers for grouping results by section
+	// Report templates requests may name in output_template
+	outputTemplates map[string]*OutputTemplate
+}
+
+// matchOptions controls how extracted values are located in the text.
+type matchOptions struct {
+	fallbackContextFromText bool     // Use the text around a fallback match as its context text
+	workers                 int      // Entities searched concurrently
+	collapseWhitespace      bool     // Match against a copy with whitespace runs collapsed
+	normalizePunctuation    bool     // Match against a copy with smart quotes, dashes and ellipses in ASCII
+	shortValueLength        int      // Values up to this many runes must match whole words within the context
+	minContextSimilarity    float64  // Context matches agreeing less with the LLM context are rejected
+	sentenceFallbackContext bool     // Fallback contexts span the enclosing sentence instead of ±20 bytes
+	nearestContextOnly      bool     // Use only the context match nearest the value's first direct match
+	flexibleThousands       bool     // Match number values regardless of thousands separators
+	units                   unitList // Split off number values when non-empty
+	maxContextMatches       int      // More context matches mark an occurrence ambiguous, 0 disables
+	maxContextLength        int      // Longer LLM contexts are trimmed before searching, 0 disables
+	valueCleaning           valueCleaning
+}
+
+// SectionMarkers returns the configured section headers, used to group
+// results by section when a request names none.
+func (s *ExtractorService) SectionMarkers() []SectionMarker {
+	return slices.Clone(s.sectionMarkers)
 }
 
 func NewExtractorService(cfg *config.Config, logger *zap.Logger, projectRoot string) (*ExtractorService, error) {
 	llmURL := cfg.LLM.ServerURL
-	// Ensure schema dir path is absolute
-	schemasDir := cfg.LLM.SchemaDir
-	if !filepath.IsAbs(schemasDir) {
-		schemasDir = filepath.Join(projectRoot, schemasDir)
+	// Ensure schema dir paths are absolute
+	schemasDirs := make([]string, 0, len(cfg.LLM.SchemaDirs))
+	for _, dir := range cfg.LLM.SchemaDirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(projectRoot, dir)
+		}
+		schemasDirs = append(schemasDirs, dir)
 	}
-	logger.Info("Resolved schema directory", zap.String("path", schemasDir))
+	logger.Info("Resolved schema directories", zap.Strings("paths", schemasDirs))
 
 	_, err := url.ParseRequestURI(llmURL)
 	if err != nil {
 		logger.Error("Invalid LLM Server URL configured", zap.String("url", llmURL), zap.Error(err))
 		return nil, fmt.Errorf("invalid llm server url: %w", err)
 	}
+	allowedLLMServers := make(map[string]bool, len(cfg.LLM.AllowedServers))
+	for _, server := range cfg.LLM.AllowedServers {
+		if _, err := url.ParseRequestURI(server); err != nil {
+			logger.Error("Invalid allowed LLM server configured", zap.String("url", server), zap.Error(err))
+			return nil, fmt.Errorf("invalid allowed llm server url: %w", err)
+		}
+		allowedLLMServers[server] = true
+	}
 
 	// Load Schemas AND their file paths
-	schemas, schemaNames, schemaFiles, err := loadSchemasFromDir(schemasDir, logger) // Modified return
+	schemas, schemaNames, schemaFiles, err := loadSchemasFromDirs(schemasDirs, cfg.LLM.StrictSchemas, logger)
 	if err != nil {
-		logger.Error("Failed to load schemas", zap.String("directory", schemasDir), zap.Error(err))
-		return nil, fmt.Errorf("failed to load schemas from %s: %w", schemasDir, err)
+		logger.Error("Failed to load schemas", zap.Strings("directories", schemasDirs), zap.Error(err))
+		return nil, fmt.Errorf("failed to load schemas: %w", err)
 	}
 	if len(schemas) == 0 {
 		// This might be acceptable, but log a warning
-		logger.Warn("No schemas found or loaded from directory", zap.String("directory", schemasDir))
+		logger.Warn("No schemas found or loaded from directories", zap.Strings("directories", schemasDirs))
 		// return nil, fmt.Errorf("no schemas found in directory: %s", schemasDir) // Changed to Warning
 	} else {
 		logger.Info("Successfully loaded schemas", zap.Strings("names", schemaNames))
 	}
 
-	return &ExtractorService{
-		llmServerURL: llmURL,
+	hiddenSchemas := hiddenSchemaSet(schemaNames, cfg.LLM.AllowedSchemas, cfg.LLM.DeniedSchemas)
+	if len(hiddenSchemas) > 0 {
+		logger.Info("Hiding schemas from callers", zap.Strings("names", slices.Sorted(maps.Keys(hiddenSchemas))))
+	}
+
+	for _, schemaName := range cfg.LLM.DefaultSchemas {
+		if _, exists := schemas[schemaName]; !exists {
+			logger.Error("Configured default schema not found", zap.String("schema", schemaName), zap.Strings("available", schemaNames))
+			return nil, fmt.Errorf("default schema '%s' not found", schemaName)
+		}
+		if hiddenSchemas[schemaName] {
+			logger.Error("Configured default schema is hidden", zap.String("schema", schemaName))
+			return nil, fmt.Errorf("default schema '%s' is hidden by allowed_schemas/denied_schemas", schemaName)
+		}
+	}
+	// Requests without schema names get the defaults, so they must pass the same cap
+	if cfg.LLM.MaxRequestSchemas > 0 && len(cfg.LLM.DefaultSchemas) > cfg.LLM.MaxRequestSchemas {
+		logger.Error("Configured default schemas exceed max_request_schemas",
+			zap.Strings("default_schemas", cfg.LLM.DefaultSchemas), zap.Int("max_request_schemas", cfg.LLM.MaxRequestSchemas))
+		return nil, fmt.Errorf("%d default schemas exceed max_request_schemas of %d", len(cfg.LLM.DefaultSchemas), cfg.LLM.MaxRequestSchemas)
+	}
+
+	nestingDelimiter := cfg.LLM.NestingDelimiter
+	if nestingDelimiter == "" {
+		nestingDelimiter = "."
+	}
+	if strings.ContainsAny(nestingDelimiter, "%\"\\") || strings.TrimSpace(nestingDelimiter) != nestingDelimiter {
+		logger.Error("Invalid nesting delimiter configured", zap.String("delimiter", nestingDelimiter))
+		return nil, fmt.Errorf("invalid nesting delimiter %q", nestingDelimiter)
+	}
+
+	for _, schemaName := range schemaNames {
+		problems := validateSchemaStructure(schemas[schemaName], nestingDelimiter)
+		if len(problems) == 0 {
+			continue
+		}
+		if cfg.LLM.StrictSchemas {
+			logger.Error("Invalid schema structure", zap.String("schema", schemaName), zap.Strings("problems", problems))
+			return nil, fmt.Errorf("schema '%s' is invalid: %s", schemaName, strings.Join(problems, "; "))
+		}
+		logger.Warn("Schema has structural problems", zap.String("schema", schemaName), zap.Strings("problems", problems))
+	}
+
+	maxResponseBytes := cfg.LLM.MaxResponseBytes
+	if maxResponseBytes <= 0 {
+		maxResponseBytes = 64 * 1024 * 1024
+	}
+
+	postProcessors, err := resolvePostProcessors(cfg.PostProcessing.Processors)
+	if err != nil {
+		logger.Error("Invalid post-processor configured", zap.Strings("post_processing.processors", cfg.PostProcessing.Processors), zap.Error(err))
+		return nil, err
+	}
+
+	if err := validateEntityNameMode(cfg.Matching.EntityNameValidation); err != nil {
+		logger.Error("Invalid entity name validation configured", zap.Error(err))
+		return nil, err
+	}
+
+	if err := validateEntityKeyCase(cfg.Matching.EntityKeyCase); err != nil {
+		logger.Error("Invalid entity key case configured", zap.Error(err))
+		return nil, err
+	}
+
+	if err := validatePromptStyle(cfg.LLM.PromptStyle); err != nil {
+		logger.Error("Invalid prompt style configured", zap.Error(err))
+		return nil, err
+	}
+
+	sectionMarkers := make([]SectionMarker, 0, len(cfg.Sections.Markers))
+	for _, marker := range cfg.Sections.Markers {
+		sectionMarkers = append(sectionMarkers, SectionMarker{Name: marker.Name, Pattern: marker.Pattern, Regex: marker.Regex})
+	}
+	if err := (&SectionOptions{Markers: sectionMarkers}).Validate(); err != nil {
+		logger.Error("Invalid section markers configured", zap.Error(err))
+		return nil, err
+	}
+
+	if err := validateModelConflictPolicy(cfg.LLM.ModelConflicts); err != nil {
+		logger.Error("Invalid model conflict policy configured", zap.Error(err))
+		return nil, err
+	}
+	if cfg.LLM.LowYieldRatio < 0 || cfg.LLM.LowYieldRatio > 1 {
+		logger.Error("Invalid low-yield ratio configured", zap.Float64("low_yield_ratio", cfg.LLM.LowYieldRatio))
+		return nil, fmt.Errorf("invalid low_yield_ratio %v, expected a share between 0 and 1", cfg.LLM.LowYieldRatio)
+	}
+	if err := validateInvalidUTF8Policy(cfg.Input.InvalidUTF8); err != nil {
+		logger.Error("Invalid UTF-8 policy configured", zap.Error(err))
+		return nil, err
+	}
+
+	promptDir := cfg.LLM.PromptDir
+	if promptDir != "" && !filepath.IsAbs(promptDir) {
+		promptDir = filepath.Join(projectRoot, promptDir)
+	}
+	promptTemplates, err := loadPromptTemplates(promptDir)
+	if err != nil {
+		logger.Error("Failed to load prompt templates", zap.String("dir", promptDir), zap.Error(err))
+		return nil, err
+	}
+	promptLang := cfg.LLM.PromptLang
+	if promptLang == "" {
+		promptLang = PromptLangEnglish
+	}
+	if _, ok := promptTemplates[promptLang]; !ok && promptLang != PromptLangEnglish {
+		logger.Error("No prompt template for the configured language", zap.String("prompt_lang", promptLang), zap.String("dir", promptDir))
+		return nil, fmt.Errorf("no prompt template for language %q in %q", promptLang, promptDir)
+	}
+
+	outputTemplateDir := cfg.OutputTemplates.Dir
+	if outputTemplateDir != "" && !filepath.IsAbs(outputTemplateDir) {
+		outputTemplateDir = filepath.Join(projectRoot, outputTemplateDir)
+	}
+	outputTemplates, err := loadOutputTemplates(outputTemplateDir)
+	if err != nil {
+		logger.Error("Failed to load output templates", zap.String("dir", outputTemplateDir), zap.Error(err))
+		return nil, err
+	}
+
+	contentPaths := cfg.LLM.ContentPaths
+	if len(contentPaths) == 0 {
+		contentPaths = []string{"content"} // llama.cpp /completion
+	}
+
+	service := &ExtractorService{
+		llmServerURL:      llmURL,
+		allowedLLMServers: allowedLLMServers,
 		httpClient: &http.Client{
 			Timeout: 5 * time.Minute, // Keep existing timeout
 		},
-		logger:      logger.Named("extractor"),
-		Schemas:     schemas,
-		schemaNames: schemaNames,
-		SchemaFiles: schemaFiles, // Store file paths
-	}, nil
+		logger:               logger.Named("extractor"),
+		Schemas:              schemas,
+		schemaNames:          schemaNames,
+		SchemaFiles:          schemaFiles, // Store file paths
+		maxExamples:          cfg.LLM.MaxExamples,
+		injectReferenceDate:  cfg.LLM.InjectReferenceDate,
+		resolveRelativeDates: cfg.LLM.ResolveRelativeDates,
+		match: matchOptions{
+			fallbackContextFromText: cfg.Matching.FallbackContextFromText,
+			workers:                 cfg.Matching.Workers,
+			collapseWhitespace:      cfg.Matching.CollapseWhitespace,
+			normalizePunctuation:    cfg.Matching.NormalizePunctuation,
+			shortValueLength:        cfg.Matching.ShortValueLength,
+			minContextSimilarity:    cfg.Matching.MinContextSimilarity,
+			sentenceFallbackContext: cfg.Matching.SentenceFallbackContext,
+			nearestContextOnly:      cfg.Matching.NearestContextOnly,
+			flexibleThousands:       cfg.Matching.FlexibleThousandsSeparators,
+			maxContextMatches:       cfg.Matching.MaxContextMatches,
+			maxContextLength:        cfg.Matching.MaxContextLength,
+			valueCleaning: valueCleaning{
+				trim:          cfg.Matching.TrimValues,
+				stripTrailing: cfg.Matching.StripTrailingPunctuation,
+				keepCleaned:   cfg.Matching.KeepCleanedValues,
+			},
+		},
+		regexes:              newRegexCache(cfg.Matching.RegexCacheSize),
+		searchSlots:          newSearchSlots(cfg.Matching.MaxTotalWorkers),
+		booleanVocabulary:    newBooleanVocabulary(cfg.Matching.BooleanTrueValues, cfg.Matching.BooleanFalseValues),
+		contentPaths:         contentPaths,
+		defaultSchemas:       cfg.LLM.DefaultSchemas,
+		hiddenSchemas:        hiddenSchemas,
+		repairJSON:           cfg.LLM.RepairJSON,
+		maxReprompts:         cfg.LLM.MaxReprompts,
+		emptyContentRetries:  cfg.LLM.EmptyContentRetries,
+		entityNameValidation: cfg.Matching.EntityNameValidation,
+		entityKeyCase:        cfg.Matching.EntityKeyCase,
+		promptStyle:          cfg.LLM.PromptStyle,
+		promptLang:           promptLang,
+		promptTemplates:      promptTemplates,
+		outputTemplates:      outputTemplates,
+		model:                cfg.LLM.Model,
+		modelConflicts:       cfg.LLM.ModelConflicts,
+		invalidUTF8:          cfg.Input.InvalidUTF8,
+		hashText:             cfg.Input.HashText,
+		lowYieldRatio:        cfg.LLM.LowYieldRatio,
+		lowYieldTemperature:  cfg.LLM.LowYieldTemperature,
+		nestingDelimiter:     nestingDelimiter,
+		maxResponseBytes:     maxResponseBytes,
+		contextSize:          cfg.LLM.ContextSize,
+		maxOutputTokens:      cfg.LLM.MaxOutputTokens,
+		responseReserve:      cfg.LLM.ResponseReserveTokens,
+		maxRequestSchemas:    cfg.LLM.MaxRequestSchemas,
+		postProcessors:       postProcessors,
+		postProcessorNames:   slices.Clone(cfg.PostProcessing.Processors),
+		header:               newHeaderOptions(cfg.Header.Keys, cfg.Header.MaxLines),
+		sectionMarkers:       sectionMarkers,
+	}
+
+	if cfg.Matching.SplitUnits {
+		service.match.units = newUnitList(cfg.Matching.Units)
+	}
+	service.health.polling = cfg.LLM.HealthCheckIntervalSeconds > 0
+	llmHealthGauge.Set(1)
+
+	if cfg.LLM.WarmUp {
+		service.runWarmUp(time.Duration(cfg.LLM.WarmUpTimeoutSeconds) * time.Second)
+	}
+	if cfg.LLM.HealthCheckIntervalSeconds > 0 {
+		go service.pollLLMHealth(time.Duration(cfg.LLM.HealthCheckIntervalSeconds)*time.Second,
+			time.Duration(cfg.LLM.HealthCheckTimeoutSeconds)*time.Second)
+	}
+
+	return service, nil
 }
 
 // ExtractEntities processes text content using the specified schema
@@ -127,252 +534,835 @@ func (s *ExtractorService) ExtractEntities(schemaName string, content string) (m
 }
 
 // GetAvailableSchemas returns the list of loaded schema names //TODO evaluate if we need this
+// Schemas hidden by the allowed/denied schema settings are left out.
 func (s *ExtractorService) GetAvailableSchemas() []string {
 	// Return a copy to prevent external modification
-	names := make([]string, len(s.schemaNames))
-	copy(names, s.schemaNames)
+	names := make([]string, 0, len(s.schemaNames))
+	for _, name := range s.schemaNames {
+		if !s.hiddenSchemas[name] {
+			names = append(names, name)
+		}
+	}
 	return names
 }
 
+// IsSchemaHidden reports whether a loaded schema is hidden from callers.
+func (s *ExtractorService) IsSchemaHidden(name string) bool {
+	return s.hiddenSchemas[name]
+}
+
+// hiddenSchemaSet returns the loaded schemas that are not in a non-empty
+// allow list or that are in the deny list.
+func hiddenSchemaSet(schemaNames, allowed, denied []string) map[string]bool {
+	hidden := make(map[string]bool)
+	for _, name := range schemaNames {
+		if (len(allowed) > 0 && !slices.Contains(allowed, name)) || slices.Contains(denied, name) {
+			hidden[name] = true
+		}
+	}
+	return hidden
+}
+
+// NestingDelimiter returns the separator used in nested entity names.
+func (s *ExtractorService) NestingDelimiter() string {
+	return s.nestingDelimiter
+}
+
+// MaxRequestSchemas returns how many schema names one request may combine,
+// 0 for no limit.
+func (s *ExtractorService) MaxRequestSchemas() int {
+	return s.maxRequestSchemas
+}
+
+// ValidateLLMServer rejects LLM server URLs a request may not select: only
+// the configured server and those in llm.allowed_servers are accepted.
+func (s *ExtractorService) ValidateLLMServer(serverURL string) error {
+	if serverURL != s.llmServerURL && !s.allowedLLMServers[serverURL] {
+		return fmt.Errorf("llm server %q is not allowed", serverURL)
+	}
+	return nil
+}
+
+// serverFor returns the LLM server the request should be sent to.
+func (s *ExtractorService) serverFor(opts ProcessOptions) string {
+	if opts.LLMServer != "" {
+		return opts.LLMServer
+	}
+	return s.llmServerURL
+}
+
+// DefaultSchemas returns the schemas to use when a request names none.
+func (s *ExtractorService) DefaultSchemas() []string {
+	return slices.Clone(s.defaultSchemas)
+}
+
 // ProcessText orchestrates the extraction process for a given text and schema.
-func (s *ExtractorService) ProcessText(schemaNames []string, text string) (*ExtractionOutput, error) {
+// Cancelling ctx aborts the in-flight LLM call.
+func (s *ExtractorService) ProcessText(ctx context.Context, schemaNames []string, text string, opts ProcessOptions) (*ExtractionOutput, error) {
+	output, err := s.processText(ctx, schemaNames, text, opts)
+	if err != nil {
+		return nil, err
+	}
+	if output, err = s.retryLowYield(ctx, schemaNames, text, opts, output); err != nil {
+		return nil, err
+	}
+	recordMatches(output.Metadata.Matches)
+	return output, nil
+}
+
+// processText runs one attempt of ProcessText.
+func (s *ExtractorService) processText(ctx context.Context, schemaNames []string, text string, opts ProcessOptions) (*ExtractionOutput, error) {
 	s.logger.Info("Starting extraction process",
 		zap.Strings("schemaName", schemaNames),
 		zap.Int("textLength", len(text)),
 	)
 
 	// Step 0: Normalize text
+	// Decode to UTF-8 first so rune positions are valid
+	text, encoding, invalidUTF8, err := decodeInput([]byte(text), s.invalidUTF8)
+	if err != nil {
+		s.logger.Warn("Rejected text that is not valid UTF-8")
+		return nil, err
+	}
+	if invalidUTF8 != "" {
+		s.logger.Info("Text is not valid UTF-8", zap.String("policy", invalidUTF8), zap.String("encoding", encoding))
+	} else if encoding != EncodingUTF8 {
+		s.logger.Info("Decoded text to UTF-8", zap.String("encoding", encoding))
+	}
 	// Replace Windows CRLF and standalone CR with Unix LF for consistency
-	normalizedText := strings.ReplaceAll(text, "\r\n", "\n")
-	normalizedText = strings.ReplaceAll(normalizedText, "\r", "\n")
+	lineEndings := normalizeLineEndings(text)
+	normalizedText := lineEndings.text
 	s.logger.Debug("Text normalizedoy", zap.Int("normalizedLength", len(normalizedText)))
+	textHash := ""
+	if s.hashText {
+		textHash = HashText(normalizedText)
+	}
+
+	if opts.Split != nil {
+		if opts.OriginalPositions {
+			return nil, fmt.Errorf("a document separator cannot be combined with original positions")
+		}
+		if opts.Previous != nil {
+			return nil, fmt.Errorf("a document separator cannot be combined with a previous result")
+		}
+		output, err := s.processDocuments(ctx, schemaNames, normalizedText, opts)
+		if err != nil {
+			return nil, err
+		}
+		output.Metadata.Encoding = encoding
+		output.Metadata.InvalidUTF8 = invalidUTF8
+		output.Metadata.TextHash = textHash
+		return output, nil
+	}
 
-	combinedSchema, err := s.CombineSchemas(schemaNames)
+	combinedSchema, mergeReport, err := s.combineRequestSchemas(schemaNames, opts.InlineSchema)
 	if err != nil {
 		s.logger.Error("Failed to combine schemas", zap.Strings("names", schemaNames), zap.Error(err))
 		return nil, fmt.Errorf("failed during schema combination: %w", err)
 	}
+	if opts.InlineSchema != nil && opts.Sections != nil && len(opts.Sections.SchemaSections) > 0 {
+		return nil, fmt.Errorf("sections cannot be combined with an inline schema")
+	}
+	if opts.Previous != nil && opts.Sections != nil && len(opts.Sections.SchemaSections) > 0 {
+		return nil, fmt.Errorf("sections cannot be combined with a previous result")
+	}
+	if opts.ParseHeader && opts.Sections != nil && len(opts.Sections.SchemaSections) > 0 {
+		return nil, fmt.Errorf("a header cannot be parsed when extracting by sections")
+	}
+	if opts.ParseHeader && opts.Previous != nil {
+		return nil, fmt.Errorf("a header cannot be parsed when extracting against a previous result")
+	}
 
+	if opts.model, err = s.resolveModel(schemaNames, opts.InlineSchema); err != nil {
+		s.logger.Warn("Rejected schemas preferring different models", zap.Strings("names", schemaNames), zap.Error(err))
+		return nil, err
+	}
+
+	if opts.ReferenceDate == nil && s.injectReferenceDate {
+		today := time.Now()
+		opts.ReferenceDate = &today
+	}
+
+	var documentMetadata DocumentMetadata
+	bodyStart := 0
+	if opts.ParseHeader {
+		documentMetadata, bodyStart = s.header.parseHeader(normalizedText)
+		s.logger.Debug("Parsed document header", zap.Int("keys", len(documentMetadata)), zap.Int("bodyStart", bodyStart))
+	}
+
+	var finalOutput *ExtractionOutput
+	if opts.Sections != nil && len(opts.Sections.SchemaSections) > 0 {
+		finalOutput, err = s.extractSections(ctx, schemaNames, normalizedText, opts)
+	} else if opts.Previous != nil {
+		finalOutput, err = s.extractIncremental(ctx, combinedSchema, normalizedText, opts)
+	} else if bodyStart > 0 {
+		// The header was read above; only the body goes to the LLM
+		body := excerpt(normalizedText, []textRange{{bodyStart, len(normalizedText)}})
+		finalOutput, err = s.extractSegment(ctx, combinedSchema, body.text, opts)
+		if err == nil {
+			remapOccurrences(finalOutput, body, normalizedText)
+			finalOutput.Text = normalizedText
+		}
+	} else {
+		finalOutput, err = s.extractSegment(ctx, combinedSchema, normalizedText, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	annotateSchemaSources(finalOutput, mergeReport.Sources, s.nestingDelimiter)
+	if s.entityKeyCase != "" {
+		canonicalizeEntityKeys(finalOutput, s.entityKeyCase)
+	}
+
+	finalOutput.Metadata.PromptVersion = s.promptVersion(s.promptLangFor(opts))
+	finalOutput.Metadata.Model = opts.model
+	if schemaHash, err := HashSchema(combinedSchema); err == nil {
+		finalOutput.Metadata.SchemaHash = schemaHash
+	} else {
+		s.logger.Warn("Failed to hash combined schema", zap.Error(err))
+	}
+
+	if err := s.runPostProcessors(finalOutput); err != nil {
+		s.logger.Error("Post-processing failed", zap.Error(err))
+		return nil, fmt.Errorf("failed during post-processing: %w", err)
+	}
+	if opts.MinConfidence > 0 {
+		finalOutput.Metadata.LowConfidenceDropped = filterByConfidence(finalOutput, opts.MinConfidence)
+		if finalOutput.Metadata.LowConfidenceDropped > 0 {
+			s.logger.Info("Dropped low-confidence occurrences",
+				zap.Int("dropped", finalOutput.Metadata.LowConfidenceDropped),
+				zap.Float64("minConfidence", opts.MinConfidence),
+			)
+		}
+	}
+
+	finalOutput.Metadata.Encoding = encoding
+	finalOutput.Metadata.InvalidUTF8 = invalidUTF8
+	finalOutput.Metadata.TextHash = textHash
+	finalOutput.DocumentMetadata = documentMetadata
+	if opts.ValueTypes {
+		annotateValueTypes(finalOutput)
+	}
+	countMatches(finalOutput)
+	if ambiguous := ambiguousContexts(finalOutput); len(ambiguous) > 0 {
+		s.logger.Warn("Extracted occurrences with ambiguous contexts", zap.Int("count", len(ambiguous)))
+		finalOutput.Metadata.AmbiguousContexts = ambiguous
+	}
+	tracks := AssignTracks(finalOutput)
+	s.logger.Debug("Assigned highlight tracks", zap.Int("tracks", tracks))
+	if s.resolveRelativeDates && opts.ReferenceDate != nil {
+		resolveRelativeDates(finalOutput, *opts.ReferenceDate)
+	}
+	if opts.OriginalPositions {
+		remapOccurrences(finalOutput, lineEndings, text)
+	}
+
+	s.logger.Info("Extraction process completed successfully",
+		zap.Strings("schemaName", schemaNames),
+		zap.Int("finalEntityCount", len(finalOutput.Entities)), // Count top-level entities
+	)
+	return finalOutput, nil
+}
+
+// extractSegment runs one prompt/LLM/position-finding pass of the schema over
+// text and returns the located entities with the LLM metadata filled in.
+func (s *ExtractorService) extractSegment(ctx context.Context, schema Schema, text string, opts ProcessOptions) (*ExtractionOutput, error) {
 	// Step 1: Format the prompt
-	prompt, err := s.formatExtractionPrompt(combinedSchema, normalizedText)
+	prompt, err := s.formatExtractionPrompt(schema, text, opts)
 	if err != nil {
 		// Error already logged in formatExtractionPrompt
 		return nil, fmt.Errorf("failed during prompt formatting: %w", err)
 	}
+	s.logger.Debug("Formatted extraction prompt", zap.String("prompt", promptUserContent(prompt)))
 
-	// Step 2: Call the LLM
-	llmResponseString, err := s.callLLM(prompt)
+	// Steps 2 and 3: Call the LLM and parse its JSON response, repairing or
+	// re-prompting as configured
+	llmResponseString, rawExtraction, llmResponse, attempts, err := s.completeExtraction(ctx, s.serverFor(opts), opts.model, opts.temperature, prompt)
 	if err != nil {
-		// Error already logged in callLLM
-		return nil, fmt.Errorf("failed during LLM call: %w", err)
+		// Error already logged in callLLM/parseLLMResponse
+		return nil, err
+	}
+	if err := s.expandTableRows(llmResponseString, rawExtraction, tableColumns(schema, s.nestingDelimiter)); err != nil {
+		s.logger.Error("Failed to expand table rows", zap.Error(err))
+		return nil, fmt.Errorf("failed during table row expansion: %w", err)
+	}
+	var unknownEntities []string
+	if s.entityNameValidation != "" {
+		unknownEntities = validateEntityNames(rawExtraction, schema, s.nestingDelimiter, s.entityNameValidation == entityNamesStrict)
+		if len(unknownEntities) > 0 {
+			s.logger.Warn("LLM returned entities not in the schema",
+				zap.Strings("entities", unknownEntities),
+				zap.Bool("dropped", s.entityNameValidation == entityNamesStrict))
+		}
 	}
-	if llmResponseString == "" {
-		s.logger.Error("LLM call returned an empty response string")
-		return nil, fmt.Errorf("LLM call returned an empty response")
+	if coerced := coerceBooleans(rawExtraction, booleanEntities(schema, s.nestingDelimiter, s.booleanVocabulary)); coerced > 0 {
+		s.logger.Debug("Coerced boolean values", zap.Int("count", coerced))
 	}
 
-	// Step 3: Parse the LLM's JSON response
-	rawExtraction, err := s.parseLLMResponse(llmResponseString)
-	if err != nil {
-		// Error already logged in parseLLMResponse
-		return nil, fmt.Errorf("failed during LLM response parsing: %w", err)
+	contextEntities := contextHighlightEntities(schema, s.nestingDelimiter)
+	if marked := markContextHighlights(rawExtraction, contextEntities, opts.HighlightContextWhenEmpty); marked > 0 {
+		s.logger.Debug("Highlighting context for empty values", zap.Int("count", marked))
+	}
+	if s.match.flexibleThousands {
+		if marked := markNumericValues(rawExtraction, numberEntities(schema, s.nestingDelimiter)); marked > 0 {
+			s.logger.Debug("Matching number values with flexible separators", zap.Int("count", marked))
+		}
+	}
+	if marked := markSearchScopes(rawExtraction, searchScopeEntities(schema, s.nestingDelimiter)); marked > 0 {
+		s.logger.Debug("Limiting search scope of occurrences", zap.Int("count", marked))
 	}
 
 	// Step 4: Find entity positions
-	finalOutput, err := s.findEntityPositions(normalizedText, rawExtraction)
-	if err != nil || finalOutput == nil {
+	output, err := s.findEntityPositions(text, rawExtraction)
+	if err != nil || output == nil {
 		// Error potentially logged in findEntityPositions, but add context here
 		s.logger.Error("Failed during entity position finding", zap.Error(err))
 		return nil, fmt.Errorf("failed during position finding: %w", err)
 	}
 
-	s.logger.Info("Extraction process completed successfully",
-		zap.Strings("schemaName", schemaNames),
-		zap.Int("finalEntityCount", len(finalOutput.Entities)), // Count top-level entities
-	)
-	return finalOutput, nil
+	if len(s.match.units) > 0 {
+		if split := s.match.units.splitUnits(output, numberEntities(schema, s.nestingDelimiter)); split > 0 {
+			s.logger.Debug("Split units off number values", zap.Int("count", split))
+		}
+	}
+
+	output.Metadata.Parse = attempts
+	output.Metadata.UnknownEntities = unknownEntities
+	if opts.Diagnostics {
+		output.Diagnostics = diagnoseEntities(schema, s.nestingDelimiter, rawExtraction, output)
+	}
+	if opts.Debug {
+		schemaJSON, err := promptSchemaJSON(schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record prompt schema: %w", err)
+		}
+		output.Debug = &ExtractionDebug{
+			PromptSchemas: []json.RawMessage{schemaJSON},
+			LLMResponses:  []string{llmResponseString},
+			Prompts:       []string{promptUserContent(prompt)},
+		}
+	}
+	if timings, err := llmResponse.ParseTimings(); err == nil {
+		output.Metadata.Timings = timings
+	}
+	output.Metadata.TokenUsage = TokenUsage{
+		PromptTokens:    estimateTokens(prompt),
+		TokensEvaluated: llmResponse.TokensEvaluated,
+		TokensPredicted: llmResponse.TokensPredicted,
+		Total:           llmResponse.TokensEvaluated + llmResponse.TokensPredicted,
+	}
+	return output, nil
+}
+
+// completeExtraction calls the LLM and parses its response. An empty response
+// is re-requested up to emptyContentRetries times. An unparseable response is
+// first repaired (if enabled), then re-requested up to maxReprompts times.
+// Token counts of the returned response cover all calls.
+func (s *ExtractorService) completeExtraction(ctx context.Context, serverURL, model string, temperature *float64, prompt string) (string, RawLLMExtraction, *LLMResponse, ParseAttempts, error) {
+	attempts := ParseAttempts{}
+	tokensEvaluated, tokensPredicted := 0, 0
+	for {
+		llmResponseString, llmResponse, err := s.callLLM(ctx, serverURL, model, temperature, prompt)
+		if llmResponse != nil { // Including responses without content
+			tokensEvaluated += llmResponse.TokensEvaluated
+			tokensPredicted += llmResponse.TokensPredicted
+		}
+		if errors.Is(err, errEmptyLLMContent) && attempts.EmptyRetries < s.emptyContentRetries {
+			attempts.EmptyRetries++
+			s.logger.Warn("Retrying LLM call after empty content", zap.Int("retry", attempts.EmptyRetries), zap.Error(err))
+			continue
+		}
+		if err != nil {
+			return "", nil, nil, attempts, fmt.Errorf("failed during LLM call: %w", err)
+		}
+		if llmResponseString == "" {
+			s.logger.Error("LLM call returned an empty response string")
+			return "", nil, nil, attempts, fmt.Errorf("LLM call returned an empty response")
+		}
+		llmResponse.TokensEvaluated, llmResponse.TokensPredicted = tokensEvaluated, tokensPredicted
+
+		rawExtraction, parseErr := s.parseLLMResponse(llmResponseString)
+		if parseErr == nil {
+			attempts.Outcome = parseOutcomeClean
+			return llmResponseString, rawExtraction, llmResponse, attempts, nil
+		}
+		if s.repairJSON {
+			if repaired, changed := repairJSON(llmResponseString); changed {
+				if rawExtraction, err := s.parseLLMResponse(repaired); err == nil {
+					s.logger.Warn("Parsed LLM response after repairing its JSON", zap.Error(parseErr))
+					attempts.Repairs++ // Only repairs that made the response parse
+					attempts.Outcome = parseOutcomeRepaired
+					return repaired, rawExtraction, llmResponse, attempts, nil
+				}
+			}
+		}
+		if attempts.Reprompts >= s.maxReprompts {
+			return "", nil, nil, attempts, fmt.Errorf("failed during LLM response parsing: %w", parseErr)
+		}
+		attempts.Reprompts++
+		s.logger.Warn("Re-prompting LLM after unparseable response", zap.Int("reprompt", attempts.Reprompts), zap.Error(parseErr))
+	}
 }
 
 // findEntityPositions locates the extracted values and contexts in the text.
+// Entities are searched concurrently by a bounded pool of workers; each entity
+// only produces its own occurrences, so the output does not depend on scheduling
+// or on how many workers the shared limit allows.
 func (s *ExtractorService) findEntityPositions(normalizedText string, rawExtraction RawLLMExtraction) (*ExtractionOutput, error) {
-	finalOutput := make(map[string][]EntityOccurrence)
-	textLength := len(normalizedText) // Cache text length for bounds checking
-
 	s.logger.Info("Starting position finding process")
 
+	// Sorted names give a stable work order and merge order
+	entityNames := make([]string, 0, len(rawExtraction))
 	for entityName, occurrences := range rawExtraction {
 		if len(occurrences) == 0 {
 			continue // Skip if LLM returned empty list for this entity
 		}
+		entityNames = append(entityNames, entityName)
+	}
+	sort.Strings(entityNames)
 
-		// Ensure slice exists in the final output map
-		if _, exists := finalOutput[entityName]; !exists {
-			finalOutput[entityName] = []EntityOccurrence{}
+	search := identityMapping(normalizedText)
+	if s.match.normalizePunctuation {
+		search = search.then(normalizePunctuation)
+	}
+	if s.match.collapseWhitespace {
+		search = search.then(collapseWhitespace)
+	}
+
+	located := make([][]EntityOccurrence, len(entityNames))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := range max(1, min(s.match.workers, len(entityNames))) {
+		extra := worker > 0
+		if extra && !s.acquireSearchSlot() {
+			break // The first worker needs no slot, so every request makes progress
 		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if extra {
+				defer s.releaseSearchSlot()
+			}
+			for i := range jobs {
+				located[i] = s.locateEntity(normalizedText, search, entityNames[i], rawExtraction[entityNames[i]])
+			}
+		}()
+	}
+	for i := range entityNames {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 
-		for occIndex, occurrence := range occurrences {
-			// Handle potential nil values from JSON parsing (if LLM returns null)
-			if occurrence.Value == nil || occurrence.Context == "" {
-				s.logger.Warn("Skipping occurrence with nil value or empty context", zap.String("entityName", entityName))
-				continue
+	finalOutput := make(map[string][]EntityOccurrence, len(entityNames))
+	for i, entityName := range entityNames {
+		finalOutput[entityName] = located[i]
+	}
+
+	s.logger.Info("Finished position finding process")
+	return &ExtractionOutput{
+		Text:     normalizedText,
+		Entities: finalOutput,
+	}, nil
+}
+
+// locateEntity finds the positions of every occurrence the LLM returned for
+// one entity. It always returns a non-nil slice.
+// Searches run over the working copy in search; positions are mapped back to normalizedText.
+func (s *ExtractorService) locateEntity(normalizedText string, search textMapping, entityName string, occurrences []LLMOutputValueContext) []EntityOccurrence {
+	located := []EntityOccurrence{}
+	textLength := len(normalizedText) // Cache text length for bounds checking
+
+	for occIndex, occurrence := range occurrences {
+		if occurrence.HighlightContext {
+			// The context is the finding itself, so it is both value and highlight
+			occurrence.Value = occurrence.Context
+			occurrence.SearchValue = occurrence.Context
+		}
+
+		// Handle potential nil values from JSON parsing (if LLM returns null)
+		if occurrence.Value == nil || occurrence.Context == "" {
+			s.logger.Warn("Skipping occurrence with nil value or empty context", zap.String("entityName", entityName))
+			continue
+		}
+
+		// Convert value to string for searching. Handle different types.
+		var valueStr string
+		switch v := occurrence.Value.(type) {
+		case string:
+			valueStr = v
+		case float64: // Numbers are often parsed as float64 from JSON
+			// Check if it's actually an integer
+			if v == float64(int(v)) {
+				valueStr = fmt.Sprintf("%d", int(v))
+			} else {
+				valueStr = fmt.Sprintf("%f", v) // Or choose desired float format
 			}
+		case bool:
+			valueStr = fmt.Sprintf("%t", v)
+		default:
+			// Fallback for other types (like lists, though less common for direct search)
+			valueStr = fmt.Sprintf("%v", occurrence.Value)
+			s.logger.Debug("Converted non-standard value type to string for search",
+				zap.String("entityName", entityName),
+				zap.Any("originalValue", occurrence.Value),
+				zap.String("stringValue", valueStr),
+			)
+		}
 
-			// Convert value to string for searching. Handle different types.
-			var valueStr string
-			switch v := occurrence.Value.(type) {
-			case string:
-				valueStr = v
-			case float64: // Numbers are often parsed as float64 from JSON
-				// Check if it's actually an integer
-				if v == float64(int(v)) {
-					valueStr = fmt.Sprintf("%d", int(v))
-				} else {
-					valueStr = fmt.Sprintf("%f", v) // Or choose desired float format
+		if occurrence.SearchValue != "" {
+			valueStr = occurrence.SearchValue // Highlight the original phrase, not "true"/"false"
+		} else if _, isString := occurrence.Value.(string); isString && s.match.valueCleaning.enabled() {
+			if cleaned := s.match.valueCleaning.clean(valueStr); cleaned != valueStr {
+				valueStr = cleaned
+				if s.match.valueCleaning.keepCleaned {
+					occurrence.Value = cleaned
 				}
-			case bool:
-				valueStr = fmt.Sprintf("%t", v)
-			default:
-				// Fallback for other types (like lists, though less common for direct search)
-				valueStr = fmt.Sprintf("%v", occurrence.Value)
-				s.logger.Debug("Converted non-standard value type to string for search",
-					zap.String("entityName", entityName),
-					zap.Any("originalValue", occurrence.Value),
-					zap.String("stringValue", valueStr),
-				)
 			}
+		}
 
-			contextStr := occurrence.Context
+		contextStr := occurrence.Context
+		alternatives := distinctAlternatives(occurrence.Value, occurrence.Candidates)
 
-			// Skip empty strings which would cause issues with regex/search
-			if valueStr == "" || contextStr == "" {
-				s.logger.Warn("Skipping occurrence with empty value or context string after conversion", zap.String("entityName", entityName))
-				continue
+		// Skip empty strings which would cause issues with regex/search
+		if valueStr == "" || contextStr == "" {
+			s.logger.Warn("Skipping occurrence with empty value or context string after conversion", zap.String("entityName", entityName))
+			continue
+		}
+
+		if trimmed := trimContext(contextStr, valueStr, s.match.maxContextLength); trimmed != contextStr {
+			s.logger.Debug("Trimmed oversized context for matching",
+				zap.String("entityName", entityName),
+				zap.Int("contextLength", utf8.RuneCountInString(contextStr)),
+			)
+			contextStr = trimmed
+		}
+
+		// Search strings get the same normalization as the text being searched
+		searchContextStr, searchValueStr := search.normalize(contextStr), search.normalize(valueStr)
+		valuePattern := regexp.QuoteMeta(searchValueStr)
+		if occurrence.Numeric {
+			if pattern, ok := thousandsPattern(searchValueStr); ok {
+				valuePattern = pattern // Highlight whichever form the text uses
 			}
+		}
+
+		// 1. Find all occurrences of the context string using regex
+		foundInContext := false
+		contextRegexStr := `(?i)` + regexp.QuoteMeta(searchContextStr) // Case-insensitive search for context
+		contextRegex, err := s.regexes.compile(contextRegexStr)
+		if err != nil {
+			s.logger.Error("Failed to compile context regex, skipping occurrence",
+				zap.String("entityName", entityName),
+				zap.String("context", contextStr),
+				zap.Error(err),
+			)
+			continue
+		}
 
-			// 1. Find all occurrences of the context string using regex
-			foundInContext := false
-			contextRegexStr := `(?i)` + regexp.QuoteMeta(contextStr) // Case-insensitive search for context
-			contextRegex, err := regexp.Compile(contextRegexStr)
+		contextMatches := contextRegex.FindAllStringIndex(search.text, -1)
+		ambiguousMatches := 0 // Reported on the occurrences when the context is not unique enough
+		if s.match.maxContextMatches > 0 && len(contextMatches) > s.match.maxContextMatches {
+			ambiguousMatches = len(contextMatches)
+		}
+		// Every emitted match gets its own ID; the group ID ties together the
+		// matches that came from the same LLM occurrence
+		groupID := fmt.Sprintf("entity-%s-%d", entityName, occIndex)
+		matchIndex := 0
+
+		if occurrence.SearchScope == SearchScopeValue {
+			contextMatches = nil // Placed by the value alone below
+		}
+		if len(contextMatches) > 0 {
+			valueRegexStr := `(?i)` + valuePattern // Case-insensitive search for value
+			valueRegex, err := s.regexes.compile(valueRegexStr)
 			if err != nil {
-				s.logger.Error("Failed to compile context regex, skipping occurrence",
+				s.logger.Error("Failed to compile value regex, skipping context matches for this occurrence",
 					zap.String("entityName", entityName),
-					zap.String("context", contextStr),
+					zap.String("value", valueStr),
 					zap.Error(err),
 				)
-				continue
-			}
+				// Continue to fallback search below if value regex fails
+			} else {
+				if s.match.nearestContextOnly && len(contextMatches) > 1 {
+					// A repeated generic context would highlight every repetition;
+					// anchor on the one nearest the value's first direct match
+					if valueMatch := valueRegex.FindStringIndex(search.text); valueMatch != nil {
+						contextMatches = [][]int{nearestSpan(contextMatches, valueMatch)}
+					}
+				}
+				// For each context match, try to find the value *within* it
+				for _, contextMatch := range contextMatches {
+					contextByteStart, contextByteEnd := contextMatch[0], contextMatch[1] // BYTE indices of context
+					if s.match.minContextSimilarity > 0 {
+						if score := contextMatchSimilarity(search.text, contextByteStart, contextByteEnd, searchContextStr); score < s.match.minContextSimilarity {
+							s.logger.Debug("Rejected weak context match",
+								zap.String("entityName", entityName),
+								zap.String("context", contextStr),
+								zap.Float64("similarity", score),
+							)
+							continue
+						}
+					}
+					contextTextSpan := search.text[contextByteStart:contextByteEnd]
 
-			contextMatches := contextRegex.FindAllStringIndex(normalizedText, -1)
-			id := fmt.Sprintf("entity-%s-%d", entityName, occIndex)
-
-			if len(contextMatches) > 0 {
-				valueRegexStr := `(?i)` + regexp.QuoteMeta(valueStr) // Case-insensitive search for value
-				valueRegex, err := regexp.Compile(valueRegexStr)
-				if err != nil {
-					s.logger.Error("Failed to compile value regex, skipping context matches for this occurrence",
-						zap.String("entityName", entityName),
-						zap.String("value", valueStr),
-						zap.Error(err),
-					)
-					// Continue to fallback search below if value regex fails
-				} else {
-					// For each context match, try to find the value *within* it
-					for _, contextMatch := range contextMatches {
-						contextByteStart, contextByteEnd := contextMatch[0], contextMatch[1] // BYTE indices of context
-						contextTextSpan := normalizedText[contextByteStart:contextByteEnd]
-
-						// Find the first match of the value *within this specific context span*
-						valueMatchRelIndices := valueRegex.FindStringIndex(contextTextSpan) // Relative BYTE indices within context span
-
-						if valueMatchRelIndices != nil {
-							// Calculate absolute BYTE indices in normalizedText for the full value match
-							valueByteStart := contextByteStart + valueMatchRelIndices[0]
-							valueByteEnd := contextByteStart + valueMatchRelIndices[1] // End index for the full value (e.g., "98.7°F")
-
-							// --- Convert BYTE indices to RUNE indices ---
-							runeHighlightStart := byteIndexToRuneIndex(normalizedText, valueByteStart)
-							runeHighlightEnd := byteIndexToRuneIndex(normalizedText, valueByteEnd)
-							runeContextStart := byteIndexToRuneIndex(normalizedText, contextByteStart)
-							runeContextEnd := byteIndexToRuneIndex(normalizedText, contextByteEnd)
-							eo := EntityOccurrence{
-								Value:    occurrence.Value, // Store original typed value
-								Position: Position{Start: runeHighlightStart, End: runeHighlightEnd},
-								Context: Context{
-									Text:     contextStr, // Store the context string provided by LLM
-									Position: Position{Start: runeContextStart, End: runeContextEnd},
-								},
-								ID: id,
+					// Find the first match of the value *within this specific context span*
+					valueMatchRelIndices := valueRegex.FindStringIndex(contextTextSpan) // Relative BYTE indices within context span
+					if valueMatchRelIndices != nil && len([]rune(searchValueStr)) <= s.match.shortValueLength {
+						// Short values easily match inside longer words; take the first whole-word match instead
+						valueMatchRelIndices = nil
+						for _, candidate := range valueRegex.FindAllStringIndex(contextTextSpan, -1) {
+							if isWordBounded(search.text, contextByteStart+candidate[0], contextByteStart+candidate[1]) {
+								valueMatchRelIndices = candidate
+								break
 							}
-							finalOutput[entityName] = append(finalOutput[entityName], eo)
-							foundInContext = true
 						}
 					}
-				}
-			}
-
-			// 2. Fallback: If value wasn't found within any context match, search directly for the value
-			//    (Replicates Python fallback logic)
-			if !foundInContext && len(valueStr) > 1 { // Avoid searching for very short/common strings directly
-				valueRegexStr := `(?i)` + regexp.QuoteMeta(valueStr) // Case-insensitive search for value
-				valueRegex, err := regexp.Compile(valueRegexStr)
-				if err != nil {
-					s.logger.Error("Failed to compile value regex for fallback search",
-						zap.String("entityName", entityName),
-						zap.String("value", valueStr),
-						zap.Error(err),
-					)
-					continue // Skip this specific occurrence if regex fails
-				}
-
-				valueMatches := valueRegex.FindAllStringIndex(normalizedText, -1)
-				if len(valueMatches) > 0 {
-					s.logger.Debug("Value found via fallback search", zap.String("entityName", entityName), zap.String("value", valueStr))
-					for _, valueMatch := range valueMatches {
-						valueByteStart, valueByteEnd := valueMatch[0], valueMatch[1] // BYTE indices
 
-						approxContextByteStart := max(0, valueByteStart-20)
-						approxContextByteEnd := min(textLength, valueByteEnd+20)
-
-						// --- Convert Fallback BYTE indices to RUNE indices ---
-						runeHighlightStart := byteIndexToRuneIndex(normalizedText, valueByteStart)
-						runeHighlightEnd := byteIndexToRuneIndex(normalizedText, valueByteEnd)
-						runeApproxContextStart := byteIndexToRuneIndex(normalizedText, approxContextByteStart)
-						runeApproxContextEnd := byteIndexToRuneIndex(normalizedText, approxContextByteEnd)
+					if valueMatchRelIndices != nil {
+						// Calculate absolute BYTE indices in the searched text for the full value match
+						valueByteStart := contextByteStart + valueMatchRelIndices[0]
+						valueByteEnd := contextByteStart + valueMatchRelIndices[1] // End index for the full value (e.g., "98.7°F")
 
+						// --- Convert BYTE indices to RUNE indices in normalizedText ---
+						runeHighlightStart := byteIndexToRuneIndex(normalizedText, search.originalOffset(valueByteStart))
+						runeHighlightEnd := byteIndexToRuneIndex(normalizedText, search.originalOffset(valueByteEnd))
+						runeContextStart := byteIndexToRuneIndex(normalizedText, search.originalOffset(contextByteStart))
+						runeContextEnd := byteIndexToRuneIndex(normalizedText, search.originalOffset(contextByteEnd))
 						eo := EntityOccurrence{
-							Value:    occurrence.Value,
+							Value:    occurrence.Value, // Store original typed value
 							Position: Position{Start: runeHighlightStart, End: runeHighlightEnd},
 							Context: Context{
-								Text:     contextStr,                                                         // Still use context text from LLM
-								Position: Position{Start: runeApproxContextStart, End: runeApproxContextEnd}, // Use approximate position
+								Text:     contextStr, // Store the context string provided by LLM
+								Position: Position{Start: runeContextStart, End: runeContextEnd},
 							},
-							ID: id,
+							ValueInContext: valueInContext(Position{Start: runeHighlightStart, End: runeHighlightEnd}, Position{Start: runeContextStart, End: runeContextEnd}),
+							ID:             fmt.Sprintf("%s-%d", groupID, matchIndex),
+							GroupID:        groupID,
+							RowID:          occurrence.RowID,
+							Alternatives:   alternatives,
+							Confidence:     contextMatchConfidence,
+							ContextMatches: ambiguousMatches,
 						}
-						finalOutput[entityName] = append(finalOutput[entityName], eo)
+						located = append(located, eo)
+						matchIndex++
+						foundInContext = true
+					}
+				}
+			}
+		}
+
+		// 2. Fallback: If value wasn't found within any context match, search directly for the value
+		//    (Replicates Python fallback logic)
+		if !foundInContext && occurrence.SearchScope == SearchScopeContext {
+			s.logger.Debug("Value not found within context, fallback disabled by search scope",
+				zap.String("entityName", entityName),
+				zap.String("value", valueStr),
+				zap.String("context", contextStr),
+			)
+		} else if !foundInContext && len(valueStr) > 1 { // Avoid searching for very short/common strings directly
+			valueRegexStr := `(?i)` + valuePattern // Case-insensitive search for value
+			valueRegex, err := s.regexes.compile(valueRegexStr)
+			if err != nil {
+				s.logger.Error("Failed to compile value regex for fallback search",
+					zap.String("entityName", entityName),
+					zap.String("value", valueStr),
+					zap.Error(err),
+				)
+				continue // Skip this specific occurrence if regex fails
+			}
+
+			valueMatches := valueRegex.FindAllStringIndex(search.text, -1)
+			if len([]rune(searchValueStr)) <= s.match.shortValueLength {
+				// As inside contexts, a short value only counts as a whole word
+				valueMatches = slices.DeleteFunc(valueMatches, func(match []int) bool {
+					return !isWordBounded(search.text, match[0], match[1])
+				})
+			}
+			if len(valueMatches) > 0 {
+				s.logger.Debug("Value found via fallback search", zap.String("entityName", entityName), zap.String("value", valueStr))
+				for _, valueMatch := range valueMatches {
+					// BYTE indices in normalizedText
+					valueByteStart, valueByteEnd := search.originalOffset(valueMatch[0]), search.originalOffset(valueMatch[1])
+
+					approxContextByteStart := max(0, valueByteStart-20)
+					approxContextByteEnd := min(textLength, valueByteEnd+20)
+					// Keep the approximate bounds on rune boundaries
+					for approxContextByteStart > 0 && !utf8.RuneStart(normalizedText[approxContextByteStart]) {
+						approxContextByteStart--
+					}
+					for approxContextByteEnd < textLength && !utf8.RuneStart(normalizedText[approxContextByteEnd]) {
+						approxContextByteEnd++
+					}
+
+					// --- Convert Fallback BYTE indices to RUNE indices ---
+					runeHighlightStart := byteIndexToRuneIndex(normalizedText, valueByteStart)
+					runeHighlightEnd := byteIndexToRuneIndex(normalizedText, valueByteEnd)
+					runeApproxContextStart := byteIndexToRuneIndex(normalizedText, approxContextByteStart)
+					runeApproxContextEnd := byteIndexToRuneIndex(normalizedText, approxContextByteEnd)
+					approxContextText := normalizedText[approxContextByteStart:approxContextByteEnd]
+					if s.match.sentenceFallbackContext {
+						var sentence Position
+						approxContextText, sentence = sentenceContextAt(normalizedText, valueByteStart, valueByteEnd, runeHighlightStart)
+						runeApproxContextStart, runeApproxContextEnd = sentence.Start, sentence.End
+					}
+
+					fallbackContextText := contextStr // Still use context text from LLM by default
+					if s.match.fallbackContextFromText {
+						// Use the text that the approximate position actually covers
+						fallbackContextText = approxContextText
+					}
+
+					eo := EntityOccurrence{
+						Value:    occurrence.Value,
+						Position: Position{Start: runeHighlightStart, End: runeHighlightEnd},
+						Context: Context{
+							Text:     fallbackContextText,
+							Position: Position{Start: runeApproxContextStart, End: runeApproxContextEnd}, // Use approximate position
+						},
+						ValueInContext: valueInContext(Position{Start: runeHighlightStart, End: runeHighlightEnd}, Position{Start: runeApproxContextStart, End: runeApproxContextEnd}),
+						ID:             fmt.Sprintf("%s-%d", groupID, matchIndex),
+						GroupID:        groupID,
+						RowID:          occurrence.RowID,
+						Alternatives:   alternatives,
+						Confidence:     fallbackMatchConfidence,
+						fallback:       true,
 					}
-				} else {
-					s.logger.Warn("Could not find value or context in text",
-						zap.String("entityName", entityName),
-						zap.String("value", valueStr),
-						zap.String("context", contextStr),
-					)
+					located = append(located, eo)
+					matchIndex++
 				}
-			} else if !foundInContext {
-				s.logger.Warn("Could not find value within context and fallback skipped/failed",
+			} else {
+				s.logger.Warn("Could not find value or context in text",
 					zap.String("entityName", entityName),
 					zap.String("value", valueStr),
 					zap.String("context", contextStr),
-					zap.Int("valueLen", len(valueStr)),
 				)
 			}
+		} else if !foundInContext {
+			s.logger.Warn("Could not find value within context and fallback skipped/failed",
+				zap.String("entityName", entityName),
+				zap.String("value", valueStr),
+				zap.String("context", contextStr),
+				zap.Int("valueLen", len(valueStr)),
+			)
 		}
 	}
+	return located
+}
 
-	s.logger.Info("Finished position finding process")
-	return &ExtractionOutput{
-		Text:     normalizedText,
-		Entities: finalOutput,
-	}, nil
+// nearestSpan returns the span closest to target, preferring the earlier span
+// on ties. Overlapping spans are at distance zero.
+func nearestSpan(spans [][]int, target []int) []int {
+	nearest, nearestDistance := spans[0], -1
+	for _, span := range spans {
+		distance := max(0, span[0]-target[1], target[0]-span[1])
+		if nearestDistance < 0 || distance < nearestDistance {
+			nearest, nearestDistance = span, distance
+		}
+	}
+	return nearest
+}
+
+// valueInContext returns the value span relative to the start of the context
+// span, or nil when the value is not inside the context.
+func valueInContext(value, context Position) *Position {
+	if value.Start < context.Start || value.End > context.End {
+		return nil
+	}
+	return &Position{Start: value.Start - context.Start, End: value.End - context.Start}
+}
+
+// annotateSchemaSources sets Schema on every occurrence from the schema that
+// supplied the entity's top-level key ("Vital signs" for "Vital signs.Temperature").
+func annotateSchemaSources(output *ExtractionOutput, sources map[string]string, delimiter string) {
+	output.schemaSources, output.nestingDelimiter = sources, delimiter
+	for entityName, occurrences := range output.Entities {
+		source := schemaSourceOf(entityName, sources, delimiter)
+		for i := range occurrences {
+			occurrences[i].Schema = source
+		}
+	}
+}
+
+// schemaSourceOf looks up the longest delimited prefix of entityName that is
+// a top-level schema key, since top-level keys may themselves contain the
+// delimiter. The ArrayItemMarker of item properties is not part of the key.
+func schemaSourceOf(entityName string, sources map[string]string, delimiter string) string {
+	for key := entityName; ; {
+		if source, ok := sources[strings.TrimSuffix(key, ArrayItemMarker)]; ok {
+			return source
+		}
+		cut := strings.LastIndex(key, delimiter)
+		if cut < 0 {
+			return ""
+		}
+		key = key[:cut]
+	}
+}
+
+// contextMatchSimilarity scores how well a context match agrees with the
+// context the LLM provided, as the overlap (Jaccard) of their lower-cased
+// words. The match is first widened to whole words, so a short generic context
+// matching inside unrelated words ("ine 12" in "Creatinine 120") scores low,
+// while an exact match scores 1 however long its line is.
+func contextMatchSimilarity(text string, start, end int, llmContext string) float64 {
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !isWordRune(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(r) {
+			break
+		}
+		end += size
+	}
+
+	expected := lowerWordSet(llmContext)
+	if len(expected) == 0 {
+		return 1
+	}
+	matched := lowerWordSet(text[start:end])
+	shared := 0
+	for word := range expected {
+		if matched[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(expected)+len(matched)-shared)
+}
+
+// lowerWordSet returns the distinct lower-cased words of text.
+func lowerWordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) }) {
+		words[strings.ToLower(word)] = true
+	}
+	return words
+}
+
+// isWordBounded reports whether text[start:end] is not directly preceded or
+// followed by a letter, digit or underscore.
+func isWordBounded(text string, start, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(text[:start]); isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		if r, _ := utf8.DecodeRuneInString(text[end:]); isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
 }
 
 // byteIndexToRuneIndex converts a byte index within a UTF-8 string to a rune index (character count).
@@ -388,3 +1378,58 @@ func byteIndexToRuneIndex(text string, byteIdx int) int {
 	// Count the number of runes (characters) in the substring up to the byte index
 	return utf8.RuneCountInString(text[:byteIdx])
 }
+
+// SchemaEntities holds the entities attributed to one schema, or found in
+// one section for GroupEntitiesBySection.
+type SchemaEntities struct {
+	Entities map[string][]EntityOccurrence `json:"entities"`
+}
+
+// CountEntities returns the number of occurrences found per entity name,
+// summed across the documents of a split input.
+func CountEntities(output *ExtractionOutput) map[string]int {
+	counts := make(map[string]int, len(output.Entities))
+	for entityName, occurrences := range output.Entities {
+		counts[entityName] += len(occurrences)
+	}
+	for _, document := range output.Documents {
+		for entityName, count := range CountEntities(document.ExtractionOutput) {
+			counts[entityName] += count
+		}
+	}
+	return counts
+}
+
+// GroupEntitiesBySchema partitions the output's entities by the schema they
+// were attributed to. Entities without a known source are grouped under
+// "unknown". Occurrences go by their own Schema, since a case-normalized key
+// may gather occurrences of several schemas.
+func GroupEntitiesBySchema(output *ExtractionOutput) map[string]SchemaEntities {
+	groups := make(map[string]SchemaEntities)
+	add := func(schemaName, entityName string, occurrences []EntityOccurrence) {
+		if schemaName == "" {
+			schemaName = "unknown"
+		}
+		group, exists := groups[schemaName]
+		if !exists {
+			group = SchemaEntities{Entities: make(map[string][]EntityOccurrence)}
+			groups[schemaName] = group
+		}
+		group.Entities[entityName] = append(group.Entities[entityName], occurrences...)
+	}
+	for entityName, occurrences := range output.Entities {
+		keySchema := schemaSourceOf(entityName, output.schemaSources, output.nestingDelimiter)
+		if len(occurrences) == 0 {
+			add(keySchema, entityName, []EntityOccurrence{})
+			continue
+		}
+		for _, occurrence := range occurrences {
+			schemaName := occurrence.Schema
+			if schemaName == "" {
+				schemaName = keySchema
+			}
+			add(schemaName, entityName, []EntityOccurrence{occurrence})
+		}
+	}
+	return groups
+}
@@ -0,0 +1,58 @@
+package extractor
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFallbackContextModes(t *testing.T) {
+	text := "History: the patient reports taking aspirin every morning with breakfast."
+	raw := RawLLMExtraction{
+		"Medication": {{Value: "aspirin", Context: "takes aspirin daily"}}, // Context is not in the text
+	}
+
+	for _, fromText := range []bool{false, true} {
+		s := newTestService(t, "http://127.0.0.1:1", nil)
+		s.match.fallbackContextFromText = fromText
+
+		output, err := s.findEntityPositions(text, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		occs := output.Entities["Medication"]
+		if len(occs) != 1 {
+			t.Fatalf("fromText=%v: got %d occurrences, want 1", fromText, len(occs))
+		}
+		occ := occs[0]
+		runes := []rune(text)
+		if got := string(runes[occ.Position.Start:occ.Position.End]); got != "aspirin" {
+			t.Errorf("fromText=%v: value span = %q, want aspirin", fromText, got)
+		}
+
+		span := string(runes[occ.Context.Position.Start:occ.Context.Position.End])
+		if fromText {
+			if occ.Context.Text != span {
+				t.Errorf("context text %q does not match its position %q", occ.Context.Text, span)
+			}
+		} else if occ.Context.Text != "takes aspirin daily" {
+			t.Errorf("default mode context text = %q, want the LLM context", occ.Context.Text)
+		}
+	}
+}
+
+func TestFallbackContextFromTextKeepsRuneBoundaries(t *testing.T) {
+	text := "Überweisung: ß-Blocker Bisoprolol 5 mg täglich, Ödeme rückläufig."
+	s := newTestService(t, "http://127.0.0.1:1", nil)
+	s.match.fallbackContextFromText = true
+
+	output, err := s.findEntityPositions(text, RawLLMExtraction{
+		"Medication": {{Value: "Bisoprolol", Context: "not in the text"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	occ := output.Entities["Medication"][0]
+	if !utf8.ValidString(occ.Context.Text) {
+		t.Errorf("context text %q is not valid UTF-8", occ.Context.Text)
+	}
+}
@@ -0,0 +1,79 @@
+package extractor
+
+import (
+	"sort"
+	"strings"
+)
+
+// FlatOccurrence is one occurrence of a flattened extraction, carrying its
+// entity name and its relationships to the other occurrences.
+type FlatOccurrence struct {
+	Entity string `json:"entity"`
+	// Parent in the schema, e.g. "Vital signs" of "Vital signs.Temperature"
+	ParentEntity string `json:"parent_entity,omitempty"`
+	EntityOccurrence
+	// ID of the smallest other occurrence whose span encloses this one
+	ParentID string `json:"parent_id,omitempty"`
+	// IDs of the occurrences whose spans overlap this one without either
+	// enclosing the other, or that cover the same span
+	OverlapIDs []string `json:"overlap_ids,omitempty"`
+}
+
+// FlattenOutput lists every occurrence of the output once, sorted by
+// position with longer spans first, and links the spans that contain or
+// overlap one another.
+func FlattenOutput(output *ExtractionOutput) []FlatOccurrence {
+	flat := []FlatOccurrence{}
+	for entityName, occurrences := range output.Entities {
+		parentEntity := ""
+		if i := strings.LastIndex(entityName, output.nestingDelimiter); output.nestingDelimiter != "" && i > 0 {
+			parentEntity = strings.TrimSuffix(entityName[:i], ArrayItemMarker)
+		}
+		for _, occurrence := range occurrences {
+			flat = append(flat, FlatOccurrence{Entity: entityName, ParentEntity: parentEntity, EntityOccurrence: occurrence})
+		}
+	}
+	sort.Slice(flat, func(i, j int) bool {
+		a, b := flat[i], flat[j]
+		if a.Position.Start != b.Position.Start {
+			return a.Position.Start < b.Position.Start
+		}
+		if a.Position.End != b.Position.End {
+			return a.Position.End > b.Position.End
+		}
+		if a.Entity != b.Entity {
+			return a.Entity < b.Entity
+		}
+		return a.ID < b.ID
+	})
+
+	parents := make([]int, len(flat)) // Index of each occurrence's parent, -1 for none
+	for i := range parents {
+		parents[i] = -1
+	}
+	for i := range flat {
+		outer := flat[i].Position
+		// Sorted by start, so only later occurrences starting inside this span can relate to it
+		for j := i + 1; j < len(flat) && flat[j].Position.Start < outer.End; j++ {
+			inner := flat[j].Position
+			switch {
+			case inner == outer:
+				flat[i].OverlapIDs = append(flat[i].OverlapIDs, flat[j].ID)
+				flat[j].OverlapIDs = append(flat[j].OverlapIDs, flat[i].ID)
+			case inner.End <= outer.End:
+				if p := parents[j]; p < 0 || spanLength(outer) <= spanLength(flat[p].Position) {
+					parents[j] = i
+					flat[j].ParentID = flat[i].ID
+				}
+			default:
+				flat[i].OverlapIDs = append(flat[i].OverlapIDs, flat[j].ID)
+				flat[j].OverlapIDs = append(flat[j].OverlapIDs, flat[i].ID)
+			}
+		}
+	}
+	return flat
+}
+
+func spanLength(p Position) int {
+	return p.End - p.Start
+}
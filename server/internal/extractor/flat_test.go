@@ -0,0 +1,59 @@
+package extractor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFlattenOutput(t *testing.T) {
+	output := &ExtractionOutput{
+		Entities: map[string][]EntityOccurrence{
+			"Diagnosis":               {{ID: "d1", Position: Position{Start: 0, End: 20}}},
+			"Vital signs.Temperature": {{ID: "t1", Position: Position{Start: 5, End: 9}}, {ID: "t2", Position: Position{Start: 30, End: 34}}},
+			"Vital signs.Fever":       {{ID: "f1", Position: Position{Start: 5, End: 9}}},
+			"Medication":              {{ID: "m1", Position: Position{Start: 15, End: 25}}},
+		},
+		nestingDelimiter: ".",
+	}
+
+	flat := FlattenOutput(output)
+	seen := make(map[string]FlatOccurrence)
+	for _, occurrence := range flat {
+		if _, ok := seen[occurrence.ID]; ok {
+			t.Errorf("occurrence %s listed twice", occurrence.ID)
+		}
+		seen[occurrence.ID] = occurrence
+	}
+	if len(flat) != 5 || len(seen) != 5 {
+		t.Fatalf("flat = %+v, want each of the 5 occurrences once", flat)
+	}
+	if !slices.IsSortedFunc(flat, func(a, b FlatOccurrence) int { return a.Position.Start - b.Position.Start }) {
+		t.Errorf("flat list is not sorted by position: %+v", flat)
+	}
+
+	tests := []struct {
+		id           string
+		entity       string
+		parentEntity string
+		parentID     string
+		overlapIDs   []string
+	}{
+		{"d1", "Diagnosis", "", "", []string{"m1"}},
+		{"t1", "Vital signs.Temperature", "Vital signs", "d1", []string{"f1"}},
+		{"f1", "Vital signs.Fever", "Vital signs", "d1", []string{"t1"}},
+		{"m1", "Medication", "", "", []string{"d1"}},
+		{"t2", "Vital signs.Temperature", "Vital signs", "", nil},
+	}
+	for _, tt := range tests {
+		got := seen[tt.id]
+		if got.Entity != tt.entity || got.ParentEntity != tt.parentEntity {
+			t.Errorf("%s: entity = %q, parent entity = %q, want %q, %q", tt.id, got.Entity, got.ParentEntity, tt.entity, tt.parentEntity)
+		}
+		if got.ParentID != tt.parentID {
+			t.Errorf("%s: parent ID = %q, want %q", tt.id, got.ParentID, tt.parentID)
+		}
+		if !slices.Equal(got.OverlapIDs, tt.overlapIDs) {
+			t.Errorf("%s: overlap IDs = %v, want %v", tt.id, got.OverlapIDs, tt.overlapIDs)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package extractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DocumentMetadata holds the "Key: Value" lines of a document's header,
+// keyed by the configured spelling of each key.
+type DocumentMetadata map[string]string
+
+var headerLineRegex = regexp.MustCompile(`^[ \t]*([^:\n]+?)[ \t]*:[ \t]*(.*?)[ \t]*$`)
+
+// headerOptions controls which leading lines parseHeader reads.
+type headerOptions struct {
+	keys     map[string]string // Lowercased key to its configured spelling
+	maxLines int               // Leading lines scanned, 0 for no limit
+}
+
+func newHeaderOptions(keys []string, maxLines int) headerOptions {
+	options := headerOptions{keys: make(map[string]string, len(keys)), maxLines: maxLines}
+	for _, key := range keys {
+		options.keys[strings.ToLower(strings.TrimSpace(key))] = key
+	}
+	return options
+}
+
+// parseHeader reads the header of text: the leading lines (after any blank
+// ones) of the form "Key: Value" whose key is configured. The header ends at
+// the first other line. It returns the values found and the byte offset at
+// which the body starts; the first value of a repeated key wins.
+func (o headerOptions) parseHeader(text string) (DocumentMetadata, int) {
+	metadata := DocumentMetadata{}
+	bodyStart := 0
+	for offset, lines := 0, 0; offset < len(text); lines++ {
+		if o.maxLines > 0 && lines >= o.maxLines {
+			break
+		}
+		lineEnd := len(text)
+		next := len(text)
+		if i := strings.IndexByte(text[offset:], '\n'); i >= 0 {
+			lineEnd, next = offset+i, offset+i+1
+		}
+		line := text[offset:lineEnd]
+		if strings.TrimSpace(line) == "" {
+			if len(metadata) > 0 {
+				break // A blank line closes the header
+			}
+			offset = next
+			continue
+		}
+		match := headerLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			break
+		}
+		key, known := o.keys[strings.ToLower(match[1])]
+		if !known {
+			break
+		}
+		if _, seen := metadata[key]; !seen {
+			metadata[key] = match[2]
+		}
+		offset = next
+		bodyStart = next
+	}
+	return metadata, bodyStart
+}
@@ -0,0 +1,77 @@
+package extractor
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessTextParsesHeader(t *testing.T) {
+	text := "MRN: 0012345\nEncounter Date: 2026-03-14\nAuthor: Dr. Lee\n\nPatient on aspirin. MRN reviewed."
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "Patient on aspirin"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+	s.header = newHeaderOptions([]string{"MRN", "Encounter Date", "Author"}, 20)
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{ParseHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DocumentMetadata{"MRN": "0012345", "Encounter Date": "2026-03-14", "Author": "Dr. Lee"}
+	if !reflect.DeepEqual(output.DocumentMetadata, want) {
+		t.Errorf("document metadata = %v, want %v", output.DocumentMetadata, want)
+	}
+
+	prompt, _ := llm.Requests()[0]["prompt"].(string)
+	if strings.Contains(prompt, "0012345") || !strings.Contains(prompt, "Patient on aspirin") {
+		t.Errorf("prompt should hold the body only:\n%s", prompt)
+	}
+	meds := output.Entities["Medication"]
+	if output.Text != text || len(meds) != 1 || meds[0].Position.Start != strings.Index(text, "aspirin") {
+		t.Errorf("Medication = %+v, want aspirin positioned in the full text", meds)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	options := newHeaderOptions([]string{"MRN", "DOB"}, 3)
+	tests := []struct {
+		name          string
+		text          string
+		wantMetadata  DocumentMetadata
+		wantBodyStart int
+	}{
+		{"case-insensitive keys", "mrn: 1\ndob : 1990-01-01\nBody", DocumentMetadata{"MRN": "1", "DOB": "1990-01-01"}, 24},
+		{"leading blank lines", "\n\nMRN: 1\nBody", DocumentMetadata{"MRN": "1"}, 9},
+		{"unknown key ends the header", "MRN: 1\nPlan: rest\nDOB: 1990-01-01", DocumentMetadata{"MRN": "1"}, 7},
+		{"first repeated key wins", "MRN: 1\nMRN: 2\n", DocumentMetadata{"MRN": "1"}, 14},
+		{"no header", "Patient seen today.", DocumentMetadata{}, 0},
+		{"line limit", "MRN: 1\nDOB: 2\nMRN: 3\nDOB: 4", DocumentMetadata{"MRN": "1", "DOB": "2"}, 21},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, bodyStart := options.parseHeader(tt.text)
+			if !reflect.DeepEqual(metadata, tt.wantMetadata) || bodyStart != tt.wantBodyStart {
+				t.Errorf("parseHeader = %v, %d; want %v, %d", metadata, bodyStart, tt.wantMetadata, tt.wantBodyStart)
+			}
+		})
+	}
+}
+
+func TestProcessTextRejectsHeaderWithSectionsOrPrevious(t *testing.T) {
+	llm := newStubLLM(t, completion(`{}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+	s.header = newHeaderOptions([]string{"MRN"}, 20)
+	text := "MRN: 1\nMedications:\naspirin daily."
+
+	for name, opts := range map[string]ProcessOptions{
+		"sections": {ParseHeader: true, Sections: &SectionOptions{SchemaSections: map[string][]string{"demo": {"Medications"}}}},
+		"previous": {ParseHeader: true, Previous: &PriorExtraction{Text: "MRN: 1\nMedications:\naspirin."}},
+	} {
+		if _, err := s.ProcessText(context.Background(), []string{"demo"}, text, opts); err == nil {
+			t.Errorf("%s: ProcessText accepted parse_header", name)
+		}
+	}
+	if len(llm.Requests()) != 0 {
+		t.Error("the LLM was called for a rejected combination")
+	}
+}
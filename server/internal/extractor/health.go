@@ -0,0 +1,88 @@
+package extractor
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 1 while the LLM server answers health checks, 0 otherwise; served at
+// /api/debug/vars
+var llmHealthGauge = expvar.NewInt("llm_healthy")
+
+// LLMHealth is the outcome of the latest LLM server health check.
+type LLMHealth struct {
+	Healthy   bool       `json:"healthy"`
+	Polling   bool       `json:"polling"`             // False when health checks are disabled
+	LastCheck *time.Time `json:"lastCheck,omitempty"` // nil until the first check
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// llmHealthState guards the health recorded by the poller. The zero value
+// presumes the LLM server healthy until a check fails.
+type llmHealthState struct {
+	mu        sync.RWMutex
+	polling   bool
+	down      bool
+	lastCheck *time.Time
+	lastError string
+}
+
+// LLMHealth returns the latest health check outcome.
+func (s *ExtractorService) LLMHealth() LLMHealth {
+	s.health.mu.RLock()
+	defer s.health.mu.RUnlock()
+	return LLMHealth{
+		Healthy:   !s.health.down,
+		Polling:   s.health.polling,
+		LastCheck: s.health.lastCheck,
+		LastError: s.health.lastError,
+	}
+}
+
+// LLMHealthy reports whether extractions can be expected to reach the LLM
+// server. It is always true when health checks are disabled.
+func (s *ExtractorService) LLMHealthy() bool {
+	return s.LLMHealth().Healthy
+}
+
+// pollLLMHealth checks the LLM server every interval, for the life of the
+// process, recovering automatically once the server answers again.
+func (s *ExtractorService) pollLLMHealth(interval, timeout time.Duration) {
+	s.logger.Info("Polling LLM server health", zap.Duration("interval", interval))
+	for {
+		s.checkLLMHealth(timeout)
+		time.Sleep(interval)
+	}
+}
+
+// checkLLMHealth pings the LLM server once and records the outcome, logging
+// transitions between healthy and unhealthy.
+func (s *ExtractorService) checkLLMHealth(timeout time.Duration) {
+	err := s.warmUp(timeout) // A one-token completion proves the model answers
+	now := time.Now()
+
+	s.health.mu.Lock()
+	wasHealthy := !s.health.down
+	s.health.lastCheck = &now
+	s.health.down = err != nil
+	s.health.lastError = ""
+	if err != nil {
+		s.health.lastError = err.Error()
+	}
+	s.health.mu.Unlock()
+
+	switch {
+	case err != nil && wasHealthy:
+		s.logger.Error("LLM server health check failed, marking service degraded", zap.Error(err))
+	case err == nil && !wasHealthy:
+		s.logger.Info("LLM server is healthy again")
+	}
+	if err == nil {
+		llmHealthGauge.Set(1)
+	} else {
+		llmHealthGauge.Set(0)
+	}
+}
@@ -0,0 +1,50 @@
+package extractor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckLLMHealthTracksAvailability(t *testing.T) {
+	var down atomic.Bool
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "loading model", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(completion("{}")))
+	}))
+	defer llm.Close()
+	s := newTestService(t, llm.URL, nil)
+
+	if !s.LLMHealthy() {
+		t.Fatal("service unhealthy before any check")
+	}
+
+	steps := []struct {
+		down        bool
+		wantHealthy bool
+	}{
+		{false, true},
+		{true, false},
+		{true, false},
+		{false, true}, // Recovers without a restart
+	}
+	for i, step := range steps {
+		down.Store(step.down)
+		s.checkLLMHealth(time.Second)
+		health := s.LLMHealth()
+		if health.Healthy != step.wantHealthy {
+			t.Errorf("step %d: healthy = %v, want %v", i, health.Healthy, step.wantHealthy)
+		}
+		if health.LastCheck == nil || (health.LastError != "") == step.wantHealthy {
+			t.Errorf("step %d: health = %+v, want a check with an error only while down", i, health)
+		}
+		if want := map[bool]string{true: "1", false: "0"}[step.wantHealthy]; llmHealthGauge.String() != want {
+			t.Errorf("step %d: llm_healthy = %s, want %s", i, llmHealthGauge.String(), want)
+		}
+	}
+}
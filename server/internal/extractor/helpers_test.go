@@ -0,0 +1,73 @@
+package extractor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+// newTestService loads the schema files (name to YAML) into a service that
+// sends its prompts to llmURL.
+func newTestService(t testing.TB, llmURL string, schemas map[string]string) *ExtractorService {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range schemas {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.LLM.ServerURL = llmURL
+	cfg.LLM.SchemaDirs = []string{dir}
+	s, err := NewExtractorService(cfg, zap.NewNop(), dir)
+	if err != nil {
+		t.Fatalf("NewExtractorService: %v", err)
+	}
+	return s
+}
+
+// completion encodes a llama.cpp completion response carrying content.
+func completion(content string) string {
+	body, _ := json.Marshal(map[string]any{"content": content})
+	return string(body)
+}
+
+// stubLLM serves the response bodies in turn, repeating the last one, and
+// records the decoded request payloads.
+type stubLLM struct {
+	*httptest.Server
+	mu       sync.Mutex
+	bodies   []string
+	requests []map[string]any
+}
+
+func newStubLLM(t testing.TB, bodies ...string) *stubLLM {
+	t.Helper()
+	stub := &stubLLM{bodies: bodies}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		stub.mu.Lock()
+		stub.requests = append(stub.requests, payload)
+		body := stub.bodies[min(len(stub.requests), len(stub.bodies))-1]
+		stub.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// Requests returns the payloads received so far.
+func (s *stubLLM) Requests() []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]any(nil), s.requests...)
+}
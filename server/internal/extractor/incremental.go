@@ -0,0 +1,292 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// Largest sentence table diffed after trimming the common start and end;
+// bigger edits re-extract everything between the common start and end
+const maxDiffCells = 4_000_000
+
+// PriorExtraction is an earlier result for a previous version of the
+// document. Positions are rune positions in Text, as ProcessText returns
+// them without OriginalPositions.
+type PriorExtraction struct {
+	Text     string                        `json:"text"`
+	Entities map[string][]EntityOccurrence `json:"entities"`
+	// Hash of the schemas the result was produced with; when set and no
+	// longer current, the prior result is ignored
+	SchemaHash string `json:"schema_hash,omitempty"`
+}
+
+// IncrementalStats reports how much of an incremental extraction was reused.
+type IncrementalStats struct {
+	Reused      int        `json:"reused"`      // Prior occurrences carried over
+	Reextracted []Position `json:"reextracted"` // Changed ranges of the new text sent to the LLM
+}
+
+// textUnit is one sentence or line of a text, with trailing spaces, as a
+// rune range and a byte range.
+type textUnit struct {
+	runes, bytes textRange
+	text         string
+}
+
+// unchangedBlock maps a run of unchanged units of the prior text onto the new
+// text: old runes [oldStart, oldEnd) moved by delta.
+type unchangedBlock struct {
+	oldStart, oldEnd, delta int
+}
+
+// extractIncremental diffs text against the prior version sentence by
+// sentence, carries over prior occurrences lying in unchanged sentences and
+// re-extracts only the changed ones.
+func (s *ExtractorService) extractIncremental(ctx context.Context, schema Schema, text string, opts ProcessOptions) (*ExtractionOutput, error) {
+	prior := opts.Previous
+	if prior.SchemaHash != "" {
+		if schemaHash, err := HashSchema(schema); err == nil && schemaHash != prior.SchemaHash {
+			s.logger.Info("Prior result was produced with other schemas, extracting the whole text")
+			return s.extractSegment(ctx, schema, text, opts)
+		}
+	}
+
+	oldUnits := splitTextUnits(normalizeLineEndings(prior.Text).text)
+	newUnits := splitTextUnits(text)
+	blocks, changed := diffTextUnits(oldUnits, newUnits)
+
+	output := &ExtractionOutput{Text: text, Entities: make(map[string][]EntityOccurrence)}
+	stats := &IncrementalStats{Reextracted: []Position{}}
+	taken := make(map[string]bool)
+	for entityName, occurrences := range prior.Entities {
+		for _, occurrence := range occurrences {
+			if !carryOccurrence(&occurrence, blocks) {
+				continue
+			}
+			output.Entities[entityName] = append(output.Entities[entityName], occurrence)
+			taken[occurrence.GroupID] = true
+			if occurrence.RowID != "" {
+				taken[occurrence.RowID] = true
+			}
+			stats.Reused++
+		}
+	}
+
+	if len(changed) > 0 {
+		byteRanges := make([]textRange, len(changed))
+		for i, r := range changed {
+			byteRanges[i] = textRange{newUnits[r.start].bytes.start, newUnits[r.end-1].bytes.end}
+			stats.Reextracted = append(stats.Reextracted, Position{Start: newUnits[r.start].runes.start, End: newUnits[r.end-1].runes.end})
+		}
+		segment := excerpt(text, byteRanges)
+		segmentOutput, err := s.extractSegment(ctx, schema, segment.text, opts)
+		if err != nil {
+			return nil, err
+		}
+		remapOccurrences(segmentOutput, segment, text)
+		renumberGroups(segmentOutput, taken)
+		for entityName, occurrences := range segmentOutput.Entities {
+			output.Entities[entityName] = append(output.Entities[entityName], occurrences...)
+		}
+		output.Metadata = segmentOutput.Metadata
+		output.Debug = segmentOutput.Debug
+		output.Diagnostics = segmentOutput.Diagnostics
+	}
+	output.Metadata.Incremental = stats
+
+	s.logger.Info("Extracted incrementally",
+		zap.Int("reusedOccurrences", stats.Reused),
+		zap.Int("changedRanges", len(changed)),
+		zap.Int("units", len(newUnits)),
+	)
+	return output, nil
+}
+
+// splitTextUnits splits text after every line break and sentence end, so
+// editing one sentence changes only its own unit.
+func splitTextUnits(text string) []textUnit {
+	runes := []rune(text)
+	units := []textUnit{}
+	runeStart, byteStart, byteIdx := 0, 0, 0
+	for i := 0; i < len(runes); i++ {
+		byteIdx += utf8.RuneLen(runes[i])
+		if runes[i] != '\n' && !isSentenceEnd(runes, i) {
+			continue
+		}
+		for i+1 < len(runes) && runes[i+1] != '\n' && unicode.IsSpace(runes[i+1]) {
+			i++
+			byteIdx += utf8.RuneLen(runes[i])
+		}
+		units = append(units, textUnit{textRange{runeStart, i + 1}, textRange{byteStart, byteIdx}, text[byteStart:byteIdx]})
+		runeStart, byteStart = i+1, byteIdx
+	}
+	if runeStart < len(runes) {
+		units = append(units, textUnit{textRange{runeStart, len(runes)}, textRange{byteStart, len(text)}, text[byteStart:]})
+	}
+	return units
+}
+
+// diffTextUnits pairs identical units of the two texts in order (a longest
+// common subsequence) and returns the paired runs as blocks, along with the
+// ranges of unpaired new units.
+func diffTextUnits(oldUnits, newUnits []textUnit) ([]unchangedBlock, []textRange) {
+	// Common start and end are paired directly; only the middle is diffed
+	prefix := 0
+	for prefix < len(oldUnits) && prefix < len(newUnits) && oldUnits[prefix].text == newUnits[prefix].text {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldUnits)-prefix && suffix < len(newUnits)-prefix &&
+		oldUnits[len(oldUnits)-1-suffix].text == newUnits[len(newUnits)-1-suffix].text {
+		suffix++
+	}
+	oldMiddle := oldUnits[prefix : len(oldUnits)-suffix]
+	newMiddle := newUnits[prefix : len(newUnits)-suffix]
+
+	pairs := make([][2]int, 0, len(newUnits))
+	for i := range prefix {
+		pairs = append(pairs, [2]int{i, i})
+	}
+	if len(oldMiddle) > 0 && len(newMiddle) > 0 && len(oldMiddle)*len(newMiddle) <= maxDiffCells {
+		for _, pair := range commonUnits(oldMiddle, newMiddle) {
+			pairs = append(pairs, [2]int{prefix + pair[0], prefix + pair[1]})
+		}
+	}
+	for i := suffix; i > 0; i-- {
+		pairs = append(pairs, [2]int{len(oldUnits) - i, len(newUnits) - i})
+	}
+
+	blocks := []unchangedBlock{}
+	paired := make([]bool, len(newUnits))
+	for k, pair := range pairs {
+		paired[pair[1]] = true
+		oldUnit, newUnit := oldUnits[pair[0]], newUnits[pair[1]]
+		delta := newUnit.runes.start - oldUnit.runes.start
+		if k > 0 && pairs[k-1] == [2]int{pair[0] - 1, pair[1] - 1} {
+			blocks[len(blocks)-1].oldEnd = oldUnit.runes.end
+			continue
+		}
+		blocks = append(blocks, unchangedBlock{oldUnit.runes.start, oldUnit.runes.end, delta})
+	}
+
+	changed := []textRange{}
+	for i := 0; i < len(newUnits); i++ {
+		if paired[i] || strings.TrimSpace(newUnits[i].text) == "" {
+			continue
+		}
+		start := i
+		for i+1 < len(newUnits) && !paired[i+1] {
+			i++
+		}
+		changed = append(changed, textRange{start, i + 1})
+	}
+	return blocks, changed
+}
+
+// commonUnits returns the index pairs of a longest common subsequence of the
+// unit texts.
+func commonUnits(oldUnits, newUnits []textUnit) [][2]int {
+	n, m := len(oldUnits), len(newUnits)
+	// lengths[i*(m+1)+j] is the LCS length of oldUnits[i:] and newUnits[j:]
+	lengths := make([]int, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldUnits[i].text == newUnits[j].text {
+				lengths[i*(m+1)+j] = lengths[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lengths[i*(m+1)+j] = max(lengths[(i+1)*(m+1)+j], lengths[i*(m+1)+j+1])
+			}
+		}
+	}
+	pairs := [][2]int{}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case oldUnits[i].text == newUnits[j].text:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lengths[(i+1)*(m+1)+j] >= lengths[i*(m+1)+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// carryOccurrence moves a prior occurrence onto the new text and reports
+// whether all of its spans lie in unchanged text.
+func carryOccurrence(occurrence *EntityOccurrence, blocks []unchangedBlock) bool {
+	move := func(position *Position) bool {
+		for _, block := range blocks {
+			if position.Start >= block.oldStart && position.End <= block.oldEnd {
+				position.Start += block.delta
+				position.End += block.delta
+				return true
+			}
+		}
+		return false
+	}
+	if !move(&occurrence.Position) || !move(&occurrence.Context.Position) {
+		return false
+	}
+	occurrence.Positions = append([]Position(nil), occurrence.Positions...)
+	for i := range occurrence.Positions {
+		if !move(&occurrence.Positions[i]) {
+			return false
+		}
+	}
+	if occurrence.ValueInContext != nil {
+		occurrence.ValueInContext = valueInContext(occurrence.Position, occurrence.Context.Position)
+	}
+	occurrence.fallback = occurrence.Confidence == fallbackMatchConfidence
+	return true
+}
+
+// renumberGroups gives re-extracted occurrences group IDs that no carried
+// occurrence uses, keeping the "entity-<name>-<n>" form, and likewise renames
+// their table rows ("row-<table>-<n>"). The new IDs are added to taken.
+func renumberGroups(output *ExtractionOutput, taken map[string]bool) {
+	renamedRows := make(map[string]string) // Rows span the entities of their columns
+	nextRow := make(map[string]int)
+	for entityName, occurrences := range output.Entities {
+		renamed := make(map[string]string)
+		next := 0
+		for i := range occurrences {
+			o := &occurrences[i]
+			groupID, seen := renamed[o.GroupID]
+			if !seen {
+				groupID = o.GroupID
+				for taken[groupID] {
+					groupID = fmt.Sprintf("entity-%s-%d", entityName, next)
+					next++
+				}
+				renamed[o.GroupID] = groupID
+				taken[groupID] = true
+			}
+			o.ID = groupID + strings.TrimPrefix(o.ID, o.GroupID)
+			o.GroupID = groupID
+
+			if o.RowID == "" {
+				continue
+			}
+			rowID, seen := renamedRows[o.RowID]
+			if !seen {
+				rowID = o.RowID
+				table := o.RowID[:strings.LastIndex(o.RowID, "-")]
+				for taken[rowID] {
+					rowID = fmt.Sprintf("%s-%d", table, nextRow[table])
+					nextRow[table]++
+				}
+				renamedRows[o.RowID] = rowID
+				taken[rowID] = true
+			}
+			o.RowID = rowID
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProcessTextIncremental(t *testing.T) {
+	schema := "Medication:\n  type: string\nHeartRate:\n  type: number\n"
+	original := "Patient on aspirin. HR 80 at rest. Follow up in two weeks."
+	edited := "Patient on aspirin. HR 95 after walking to clinic. Follow up in two weeks."
+	llm := newStubLLM(t,
+		completion(`{
+			"Medication": [{"value": "aspirin", "context": "Patient on aspirin"}],
+			"HeartRate": [{"value": "80", "context": "HR 80 at rest"}]
+		}`),
+		completion(`{"HeartRate": [{"value": "95", "context": "HR 95 after walking"}]}`),
+	)
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": schema})
+
+	prior, err := s.ProcessText(context.Background(), []string{"demo"}, original, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, edited, ProcessOptions{
+		Previous: &PriorExtraction{Text: prior.Text, Entities: prior.Entities, SchemaHash: prior.Metadata.SchemaHash},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, _ := llm.Requests()[1]["prompt"].(string)
+	if !strings.Contains(prompt, "HR 95 after walking to clinic.") || strings.Contains(prompt, "aspirin") || strings.Contains(prompt, "Follow up") {
+		t.Errorf("second prompt does not hold only the edited sentence:\n%s", prompt)
+	}
+
+	stats := output.Metadata.Incremental
+	wantStart := strings.Index(edited, "HR 95")
+	if stats == nil || stats.Reused != 1 || len(stats.Reextracted) != 1 || stats.Reextracted[0].Start != wantStart {
+		t.Errorf("incremental stats = %+v, want one reused occurrence and the edited sentence re-extracted", stats)
+	}
+
+	meds := output.Entities["Medication"]
+	if len(meds) != 1 || meds[0].Position != prior.Entities["Medication"][0].Position {
+		t.Errorf("Medication = %+v, want the prior occurrence carried over", meds)
+	}
+	rates := output.Entities["HeartRate"]
+	if len(rates) != 1 || rates[0].Value != "95" || rates[0].Position.Start != strings.Index(edited, "95") {
+		t.Errorf("HeartRate = %+v, want 95 placed in the edited text", rates)
+	}
+}
+
+func TestIncrementalCarriesShiftedOccurrences(t *testing.T) {
+	original := "Seen today. Patient on aspirin."
+	edited := "Seen today in the cardiology clinic. Patient on aspirin."
+	llm := newStubLLM(t,
+		completion(`{"Medication": [{"value": "aspirin", "context": "Patient on aspirin"}]}`),
+		completion(`{}`),
+	)
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+
+	prior, err := s.ProcessText(context.Background(), []string{"demo"}, original, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, edited, ProcessOptions{
+		Previous: &PriorExtraction{Text: prior.Text, Entities: prior.Entities},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meds := output.Entities["Medication"]
+	want := strings.Index(edited, "aspirin")
+	if len(meds) != 1 || meds[0].Position.Start != want || meds[0].Context.Text != "Patient on aspirin" {
+		t.Fatalf("Medication = %+v, want aspirin moved to %d", meds, want)
+	}
+	if got := string([]rune(output.Text)[meds[0].Context.Position.Start:meds[0].Context.Position.End]); got != "Patient on aspirin" {
+		t.Errorf("context position points at %q", got)
+	}
+}
+
+func TestIncrementalIgnoresPriorFromOtherSchemas(t *testing.T) {
+	text := "Patient on aspirin."
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "Patient on aspirin"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{
+		Previous: &PriorExtraction{Text: text, SchemaHash: "outdated"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(llm.Requests()) != 1 || len(output.Entities["Medication"]) != 1 || output.Metadata.Incremental != nil {
+		t.Errorf("output = %+v, want a full extraction", output)
+	}
+}
+
+func TestRenumberGroupsRenamesTakenRows(t *testing.T) {
+	output := &ExtractionOutput{Entities: map[string][]EntityOccurrence{
+		"Labs.Name":  {{ID: "entity-Labs.Name-0-0", GroupID: "entity-Labs.Name-0", RowID: "row-Labs-0"}},
+		"Labs.Value": {{ID: "entity-Labs.Value-0-0", GroupID: "entity-Labs.Value-0", RowID: "row-Labs-0"}},
+	}}
+	taken := map[string]bool{"entity-Labs.Name-0": true, "row-Labs-0": true}
+	renumberGroups(output, taken)
+
+	name, value := output.Entities["Labs.Name"][0], output.Entities["Labs.Value"][0]
+	if name.GroupID == "entity-Labs.Name-0" || name.ID != name.GroupID+"-0" {
+		t.Errorf("Labs.Name kept a taken group: %q/%q", name.ID, name.GroupID)
+	}
+	if value.GroupID != "entity-Labs.Value-0" {
+		t.Errorf("Labs.Value group = %q, want it kept", value.GroupID)
+	}
+	if name.RowID == "row-Labs-0" || name.RowID != value.RowID {
+		t.Errorf("row IDs = %q and %q, want one new row shared by both cells", name.RowID, value.RowID)
+	}
+}
@@ -2,11 +2,16 @@ package extractor
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 )
@@ -21,12 +26,51 @@ type LLMResponse struct {
 	Timings            json.RawMessage `json:"timings"`
 }
 
+// LLMTimings holds the per-request performance figures reported by llama.cpp
+// in the "timings" object of a completion response.
+type LLMTimings struct {
+	CacheN              int     `json:"cache_n"`
+	PromptN             int     `json:"prompt_n"`
+	PromptMS            float64 `json:"prompt_ms"`
+	PromptPerTokenMS    float64 `json:"prompt_per_token_ms"`
+	PromptPerSecond     float64 `json:"prompt_per_second"`
+	PredictedN          int     `json:"predicted_n"`
+	PredictedMS         float64 `json:"predicted_ms"`
+	PredictedPerTokenMS float64 `json:"predicted_per_token_ms"`
+	PredictedPerSecond  float64 `json:"predicted_per_second"`
+}
+
+// ParseTimings decodes the raw timings object. It returns nil without an error
+// when the backend did not report timings (absent or null).
+func (r *LLMResponse) ParseTimings() (*LLMTimings, error) {
+	raw := bytes.TrimSpace(r.Timings)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	var timings LLMTimings
+	if err := json.Unmarshal(raw, &timings); err != nil {
+		return nil, fmt.Errorf("failed to decode LLM timings: %w", err)
+	}
+	return &timings, nil
+}
+
 // LLMOutputValueContext is the intermediate structure we expect the LLM
 // to generate *within* the JSON object for each entity occurrence.
 // It only contains the value and the context string. Positions are calculated later.
 type LLMOutputValueContext struct {
 	Value   any    `json:"value"`   // Use 'any' for flexibility (string, number, bool, list, etc.)
 	Context string `json:"context"` // Just the context string from the LLM
+	RowID   string `json:"-"`       // Set for table cells, not produced by the LLM
+	// Text to search for instead of Value, set when Value was coerced (e.g. "denies" -> false)
+	SearchValue string `json:"-"`
+	// Highlight the whole context because the value is empty
+	HighlightContext bool `json:"-"`
+	// Match Value regardless of thousands separators, set for number entities
+	Numeric bool `json:"-"`
+	// Limit placement to context or value matches, from the entity's search_scope
+	SearchScope string `json:"-"`
+	// Alternative values offered for ambiguous occurrences of entities marked `alternatives: true`
+	Candidates []any `json:"candidates,omitempty"`
 }
 
 // RawLLMExtraction defines the expected structure of the *entire* JSON object
@@ -34,60 +78,110 @@ type LLMOutputValueContext struct {
 // in LLMResponse). Keys are entity names (potentially dotted).
 type RawLLMExtraction map[string][]LLMOutputValueContext
 
-func (s *ExtractorService) callLLM(prompt string) (string, error) {
+// errEmptyLLMContent is returned by callLLM when the response holds no
+// content; the model often answers properly when simply asked again.
+var errEmptyLLMContent = errors.New("LLM response content is empty")
+
+// callLLM sends the prompt to the LLM server at serverURL, asking for model if
+// set, and returns the cleaned inner JSON string along with the decoded outer
+// response. The outer response is also returned with errEmptyLLMContent, as
+// its tokens were still spent.
+func (s *ExtractorService) callLLM(ctx context.Context, serverURL, model string, temperature *float64, prompt string) (string, *LLMResponse, error) {
+	sampling := defaultTemperature
+	if temperature != nil {
+		sampling = *temperature // May be 0 for greedy decoding
+	}
 	payload := map[string]any{ // Using a map for flexibility, matches Python example better
 		"prompt":       prompt,
 		"max_tokens":   16384, // Or use n_predict as per llama.cpp docs
-		"temperature":  0.01,
+		"temperature":  sampling,
 		"top_p":        0.5,
 		"stop":         []string{"<|im_end|>"}, // Common stop sequence
 		"n_predict":    -1,                     // Predict until stop or context full
 		"stream":       false,                  // Ensure streaming is off
 		"cache_prompt": true,                   // Optional: might speed up similar requests
 	}
+	if s.maxOutputTokens > 0 {
+		payload["max_tokens"] = s.maxOutputTokens
+		payload["n_predict"] = s.maxOutputTokens
+	}
+	if model != "" {
+		payload["model"] = model // Left to the server when no schema or config names one
+	}
 
 	data, err := json.Marshal(payload)
 	if err != nil {
 		s.logger.Error("Failed to marshal request payload", zap.Error(err))
-		return "", fmt.Errorf("failed to marshal request payload: %w", err)
+		return "", nil, fmt.Errorf("failed to marshal request payload: %w", err)
 	}
-	s.logger.Debug("Attempting LLM call", zap.String("url", s.llmServerURL))
-	req, err := http.NewRequest("POST", s.llmServerURL, bytes.NewBuffer(data))
+	s.logger.Debug("Attempting LLM call", zap.String("url", serverURL))
+	req, err := http.NewRequestWithContext(ctx, "POST", serverURL, bytes.NewBuffer(data))
 	if err != nil {
 		s.logger.Error("Failed to create request", zap.Error(err))
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		s.logger.Error("Failed to send request to LLM server", zap.Error(err))
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body) // Read the entire body
+	// Read one byte past the cap so an oversized body is detected, not truncated
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, s.maxResponseBytes+1))
 	if err != nil {
 		s.logger.Error("Failed to read LLM response body", zap.Error(err))
-		return "", fmt.Errorf("failed to read LLM response body: %w", err)
+		return "", nil, fmt.Errorf("failed to read LLM response body: %w", err)
+	}
+	if int64(len(bodyBytes)) > s.maxResponseBytes {
+		s.logger.Error("LLM response body exceeds the configured limit", zap.Int64("max_response_bytes", s.maxResponseBytes))
+		return "", nil, fmt.Errorf("llm response exceeds %d bytes", s.maxResponseBytes)
 	}
 	if resp.StatusCode != http.StatusOK {
 		s.logger.Error("LLM server returned non-ok status",
 			zap.Int("status_code", resp.StatusCode),
 			zap.String("error_body", string(bodyBytes)), // Log full body on error
 		)
-		return "", fmt.Errorf("llm server returned non-200 status: %d - %s", resp.StatusCode, limitString(string(bodyBytes), 100))
+		return "", nil, fmt.Errorf("llm server returned non-200 status: %d - %s", resp.StatusCode, limitString(string(bodyBytes), 100))
 	}
 
 	// Decode the outer JSON structure
 	var outerResponse LLMResponse
 	if err := json.Unmarshal(bodyBytes, &outerResponse); err != nil {
 		s.logger.Error("Failed to decode outer LLM response JSON", zap.Error(err), zap.String("raw_body_snippet", limitString(string(bodyBytes), 200)))
-		return "", fmt.Errorf("failed to decode outer LLM response JSON: %w", err)
+		return "", nil, fmt.Errorf("failed to decode outer LLM response JSON: %w", err)
+	}
+
+	// Timings are llama.cpp specific; other backends simply omit them
+	timings, err := outerResponse.ParseTimings()
+	if err != nil {
+		s.logger.Warn("Ignoring unparseable LLM timings", zap.Error(err))
+	} else if timings != nil {
+		s.logger.Info("LLM timings",
+			zap.Int("prompt_tokens", timings.PromptN),
+			zap.Float64("prompt_ms", timings.PromptMS),
+			zap.Float64("prompt_per_second", timings.PromptPerSecond),
+			zap.Int("predicted_tokens", timings.PredictedN),
+			zap.Float64("predicted_ms", timings.PredictedMS),
+			zap.Float64("predicted_per_second", timings.PredictedPerSecond),
+		)
 	}
 
-	// Extract the inner JSON string from the 'content' field
-	innerJsonString := outerResponse.Content
+	// Extract the inner JSON string from the first configured path that holds content
+	innerJsonString, contentPath, found := extractResponseContent(bodyBytes, s.contentPaths)
+	if !found && hasResponsePath(bodyBytes, s.contentPaths) {
+		s.logger.Warn("LLM response content is empty", zap.String("raw_body_snippet", limitString(string(bodyBytes), 200)))
+		return "", &outerResponse, fmt.Errorf("%w at paths %v", errEmptyLLMContent, s.contentPaths)
+	}
+	if !found {
+		s.logger.Error("No configured content path found in LLM response",
+			zap.Strings("paths", s.contentPaths),
+			zap.String("raw_body_snippet", limitString(string(bodyBytes), 200)))
+		return "", nil, fmt.Errorf("no content found in LLM response at paths %v", s.contentPaths)
+	}
+	s.logger.Debug("Extracted LLM content", zap.String("path", contentPath))
 
 	// --- Optional: Clean the inner JSON string ---
 	// The LLM sometimes includes markdown fences (```json ... ```) or leading/trailing whitespace
@@ -106,28 +200,150 @@ func (s *ExtractorService) callLLM(prompt string) (string, error) {
 
 	// Check if the extracted content is empty after cleaning
 	if innerJsonString == "" {
-		s.logger.Error("Extracted 'content' field is empty after cleaning", zap.String("raw_body_snippet", limitString(string(bodyBytes), 200)))
-		return "", fmt.Errorf("extracted 'content' from LLM response is empty")
+		s.logger.Warn("Extracted 'content' field is empty after cleaning", zap.String("raw_body_snippet", limitString(string(bodyBytes), 200)))
+		return "", &outerResponse, fmt.Errorf("%w after cleaning", errEmptyLLMContent)
 	}
 
 	s.logger.Debug("Extracted inner JSON string (after cleaning)", zap.String("inner_json", innerJsonString))
 
 	// Return the inner JSON string, which will be parsed later
-	return innerJsonString, nil
+	return innerJsonString, &outerResponse, nil
 }
 
-// formatExtractionPrompt formats the prompt for the LLM based on the Python script's template.
-func (s *ExtractorService) formatExtractionPrompt(schema Schema, text string) (string, error) {
+// extractResponseContent returns the value at the first dotted path (e.g.
+// "choices.0.message.content") that is present and non-empty in the response
+// body. Numeric segments index into arrays. Object values are re-encoded as JSON,
+// for backends that return the extraction unwrapped.
+func extractResponseContent(body []byte, paths []string) (string, string, bool) {
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return "", "", false
+	}
+	for _, path := range paths {
+		value, ok := lookupJSONPath(decoded, path)
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if strings.TrimSpace(v) != "" {
+				return v, path, true
+			}
+		case map[string]any:
+			if len(v) > 0 {
+				encoded, err := json.Marshal(v)
+				if err == nil {
+					return string(encoded), path, true
+				}
+			}
+		}
+	}
+	return "", "", false
+}
+
+// hasResponsePath reports whether any of the dotted paths is present in the
+// response body, even if its value is empty.
+func hasResponsePath(body []byte, paths []string) bool {
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return false
+	}
+	for _, path := range paths {
+		if _, ok := lookupJSONPath(decoded, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupJSONPath walks a decoded JSON value along a dotted path.
+func lookupJSONPath(value any, path string) (any, bool) {
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// PromptTemplateVersion identifies the prompt built by formatExtractionPrompt.
+// Bump it whenever the template changes in a way that affects results. See
+// also ExtractorService.PromptVersion, which adds the prompt style or the
+// translated template.
+const PromptTemplateVersion = "5"
+
+// promptSchemaJSON renders the schema block embedded in the extraction prompt.
+func promptSchemaJSON(schema Schema) ([]byte, error) {
 	// Marshal the schema map into a pretty-printed JSON string
-	schemaJSON, err := json.MarshalIndent(schema, "", "  ") // Indent with 2 spaces
+	// Examples are rendered as their own section, so keep them out of the schema block
+	// Meta keys (_name, _version, ...) describe the schema, not entities to extract
+	return json.MarshalIndent(stripSchemaExamples(stripSchemaMeta(schema)), "", "  ") // Indent with 2 spaces
+}
+
+// formatExtractionPrompt formats the prompt for the LLM based on the Python script's template.
+func (s *ExtractorService) formatExtractionPrompt(schema Schema, text string, opts ProcessOptions) (string, error) {
+	if len(stripSchemaMeta(schema)) == 0 {
+		s.logger.Warn("Refusing to prompt with a schema without entities")
+		return "", ErrNoEntitiesSelected
+	}
+	schemaJSON, err := promptSchemaJSON(schema)
 	if err != nil {
 		s.logger.Error("Failed to marshal schema to JSON", zap.Error(err))
 		return "", fmt.Errorf("failed to marshal combined schema to JSON: %w", err)
 	}
 
+	examples := limitExamples(collectSchemaExamples(schema, s.nestingDelimiter), s.maxExamples)
+	examplesSection, err := formatExamplesSection(examples)
+	if err != nil {
+		s.logger.Error("Failed to format schema examples", zap.Error(err))
+		return "", err
+	}
+
+	alternatives := alternativeEntities(schema, s.nestingDelimiter)
+	arrayItems := arrayItemEntities(schema, s.nestingDelimiter)
+	hasTables := len(tableColumns(schema, s.nestingDelimiter)) > 0
+
+	if lang := s.promptLangFor(opts); lang != "" && lang != PromptLangEnglish {
+		template, ok := s.promptTemplates[lang]
+		if !ok {
+			return "", fmt.Errorf("unsupported prompt language %q", lang)
+		}
+		return renderPromptTemplate(template.prompt, string(schemaJSON), examplesSection, text,
+			template.instructions(opts.ReferenceDate, hasTables, arrayItems, alternatives), s.nestingDelimiter), nil
+	}
+
+	dateInstructions := formatReferenceDateInstructions(opts.ReferenceDate)
+
+	alternativesInstructions := formatAlternativesInstructions(alternatives)
+
+	arrayItemInstructions := formatArrayItemInstructions(arrayItems)
+
+	tableInstructions := ""
+	if hasTables {
+		tableInstructions = tableRowInstructions
+	}
+
+	if s.promptStyle == PromptStyleConcise {
+		return fmt.Sprintf(fillPromptDelimiter(concisePromptTemplate, s.nestingDelimiter),
+			string(schemaJSON), examplesSection, text, dateInstructions, tableInstructions+arrayItemInstructions+alternativesInstructions), nil
+	}
+
 	// Use fmt.Sprintf to build the prompt string, replicating the Python structure
 	// Note: Backticks ` ` are used for raw string literals in Go to handle newlines and quotes easily.
-	prompt := fmt.Sprintf(
+	prompt := fmt.Sprintf(fillPromptDelimiter(
 		`<|im_start|>system
 You are a medical information extraction system specialized in extracting entities with their surrounding context. Your output MUST be a valid JSON object.
 <|im_end|>
@@ -135,12 +351,12 @@ You are a medical information extraction system specialized in extracting entiti
 Extract the following entities from the medical text according to this schema:
 
 %s%s%s
-
+%s
 Medical Text:
 %s
 %s
 %s
-
+%s
 Extract all entities listed in the schema that are present in the text. For each entity found, provide:
 1. The exact 'value' of the entity as it appears in the text.
 2. The 'context' (the surrounding phrase or sentence) where the value was found.
@@ -150,12 +366,12 @@ Special instructions:
 - For numeric values (like scores, grades, dates, etc.), include identifying phrases or labels in the context, especially for single character values. Ensure no duplicates in the context.
 - For vital signs, extract both the value and the unit if present in the 'value' field.
 - If an entity is not present in the text, omit it from the final JSON or set its value to null or an empty list as appropriate according to the schema type.
-- **IMPORTANT:** For entities defined as objects with properties in the schema, extract each property as a separate key using dot notation (e.g., "Labs.WBC", "Labs.Hb", "Labs.Sodium"). The value for each specific lab key MUST be an array containing the extracted 'value' and 'context' objects. Do NOT group all results under a single key.
-- Structure nested entities (like Vital Signs properties) using dot notation in the JSON keys (e.g., "Vital signs.Temperature").
-- Always return the found occurrences for an entity within a JSON list (array), even if only one occurrence is found.
+- **IMPORTANT:** For entities defined as objects with properties in the schema, extract each property as a separate key using '{delimiter}' notation (e.g., "Labs{delimiter}WBC", "Labs{delimiter}Hb", "Labs{delimiter}Sodium"). The value for each specific lab key MUST be an array containing the extracted 'value' and 'context' objects. Do NOT group all results under a single key.
+- Structure nested entities (like Vital Signs properties) using '{delimiter}' notation in the JSON keys (e.g., "Vital signs{delimiter}Temperature").
+- Always return the found occurrences for an entity within a JSON list (array), even if only one occurrence is found.%s
 
 1.  **JSON Structure:** The output MUST be a single JSON object.
-    * The keys of this object MUST be the entity names from the schema (using dot notation for nested properties, e.g., "Vital signs.Temperature").
+    * The keys of this object MUST be the entity names from the schema (using '{delimiter}' notation for nested properties, e.g., "Vital signs{delimiter}Temperature").
     * The value for each key MUST be a JSON array (list) '[...]'.
     * Each element within the array MUST be a JSON object '{...}' representing one occurrence of the entity found in the text.
     * Each occurrence object MUST contain exactly two keys:
@@ -163,14 +379,14 @@ Special instructions:
         * '"context"': A short, unique surrounding phrase or sentence from the text where the value was found. This context MUST be sufficient to uniquely locate the value in the original text using pattern matching (typically 3-5 words before and after the value). Ensure context itself does not contain duplicates where possible.
 
 2.  **Comma Placement (VERY IMPORTANT):**
-    * Inside the main JSON object '{}', commas (',') MUST ONLY be placed *between* the '"entity.name": [...]' pairs.
+    * Inside the main JSON object '{}', commas (',') MUST ONLY be placed *between* the '"entity{delimiter}name": [...]' pairs.
     * Do **NOT** place a comma after the last key-value pair in the main object.
     * Do **NOT** place a comma or any other character after the closing square bracket ']' of an entity's array value if it's part of the main object structure.
 
 3.  **Handling Entities:**
     * **Always Use Arrays:** Even if only one occurrence of an entity is found, its value MUST be an array containing a single occurrence object '[ { "value": ..., "context": ... } ]'.
     * **Missing Entities:** If an entity defined in the schema is *not* found in the text, its corresponding key MUST have an empty array '[]' as its value in the output JSON. Do *not* omit the key entirely.
-    * **Dot Notation:** Use dot notation strictly as defined in the schema for nested entities (e.g., "Labs.WBC", "Vital signs.Blood pressure").
+    * **'{delimiter}' Notation:** Use '{delimiter}' notation strictly as defined in the schema for nested entities (e.g., "Labs{delimiter}WBC", "Vital signs{delimiter}Blood pressure").
 
 4.  **JSON Formatting Rules:**
     * Use double quotes ('"') for all keys and string values.
@@ -199,7 +415,7 @@ Return ONLY a single valid JSON object containing the extracted entities and the
       "context": "Context for the single value."
     }
   ],
-  "nested_entity.nested_property": [ // Example using dot notation, also in a list
+  "nested_entity{delimiter}nested_property": [ // Example using '{delimiter}' notation, also in a list
     {
       "value": "property value",
       "context": "surrounding context text for property"
@@ -212,22 +428,54 @@ Generate ONLY the single, valid JSON object containing the extracted data.
 Use standard JSON format: double quotes for all keys and string values, 'true'/'false' for booleans, 'null' for null values. Do NOT include %s%s markers or any other text outside the main JSON object in your response.
 <|im_end|>
 <|im_start|>assistant
-`,
+`, s.nestingDelimiter),
 		"```json",          // Start code block for schema JSON
 		string(schemaJSON), // The schema itself as JSON
 		"```",              // End code block for schema JSON
+		examplesSection,    // Optional few-shot examples from the schema
 		"```",              // Start code block for medical text
 		text,               // The input medical text
 		"```",              // End code block for medical text
-		"```json",          // Start code block for example output format
-		"```",              // End code block for example output format
-		"```json",          // Stray markers mentioned in prompt instruction
-		"```",              // Stray markers mentioned in prompt instruction
+		dateInstructions,   // Anchor for relative dates, if any
+		tableInstructions+arrayItemInstructions+alternativesInstructions, // Row format for table entities, array item keys and alternatives, if any
+		"```json", // Start code block for example output format
+		"```",     // End code block for example output format
+		"```json", // Stray markers mentioned in prompt instruction
+		"```",     // Stray markers mentioned in prompt instruction
 	)
 
 	return prompt, nil
 }
 
+// fillPromptDelimiter inserts the nesting delimiter at the template's
+// {delimiter} placeholders, so the model produces keys that the rest of the
+// pipeline splits the same way. The delimiter cannot contain '%', so the
+// result is still a valid format string.
+func fillPromptDelimiter(template, delimiter string) string {
+	return strings.ReplaceAll(template, promptPlaceholderDelimiter, delimiter)
+}
+
+// promptUserContent returns the user turn of a ChatML formatted prompt (schema,
+// text and instructions) without the <|im_start|>/<|im_end|> control tokens.
+// It is meant for logging and exposing prompts; the LLM always receives the
+// full wrapped prompt. Prompts without a user turn are returned trimmed.
+func promptUserContent(prompt string) string {
+	const userStart = "<|im_start|>user"
+	const turnEnd = "<|im_end|>"
+
+	content := prompt
+	if start := strings.Index(content, userStart); start != -1 {
+		content = content[start+len(userStart):]
+		if end := strings.Index(content, turnEnd); end != -1 {
+			content = content[:end]
+		}
+	}
+	// Drop any stray control tokens left behind
+	content = strings.ReplaceAll(content, "<|im_start|>", "")
+	content = strings.ReplaceAll(content, turnEnd, "")
+	return strings.TrimSpace(content)
+}
+
 // parseLLMResponse parses the JSON string returned by the LLM.
 func (s *ExtractorService) parseLLMResponse(llmResponseString string) (RawLLMExtraction, error) {
 	// Check if the cleaned response looks like a JSON object
@@ -252,6 +500,53 @@ func (s *ExtractorService) parseLLMResponse(llmResponseString string) (RawLLMExt
 	return parsedData, nil
 }
 
+// PromptEstimate describes the size of a prompt without sending it.
+type PromptEstimate struct {
+	PromptLength    int  `json:"prompt_length"`    // Characters (runes)
+	EstimatedTokens int  `json:"estimated_tokens"` // See estimateTokens
+	OutputTokens    int  `json:"output_tokens"`    // Response budget, or the reserve while unbounded
+	ContextSize     int  `json:"context_size"`     // Configured model context, 0 if unknown
+	ExceedsContext  bool `json:"exceeds_context"`  // Prompt and response budget together overflow the context
+}
+
+// EstimatePrompt renders the extraction prompt for the schemas and sample
+// text and reports its size, without calling the LLM.
+func (s *ExtractorService) EstimatePrompt(schemaNames []string, text string) (*PromptEstimate, error) {
+	combinedSchema, err := s.CombineSchemas(schemaNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed during schema combination: %w", err)
+	}
+	opts := ProcessOptions{}
+	if s.injectReferenceDate {
+		today := time.Now()
+		opts.ReferenceDate = &today
+	}
+	prompt, err := s.formatExtractionPrompt(combinedSchema, text, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed during prompt formatting: %w", err)
+	}
+
+	estimate := &PromptEstimate{
+		PromptLength:    utf8.RuneCountInString(prompt),
+		EstimatedTokens: estimateTokens(prompt),
+		OutputTokens:    s.maxOutputTokens,
+		ContextSize:     s.contextSize,
+	}
+	if estimate.OutputTokens <= 0 {
+		estimate.OutputTokens = s.responseReserve // Unbounded responses still need room
+	}
+	// The response shares the context with the prompt, so a prompt that only
+	// just fits leaves no room to answer
+	estimate.ExceedsContext = s.contextSize > 0 && estimate.EstimatedTokens+estimate.OutputTokens > s.contextSize
+	return estimate, nil
+}
+
+// estimateTokens approximates the token count of a text using the common
+// heuristic of roughly four characters per token.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
 // Helper function to limit string length for logging
 func limitString(s string, maxLength int) string {
 	if len(s) <= maxLength {
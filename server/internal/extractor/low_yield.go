@@ -0,0 +1,100 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Sampling temperature of extraction calls unless a retry raises it
+const defaultTemperature = 0.01
+
+// LowYieldRetry records the repeated extraction of a document for which the
+// first attempt found too few of the schema's entities.
+type LowYieldRetry struct {
+	Yield       float64 `json:"yield"`       // Share of the schema's entities the first attempt found
+	Threshold   float64 `json:"threshold"`   // llm.low_yield_ratio
+	Temperature float64 `json:"temperature"` // Sampling temperature of the retry
+	RetryYield  float64 `json:"retry_yield"`
+	Kept        string  `json:"kept"`            // "retry", or "first" when the retry found no more or failed
+	Error       string  `json:"error,omitempty"` // Why the retry failed
+}
+
+// entityYield returns the share of the schema's leaf entities that have at
+// least one occurrence in the output.
+func entityYield(schema Schema, delimiter string, output *ExtractionOutput) float64 {
+	leaves := 0
+	walkEntityDefinitions(stripSchemaMeta(schema), delimiter, func(_ string, def map[string]any) {
+		if _, ok := asMap(def["properties"]); ok || def["type"] == tableEntityType {
+			return
+		}
+		if _, ok := itemProperties(def); ok {
+			return
+		}
+		leaves++
+	})
+	if leaves == 0 {
+		return 1
+	}
+	found := 0
+	for _, occurrences := range output.Entities {
+		if len(occurrences) > 0 {
+			found++
+		}
+	}
+	return min(1, float64(found)/float64(leaves))
+}
+
+// retryLowYield repeats the extraction once at llm.low_yield_temperature when
+// the output found fewer than llm.low_yield_ratio of the schema's entities in
+// a non-blank text, a common sign of a transient model failure. It returns
+// the output finding more entities, with the retry recorded in its metadata.
+// A failed retry keeps the first output.
+func (s *ExtractorService) retryLowYield(ctx context.Context, schemaNames []string, text string, opts ProcessOptions, output *ExtractionOutput) (*ExtractionOutput, error) {
+	if s.lowYieldRatio <= 0 || opts.Split != nil || strings.TrimSpace(text) == "" {
+		return output, nil // Split documents are retried one by one
+	}
+	schema, _, err := s.combineRequestSchemas(schemaNames, opts.InlineSchema)
+	if err != nil {
+		return nil, err
+	}
+	yield := entityYield(schema, s.nestingDelimiter, output)
+	if yield >= s.lowYieldRatio {
+		return output, nil
+	}
+
+	s.logger.Warn("Extraction found few entities, retrying",
+		zap.Float64("yield", yield),
+		zap.Float64("threshold", s.lowYieldRatio),
+		zap.Float64("temperature", s.lowYieldTemperature),
+	)
+	temperature := s.lowYieldTemperature
+	opts.temperature = &temperature
+	retry := &LowYieldRetry{
+		Yield:       yield,
+		Threshold:   s.lowYieldRatio,
+		Temperature: s.lowYieldTemperature,
+		Kept:        "retry",
+	}
+	retried, err := s.processText(ctx, schemaNames, text, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err // The caller is gone, not the model
+		}
+		s.logger.Warn("Low-yield retry failed, keeping the first extraction", zap.Error(err))
+		retry.Kept = "first"
+		retry.Error = err.Error()
+		output.Metadata.LowYieldRetry = retry
+		return output, nil
+	}
+	retry.RetryYield = entityYield(schema, s.nestingDelimiter, retried)
+	kept, discarded := retried, output
+	if retry.RetryYield <= retry.Yield {
+		retry.Kept = "first"
+		kept, discarded = output, retried
+	}
+	kept.Metadata.LowYieldRetry = retry
+	addLLMUsage(&kept.Metadata, discarded.Metadata) // Both attempts were paid for
+	return kept, nil
+}
@@ -0,0 +1,151 @@
+package extractor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProcessTextRetriesLowYield(t *testing.T) {
+	schemas := map[string]string{"demo.yaml": "Medication:\n  type: string\nAllergy:\n  type: string\n"}
+	text := "Patient on aspirin, allergic to penicillin."
+	empty := completion(`{}`)
+	partial := completion(`{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`)
+	full := completion(`{"Medication": [{"value": "aspirin", "context": "on aspirin"}], "Allergy": [{"value": "penicillin", "context": "allergic to penicillin"}]}`)
+
+	tests := []struct {
+		name      string
+		ratio     float64
+		bodies    []string
+		wantCalls int
+		wantKept  string // "" when no retry is recorded
+		wantFound int
+	}{
+		{"disabled", 0, []string{empty, full}, 1, "", 0},
+		{"enough found", 0.5, []string{partial, full}, 1, "", 1},
+		{"empty then populated", 0.5, []string{empty, full}, 2, "retry", 2},
+		{"retry finds no more", 1, []string{partial, partial}, 2, "first", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newStubLLM(t, tt.bodies...)
+			s := newTestService(t, llm.URL, schemas)
+			s.lowYieldRatio = tt.ratio
+			s.lowYieldTemperature = 0.3
+
+			output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			requests := llm.Requests()
+			if len(requests) != tt.wantCalls {
+				t.Fatalf("LLM called %d times, want %d", len(requests), tt.wantCalls)
+			}
+			if requests[0]["temperature"] != defaultTemperature {
+				t.Errorf("first temperature = %v, want %v", requests[0]["temperature"], defaultTemperature)
+			}
+			if tt.wantCalls > 1 && requests[1]["temperature"] != 0.3 {
+				t.Errorf("retry temperature = %v, want 0.3", requests[1]["temperature"])
+			}
+
+			retry := output.Metadata.LowYieldRetry
+			switch {
+			case tt.wantKept == "" && retry != nil:
+				t.Errorf("low_yield_retry = %+v, want none", retry)
+			case tt.wantKept != "" && (retry == nil || retry.Kept != tt.wantKept || retry.Threshold != tt.ratio):
+				t.Errorf("low_yield_retry = %+v, want kept %q at threshold %v", retry, tt.wantKept, tt.ratio)
+			}
+			found := 0
+			for _, occurrences := range output.Entities {
+				if len(occurrences) > 0 {
+					found++
+				}
+			}
+			if found != tt.wantFound {
+				t.Errorf("found %d entities, want %d", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestProcessTextSkipsLowYieldRetryForBlankText(t *testing.T) {
+	llm := newStubLLM(t, completion(`{}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+	s.lowYieldRatio = 1
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, " \n ", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Metadata.LowYieldRetry != nil || len(llm.Requests()) > 1 {
+		t.Errorf("blank text retried: %d calls, metadata %+v", len(llm.Requests()), output.Metadata.LowYieldRetry)
+	}
+}
+
+func TestLowYieldRetryMergesUsageAndCountsOnce(t *testing.T) {
+	empty := `{"content": "not json", "tokens_evaluated": 100, "tokens_predicted": 5}`
+	full := `{"content": "{\"Medication\": [{\"value\": \"aspirin\", \"context\": \"on aspirin\"}]}", "tokens_evaluated": 100, "tokens_predicted": 20}`
+	// The first attempt's unparseable answer is re-prompted into an empty result
+	llm := newStubLLM(t, empty, completion(`{}`), full)
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+	s.lowYieldRatio = 1
+	s.lowYieldTemperature = 0 // Greedy decoding, not "unset"
+	s.maxReprompts = 1
+	primaryBefore := matchCounter("primary")
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient on aspirin.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := llm.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("LLM called %d times, want 3", len(requests))
+	}
+	if requests[2]["temperature"] != 0.0 {
+		t.Errorf("retry temperature = %v, want 0", requests[2]["temperature"])
+	}
+	if retry := output.Metadata.LowYieldRetry; retry == nil || retry.Kept != "retry" {
+		t.Fatalf("low_yield_retry = %+v, want the retry kept", retry)
+	}
+	usage := output.Metadata.TokenUsage
+	if usage.TokensEvaluated != 200 || usage.TokensPredicted != 25 {
+		t.Errorf("token usage = %+v, want both attempts' tokens", usage)
+	}
+	if output.Metadata.Parse.Reprompts != 1 {
+		t.Errorf("reprompts = %d, want the discarded attempt's reprompt", output.Metadata.Parse.Reprompts)
+	}
+	if got := matchCounter("primary") - primaryBefore; got != 1 {
+		t.Errorf("primary counter grew by %d, want only the kept attempt's 1", got)
+	}
+}
+
+func TestLowYieldRetryFailureKeepsFirstOutput(t *testing.T) {
+	var calls atomic.Int32
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			http.Error(w, "model unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, completion(`{}`))
+	}))
+	t.Cleanup(llm.Close)
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Medication:\n  type: string\n"})
+	s.lowYieldRatio = 0.5
+	s.lowYieldTemperature = 0.3
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient on aspirin.", ProcessOptions{})
+	if err != nil {
+		t.Fatalf("ProcessText() = %v, want the first extraction despite the failed retry", err)
+	}
+	if calls.Load() < 2 {
+		t.Fatalf("LLM called %d times, want a retry", calls.Load())
+	}
+	retry := output.Metadata.LowYieldRetry
+	if retry == nil || retry.Kept != "first" || retry.Error == "" {
+		t.Errorf("low-yield retry = %+v, want the failed attempt recorded with the first output kept", retry)
+	}
+}
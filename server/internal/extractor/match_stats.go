@@ -0,0 +1,40 @@
+package extractor
+
+import "expvar"
+
+// Running totals across extractions, served at /api/debug/vars
+var matchCounters = expvar.NewMap("extractor_matches")
+
+// MatchStats counts how the occurrences of an extraction were located.
+type MatchStats struct {
+	Primary  int `json:"primary"`  // Value found inside a match of its context
+	Fallback int `json:"fallback"` // Value found by searching the whole text
+	// Share of occurrences located by fallback; a high ratio means the LLM's
+	// contexts do not match the text well
+	FallbackRatio float64 `json:"fallback_ratio"`
+}
+
+// countMatches sets Metadata.Matches from the output's occurrences.
+func countMatches(output *ExtractionOutput) {
+	stats := MatchStats{}
+	for _, occurrences := range output.Entities {
+		for _, occurrence := range occurrences {
+			if occurrence.fallback {
+				stats.Fallback++
+			} else {
+				stats.Primary++
+			}
+		}
+	}
+	if total := stats.Primary + stats.Fallback; total > 0 {
+		stats.FallbackRatio = float64(stats.Fallback) / float64(total)
+	}
+	output.Metadata.Matches = stats
+}
+
+// recordMatches adds the match stats of a returned extraction to the
+// process-wide counters. Attempts a low-yield retry discards are not counted.
+func recordMatches(stats MatchStats) {
+	matchCounters.Add("primary", int64(stats.Primary))
+	matchCounters.Add("fallback", int64(stats.Fallback))
+}
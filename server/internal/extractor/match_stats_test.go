@@ -0,0 +1,41 @@
+package extractor
+
+import (
+	"context"
+	"expvar"
+	"testing"
+)
+
+// matchCounter reads a process-wide match counter.
+func matchCounter(name string) int64 {
+	if v, ok := matchCounters.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestProcessTextReportsMatchStats(t *testing.T) {
+	text := "Patient on aspirin daily. Takes metoprolol at night."
+	// The metoprolol context is not in the text, so it is only found by fallback
+	llm := newStubLLM(t, completion(`{"Medication": [
+		{"value": "aspirin", "context": "Patient on aspirin daily"},
+		{"value": "metoprolol", "context": "metoprolol 25 mg twice daily"}
+	]}`))
+	s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+	primaryBefore, fallbackBefore := matchCounter("primary"), matchCounter("fallback")
+
+	output, err := s.ProcessText(context.Background(), []string{"meds"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MatchStats{Primary: 1, Fallback: 1, FallbackRatio: 0.5}
+	if output.Metadata.Matches != want {
+		t.Errorf("matches = %+v, want %+v", output.Metadata.Matches, want)
+	}
+	if got := matchCounter("primary") - primaryBefore; got != 1 {
+		t.Errorf("primary counter grew by %d, want 1", got)
+	}
+	if got := matchCounter("fallback") - fallbackBefore; got != 1 {
+		t.Errorf("fallback counter grew by %d, want 1", got)
+	}
+}
@@ -0,0 +1,66 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+// Schema meta key naming the model a schema extracts best with
+const schemaModelKey = "_model"
+
+// Policies for schemas of one request preferring different models, set with
+// llm.model_conflicts
+const (
+	ModelConflictDefault = "default" // Use llm.model and log a warning
+	ModelConflictError   = "error"   // Reject the request
+)
+
+// ErrConflictingModels is returned when the schemas of a request prefer
+// different models and llm.model_conflicts is "error".
+var ErrConflictingModels = errors.New("schemas prefer different models")
+
+func validateModelConflictPolicy(policy string) error {
+	switch policy {
+	case "", ModelConflictDefault, ModelConflictError:
+		return nil
+	default:
+		return fmt.Errorf("invalid model conflict policy %q, expected %q or %q", policy, ModelConflictDefault, ModelConflictError)
+	}
+}
+
+// schemaModel returns the model a schema prefers, if any.
+func schemaModel(schema Schema) string {
+	model, _ := schema[schemaModelKey].(string)
+	return model
+}
+
+// resolveModel picks the model for a request from the _model of its schemas.
+// Schemas without a preference defer to the others; when none has one, or
+// they disagree under the default policy, llm.model is used.
+func (s *ExtractorService) resolveModel(schemaNames []string, inline Schema) (string, error) {
+	models := []string{}
+	addModel := func(model string) {
+		if model != "" && !slices.Contains(models, model) {
+			models = append(models, model)
+		}
+	}
+	for _, name := range schemaNames {
+		addModel(schemaModel(s.Schemas[name]))
+	}
+	addModel(schemaModel(inline))
+
+	switch {
+	case len(models) == 0:
+		return s.model, nil
+	case len(models) == 1:
+		return models[0], nil
+	case s.modelConflicts == ModelConflictError:
+		return "", fmt.Errorf("%w: %v", ErrConflictingModels, models)
+	default:
+		s.logger.Warn("Schemas prefer different models, using the default", zap.Strings("models", models), zap.String("default", s.model))
+		return s.model, nil
+	}
+}
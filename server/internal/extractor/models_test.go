@@ -0,0 +1,62 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProcessTextRoutesToSchemaModel(t *testing.T) {
+	llm := newStubLLM(t, completion(`{}`))
+	s := newTestService(t, llm.URL, map[string]string{
+		"radiology.yaml": "_model: radiology-7b\nFinding:\n  type: string\n",
+		"imaging.yaml":   "_model: radiology-7b\nModality:\n  type: string\n",
+		"labs.yaml":      "_model: general-13b\nGlucose:\n  type: number\n",
+		"vitals.yaml":    "HeartRate:\n  type: number\n",
+	})
+	s.model = "default-8b"
+
+	tests := []struct {
+		name    string
+		schemas []string
+		want    string
+	}{
+		{"single schema", []string{"radiology"}, "radiology-7b"},
+		{"agreeing schemas", []string{"radiology", "imaging"}, "radiology-7b"},
+		{"schema without preference defers", []string{"vitals", "labs"}, "general-13b"},
+		{"no preference", []string{"vitals"}, "default-8b"},
+		{"conflict falls back", []string{"radiology", "labs"}, "default-8b"},
+	}
+	for i, tt := range tests {
+		output, err := s.ProcessText(context.Background(), tt.schemas, "HR 80", ProcessOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := llm.Requests()[i]["model"]; got != tt.want {
+			t.Errorf("%s: requested model %v, want %q", tt.name, got, tt.want)
+		}
+		if output.Metadata.Model != tt.want {
+			t.Errorf("%s: metadata model = %q, want %q", tt.name, output.Metadata.Model, tt.want)
+		}
+	}
+
+	s.modelConflicts = ModelConflictError
+	calls := len(llm.Requests())
+	if _, err := s.ProcessText(context.Background(), []string{"radiology", "labs"}, "HR 80", ProcessOptions{}); !errors.Is(err, ErrConflictingModels) {
+		t.Errorf("conflict under the error policy: err = %v, want ErrConflictingModels", err)
+	}
+	if len(llm.Requests()) != calls {
+		t.Error("LLM called despite conflicting models")
+	}
+}
+
+func TestCallLLMOmitsModelWhenUnset(t *testing.T) {
+	llm := newStubLLM(t, completion(`{}`))
+	s := newTestService(t, llm.URL, nil)
+	if _, _, err := s.callLLM(context.Background(), s.llmServerURL, "", nil, "prompt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := llm.Requests()[0]["model"]; ok {
+		t.Error("payload names a model, want the server default")
+	}
+}
@@ -0,0 +1,55 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProcessTextNearestContextOnly(t *testing.T) {
+	text := "Day 1 - Medication: aspirin.\nDay 2 - Medication: aspirin.\nDay 3 - Medication: aspirin."
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "Medication: aspirin"}]}`))
+
+	tests := map[string]struct {
+		nearestOnly bool
+		want        int
+	}{
+		"every repetition":   {false, 3},
+		"nearest match only": {true, 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+			s.match.nearestContextOnly = tt.nearestOnly
+
+			output, err := s.ProcessText(context.Background(), []string{"meds"}, text, ProcessOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			meds := output.Entities["Medication"]
+			if len(meds) != tt.want {
+				t.Fatalf("got %d occurrences, want %d", len(meds), tt.want)
+			}
+			if got := string([]rune(text)[meds[0].Position.Start:meds[0].Position.End]); got != "aspirin" {
+				t.Errorf("first occurrence covers %q, want aspirin", got)
+			}
+		})
+	}
+}
+
+func TestNearestSpan(t *testing.T) {
+	spans := [][]int{{0, 10}, {20, 30}, {40, 50}}
+	tests := map[string]struct {
+		target []int
+		want   int
+	}{
+		"inside a span":     {[]int{22, 25}, 20},
+		"between spans":     {[]int{33, 35}, 20},
+		"closer to later":   {[]int{37, 38}, 40},
+		"tie takes earlier": {[]int{15, 15}, 0},
+	}
+	for name, tt := range tests {
+		if got := nearestSpan(spans, tt.target); got[0] != tt.want {
+			t.Errorf("%s: nearest span starts at %d, want %d", name, got[0], tt.want)
+		}
+	}
+}
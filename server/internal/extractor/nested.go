@@ -0,0 +1,81 @@
+package extractor
+
+import (
+	"sort"
+	"strings"
+)
+
+// Key under which NestDottedEntities keeps the occurrences of an entity that
+// is also the parent of other entities
+const NestedOccurrencesKey = "_occurrences"
+
+// NestedExtractionOutput is an ExtractionOutput whose entities are rebuilt
+// into the nested structure of the schema.
+type NestedExtractionOutput struct {
+	Text        string             `json:"text"`
+	Entities    map[string]any     `json:"entities"`
+	Metadata    ExtractionMetadata `json:"metadata"`
+	Debug       *ExtractionDebug   `json:"debug,omitempty"`
+	Diagnostics *EntityDiagnostics `json:"diagnostics,omitempty"`
+	// Header values, as in ExtractionOutput
+	DocumentMetadata DocumentMetadata `json:"document_metadata,omitempty"`
+}
+
+// NestOutput returns the output with its entities nested; see
+// NestDottedEntities.
+func NestOutput(output *ExtractionOutput) *NestedExtractionOutput {
+	return &NestedExtractionOutput{
+		Text:             output.Text,
+		Entities:         NestDottedEntities(output.Entities, output.nestingDelimiter),
+		Metadata:         output.Metadata,
+		Debug:            output.Debug,
+		Diagnostics:      output.Diagnostics,
+		DocumentMetadata: output.DocumentMetadata,
+	}
+}
+
+// NestDottedEntities rebuilds the hierarchy of flat entity keys by splitting
+// them on delimiter, so "Vital signs.Temperature" ends up at
+// ["Vital signs"]["Temperature"]. Leaves hold their occurrences. A key that is
+// both a leaf and a parent, such as "Labs" next to "Labs.WBC", becomes an
+// object with its own occurrences under NestedOccurrencesKey.
+func NestDottedEntities(entities map[string][]EntityOccurrence, delimiter string) map[string]any {
+	if delimiter == "" {
+		delimiter = "."
+	}
+	// Sorted so parents are seen before their children and the result does
+	// not depend on map order
+	names := make([]string, 0, len(entities))
+	for name := range entities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nested := make(map[string]any)
+	for _, name := range names {
+		parts := strings.Split(name, delimiter)
+		node := nested
+		for _, part := range parts[:len(parts)-1] {
+			switch child := node[part].(type) {
+			case map[string]any:
+				node = child
+			case []EntityOccurrence:
+				// A leaf turns out to be a parent; keep its occurrences inside it
+				parent := map[string]any{NestedOccurrencesKey: child}
+				node[part] = parent
+				node = parent
+			default:
+				parent := make(map[string]any)
+				node[part] = parent
+				node = parent
+			}
+		}
+		leaf := parts[len(parts)-1]
+		if parent, ok := node[leaf].(map[string]any); ok {
+			parent[NestedOccurrencesKey] = entities[name]
+			continue
+		}
+		node[leaf] = entities[name]
+	}
+	return nested
+}
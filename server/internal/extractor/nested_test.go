@@ -0,0 +1,80 @@
+package extractor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// flattenNested undoes NestDottedEntities, for round-trip checks.
+func flattenNested(nested map[string]any, prefix, delimiter string, flat map[string][]EntityOccurrence) {
+	for key, value := range nested {
+		name := key
+		if key == NestedOccurrencesKey {
+			name = prefix
+		} else if prefix != "" {
+			name = prefix + delimiter + key
+		}
+		switch v := value.(type) {
+		case []EntityOccurrence:
+			flat[name] = v
+		case map[string]any:
+			flattenNested(v, name, delimiter, flat)
+		}
+	}
+}
+
+func TestNestOutputRoundTrip(t *testing.T) {
+	text := "Temp 38.2 C, BP 120/80, on aspirin."
+	llm := newStubLLM(t, completion(`{
+		"Vital signs.Temperature": [{"value": "38.2", "context": "Temp 38.2 C"}],
+		"Vital signs.Blood pressure": [{"value": "120/80", "context": "BP 120/80"}],
+		"Medication": [{"value": "aspirin", "context": "on aspirin"}]
+	}`))
+	schema := `
+Medication:
+  type: string
+Vital signs:
+  type: object
+  properties:
+    Temperature:
+      type: number
+    Blood pressure:
+      type: string
+`
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": schema})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := NestOutput(output)
+	vitals, ok := nested.Entities["Vital signs"].(map[string]any)
+	if !ok {
+		t.Fatalf("Vital signs = %#v, want an object", nested.Entities["Vital signs"])
+	}
+	if temps, _ := vitals["Temperature"].([]EntityOccurrence); len(temps) != 1 || temps[0].Value != "38.2" {
+		t.Errorf("Vital signs.Temperature = %+v, want 38.2", vitals["Temperature"])
+	}
+
+	flat := make(map[string][]EntityOccurrence)
+	flattenNested(nested.Entities, "", ".", flat)
+	if !reflect.DeepEqual(flat, output.Entities) {
+		t.Errorf("round trip = %+v, want %+v", flat, output.Entities)
+	}
+}
+
+func TestNestDottedEntitiesLeafAndParent(t *testing.T) {
+	labs := []EntityOccurrence{{Value: "CBC"}}
+	wbc := []EntityOccurrence{{Value: 7.1}}
+	entities := map[string][]EntityOccurrence{"Labs": labs, "Labs/WBC": wbc}
+
+	want := map[string]any{
+		"Labs": map[string]any{NestedOccurrencesKey: labs, "WBC": wbc},
+	}
+	for range 10 { // Map order must not matter
+		if got := NestDottedEntities(entities, "/"); !reflect.DeepEqual(got, want) {
+			t.Fatalf("nested = %#v, want %#v", got, want)
+		}
+	}
+}
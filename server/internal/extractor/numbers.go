@@ -0,0 +1,67 @@
+package extractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Separators accepted between digit groups of a number: comma, space,
+// no-break space and narrow no-break space
+const thousandsSeparatorClass = `[, \x{00A0}\x{202F}]`
+
+// Numbers written with or without thousands separators, with an optional
+// decimal part
+var (
+	groupedNumberRegex      = regexp.MustCompile(`^(\d{1,3}(?:` + thousandsSeparatorClass + `\d{3})+|\d+)(\.\d+)?$`)
+	thousandsSeparatorRegex = regexp.MustCompile(thousandsSeparatorClass)
+)
+
+// numberEntities returns the entities the schema types as number or integer.
+func numberEntities(schema Schema, delimiter string) map[string]bool {
+	entities := make(map[string]bool)
+	walkEntityDefinitions(schema, delimiter, func(fullName string, def map[string]any) {
+		if def["type"] == "number" || def["type"] == "integer" {
+			entities[fullName] = true
+		}
+	})
+	return entities
+}
+
+// markNumericValues flags the occurrences of the given entities so that
+// their values match whatever thousands separators the text uses.
+func markNumericValues(rawExtraction RawLLMExtraction, entities map[string]bool) int {
+	marked := 0
+	for entityName := range entities {
+		for i := range rawExtraction[entityName] {
+			rawExtraction[entityName][i].Numeric = true
+			marked++
+		}
+	}
+	return marked
+}
+
+// thousandsPattern returns a regex matching the number value with or without
+// separators between its digit groups, so "11200", "11,200" and "11 200" all
+// match one another. It reports false when value is not such a number.
+func thousandsPattern(value string) (string, bool) {
+	match := groupedNumberRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return "", false
+	}
+	digits := thousandsSeparatorRegex.ReplaceAllString(match[1], "")
+	if len(digits) <= 3 {
+		return "", false // Too short to carry a separator
+	}
+
+	var pattern strings.Builder
+	first := len(digits) % 3
+	if first == 0 {
+		first = 3
+	}
+	pattern.WriteString(digits[:first])
+	for i := first; i < len(digits); i += 3 {
+		pattern.WriteString(thousandsSeparatorClass + "?" + digits[i:i+3])
+	}
+	pattern.WriteString(regexp.QuoteMeta(match[2]))
+	return pattern.String(), true
+}
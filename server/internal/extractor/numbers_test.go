@@ -0,0 +1,44 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestProcessTextMatchesThousandsSeparatorVariants(t *testing.T) {
+	forms := []string{"11200", "11,200", "11 200", "11\u00a0200"}
+	for _, textForm := range forms {
+		for _, valueForm := range forms {
+			t.Run(fmt.Sprintf("%q in text, %q from LLM", textForm, valueForm), func(t *testing.T) {
+				text := "CBC drawn. WBC " + textForm + " cells/uL."
+				llm := newStubLLM(t, completion(fmt.Sprintf(`{"WBC": [{"value": %q, "context": "WBC %s cells/uL"}]}`, valueForm, textForm)))
+				s := newTestService(t, llm.URL, map[string]string{"labs.yaml": "WBC:\n  type: number\n"})
+
+				output, err := s.ProcessText(context.Background(), []string{"labs"}, text, ProcessOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				wbc := output.Entities["WBC"]
+				if len(wbc) != 1 {
+					t.Fatalf("got %d WBC occurrences, want 1", len(wbc))
+				}
+				if got := string([]rune(text)[wbc[0].Position.Start:wbc[0].Position.End]); got != textForm {
+					t.Errorf("highlight covers %q, want the text's form %q", got, textForm)
+				}
+			})
+		}
+	}
+}
+
+func TestThousandsPatternLeavesOtherValuesAlone(t *testing.T) {
+	for _, value := range []string{"120", "7.2", "1,20", "12a00", "BP 120/80"} {
+		if pattern, ok := thousandsPattern(value); ok {
+			t.Errorf("thousandsPattern(%q) = %q, want no pattern", value, pattern)
+		}
+	}
+	if pattern, ok := thousandsPattern("1234567.5"); !ok || !strings.HasSuffix(pattern, `567\.5`) {
+		t.Errorf("thousandsPattern(1234567.5) = %q, %v", pattern, ok)
+	}
+}
@@ -0,0 +1,36 @@
+package extractor
+
+import "testing"
+
+func TestFindEntityPositionsEmitsDistinctIDs(t *testing.T) {
+	// The context occurs twice and the fallback value three times, so single
+	// LLM occurrences produce several matches each
+	text := "BP 120/80 on arrival. BP 120/80 on arrival. Aspirin given; aspirin continued; aspirin at discharge."
+	raw := RawLLMExtraction{
+		"BP":         {{Value: "120/80", Context: "BP 120/80 on arrival"}},
+		"Medication": {{Value: "aspirin", Context: "not in the text"}, {Value: "Aspirin", Context: "Aspirin given"}},
+	}
+	s := newTestService(t, "http://127.0.0.1:1", nil)
+	output, err := s.findEntityPositions(text, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	groups := map[string]int{}
+	for _, occs := range output.Entities {
+		for _, occ := range occs {
+			if seen[occ.ID] {
+				t.Errorf("duplicate ID %q", occ.ID)
+			}
+			seen[occ.ID] = true
+			groups[occ.GroupID]++
+		}
+	}
+	if len(seen) != 6 {
+		t.Errorf("got %d occurrences, want 6", len(seen))
+	}
+	if groups["entity-BP-0"] != 2 || groups["entity-Medication-0"] != 3 {
+		t.Errorf("group sizes = %v, want the matches of each LLM occurrence grouped", groups)
+	}
+}
@@ -0,0 +1,40 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProcessTextPositionMappings(t *testing.T) {
+	text := "Chief complaint:\r\nchest pain\r\n\r\nMedications:\r\naspirin daily"
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "aspirin daily"}]}`))
+
+	tests := map[string]struct {
+		opts     ProcessOptions
+		relative func(output *ExtractionOutput) string
+	}{
+		"normalized": {ProcessOptions{}, func(output *ExtractionOutput) string { return output.Text }},
+		"original":   {ProcessOptions{OriginalPositions: true}, func(*ExtractionOutput) string { return text }},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+			output, err := s.ProcessText(context.Background(), []string{"meds"}, text, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			meds := output.Entities["Medication"]
+			if len(meds) != 1 {
+				t.Fatalf("got %d medications, want 1", len(meds))
+			}
+			runes := []rune(tt.relative(output))
+			if got := string(runes[meds[0].Position.Start:meds[0].Position.End]); got != "aspirin" {
+				t.Errorf("value position covers %q, want aspirin", got)
+			}
+			context := meds[0].Context.Position
+			if got := string(runes[context.Start:context.End]); got != "aspirin daily" {
+				t.Errorf("context position covers %q, want aspirin daily", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownOutputTemplate is returned for a template name that is not in
+// output_templates.dir.
+var ErrUnknownOutputTemplate = errors.New("unknown output template")
+
+// Placeholders of an output template: "{{Entity}}" or "{{Entity|default}}"
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([^{}|]+?)\s*(?:\|([^{}]*))?\}\}`)
+
+// Separator of the values of an entity found more than once
+const templateValueSeparator = "; "
+
+// templateField is one field of an output template.
+type templateField struct {
+	Key   string
+	Value string
+}
+
+// OutputTemplate is a fixed report shape: a flat JSON object whose string
+// values may reference entities with "{{Entity}}" placeholders, filled with
+// the values extracted for them. Fields keep the template's order.
+type OutputTemplate struct {
+	fields []templateField
+}
+
+// UnmarshalJSON reads the template object, keeping the order of its fields.
+func (t *OutputTemplate) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("output template must be a JSON object of field names to strings")
+	}
+	t.fields = nil
+	seen := make(map[string]bool)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("invalid output template: %w", err)
+		}
+		key := token.(string) // Object keys are always strings
+		var value string
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("output template field %q must be a string", key)
+		}
+		if seen[key] {
+			return fmt.Errorf("output template field %q is repeated", key)
+		}
+		seen[key] = true
+		t.fields = append(t.fields, templateField{Key: key, Value: value})
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("invalid output template: %w", err)
+	}
+	return nil
+}
+
+// Fill renders the template with the values of output. A placeholder whose
+// entity was not found becomes its default, or "" without one; the values
+// of an entity found more than once are joined in text order.
+func (t *OutputTemplate) Fill(output *ExtractionOutput) *TemplateReport {
+	report := &TemplateReport{fields: make([]templateField, len(t.fields))}
+	for i, field := range t.fields {
+		value := templatePlaceholder.ReplaceAllStringFunc(field.Value, func(placeholder string) string {
+			match := templatePlaceholder.FindStringSubmatch(placeholder)
+			if values := entityValueStrings(output.Entities[match[1]]); len(values) > 0 {
+				return strings.Join(values, templateValueSeparator)
+			}
+			return strings.TrimSpace(match[2])
+		})
+		report.fields[i] = templateField{Key: field.Key, Value: value}
+	}
+	return report
+}
+
+// entityValueStrings returns the distinct values of the occurrences as text,
+// in the order they appear in the document.
+func entityValueStrings(occurrences []EntityOccurrence) []string {
+	sorted := append([]EntityOccurrence(nil), occurrences...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Position.Start < sorted[j].Position.Start })
+	values := []string{}
+	seen := make(map[string]bool)
+	for _, occurrence := range sorted {
+		value := fmt.Sprint(occurrence.Value)
+		if number, ok := occurrence.Value.(float64); ok {
+			value = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		if value != "" && !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// TemplateReport is a filled OutputTemplate, encoded as a JSON object in the
+// template's field order.
+type TemplateReport struct {
+	fields []templateField
+}
+
+func (r *TemplateReport) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// loadOutputTemplates reads the named output templates from dir, one
+// "<name>.json" file each. A missing directory leaves none.
+func loadOutputTemplates(dir string) (map[string]*OutputTemplate, error) {
+	templates := make(map[string]*OutputTemplate)
+	if dir == "" {
+		return templates, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template directory %q: %w", dir, err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read output template %s: %w", file, err)
+		}
+		template := &OutputTemplate{}
+		if err := json.Unmarshal(content, template); err != nil {
+			return nil, fmt.Errorf("output template %s: %w", file, err)
+		}
+		templates[strings.TrimSuffix(filepath.Base(file), ".json")] = template
+	}
+	return templates, nil
+}
+
+// OutputTemplate returns the template named name in output_templates.dir.
+func (s *ExtractorService) OutputTemplate(name string) (*OutputTemplate, error) {
+	template, ok := s.outputTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownOutputTemplate, name)
+	}
+	return template, nil
+}
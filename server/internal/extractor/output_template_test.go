@@ -0,0 +1,65 @@
+package extractor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputTemplateFill(t *testing.T) {
+	var template OutputTemplate
+	err := json.Unmarshal([]byte(`{
+		"patient_medications": "{{Medication}}",
+		"heart_rate": "{{HeartRate}} bpm",
+		"smoker": "{{Smoking|unknown}}",
+		"notes": "{{Comment}}",
+		"source": "med-ex"
+	}`), &template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &ExtractionOutput{Entities: map[string][]EntityOccurrence{
+		"Medication": { // Out of text order, with a repeat
+			{Value: "metformin", Position: Position{Start: 30, End: 39}},
+			{Value: "aspirin", Position: Position{Start: 10, End: 17}},
+			{Value: "aspirin", Position: Position{Start: 50, End: 57}},
+		},
+		"HeartRate": {{Value: 80.0, Position: Position{Start: 3, End: 5}}},
+	}}
+
+	report, err := json.Marshal(template.Fill(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"patient_medications":"aspirin; metformin","heart_rate":"80 bpm","smoker":"unknown","notes":"","source":"med-ex"}`
+	if string(report) != want {
+		t.Errorf("report = %s, want %s", report, want)
+	}
+}
+
+func TestOutputTemplateRejectsInvalidTemplates(t *testing.T) {
+	for _, invalid := range []string{`["{{Medication}}"]`, `{"dose": 5}`, `{"a": "x", "a": "y"}`} {
+		var template OutputTemplate
+		if err := json.Unmarshal([]byte(invalid), &template); err == nil {
+			t.Errorf("template %s accepted", invalid)
+		}
+	}
+}
+
+func TestLoadOutputTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "registry.json"), []byte(`{"drug": "{{Medication}}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := loadOutputTemplates(dir)
+	if err != nil || len(templates) != 1 || templates["registry"] == nil {
+		t.Fatalf("templates = %v, err = %v, want registry", templates, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"drug": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadOutputTemplates(dir); err == nil {
+		t.Error("loadOutputTemplates accepted an invalid template")
+	}
+}
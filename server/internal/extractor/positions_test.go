@@ -0,0 +1,64 @@
+package extractor
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// largeExtraction builds a long note and an extraction with many entities,
+// each with a few occurrences found through their context.
+func largeExtraction(entities, occurrences int) (string, RawLLMExtraction) {
+	var sb strings.Builder
+	raw := RawLLMExtraction{}
+	for e := range entities {
+		name := fmt.Sprintf("Entity%03d", e)
+		for o := range occurrences {
+			value := fmt.Sprintf("value-%d-%d", e, o)
+			context := fmt.Sprintf("observed %s during visit %d", value, o)
+			sb.WriteString("Unrelated filler sentence about the hospital course. ")
+			sb.WriteString(context + ". ")
+			raw[name] = append(raw[name], LLMOutputValueContext{Value: value, Context: context})
+		}
+	}
+	return sb.String(), raw
+}
+
+func TestFindEntityPositionsIsDeterministicAcrossWorkers(t *testing.T) {
+	text, raw := largeExtraction(40, 3)
+	s := newTestService(t, "http://127.0.0.1:1", nil)
+
+	s.match.workers = 1
+	sequential, err := s.findEntityPositions(text, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.match.workers = 8
+	concurrent, err := s.findEntityPositions(text, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sequential, concurrent) {
+		t.Error("concurrent output differs from sequential output")
+	}
+	if n := len(concurrent.Entities["Entity039"]); n != 3 {
+		t.Errorf("Entity039 has %d occurrences, want 3", n)
+	}
+}
+
+func BenchmarkFindEntityPositions(b *testing.B) {
+	text, raw := largeExtraction(100, 2)
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			s := newTestService(b, "http://127.0.0.1:1", nil)
+			s.match.workers = workers
+			b.ResetTimer()
+			for range b.N {
+				if _, err := s.findEntityPositions(text, raw); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package extractor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PostProcessor transforms an extraction after entity positions are found.
+type PostProcessor interface {
+	Process(output *ExtractionOutput) error
+}
+
+// PostProcessorFunc adapts a plain function to the PostProcessor interface.
+type PostProcessorFunc func(output *ExtractionOutput) error
+
+// Process calls f(output).
+func (f PostProcessorFunc) Process(output *ExtractionOutput) error {
+	return f(output)
+}
+
+var (
+	postProcessorsMu sync.RWMutex
+	postProcessors   = map[string]PostProcessor{
+		"sort_by_position": PostProcessorFunc(sortOccurrencesByPosition),
+		"dedup":            PostProcessorFunc(dedupOccurrences),
+		"collapse_repeats": PostProcessorFunc(collapseRepeatedOccurrences),
+	}
+)
+
+// RegisterPostProcessor makes a post-processor available by name to the
+// post_processing.processors setting. Registering an existing name replaces it.
+func RegisterPostProcessor(name string, processor PostProcessor) {
+	postProcessorsMu.Lock()
+	defer postProcessorsMu.Unlock()
+	postProcessors[name] = processor
+}
+
+// resolvePostProcessors looks up the named post-processors, keeping their order.
+func resolvePostProcessors(names []string) ([]PostProcessor, error) {
+	postProcessorsMu.RLock()
+	defer postProcessorsMu.RUnlock()
+	pipeline := make([]PostProcessor, 0, len(names))
+	for _, name := range names {
+		processor, ok := postProcessors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown post-processor %q", name)
+		}
+		pipeline = append(pipeline, processor)
+	}
+	return pipeline, nil
+}
+
+// runPostProcessors applies the configured pipeline in order, stopping at
+// the first error.
+func (s *ExtractorService) runPostProcessors(output *ExtractionOutput) error {
+	for i, processor := range s.postProcessors {
+		if err := processor.Process(output); err != nil {
+			return fmt.Errorf("post-processor %q: %w", s.postProcessorNames[i], err)
+		}
+	}
+	return nil
+}
+
+// sortOccurrencesByPosition orders the occurrences of every entity by start,
+// then end position.
+func sortOccurrencesByPosition(output *ExtractionOutput) error {
+	for _, occurrences := range output.Entities {
+		sort.SliceStable(occurrences, func(i, j int) bool {
+			a, b := occurrences[i].Position, occurrences[j].Position
+			if a.Start != b.Start {
+				return a.Start < b.Start
+			}
+			return a.End < b.End
+		})
+	}
+	return nil
+}
+
+// dedupOccurrences drops occurrences of an entity that repeat the value and
+// position of an earlier one, as when the LLM lists the same mention twice.
+func dedupOccurrences(output *ExtractionOutput) error {
+	type key struct {
+		value    string
+		position Position
+	}
+	for entityName, occurrences := range output.Entities {
+		seen := make(map[key]bool, len(occurrences))
+		kept := occurrences[:0]
+		for _, occurrence := range occurrences {
+			k := key{fmt.Sprint(occurrence.Value), occurrence.Position}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			kept = append(kept, occurrence)
+		}
+		output.Entities[entityName] = kept
+	}
+	return nil
+}
+
+// collapseRepeatedOccurrences merges occurrences of an entity whose value and
+// context text are both identical, as with page headers repeated throughout a
+// PDF. The earliest occurrence is kept, with Count and Positions recording
+// every place the text appeared.
+func collapseRepeatedOccurrences(output *ExtractionOutput) error {
+	type key struct {
+		value   string
+		context string
+	}
+	for entityName, occurrences := range output.Entities {
+		first := make(map[key]int, len(occurrences)) // Index in kept
+		kept := make([]EntityOccurrence, 0, len(occurrences))
+		for _, occurrence := range occurrences {
+			k := key{fmt.Sprint(occurrence.Value), occurrence.Context.Text}
+			if occurrence.Count == 0 { // Not collapsed before, e.g. by an earlier incremental extraction
+				occurrence.Count = 1
+				occurrence.Positions = []Position{occurrence.Position}
+			}
+			i, seen := first[k]
+			if !seen {
+				first[k] = len(kept)
+				kept = append(kept, occurrence)
+				continue
+			}
+			kept[i].Count += occurrence.Count
+			kept[i].Positions = append(kept[i].Positions, occurrence.Positions...)
+			if occurrence.Position.Start < kept[i].Position.Start {
+				positions, count := kept[i].Positions, kept[i].Count
+				occurrence.Positions, occurrence.Count = positions, count
+				kept[i] = occurrence
+			}
+		}
+		for i := range kept {
+			if kept[i].Count == 1 {
+				kept[i].Count, kept[i].Positions = 0, nil // Nothing was collapsed
+				continue
+			}
+			sort.Slice(kept[i].Positions, func(a, b int) bool {
+				return kept[i].Positions[a].Start < kept[i].Positions[b].Start
+			})
+		}
+		output.Entities[entityName] = kept
+	}
+	return nil
+}
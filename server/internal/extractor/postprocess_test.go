@@ -0,0 +1,150 @@
+package extractor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+// keepFirst keeps only the first occurrence of every entity.
+func keepFirst(output *ExtractionOutput) error {
+	for entityName, occurrences := range output.Entities {
+		if len(occurrences) > 1 {
+			output.Entities[entityName] = occurrences[:1]
+		}
+	}
+	return nil
+}
+
+func TestPostProcessorPipelineOrder(t *testing.T) {
+	RegisterPostProcessor("keep_first", PostProcessorFunc(keepFirst))
+
+	tests := map[string]struct {
+		pipeline  []string
+		wantStart int
+	}{
+		"sort then keep first": {[]string{"sort_by_position", "keep_first"}, 5},
+		"keep first then sort": {[]string{"keep_first", "sort_by_position"}, 40},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			processors, err := resolvePostProcessors(tt.pipeline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &ExtractorService{postProcessors: processors, postProcessorNames: tt.pipeline}
+			output := &ExtractionOutput{Entities: map[string][]EntityOccurrence{
+				"Medication": {
+					{Value: "aspirin", Position: Position{Start: 40, End: 47}},
+					{Value: "aspirin", Position: Position{Start: 5, End: 12}},
+				},
+			}}
+
+			if err := s.runPostProcessors(output); err != nil {
+				t.Fatal(err)
+			}
+			meds := output.Entities["Medication"]
+			if len(meds) != 1 || meds[0].Position.Start != tt.wantStart {
+				t.Errorf("occurrences = %+v, want one starting at %d", meds, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestDedupOccurrences(t *testing.T) {
+	output := &ExtractionOutput{Entities: map[string][]EntityOccurrence{
+		"Medication": {
+			{Value: "aspirin", Position: Position{Start: 5, End: 12}},
+			{Value: "aspirin", Position: Position{Start: 5, End: 12}},
+			{Value: "aspirin", Position: Position{Start: 40, End: 47}},
+		},
+	}}
+	if err := dedupOccurrences(output); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(output.Entities["Medication"]); got != 2 {
+		t.Errorf("kept %d occurrences, want 2", got)
+	}
+}
+
+func TestResolvePostProcessorsRejectsUnknownName(t *testing.T) {
+	if _, err := resolvePostProcessors([]string{"dedup", "no_such_pass"}); err == nil {
+		t.Error("resolvePostProcessors accepted an unknown name")
+	}
+}
+
+func TestCollapseRepeatedOccurrences(t *testing.T) {
+	header := Context{Text: "St. Mary Clinic - Cardiology"}
+	output := &ExtractionOutput{Entities: map[string][]EntityOccurrence{
+		"Clinic": {
+			{Value: "St. Mary Clinic", Position: Position{Start: 300, End: 315}, Context: header},
+			{Value: "St. Mary Clinic", Position: Position{Start: 0, End: 15}, Context: header},
+			{Value: "St. Mary Clinic", Position: Position{Start: 150, End: 165}, Context: header},
+			{Value: "St. Mary Clinic", Position: Position{Start: 90, End: 105}, Context: Context{Text: "referred from St. Mary Clinic"}},
+		},
+	}}
+	if err := collapseRepeatedOccurrences(output); err != nil {
+		t.Fatal(err)
+	}
+
+	clinics := output.Entities["Clinic"]
+	if len(clinics) != 2 {
+		t.Fatalf("occurrences = %+v, want the header collapsed and the referral kept", clinics)
+	}
+	wantPositions := []Position{{0, 15}, {150, 165}, {300, 315}}
+	collapsed := clinics[0]
+	if collapsed.Position.Start != 0 || collapsed.Count != 3 || !reflect.DeepEqual(collapsed.Positions, wantPositions) {
+		t.Errorf("collapsed = %+v, want the first header with count 3 and positions %v", collapsed, wantPositions)
+	}
+	if clinics[1].Count != 0 || clinics[1].Positions != nil {
+		t.Errorf("single occurrence = %+v, want no count or positions", clinics[1])
+	}
+}
+
+func TestProcessTextCollapsesRepeatedBoilerplate(t *testing.T) {
+	page := "St. Mary Clinic - Cardiology\nSeen today.\n"
+	text := page + page + page
+	occurrence := `{"value": "St. Mary Clinic", "context": "St. Mary Clinic - Cardiology"}`
+	llm := newStubLLM(t, completion(`{"Clinic": [`+occurrence+`]}`)) // Placed on every page
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Clinic:\n  type: string\n"})
+	processors, err := resolvePostProcessors([]string{"collapse_repeats"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.postProcessors, s.postProcessorNames = processors, []string{"collapse_repeats"}
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clinics := output.Entities["Clinic"]
+	if len(clinics) != 1 || clinics[0].Count != 3 || len(clinics[0].Positions) != 3 {
+		t.Fatalf("occurrences = %+v, want one collapsed occurrence with count 3", clinics)
+	}
+	for i, position := range clinics[0].Positions {
+		if want := i * len(page); position.Start != want {
+			t.Errorf("position %d starts at %d, want %d", i, position.Start, want)
+		}
+	}
+}
+
+func TestNewExtractorServiceReadsPostProcessingSection(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	cfg.LLM.SchemaDirs = []string{t.TempDir()}
+	cfg.PostProcessing.Processors = []string{"dedup", "sort_by_position"}
+	s, err := NewExtractorService(cfg, zap.NewNop(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.postProcessorNames, cfg.PostProcessing.Processors) || len(s.postProcessors) != 2 {
+		t.Errorf("pipeline = %v, want %v", s.postProcessorNames, cfg.PostProcessing.Processors)
+	}
+
+	cfg.PostProcessing.Processors = []string{"no_such_pass"}
+	if _, err := NewExtractorService(cfg, zap.NewNop(), t.TempDir()); err == nil {
+		t.Error("NewExtractorService accepted an unknown post-processor")
+	}
+}
@@ -0,0 +1,66 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptUserContentStripsControlTokens(t *testing.T) {
+	// A translated template wrapped in ChatML like config/prompts/fr.txt
+	french := "<|im_start|>system\nVous êtes un système d'extraction médicale.\n<|im_end|>\n<|im_start|>user\n" +
+		"Extrayez ce schéma :\n{schema}\n{examples}Texte médical :\n{text}\n{instructions}Renvoyez uniquement un objet JSON.\n" +
+		"<|im_end|>\n<|im_start|>assistant\n" + frenchTemplate[strings.Index(frenchTemplate, "{section:"):]
+	frenchTemplate, err := parsePromptTemplate(french)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		style      string
+		lang       string
+		wantPrefix string // Start of the user turn
+		wantSuffix string // End of the user turn
+	}{
+		{"verbose", PromptStyleVerbose, "", "Extract the following entities", "outside the main JSON object in your response."},
+		{"concise", PromptStyleConcise, "", "Extract the entities in this schema", "Use [] for entities that are not present."},
+		{"fr", PromptStyleVerbose, "fr", "Extrayez ce schéma", "Renvoyez uniquement un objet JSON."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, "http://127.0.0.1:1", nil)
+			s.promptStyle = tt.style
+			s.promptTemplates = map[string]promptTemplate{"fr": frenchTemplate}
+			prompt, err := s.formatExtractionPrompt(Schema{"Age": map[string]any{"type": "number"}}, "Patient is 62.", ProcessOptions{PromptLang: tt.lang})
+			if err != nil {
+				t.Fatal(err)
+			}
+			content := promptUserContent(prompt)
+			for _, token := range []string{"<|im_start|>", "<|im_end|>", "system", "assistant"} {
+				if strings.Contains(content, token) {
+					t.Errorf("user content still contains %q:\n%s", token, content)
+				}
+			}
+			if !strings.HasPrefix(content, tt.wantPrefix) || !strings.HasSuffix(content, tt.wantSuffix) {
+				t.Errorf("user content is not exactly the user turn:\n%s", content)
+			}
+			if !strings.Contains(content, "Patient is 62.") || !strings.Contains(content, `"Age"`) {
+				t.Errorf("user content lost the text or schema:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestPromptUserContentCases(t *testing.T) {
+	tests := []struct{ prompt, want string }{
+		{"<|im_start|>system\nBe terse<|im_end|>\n<|im_start|>user\n Extract this \n<|im_end|>\n<|im_start|>assistant\n", "Extract this"},
+		{"<|im_start|>user\nno end marker", "no end marker"},
+		{"  plain prompt  ", "plain prompt"},
+		{"stray <|im_end|>tokens<|im_start|>", "stray tokens"},
+	}
+	for _, tt := range tests {
+		if got := promptUserContent(tt.prompt); got != tt.want {
+			t.Errorf("promptUserContent(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
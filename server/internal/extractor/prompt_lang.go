@@ -0,0 +1,190 @@
+package extractor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PromptLangEnglish is the built-in prompt language, rendered with the
+// template chosen by llm.prompt_style.
+const PromptLangEnglish = "en"
+
+// Placeholders of a translated prompt template
+const (
+	promptPlaceholderSchema       = "{schema}"       // Schema JSON, as sent in English prompts
+	promptPlaceholderExamples     = "{examples}"     // Few-shot examples section, may be empty
+	promptPlaceholderText         = "{text}"         // The medical text
+	promptPlaceholderInstructions = "{instructions}" // The template's own sections a request needs, may be empty
+	promptPlaceholderDelimiter    = "{delimiter}"    // Nesting delimiter of entity keys
+	promptPlaceholderDate         = "{date}"         // Reference date, in the reference_date section
+	promptPlaceholderEntities     = "{entities}"     // Quoted entity names, in the array_items and alternatives sections
+	promptPlaceholderMarker       = "{marker}"       // ArrayItemMarker, in the array_items section
+)
+
+// Sections of a translated template, each after the prompt on lines of its
+// own following a "{section:<name>}" line. They translate the instructions
+// the English prompts add for some requests and schemas, so every template
+// must define all of them.
+const (
+	promptSectionReferenceDate = "reference_date"
+	promptSectionTable         = "table"
+	promptSectionArrayItems    = "array_items"
+	promptSectionAlternatives  = "alternatives"
+)
+
+var promptSections = []string{promptSectionReferenceDate, promptSectionTable, promptSectionArrayItems, promptSectionAlternatives}
+
+// Matches the line that starts a section of a translated template
+var promptSectionPattern = regexp.MustCompile(`(?m)^\{section:([a-z_]+)\}[ \t]*\r?\n?`)
+
+// promptTemplate is a translated prompt template loaded from prompt_dir.
+type promptTemplate struct {
+	prompt   string            // Up to the first section
+	sections map[string]string // Section name to its text, without surrounding blank lines
+	hash     string            // Hex SHA-256 prefix of the file, so edits change the prompt version
+}
+
+// parsePromptTemplate splits a translated template into its prompt and
+// sections, rejecting templates that lack a placeholder or section.
+func parsePromptTemplate(content string) (promptTemplate, error) {
+	sum := sha256.Sum256([]byte(content))
+	template := promptTemplate{sections: make(map[string]string), hash: hex.EncodeToString(sum[:])[:12]}
+	matches := promptSectionPattern.FindAllStringSubmatchIndex(content, -1)
+	template.prompt = content
+	if len(matches) > 0 {
+		template.prompt = content[:matches[0][0]]
+	}
+	for i, match := range matches {
+		name := content[match[2]:match[3]]
+		if !slices.Contains(promptSections, name) {
+			return promptTemplate{}, fmt.Errorf("unknown section %q, expected one of %v", name, promptSections)
+		}
+		end := len(content)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		template.sections[name] = strings.Trim(content[match[1]:end], "\r\n")
+	}
+
+	for _, required := range []string{promptPlaceholderSchema, promptPlaceholderText} {
+		if !strings.Contains(template.prompt, required) {
+			return promptTemplate{}, fmt.Errorf("missing %s", required)
+		}
+	}
+	for _, name := range promptSections {
+		if template.sections[name] == "" {
+			return promptTemplate{}, fmt.Errorf("missing section {section:%s}", name)
+		}
+	}
+	return template, nil
+}
+
+// instructions renders the sections a prompt needs, the translated
+// counterpart of the English reference date, table, array item and
+// alternatives instructions.
+func (t promptTemplate) instructions(referenceDate *time.Time, tables bool, arrayItems, alternatives []string) string {
+	var sb strings.Builder
+	if referenceDate != nil {
+		sb.WriteString("\n" + strings.ReplaceAll(t.sections[promptSectionReferenceDate], promptPlaceholderDate, referenceDate.Format(referenceDateLayout)) + "\n")
+	}
+	if tables {
+		sb.WriteString("\n" + t.sections[promptSectionTable])
+	}
+	if len(arrayItems) > 0 {
+		sb.WriteString("\n" + strings.NewReplacer(
+			promptPlaceholderEntities, quoteEntityNames(arrayItems),
+			promptPlaceholderMarker, ArrayItemMarker,
+		).Replace(t.sections[promptSectionArrayItems]))
+	}
+	if len(alternatives) > 0 {
+		sb.WriteString("\n" + strings.ReplaceAll(t.sections[promptSectionAlternatives], promptPlaceholderEntities, quoteEntityNames(alternatives)))
+	}
+	return sb.String()
+}
+
+// quoteEntityNames lists entity names for a prompt, quoted and comma-separated.
+func quoteEntityNames(entities []string) string {
+	quoted := make([]string, len(entities))
+	for i, entity := range entities {
+		quoted[i] = fmt.Sprintf("%q", entity)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// loadPromptTemplates reads translated prompt templates from dir, one
+// "<lang>.txt" file per language. A missing directory leaves only English.
+func loadPromptTemplates(dir string) (map[string]promptTemplate, error) {
+	templates := make(map[string]promptTemplate)
+	if dir == "" {
+		return templates, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt directory %q: %w", dir, err)
+	}
+	for _, file := range files {
+		lang := strings.TrimSuffix(filepath.Base(file), ".txt")
+		if lang == PromptLangEnglish {
+			return nil, fmt.Errorf("prompt template %s: English is built in, use llm.prompt_style instead", file)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %s: %w", file, err)
+		}
+		template, err := parsePromptTemplate(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("prompt template %s: %w", file, err)
+		}
+		templates[lang] = template
+	}
+	return templates, nil
+}
+
+// PromptLangs lists the prompt languages a request may select with prompt_lang.
+func (s *ExtractorService) PromptLangs() []string {
+	langs := []string{PromptLangEnglish}
+	for lang := range s.promptTemplates {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs[1:])
+	return langs
+}
+
+// ValidatePromptLang rejects prompt languages with no template. An empty
+// language means the configured llm.prompt_lang.
+func (s *ExtractorService) ValidatePromptLang(lang string) error {
+	if lang == "" || lang == PromptLangEnglish {
+		return nil
+	}
+	if _, ok := s.promptTemplates[lang]; !ok {
+		return fmt.Errorf("unsupported prompt language %q, expected one of %v", lang, s.PromptLangs())
+	}
+	return nil
+}
+
+// promptLangFor returns the prompt language of a request.
+func (s *ExtractorService) promptLangFor(opts ProcessOptions) string {
+	if opts.PromptLang != "" {
+		return opts.PromptLang
+	}
+	return s.promptLang
+}
+
+// renderPromptTemplate fills a translated template. Only the instruction
+// block is translated: the schema and text are inserted as provided.
+func renderPromptTemplate(template, schemaJSON, examples, text, instructions, delimiter string) string {
+	return strings.NewReplacer(
+		promptPlaceholderSchema, schemaJSON,
+		promptPlaceholderExamples, examples,
+		promptPlaceholderText, text,
+		promptPlaceholderInstructions, instructions,
+		promptPlaceholderDelimiter, delimiter,
+	).Replace(template)
+}
@@ -0,0 +1,156 @@
+package extractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+// frenchTemplate is a complete translated template for tests.
+const frenchTemplate = `Extrayez les entités de ce schéma :
+{schema}
+{examples}Texte médical :
+{text}
+{instructions}Joignez les clés imbriquées par "{delimiter}".
+{section:reference_date}
+Date de référence : rédigé le {date}.
+{section:table}
+- Une ligne de tableau par objet.
+{section:array_items}
+- Éléments de {entities} avec "{marker}".
+{section:alternatives}
+- Candidats pour {entities}.
+`
+
+func TestPromptLangRendersTranslatedTemplate(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "prompts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	french := frenchTemplate
+	if err := os.WriteFile(filepath.Join(root, "prompts", "fr.txt"), []byte(french), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "vitals.yaml"), []byte("HeartRate:\n  type: number\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	llm := newStubLLM(t, completion(`{"HeartRate": [{"value": "80", "context": "FC 80"}]}`))
+
+	cfg := config.NewDefaultConfig()
+	cfg.LLM.ServerURL = llm.URL
+	cfg.LLM.SchemaDirs = []string{root}
+	cfg.LLM.PromptDir = "prompts" // Resolved against the project root
+	s, err := NewExtractorService(cfg, zap.NewNop(), root)
+	if err != nil {
+		t.Fatalf("NewExtractorService: %v", err)
+	}
+	if got := s.PromptLangs(); len(got) != 2 || got[0] != PromptLangEnglish || got[1] != "fr" {
+		t.Fatalf("PromptLangs() = %v, want [en fr]", got)
+	}
+
+	tests := []struct {
+		lang    string
+		want    string // Instruction text of the language
+		version string
+	}{
+		{"", "You are a medical information extraction system", PromptTemplateVersion},
+		{"fr", "Extrayez les entités de ce schéma", PromptTemplateVersion + "-fr-" + s.promptTemplates["fr"].hash},
+	}
+	text := "Patient stable, FC 80."
+	for i, tt := range tests {
+		output, err := s.ProcessText(context.Background(), []string{"vitals"}, text, ProcessOptions{PromptLang: tt.lang})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.lang, err)
+		}
+		prompt, _ := llm.Requests()[i]["prompt"].(string)
+		if !strings.Contains(prompt, tt.want) {
+			t.Errorf("%q: prompt lacks %q:\n%s", tt.lang, tt.want, prompt)
+		}
+		if !strings.Contains(prompt, text) || !strings.Contains(prompt, `"HeartRate"`) {
+			t.Errorf("%q: prompt should carry the text and schema as provided", tt.lang)
+		}
+		if strings.Contains(prompt, "{schema}") || strings.Contains(prompt, "{delimiter}") || strings.Contains(prompt, "{section:") {
+			t.Errorf("%q: prompt has unfilled placeholders or sections", tt.lang)
+		}
+		if output.Metadata.PromptVersion != tt.version {
+			t.Errorf("%q: prompt version = %q, want %q", tt.lang, output.Metadata.PromptVersion, tt.version)
+		}
+	}
+
+	referenceDate := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	if _, err := s.ProcessText(context.Background(), []string{"vitals"}, text, ProcessOptions{PromptLang: "fr", ReferenceDate: &referenceDate}); err != nil {
+		t.Fatal(err)
+	}
+	prompt, _ := llm.Requests()[len(tests)]["prompt"].(string)
+	if !strings.Contains(prompt, "Date de référence : rédigé le 2026-03-14.") {
+		t.Errorf("French prompt lacks the translated reference date:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "Reference date") || strings.Contains(prompt, "Une ligne de tableau") {
+		t.Errorf("French prompt carries English or unneeded instructions:\n%s", prompt)
+	}
+
+	if err := s.ValidatePromptLang("de"); err == nil {
+		t.Error("ValidatePromptLang(de) = nil, want an error without a template")
+	}
+}
+
+func TestLoadPromptTemplatesRejectsIncompleteTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "es.txt"), []byte("Texto: {text}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPromptTemplates(dir); err == nil {
+		t.Error("loadPromptTemplates accepted a template without {schema}")
+	}
+	if templates, err := loadPromptTemplates(filepath.Join(dir, "missing")); err != nil || len(templates) != 0 {
+		t.Errorf("missing directory: templates = %v, err = %v, want none", templates, err)
+	}
+}
+
+func TestParsePromptTemplate(t *testing.T) {
+	template, err := parsePromptTemplate(frenchTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(template.prompt, "{section:") || !strings.HasSuffix(template.prompt, "\"{delimiter}\".\n") {
+		t.Errorf("prompt = %q, want the text before the first section", template.prompt)
+	}
+	got := template.instructions(nil, true, []string{"Meds[].Dose"}, []string{"Diagnosis"})
+	want := "\n- Une ligne de tableau par objet.\n- Éléments de \"Meds[].Dose\" avec \"" + ArrayItemMarker + "\".\n- Candidats pour \"Diagnosis\"."
+	if got != want {
+		t.Errorf("instructions = %q, want %q", got, want)
+	}
+
+	for name, content := range map[string]string{
+		"missing section": strings.Replace(frenchTemplate, "{section:alternatives}\n- Candidats pour {entities}.\n", "", 1),
+		"unknown section": frenchTemplate + "{section:footer}\nFin.\n",
+		"empty section":   strings.Replace(frenchTemplate, "- Une ligne de tableau par objet.\n", "", 1),
+	} {
+		if _, err := parsePromptTemplate(content); err == nil {
+			t.Errorf("%s: parsePromptTemplate accepted the template", name)
+		}
+	}
+}
+
+func TestPromptVersionTracksTemplateContent(t *testing.T) {
+	original, err := parsePromptTemplate(frenchTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited, err := parsePromptTemplate(strings.Replace(frenchTemplate, "Extrayez", "Relevez", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ExtractorService{promptTemplates: map[string]promptTemplate{"fr": original}}
+	before := s.promptVersion("fr")
+	s.promptTemplates["fr"] = edited
+	if after := s.promptVersion("fr"); after == before || !strings.HasPrefix(after, PromptTemplateVersion+"-fr-") {
+		t.Errorf("versions before/after editing the template = %q/%q, want distinct %s-fr-<hash> versions", before, after, PromptTemplateVersion)
+	}
+}
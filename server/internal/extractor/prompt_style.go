@@ -0,0 +1,77 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Prompt templates selectable with llm.prompt_style
+const (
+	PromptStyleVerbose = "verbose" // Detailed rules and an output example, for weaker models
+	PromptStyleConcise = "concise" // Schema, text and a short instruction, for instruction-tuned models
+)
+
+// validatePromptStyle rejects unsupported llm.prompt_style values. An empty
+// style means verbose.
+func validatePromptStyle(style string) error {
+	switch style {
+	case "", PromptStyleVerbose, PromptStyleConcise:
+		return nil
+	default:
+		return fmt.Errorf("invalid prompt style %q, expected %q or %q", style, PromptStyleVerbose, PromptStyleConcise)
+	}
+}
+
+// PromptVersion identifies the prompt template this service sends, so
+// results produced with another template can be told apart.
+func (s *ExtractorService) PromptVersion() string {
+	return s.promptVersion(s.promptLang)
+}
+
+// CurrentPromptVersion returns the version this service now gives prompts in
+// the language of version, an earlier prompt_version, so results extracted
+// with a translated template are compared against that template.
+func (s *ExtractorService) CurrentPromptVersion(version string) string {
+	for lang := range s.promptTemplates {
+		if strings.HasPrefix(version, PromptTemplateVersion+"-"+lang+"-") {
+			return s.promptVersion(lang)
+		}
+	}
+	return s.PromptVersion()
+}
+
+// promptVersion identifies the template of prompts in lang. Translated
+// templates replace the prompt style, so they are identified by language and
+// by a hash of the template file, which is edited outside the code.
+func (s *ExtractorService) promptVersion(lang string) string {
+	if lang != "" && lang != PromptLangEnglish {
+		return PromptTemplateVersion + "-" + lang + "-" + s.promptTemplates[lang].hash
+	}
+	if s.promptStyle == PromptStyleConcise {
+		return PromptTemplateVersion + "-" + PromptStyleConcise
+	}
+	return PromptTemplateVersion
+}
+
+// concisePromptTemplate asks for the same JSON shape as the verbose template
+// in a fraction of the tokens. Its verbs are filled like the verbose one's:
+// schema, examples, text, reference date and extra instructions.
+const concisePromptTemplate = `<|im_start|>system
+You are a medical information extraction system. Your output MUST be a valid JSON object.
+<|im_end|>
+<|im_start|>user
+Extract the entities in this schema from the medical text.
+
+` + "```json" + `
+%s
+` + "```" + `
+%s
+Medical Text:
+` + "```" + `
+%s
+` + "```" + `
+%s
+Return ONLY a JSON object whose keys are the schema's entity names, using '{delimiter}' notation for nested properties (e.g. "Vital signs{delimiter}Temperature"). Map each key to a list of occurrences, each an object with the exact "value" as it appears in the text and a "context" of 3-5 words around it copied verbatim from the text. Use [] for entities that are not present.%s
+<|im_end|>
+<|im_start|>assistant
+`
@@ -0,0 +1,70 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPromptStyles(t *testing.T) {
+	text := "Patient on aspirin. HR 80."
+	schema := `
+Medication:
+  type: string
+Vitals:
+  type: object
+  properties:
+    HeartRate:
+      type: number
+`
+	response := completion(`{
+		"Medication": [{"value": "aspirin", "context": "Patient on aspirin"}],
+		"Vitals.HeartRate": [{"value": "80", "context": "HR 80"}]
+	}`)
+
+	prompts := map[string]string{}
+	for _, style := range []string{PromptStyleVerbose, PromptStyleConcise} {
+		t.Run(style, func(t *testing.T) {
+			llm := newStubLLM(t, response)
+			s := newTestService(t, llm.URL, map[string]string{"demo.yaml": schema})
+			s.promptStyle = style
+
+			output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(output.Entities["Medication"]) != 1 || len(output.Entities["Vitals.HeartRate"]) != 1 {
+				t.Errorf("entities = %+v, want Medication and Vitals.HeartRate", output.Entities)
+			}
+			if output.Metadata.PromptVersion != s.PromptVersion() {
+				t.Errorf("prompt version = %q, want %q", output.Metadata.PromptVersion, s.PromptVersion())
+			}
+
+			prompt, _ := llm.Requests()[0]["prompt"].(string)
+			for _, want := range []string{text, `"HeartRate"`, `"Medication"`} {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt does not contain %q", want)
+				}
+			}
+			prompts[style] = prompt
+		})
+	}
+
+	if len(prompts[PromptStyleConcise]) >= len(prompts[PromptStyleVerbose]) {
+		t.Errorf("concise prompt is %d bytes, verbose %d", len(prompts[PromptStyleConcise]), len(prompts[PromptStyleVerbose]))
+	}
+}
+
+func TestPromptVersionIncludesStyle(t *testing.T) {
+	s := &ExtractorService{promptStyle: PromptStyleVerbose}
+	if got := s.PromptVersion(); got != PromptTemplateVersion {
+		t.Errorf("verbose prompt version = %q, want %q", got, PromptTemplateVersion)
+	}
+	s.promptStyle = PromptStyleConcise
+	if got := s.PromptVersion(); got == PromptTemplateVersion {
+		t.Errorf("concise prompt version = %q, want it to differ from verbose", got)
+	}
+	if err := validatePromptStyle("terse"); err == nil {
+		t.Error("unknown prompt style accepted")
+	}
+}
@@ -0,0 +1,67 @@
+package extractor
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+)
+
+// regexCache is a concurrency-safe LRU cache of compiled regular expressions,
+// keyed by the full pattern string (including flags such as (?i)).
+// A capacity of zero or less disables caching.
+type regexCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Front is most recently used
+	entries  map[string]*list.Element
+}
+
+type regexCacheEntry struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+func newRegexCache(capacity int) *regexCache {
+	return &regexCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// compile returns the compiled pattern, compiling and caching it on a miss.
+// Compiled regexps are safe to share between goroutines.
+func (c *regexCache) compile(pattern string) (*regexp.Regexp, error) {
+	if c.capacity <= 0 {
+		return regexp.Compile(pattern)
+	}
+
+	c.mu.Lock()
+	if element, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(element)
+		regex := element.Value.(*regexCacheEntry).regex
+		c.mu.Unlock()
+		return regex, nil
+	}
+	c.mu.Unlock()
+
+	// Compile without holding the lock; a concurrent miss may compile twice
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*regexCacheEntry).regex, nil
+	}
+	c.entries[pattern] = c.order.PushFront(&regexCacheEntry{pattern: pattern, regex: regex})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*regexCacheEntry).pattern)
+	}
+	return regex, nil
+}
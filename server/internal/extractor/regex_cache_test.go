@@ -0,0 +1,79 @@
+package extractor
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestRegexCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newRegexCache(2)
+	a, _ := c.compile("a")
+	c.compile("b")
+	c.compile("a") // "b" is now the least recently used
+	c.compile("c")
+
+	if _, ok := c.entries["b"]; ok {
+		t.Error("least recently used pattern was not evicted")
+	}
+	if again, _ := c.compile("a"); again != a {
+		t.Error("cached pattern was recompiled")
+	}
+	if c.order.Len() != 2 {
+		t.Errorf("cache holds %d patterns, want 2", c.order.Len())
+	}
+}
+
+func TestRegexCacheDisabledAndInvalid(t *testing.T) {
+	c := newRegexCache(0)
+	c.compile("a")
+	if len(c.entries) != 0 {
+		t.Error("disabled cache stored a pattern")
+	}
+	if _, err := newRegexCache(4).compile("("); err == nil {
+		t.Error("invalid pattern compiled without error")
+	}
+}
+
+func TestRegexCacheConcurrentUse(t *testing.T) {
+	c := newRegexCache(8)
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				if _, err := c.compile(fmt.Sprintf("(?i)p%d", (i+j)%12)); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if c.order.Len() != len(c.entries) || c.order.Len() > 8 {
+		t.Errorf("cache is inconsistent: %d list entries, %d map entries", c.order.Len(), len(c.entries))
+	}
+}
+
+func BenchmarkRegexCompile(b *testing.B) {
+	patterns := make([]string, 64)
+	for i := range patterns {
+		patterns[i] = `(?i)` + regexp.QuoteMeta(fmt.Sprintf("Temperature 38.%d C, heart rate %d", i, 60+i))
+	}
+	b.Run("uncached", func(b *testing.B) {
+		for i := range b.N {
+			if _, err := regexp.Compile(patterns[i%len(patterns)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := newRegexCache(len(patterns))
+		for i := range b.N {
+			if _, err := c.compile(patterns[i%len(patterns)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package extractor
+
+import (
+	"strings"
+)
+
+// Parse outcomes recorded in ParseAttempts
+const (
+	parseOutcomeClean    = "clean"
+	parseOutcomeRepaired = "repaired"
+)
+
+// ParseAttempts records what it took to get a parseable LLM response.
+type ParseAttempts struct {
+	Repairs      int    `json:"repairs"`       // Responses that only parsed after repairJSON
+	Reprompts    int    `json:"reprompts"`     // Extra LLM calls after unparseable responses
+	EmptyRetries int    `json:"empty_retries"` // Extra LLM calls after responses with empty content
+	Outcome      string `json:"outcome"`       // "clean" or "repaired", for the response that was used
+}
+
+// repairJSON fixes the most common ways an LLM breaks its JSON output: text
+// around the object and trailing commas. It reports false when there was
+// nothing to repair.
+func repairJSON(s string) (string, bool) {
+	start, end := strings.Index(s, "{"), strings.LastIndex(s, "}")
+	if start < 0 || end < start {
+		return s, false
+	}
+	trimmed := s[start : end+1]
+
+	// Drop commas directly followed (ignoring whitespace) by a closing bracket,
+	// leaving string contents alone
+	var sb strings.Builder
+	sb.Grow(len(trimmed))
+	inString, escaped := false, false
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		if inString {
+			sb.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+		}
+		if c == ',' {
+			next := strings.TrimLeft(trimmed[i+1:], " \t\r\n")
+			if strings.HasPrefix(next, "}") || strings.HasPrefix(next, "]") {
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+
+	repaired := sb.String()
+	return repaired, repaired != s
+}
@@ -0,0 +1,81 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProcessTextRecordsRepair(t *testing.T) {
+	llm := newStubLLM(t, completion(`Here you go: {"Age": [{"value": "62", "context": "62 year old"},],}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Age:\n  type: number\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient is a 62 year old man.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ParseAttempts{Repairs: 1, Reprompts: 0, Outcome: parseOutcomeRepaired}
+	if output.Metadata.Parse != want {
+		t.Errorf("parse attempts = %+v, want %+v", output.Metadata.Parse, want)
+	}
+	if len(output.Entities["Age"]) != 1 {
+		t.Errorf("Age = %v, want the repaired occurrence", output.Entities["Age"])
+	}
+}
+
+func TestProcessTextRecordsReprompt(t *testing.T) {
+	llm := newStubLLM(t,
+		completion(`not JSON at all`),
+		completion(`{"Age": [{"value": "62", "context": "62 year old"}]}`),
+	)
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Age:\n  type: number\n"})
+	s.maxReprompts = 1
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient is a 62 year old man.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ParseAttempts{Repairs: 0, Reprompts: 1, Outcome: parseOutcomeClean}
+	if output.Metadata.Parse != want {
+		t.Errorf("parse attempts = %+v, want %+v", output.Metadata.Parse, want)
+	}
+	if n := len(llm.Requests()); n != 2 {
+		t.Errorf("LLM called %d times, want 2", n)
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := map[string]struct {
+		in, want string
+		changed  bool
+	}{
+		"trailing commas":  {`{"a": [1, 2,], }`, `{"a": [1, 2] }`, true},
+		"surrounding text": {"Sure!\n{\"a\": 1}\nDone.", `{"a": 1}`, true},
+		"comma in string":  {`{"a": "x,]"}`, `{"a": "x,]"}`, false},
+		"no object":        {`[1, 2]`, `[1, 2]`, false},
+	}
+	for name, tt := range tests {
+		got, changed := repairJSON(tt.in)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("%s: repairJSON(%q) = %q, %v; want %q, %v", name, tt.in, got, changed, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestProcessTextCountsOnlySuccessfulRepairs(t *testing.T) {
+	llm := newStubLLM(t,
+		// Repair strips the surrounding text, but the missing comma still fails to parse
+		completion(`Sure: {"Age": [{"value": "62" "context": "62 year old"}]}`),
+		completion(`{"Age": [{"value": "62", "context": "62 year old"}]}`),
+	)
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Age:\n  type: number\n"})
+	s.maxReprompts = 1
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient is a 62 year old man.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ParseAttempts{Repairs: 0, Reprompts: 1, Outcome: parseOutcomeClean}
+	if output.Metadata.Parse != want {
+		t.Errorf("parse attempts = %+v, want %+v", output.Metadata.Parse, want)
+	}
+}
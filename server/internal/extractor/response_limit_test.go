@@ -0,0 +1,23 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCallLLMRejectsOversizedResponse(t *testing.T) {
+	body := completion(strings.Repeat("x", 4096))
+	llm := newStubLLM(t, body)
+	s := newTestService(t, llm.URL, nil)
+
+	s.maxResponseBytes = int64(len(body)) - 1
+	if _, _, err := s.callLLM(context.Background(), s.llmServerURL, "", nil, "prompt"); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("callLLM error = %v, want a response size error", err)
+	}
+
+	s.maxResponseBytes = int64(len(body))
+	if _, _, err := s.callLLM(context.Background(), s.llmServerURL, "", nil, "prompt"); err != nil {
+		t.Fatalf("callLLM rejected a response at the limit: %v", err)
+	}
+}
@@ -1,10 +1,15 @@
 package extractor
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -14,6 +19,111 @@ import (
 
 type Schema map[string]any
 
+// asMap returns v as a plain map when it is a YAML mapping. yaml.v3 decodes
+// nested mappings into the parent's map type, so both Schema and
+// map[string]any show up inside a loaded schema.
+func asMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case Schema:
+		return m, true
+	case map[string]any:
+		return m, true
+	}
+	return nil, false
+}
+
+// Top-level schema keys that describe the schema itself rather than an
+// entity. They are kept out of the prompt but remain available to the UI.
+var schemaMetaKeys = []string{"_name", "_description", "_version", "_prompt", schemaModelKey}
+
+// ErrNoEntitiesSelected is returned instead of prompting the LLM with a
+// schema that has no entities left, e.g. one holding only meta keys.
+var ErrNoEntitiesSelected = errors.New("no entities selected")
+
+// isSchemaMetaKey reports whether key is one of the recognized meta keys.
+func isSchemaMetaKey(key string) bool {
+	return slices.Contains(schemaMetaKeys, key)
+}
+
+// SchemaMeta returns the recognized meta keys set on the schema.
+func SchemaMeta(schema Schema) map[string]any {
+	meta := make(map[string]any)
+	for _, key := range schemaMetaKeys {
+		if value, exists := schema[key]; exists {
+			meta[key] = value
+		}
+	}
+	return meta
+}
+
+// stripSchemaMeta returns a copy of the schema without its meta keys.
+func stripSchemaMeta(schema Schema) Schema {
+	stripped := make(Schema, len(schema))
+	for key, value := range schema {
+		if !isSchemaMetaKey(key) {
+			stripped[key] = value
+		}
+	}
+	return stripped
+}
+
+// yamlNodeKindName describes a YAML node kind for error messages.
+func yamlNodeKindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.ScalarNode:
+		return "scalar value"
+	case yaml.AliasNode:
+		return "alias"
+	default:
+		return "non-mapping node"
+	}
+}
+
+// walkEntityDefinitions calls fn for every entity definition of the schema,
+// including nested properties, table columns and the item properties of
+// arrays, with nested names joined by delimiter and ArrayItemMarker marking
+// item properties.
+func walkEntityDefinitions(schema Schema, delimiter string, fn func(fullName string, def map[string]any)) {
+	walkSchemaEntities(schema, delimiter, func(fullName string, def map[string]any) {
+		if def != nil {
+			fn(fullName, def)
+		}
+	})
+}
+
+// walkSchemaEntities is walkEntityDefinitions that also visits plain value
+// entities, whose definition is not a mapping ("Temperature: number"), with a
+// nil def. Together these are the names the prompt's schema shows the LLM.
+func walkSchemaEntities(schema Schema, delimiter string, fn func(fullName string, def map[string]any)) {
+	var recurse func(defs map[string]any, prefix string)
+	recurse = func(defs map[string]any, prefix string) {
+		for name, value := range defs {
+			fullName := name
+			if prefix != "" {
+				fullName = prefix + delimiter + name
+			}
+			def, ok := asMap(value)
+			if !ok {
+				fn(fullName, nil)
+				continue
+			}
+			fn(fullName, def)
+			if props, ok := asMap(def["properties"]); ok {
+				recurse(props, fullName)
+			}
+			if columns, ok := asMap(def["columns"]); ok && def["type"] == tableEntityType {
+				recurse(columns, fullName)
+			}
+			if props, ok := itemProperties(def); ok {
+				recurse(props, fullName+ArrayItemMarker)
+			}
+		}
+	}
+	recurse(schema, "")
+}
+
 // loadSchema loads a single YAML file using yaml.v3
 func loadSchema(schemaPath string) (Schema, error) {
 	yamlFile, err := os.ReadFile(schemaPath)
@@ -21,13 +131,27 @@ func loadSchema(schemaPath string) (Schema, error) {
 		return nil, fmt.Errorf("failed to read schema file %s: %w", schemaPath, err)
 	}
 
+	return parseSchema(yamlFile, schemaPath)
+}
+
+// parseSchema decodes schema YAML (or JSON, which is valid YAML) read from source.
+func parseSchema(data []byte, source string) (Schema, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schema YAML from %s: %w", source, err)
+	}
+	if len(root.Content) > 0 && root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("schema root must be a mapping of entity names to definitions, found a %s in %s",
+			yamlNodeKindName(root.Content[0].Kind), source)
+	}
+
 	var schema Schema
-	err = yaml.Unmarshal(yamlFile, &schema)
+	err := yaml.Unmarshal(data, &schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal schema YAML from %s: %w", schemaPath, err)
+		return nil, fmt.Errorf("failed to unmarshal schema YAML from %s: %w", source, err)
 	}
 	if schema == nil {
-		return nil, fmt.Errorf("schema unmarshalled to nil map for %s", schemaPath)
+		return nil, fmt.Errorf("schema unmarshalled to nil map for %s", source)
 	}
 
 	// No conversion or JSON round-trip needed!
@@ -35,7 +159,7 @@ func loadSchema(schemaPath string) (Schema, error) {
 }
 
 // loadSchemasFromDir loads all YAML files from a directory
-func loadSchemasFromDir(dirPath string, logger *zap.Logger) (map[string]Schema, []string, map[string]string, error) {
+func loadSchemasFromDir(dirPath string, strict bool, logger *zap.Logger) (map[string]Schema, []string, map[string]string, error) {
 	schemas := make(map[string]Schema)
 	schemaFiles := make(map[string]string) // Map name to file path
 	var schemaNames []string
@@ -50,6 +174,9 @@ func loadSchemasFromDir(dirPath string, logger *zap.Logger) (map[string]Schema,
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to read schema directory %s: %w", dirPath, err)
 	}
+	// Processed in lexical order so duplicate names resolve the same way on
+	// every filesystem: the lexically last file wins
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
 
 	for _, file := range files {
 		if file.IsDir() {
@@ -60,6 +187,10 @@ func loadSchemasFromDir(dirPath string, logger *zap.Logger) (map[string]Schema,
 		if strings.HasSuffix(strings.ToLower(fileName), ".yaml") || strings.HasSuffix(strings.ToLower(fileName), ".yml") {
 			filePath := filepath.Join(dirPath, fileName)
 			schemaData, err := loadSchema(filePath)
+			if err != nil && strict {
+				logger.Error("Failed to load or parse schema file", zap.String("file", fileName), zap.String("path", filePath), zap.Error(err))
+				return nil, nil, nil, err
+			}
 			if err != nil {
 				logger.Warn("Failed to load or parse schema file, skipping.",
 					zap.String("file", fileName), zap.String("path", filePath), zap.Error(err))
@@ -70,7 +201,9 @@ func loadSchemasFromDir(dirPath string, logger *zap.Logger) (map[string]Schema,
 			// Handle potential duplicate schema names (e.g., file.yaml and file.YAML)
 			if _, exists := schemas[schemaName]; exists {
 				logger.Warn("Duplicate schema name detected, overwriting previous definition.",
-					zap.String("schemaName", schemaName), zap.String("newFilePath", filePath))
+					zap.String("schemaName", schemaName),
+					zap.String("previousFilePath", schemaFiles[schemaName]),
+					zap.String("newFilePath", filePath))
 			}
 
 			schemas[schemaName] = schemaData
@@ -93,23 +226,208 @@ func loadSchemasFromDir(dirPath string, logger *zap.Logger) (map[string]Schema,
 	return schemas, schemaNames, schemaFiles, nil
 }
 
+// loadSchemasFromDirs loads schemas from each directory in order. A schema in a
+// later directory overrides a same-named schema from an earlier one, which lets
+// a local overrides directory be layered over a shared one. An entry may also
+// be a .zip or .tar.gz schema bundle. Unparseable files are skipped unless
+// strict is set, in which case they fail the load.
+func loadSchemasFromDirs(dirPaths []string, strict bool, logger *zap.Logger) (map[string]Schema, []string, map[string]string, error) {
+	schemas := make(map[string]Schema)
+	schemaFiles := make(map[string]string)
+
+	for _, dirPath := range dirPaths {
+		load := loadSchemasFromDir
+		if isSchemaArchive(dirPath) {
+			load = loadSchemasFromArchive
+		}
+		dirSchemas, dirSchemaNames, dirSchemaFiles, err := load(dirPath, strict, logger)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, schemaName := range dirSchemaNames {
+			if previousPath, exists := schemaFiles[schemaName]; exists {
+				logger.Warn("Duplicate schema name detected, overwriting previous definition.",
+					zap.String("schemaName", schemaName),
+					zap.String("previousFilePath", previousPath),
+					zap.String("newFilePath", dirSchemaFiles[schemaName]))
+			}
+			schemas[schemaName] = dirSchemas[schemaName]
+			schemaFiles[schemaName] = dirSchemaFiles[schemaName]
+		}
+	}
+
+	schemaNames := make([]string, 0, len(schemas))
+	for schemaName := range schemas {
+		schemaNames = append(schemaNames, schemaName)
+	}
+	sort.Strings(schemaNames)
+	return schemas, schemaNames, schemaFiles, nil
+}
+
+// Source reported for the keys of a request's inline schema
+const InlineSchemaName = "inline"
+
+// ParseInlineSchema parses a schema supplied with a request instead of loaded
+// from the schema directories. It must be structurally valid and define at
+// least one entity.
+func (s *ExtractorService) ParseInlineSchema(data []byte) (Schema, error) {
+	schema, err := parseSchema(data, "inline schema")
+	if err != nil {
+		return nil, err
+	}
+	if problems := validateSchemaStructure(schema, s.nestingDelimiter); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid inline schema: %s", strings.Join(problems, "; "))
+	}
+	if len(stripSchemaMeta(schema)) == 0 {
+		return nil, fmt.Errorf("inline schema defines no entities")
+	}
+	return schema, nil
+}
+
+// combineRequestSchemas combines the named schemas and then merges the
+// inline schema, if any, so its definitions win. With an inline schema the
+// named schemas may be empty.
+func (s *ExtractorService) combineRequestSchemas(schemaNames []string, inline Schema) (Schema, SchemaMergeReport, error) {
+	if inline == nil {
+		return s.CombineSchemasWithReport(schemaNames)
+	}
+
+	combined, report := make(Schema), SchemaMergeReport{Sources: make(map[string]string)}
+	if len(schemaNames) > 0 {
+		var err error
+		if combined, report, err = s.CombineSchemasWithReport(schemaNames); err != nil {
+			return nil, report, err
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(inline)) {
+		if previous, exists := report.Sources[key]; exists && !isSchemaMetaKey(key) {
+			report.Conflicts = append(report.Conflicts, SchemaConflict{Key: key, ShadowedSchema: previous, WinningSchema: InlineSchemaName})
+		}
+		combined[key] = inline[key]
+		report.Sources[key] = InlineSchemaName
+	}
+	return combined, report, nil
+}
+
+// validateSchemaStructure returns the structural problems of a parsed schema:
+// non-string types, and properties, item properties or table columns that are
+// not mappings of definitions. A definition that is not a mapping is a plain
+// value entity, which the loader and the prompt accept at any level.
+func validateSchemaStructure(schema Schema, delimiter string) []string {
+	problems := []string{}
+	var recurse func(defs map[string]any, prefix string)
+	recurse = func(defs map[string]any, prefix string) {
+		for _, name := range slices.Sorted(maps.Keys(defs)) {
+			fullName := name
+			if prefix != "" {
+				fullName = prefix + delimiter + name
+			}
+			def, ok := asMap(defs[name])
+			if !ok {
+				continue
+			}
+			if entityType, has := def["type"]; has {
+				if _, ok := entityType.(string); !ok {
+					problems = append(problems, fmt.Sprintf("%s: type must be a string", fullName))
+				}
+			}
+			if scope, has := def["search_scope"]; has {
+				if scope, ok := scope.(string); !ok || !isSearchScope(scope) {
+					problems = append(problems, fmt.Sprintf("%s: search_scope must be both, context or value", fullName))
+				}
+			}
+			if props, has := def["properties"]; has {
+				if propMap, ok := asMap(props); ok {
+					recurse(propMap, fullName)
+				} else {
+					problems = append(problems, fmt.Sprintf("%s: properties must be a mapping", fullName))
+				}
+			}
+			if items, ok := asMap(def["items"]); ok {
+				if props, has := items["properties"]; has {
+					if propMap, ok := asMap(props); ok {
+						recurse(propMap, fullName+ArrayItemMarker)
+					} else {
+						problems = append(problems, fmt.Sprintf("%s: items properties must be a mapping", fullName))
+					}
+				}
+			}
+			if def["type"] == tableEntityType {
+				if columns, ok := asMap(def["columns"]); ok {
+					recurse(columns, fullName)
+				} else {
+					problems = append(problems, fmt.Sprintf("%s: table must have a mapping of columns", fullName))
+				}
+			}
+		}
+	}
+	recurse(stripSchemaMeta(schema), "")
+	if model, has := schema[schemaModelKey]; has {
+		if _, ok := model.(string); !ok {
+			problems = append(problems, fmt.Sprintf("%s: must be a string", schemaModelKey))
+		}
+	}
+	return problems
+}
+
+// HashSchema returns a hex SHA-256 of the schema's canonical JSON encoding
+// (map keys are sorted), so equal definitions hash equally.
+func HashSchema(schema Schema) (string, error) {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode schema for hashing: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SchemaConflict records a top-level key defined by more than one combined
+// schema. The earlier definition is shadowed by the later one.
+type SchemaConflict struct {
+	Key            string `json:"key"`
+	ShadowedSchema string `json:"shadowed_schema"`
+	WinningSchema  string `json:"winning_schema"`
+}
+
+// SchemaMergeReport describes how a set of schemas was combined.
+type SchemaMergeReport struct {
+	Sources   map[string]string // Top-level key to the schema whose definition won
+	Conflicts []SchemaConflict  // In merge order
+}
+
 // Simple merge strategy: last schema wins on key conflict.
 func (s *ExtractorService) CombineSchemas(schemaNames []string) (Schema, error) {
+	combined, _, err := s.CombineSchemasWithReport(schemaNames)
+	return combined, err
+}
+
+// CombineSchemasWithReport merges schemas like CombineSchemas and also reports
+// which schema supplied each key and which definitions were shadowed.
+func (s *ExtractorService) CombineSchemasWithReport(schemaNames []string) (Schema, SchemaMergeReport, error) {
 	combined := make(Schema)
+	report := SchemaMergeReport{Sources: make(map[string]string)}
 	s.logger.Debug("Combining schemas", zap.Strings("names", schemaNames))
 
 	if len(schemaNames) == 0 {
-		return nil, fmt.Errorf("no schema names provided for combination")
+		return nil, report, fmt.Errorf("no schema names provided for combination")
 	}
 
 	for _, name := range schemaNames {
 		schema, exists := s.Schemas[name]
 		if !exists {
 			s.logger.Error("Schema not found during combination", zap.String("name", name))
-			return nil, fmt.Errorf("schema '%s' not found", name)
+			return nil, report, fmt.Errorf("schema '%s' not found", name)
 		}
 		// Merge schema into combined. Later schemas overwrite existing keys.
-		maps.Copy(combined, schema)
+		for _, key := range slices.Sorted(maps.Keys(schema)) {
+			// Meta keys describe each schema, so differing values are not conflicts
+			if previous, exists := report.Sources[key]; exists && previous != name && !isSchemaMetaKey(key) {
+				report.Conflicts = append(report.Conflicts, SchemaConflict{Key: key, ShadowedSchema: previous, WinningSchema: name})
+				s.logger.Debug("Schema key shadowed", zap.String("key", key), zap.String("shadowed", previous), zap.String("winner", name))
+			}
+			combined[key] = schema[key]
+			report.Sources[key] = name
+		}
 		s.logger.Debug("Merged schema", zap.String("name", name), zap.Int("keys_in_schema", len(schema)), zap.Int("total_keys_now", len(combined)))
 	}
 
@@ -125,5 +443,5 @@ func (s *ExtractorService) CombineSchemas(schemaNames []string) (Schema, error)
 	}
 
 	s.logger.Info("Successfully combined schemas", zap.Int("count", len(schemaNames)), zap.Int("total_unique_keys", len(sortedCombined)))
-	return sortedCombined, nil // Return sorted
+	return sortedCombined, report, nil // Return sorted
 }
@@ -0,0 +1,42 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLoadSchemasFromDirsLaterOverrides(t *testing.T) {
+	shared, local := t.TempDir(), t.TempDir()
+	write := func(dir, name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(shared, "vitals.yaml", "HeartRate:\n  type: number\n")
+	write(shared, "meds.yaml", "Medication:\n  type: string\n")
+	write(local, "vitals.yaml", "BloodPressure:\n  type: string\n")
+
+	schemas, names, files, err := loadSchemasFromDirs([]string{shared, local}, false, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "meds" || names[1] != "vitals" {
+		t.Fatalf("names = %v, want [meds vitals]", names)
+	}
+	if _, ok := schemas["vitals"]["BloodPressure"]; !ok {
+		t.Errorf("vitals = %v, want the local override", schemas["vitals"])
+	}
+	if _, ok := schemas["vitals"]["HeartRate"]; ok {
+		t.Error("override merged with the shared schema instead of replacing it")
+	}
+	if files["vitals"] != filepath.Join(local, "vitals.yaml") {
+		t.Errorf("vitals file = %q, want the local path", files["vitals"])
+	}
+	if files["meds"] != filepath.Join(shared, "meds.yaml") {
+		t.Errorf("meds file = %q, want the shared path", files["meds"])
+	}
+}
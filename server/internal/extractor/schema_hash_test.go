@@ -0,0 +1,37 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHashSchemaIsStable(t *testing.T) {
+	a := Schema{"Age": map[string]any{"type": "number", "description": "Age in years"}, "Sex": map[string]any{"type": "string"}}
+	b := Schema{"Sex": map[string]any{"type": "string"}, "Age": map[string]any{"description": "Age in years", "type": "number"}}
+	c := Schema{"Age": map[string]any{"type": "string"}, "Sex": map[string]any{"type": "string"}}
+
+	hashA, err := HashSchema(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashB, _ := HashSchema(b); hashB != hashA {
+		t.Error("equal schemas hash differently")
+	}
+	if hashC, _ := HashSchema(c); hashC == hashA {
+		t.Error("different schemas hash equally")
+	}
+}
+
+func TestProcessTextRecordsDefinitionVersions(t *testing.T) {
+	llm := newStubLLM(t, completion(`{}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Age:\n  type: number\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient is 62.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHash, _ := HashSchema(s.Schemas["demo"])
+	if output.Metadata.SchemaHash != wantHash || output.Metadata.PromptVersion != PromptTemplateVersion {
+		t.Errorf("metadata = %+v, want the schema hash and prompt version", output.Metadata)
+	}
+}
@@ -0,0 +1,50 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+const metaSchema = `
+_name: Cardiology
+_description: Cardiac findings
+_version: 3
+_prompt: Focus on cardiac findings
+HeartRate:
+  type: number
+`
+
+func TestFormatExtractionPromptExcludesSchemaMeta(t *testing.T) {
+	s := newTestService(t, "http://127.0.0.1:1", map[string]string{"cardio.yaml": metaSchema})
+	prompt, err := s.formatExtractionPrompt(s.Schemas["cardio"], "HR 80", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range schemaMetaKeys {
+		if strings.Contains(prompt, `"`+key+`"`) {
+			t.Errorf("prompt schema block contains %s", key)
+		}
+	}
+	if !strings.Contains(prompt, `"HeartRate"`) {
+		t.Error("prompt lost the entities")
+	}
+
+	meta := SchemaMeta(s.Schemas["cardio"])
+	if meta["_name"] != "Cardiology" || meta["_version"] != 3 || len(meta) != 4 {
+		t.Errorf("SchemaMeta() = %v", meta)
+	}
+}
+
+func TestCombineSchemasIgnoresMetaConflicts(t *testing.T) {
+	s := newTestService(t, "http://127.0.0.1:1", map[string]string{
+		"a.yaml": "_name: A\nAge:\n  type: number\n",
+		"b.yaml": "_name: B\nSex:\n  type: string\n",
+	})
+	_, report, err := s.CombineSchemasWithReport([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Conflicts) != 0 {
+		t.Errorf("conflicts = %v, want meta keys ignored", report.Conflicts)
+	}
+}
@@ -0,0 +1,40 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLoadSchemasFromDirDuplicateNamesResolveLexically(t *testing.T) {
+	// All three files define the "vitals" schema; the lexically last one wins
+	files := map[string]string{
+		"vitals.YAML": "Temperature:\n  type: number\n",
+		"vitals.yaml": "HeartRate:\n  type: number\n",
+		"vitals.yml":  "BloodPressure:\n  type: string\n",
+	}
+	for range 5 {
+		dir := t.TempDir()
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		schemas, names, schemaFiles, err := loadSchemasFromDir(dir, false, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 1 {
+			t.Fatalf("names = %v, want only vitals", names)
+		}
+		if _, ok := schemas["vitals"]["BloodPressure"]; !ok {
+			t.Errorf("vitals = %v, want the definition from vitals.yml", schemas["vitals"])
+		}
+		if schemaFiles["vitals"] != filepath.Join(dir, "vitals.yml") {
+			t.Errorf("vitals file = %q, want vitals.yml", schemaFiles["vitals"])
+		}
+	}
+}
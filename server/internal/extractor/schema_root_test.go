@@ -0,0 +1,47 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestParseSchemaRejectsNonMappingRoot(t *testing.T) {
+	tests := map[string]struct {
+		yaml     string
+		wantKind string
+	}{
+		"list root":   {"- HeartRate\n- Medication\n", "list"},
+		"scalar root": {"just a string\n", "scalar value"},
+	}
+	for name, tt := range tests {
+		_, err := parseSchema([]byte(tt.yaml), "demo.yaml")
+		if err == nil || !strings.Contains(err.Error(), "schema root must be a mapping") || !strings.Contains(err.Error(), tt.wantKind) {
+			t.Errorf("%s: parseSchema error = %v, want a non-mapping root error naming a %s", name, err, tt.wantKind)
+		}
+	}
+}
+
+func TestLoadSchemasFromDirSkipsNonMappingRoots(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"meds.yaml":   "Medication:\n  type: string\n",
+		"list.yaml":   "- Medication\n",
+		"scalar.yaml": "42\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, names, _, err := loadSchemasFromDirs([]string{dir}, false, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "meds" {
+		t.Errorf("loaded schemas = %v, want only meds", names)
+	}
+}
@@ -0,0 +1,83 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProcessTextAttributesSchemaSources(t *testing.T) {
+	text := "T 38.5 C, HR 90. On aspirin."
+	llm := newStubLLM(t, completion(`{
+		"Vital signs.Temperature": [{"value": "38.5", "context": "T 38.5 C"}],
+		"Vital signs.HR": [{"value": "90", "context": "HR 90"}],
+		"Medication": [{"value": "aspirin", "context": "On aspirin"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{
+		"base.yaml":   "Vital signs:\n  type: object\n  properties:\n    Temperature:\n      type: number\nMedication:\n  type: string\n",
+		"cardio.yaml": "Vital signs:\n  type: object\n  properties:\n    Temperature:\n      type: number\n    HR:\n      type: number\n",
+	})
+
+	output, err := s.ProcessText(context.Background(), []string{"base", "cardio"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Vital signs.Temperature": "cardio", // Later schema's definition wins
+		"Vital signs.HR":          "cardio",
+		"Medication":              "base",
+	}
+	for entity, schema := range want {
+		occs := output.Entities[entity]
+		if len(occs) != 1 {
+			t.Fatalf("%s: got %d occurrences, want 1", entity, len(occs))
+		}
+		if occs[0].Schema != schema {
+			t.Errorf("%s: schema = %q, want %q", entity, occs[0].Schema, schema)
+		}
+	}
+}
+
+func TestSchemaSourceOfDottedKeys(t *testing.T) {
+	sources := map[string]string{"Labs": "labs", "Labs.CBC": "hematology"}
+	tests := map[string]string{
+		"Labs.WBC":     "labs",
+		"Labs.CBC.Hb":  "hematology",
+		"Unknown.Name": "",
+	}
+	for entity, want := range tests {
+		if got := schemaSourceOf(entity, sources, "."); got != want {
+			t.Errorf("schemaSourceOf(%q) = %q, want %q", entity, got, want)
+		}
+	}
+}
+
+func TestGroupEntitiesBySchema(t *testing.T) {
+	text := "HR 90. On aspirin."
+	llm := newStubLLM(t, completion(`{
+		"Vital signs.HR": [{"value": "90", "context": "HR 90"}],
+		"Medication": [{"value": "aspirin", "context": "On aspirin"}],
+		"Stray": [{"value": "On", "context": "On aspirin"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{
+		"base.yaml":   "Medication:\n  type: string\n",
+		"cardio.yaml": "Vital signs:\n  type: object\n  properties:\n    HR:\n      type: number\n",
+	})
+
+	output, err := s.ProcessText(context.Background(), []string{"base", "cardio"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := GroupEntitiesBySchema(output)
+	if len(groups) != 3 {
+		t.Fatalf("got groups %v, want base, cardio and unknown", groups)
+	}
+	if _, ok := groups["base"].Entities["Medication"]; !ok {
+		t.Error("Medication not grouped under base")
+	}
+	if _, ok := groups["cardio"].Entities["Vital signs.HR"]; !ok {
+		t.Error("Vital signs.HR not grouped under cardio")
+	}
+	if _, ok := groups["unknown"].Entities["Stray"]; !ok {
+		t.Error("entity outside every schema not grouped under unknown")
+	}
+}
@@ -0,0 +1,38 @@
+package extractor
+
+// Values of an entity's `search_scope`, which decides how its occurrences are
+// placed in the text
+const (
+	SearchScopeBoth    = "both"    // Value within its context, else the value alone (the default)
+	SearchScopeContext = "context" // Only within its context, e.g. single digits
+	SearchScopeValue   = "value"   // Only the value alone, e.g. unique lab codes
+)
+
+func isSearchScope(scope string) bool {
+	return scope == SearchScopeBoth || scope == SearchScopeContext || scope == SearchScopeValue
+}
+
+// searchScopeEntities returns the entities whose definition narrows
+// `search_scope` to context or value, keyed by entity name with nested names
+// joined by delimiter.
+func searchScopeEntities(schema Schema, delimiter string) map[string]string {
+	entities := make(map[string]string)
+	walkEntityDefinitions(schema, delimiter, func(fullName string, def map[string]any) {
+		if scope, _ := def["search_scope"].(string); scope == SearchScopeContext || scope == SearchScopeValue {
+			entities[fullName] = scope
+		}
+	})
+	return entities
+}
+
+// markSearchScopes sets the search scope on the occurrences of the given entities.
+func markSearchScopes(rawExtraction RawLLMExtraction, entities map[string]string) int {
+	marked := 0
+	for entityName, scope := range entities {
+		for i := range rawExtraction[entityName] {
+			rawExtraction[entityName][i].SearchScope = scope
+			marked++
+		}
+	}
+	return marked
+}
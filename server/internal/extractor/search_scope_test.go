@@ -0,0 +1,51 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearchScope(t *testing.T) {
+	text := "Labs sent: LOINC 2345-7 pending. Pain 7 of 10, pain score 7 again."
+	schema := `
+LabCode:
+  type: string
+  search_scope: value
+PainScore:
+  type: string
+  search_scope: context
+Pending:
+  type: string
+`
+	// Every context is paraphrased, so none of them is found in the text
+	llm := newStubLLM(t, completion(`{
+		"LabCode": [{"value": "2345-7", "context": "lab code 2345-7 sent"}],
+		"PainScore": [{"value": "7", "context": "pain rated 7"}, {"value": "7", "context": "pain score 7 again"}],
+		"Pending": [{"value": "pending", "context": "results are pending"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": schema})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := output.Entities["LabCode"]
+	if len(codes) != 1 || codes[0].Position.Start != 17 {
+		t.Errorf("LabCode = %+v, want the value placed without its context", codes)
+	}
+	pain := output.Entities["PainScore"]
+	if len(pain) != 1 || pain[0].Context.Text != "pain score 7 again" {
+		t.Errorf("PainScore = %+v, want only the occurrence whose context matched", pain)
+	}
+	if pending := output.Entities["Pending"]; len(pending) != 1 {
+		t.Errorf("Pending = %+v, want the default scope to fall back to the value", pending)
+	}
+}
+
+func TestValidateSchemaStructureSearchScope(t *testing.T) {
+	schema := Schema{"Code": map[string]any{"type": "string", "search_scope": "anywhere"}}
+	if problems := validateSchemaStructure(schema, "."); len(problems) != 1 {
+		t.Errorf("problems = %v, want the invalid search_scope reported", problems)
+	}
+}
@@ -0,0 +1,30 @@
+package extractor
+
+// newSearchSlots returns the pool of extra search workers shared by all
+// requests, or nil when size is 0 and workers are not limited across requests.
+func newSearchSlots(size int) chan struct{} {
+	if size <= 0 {
+		return nil
+	}
+	return make(chan struct{}, size)
+}
+
+// acquireSearchSlot takes a slot for an extra search worker without waiting,
+// reporting whether one was free.
+func (s *ExtractorService) acquireSearchSlot() bool {
+	if s.searchSlots == nil {
+		return true
+	}
+	select {
+	case s.searchSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *ExtractorService) releaseSearchSlot() {
+	if s.searchSlots != nil {
+		<-s.searchSlots
+	}
+}
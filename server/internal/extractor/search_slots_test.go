@@ -0,0 +1,56 @@
+package extractor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFindEntityPositionsIsDeterministicUnderSharedLimit(t *testing.T) {
+	text, raw := largeExtraction(40, 3)
+	s := newTestService(t, "http://127.0.0.1:1", nil)
+
+	s.match.workers = 1
+	sequential, err := s.findEntityPositions(text, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.match.workers = 8
+	for _, free := range []int{0, 2, 7} {
+		s.searchSlots = newSearchSlots(7)
+		for range 7 - free { // Held by other requests
+			s.searchSlots <- struct{}{}
+		}
+		limited, err := s.findEntityPositions(text, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sequential, limited) {
+			t.Errorf("%d free slots: output differs from the 1-worker output", free)
+		}
+		if held := len(s.searchSlots); held != 7-free {
+			t.Errorf("%d free slots: %d held after the search, want the %d of other requests", free, held, 7-free)
+		}
+	}
+}
+
+func BenchmarkFindEntityPositionsSharedLimit(b *testing.B) {
+	text, raw := largeExtraction(100, 2)
+	for _, maxTotal := range []int{0, 4} {
+		b.Run(fmt.Sprintf("max_total_workers=%d", maxTotal), func(b *testing.B) {
+			s := newTestService(b, "http://127.0.0.1:1", nil)
+			s.match.workers = 8
+			s.searchSlots = newSearchSlots(maxTotal)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) { // Concurrent requests compete for the slots
+				for pb.Next() {
+					if _, err := s.findEntityPositions(text, raw); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package extractor
+
+import "sort"
+
+// Group of the occurrences that lie outside every section
+const UnsectionedGroup = "unsectioned"
+
+// GroupEntitiesBySection partitions the output's occurrences by the section
+// of the text their value starts in, with sections found by the markers as
+// for section-aware extraction. Occurrences before the first header go under
+// UnsectionedGroup, as do all of them when no header is found.
+func GroupEntitiesBySection(output *ExtractionOutput, markers []SectionMarker) map[string]SchemaEntities {
+	type section struct {
+		name       string
+		start, end int // Rune offsets in output.Text
+	}
+	sections := []section{}
+	for name, ranges := range (&SectionOptions{Markers: markers}).findSections(output.Text) {
+		for _, r := range ranges {
+			sections = append(sections, section{name, byteIndexToRuneIndex(output.Text, r.start), byteIndexToRuneIndex(output.Text, r.end)})
+		}
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].start < sections[j].start })
+
+	groups := make(map[string]SchemaEntities)
+	for entityName, occurrences := range output.Entities {
+		for _, occurrence := range occurrences {
+			name := UnsectionedGroup
+			// Sections do not overlap, so the last one starting at or before the value holds it
+			if i := sort.Search(len(sections), func(i int) bool { return sections[i].start > occurrence.Position.Start }) - 1; i >= 0 && occurrence.Position.Start < sections[i].end {
+				name = sections[i].name
+			}
+			group, exists := groups[name]
+			if !exists {
+				group = SchemaEntities{Entities: make(map[string][]EntityOccurrence)}
+				groups[name] = group
+			}
+			group.Entities[entityName] = append(group.Entities[entityName], occurrence)
+		}
+	}
+	return groups
+}
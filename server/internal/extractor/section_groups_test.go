@@ -0,0 +1,65 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGroupEntitiesBySection(t *testing.T) {
+	// Accented text before the headers checks that byte offsets become rune offsets
+	text := "Référé par le Dr Émile, fièvre.\nHPI: fever 38.5 for two days\nLabs: WBC 12\nPlan: start ceftriaxone\n"
+	at := func(value string) EntityOccurrence {
+		start := utf8.RuneCountInString(text[:strings.Index(text, value)])
+		return EntityOccurrence{Value: value, Position: Position{Start: start, End: start + utf8.RuneCountInString(value)}}
+	}
+	output := &ExtractionOutput{
+		Text: text,
+		Entities: map[string][]EntityOccurrence{
+			"Symptom":     {at("fièvre"), at("fever")},
+			"Temperature": {at("38.5")},
+			"WBC":         {at("12")},
+			"Medication":  {at("ceftriaxone")},
+		},
+	}
+	markers := []SectionMarker{{Name: "HPI", Pattern: "HPI:"}, {Name: "Labs", Pattern: "Labs:"}, {Name: "Plan", Pattern: "Plan:"}}
+
+	groups := GroupEntitiesBySection(output, markers)
+	tests := []struct {
+		section string
+		entity  string
+		values  []string
+	}{
+		{UnsectionedGroup, "Symptom", []string{"fièvre"}},
+		{"HPI", "Symptom", []string{"fever"}},
+		{"HPI", "Temperature", []string{"38.5"}},
+		{"Labs", "WBC", []string{"12"}},
+		{"Plan", "Medication", []string{"ceftriaxone"}},
+	}
+	total := 0
+	for _, tt := range tests {
+		occurrences := groups[tt.section].Entities[tt.entity]
+		if len(occurrences) != len(tt.values) {
+			t.Errorf("%s/%s = %+v, want %v", tt.section, tt.entity, occurrences, tt.values)
+			continue
+		}
+		for i, occurrence := range occurrences {
+			if occurrence.Value != tt.values[i] {
+				t.Errorf("%s/%s[%d] = %v, want %q", tt.section, tt.entity, i, occurrence.Value, tt.values[i])
+			}
+		}
+	}
+	for _, group := range groups {
+		for _, occurrences := range group.Entities {
+			total += len(occurrences)
+		}
+	}
+	if len(groups) != 4 || total != 5 {
+		t.Errorf("groups = %+v, want 4 sections holding all 5 occurrences", groups)
+	}
+
+	groups = GroupEntitiesBySection(output, []SectionMarker{{Name: "Exam", Pattern: "Exam:"}})
+	if len(groups) != 1 || len(groups[UnsectionedGroup].Entities) != 4 {
+		t.Errorf("without headers in the text: groups = %+v, want everything unsectioned", groups)
+	}
+}
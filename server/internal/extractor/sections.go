@@ -0,0 +1,240 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// SectionMarker identifies the header that starts a labeled section.
+type SectionMarker struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"` // Literal header (e.g. "HPI:") matched case-insensitively at a line start
+	Regex   bool   `json:"regex"`   // Treat Pattern as a regular expression instead
+}
+
+// SectionOptions confines schemas to sections of the document. A section runs
+// from its marker to the next marker of any section. Schemas not listed in
+// SchemaSections are extracted from the whole document.
+type SectionOptions struct {
+	Markers        []SectionMarker     `json:"markers"`
+	SchemaSections map[string][]string `json:"schema_sections"` // Schema name to the sections it may use
+}
+
+// Validate checks that markers compile and every referenced section exists.
+func (o *SectionOptions) Validate() error {
+	names := make([]string, 0, len(o.Markers))
+	for _, marker := range o.Markers {
+		if marker.Name == "" || marker.Pattern == "" {
+			return fmt.Errorf("section markers need a name and a pattern")
+		}
+		if _, err := marker.compile(); err != nil {
+			return fmt.Errorf("invalid pattern for section '%s': %w", marker.Name, err)
+		}
+		names = append(names, marker.Name)
+	}
+	for schemaName, sections := range o.SchemaSections {
+		for _, section := range sections {
+			if !slices.Contains(names, section) {
+				return fmt.Errorf("schema '%s' refers to unknown section '%s'", schemaName, section)
+			}
+		}
+	}
+	return nil
+}
+
+func (m SectionMarker) compile() (*regexp.Regexp, error) {
+	if m.Regex {
+		return regexp.Compile(`(?m)` + m.Pattern)
+	}
+	return regexp.Compile(`(?im)^[ \t]*` + regexp.QuoteMeta(m.Pattern))
+}
+
+// textRange is a half-open byte range of a text.
+type textRange struct {
+	start, end int
+}
+
+// findSections returns the byte ranges of each named section in text, in
+// document order.
+func (o *SectionOptions) findSections(text string) map[string][]textRange {
+	type header struct {
+		name  string
+		start int
+	}
+	headers := []header{}
+	for _, marker := range o.Markers {
+		regex, err := marker.compile()
+		if err != nil {
+			continue // Rejected by Validate
+		}
+		for _, match := range regex.FindAllStringIndex(text, -1) {
+			headers = append(headers, header{marker.Name, match[0]})
+		}
+	}
+	sort.SliceStable(headers, func(i, j int) bool { return headers[i].start < headers[j].start })
+
+	sections := make(map[string][]textRange)
+	for i, h := range headers {
+		end := len(text)
+		if i+1 < len(headers) {
+			end = headers[i+1].start
+		}
+		if end > h.start {
+			sections[h.name] = append(sections[h.name], textRange{h.start, end})
+		}
+	}
+	return sections
+}
+
+// excerpt joins the ranges of text into one string, separated by blank lines,
+// and records for each byte of the excerpt its offset in text.
+func excerpt(text string, ranges []textRange) textMapping {
+	var sb strings.Builder
+	offsets := []int{}
+	for i, r := range ranges {
+		if i > 0 {
+			sb.WriteString("\n\n")
+			offsets = append(offsets, ranges[i-1].end, ranges[i-1].end)
+		}
+		sb.WriteString(text[r.start:r.end])
+		for b := r.start; b < r.end; b++ {
+			offsets = append(offsets, b)
+		}
+	}
+	end := len(text)
+	if len(ranges) > 0 {
+		end = ranges[len(ranges)-1].end
+	}
+	offsets = append(offsets, end)
+	return textMapping{text: sb.String(), offsets: offsets}
+}
+
+// extractSections extracts each group of schemas sharing the same allowed
+// sections from just those sections, then maps positions back onto text.
+func (s *ExtractorService) extractSections(ctx context.Context, schemaNames []string, text string, opts ProcessOptions) (*ExtractionOutput, error) {
+	sections := opts.Sections.findSections(text)
+
+	// Group schemas by their allowed sections; "" means the whole document
+	groups := make(map[string][]string)
+	groupOrder := []string{}
+	for _, schemaName := range schemaNames {
+		allowed := slices.Clone(opts.Sections.SchemaSections[schemaName])
+		sort.Strings(allowed)
+		key := strings.Join(slices.Compact(allowed), "\x00")
+		if _, exists := groups[key]; !exists {
+			groupOrder = append(groupOrder, key)
+		}
+		groups[key] = append(groups[key], schemaName)
+	}
+
+	merged := &ExtractionOutput{Text: text, Entities: make(map[string][]EntityOccurrence)}
+	taken := make(map[string]bool) // IDs of the groups merged so far
+	for _, key := range groupOrder {
+		groupSchema, err := s.CombineSchemas(groups[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed during schema combination: %w", err)
+		}
+
+		segment := identityMapping(text)
+		if key != "" {
+			ranges := []textRange{}
+			for _, section := range strings.Split(key, "\x00") {
+				ranges = append(ranges, sections[section]...)
+			}
+			if len(ranges) == 0 {
+				s.logger.Info("No allowed sections found for schemas, skipping", zap.Strings("schemas", groups[key]))
+				continue
+			}
+			sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+			segment = excerpt(text, ranges)
+		}
+
+		output, err := s.extractSegment(ctx, groupSchema, segment.text, opts)
+		if err != nil {
+			return nil, err
+		}
+		s.logger.Debug("Extracted section group", zap.Strings("schemas", groups[key]), zap.Int("excerptLength", len(segment.text)))
+
+		remapOccurrences(output, segment, text)
+		renumberGroups(output, taken)
+		for entityName, occurrences := range output.Entities {
+			merged.Entities[entityName] = append(merged.Entities[entityName], occurrences...)
+		}
+		mergeMetadata(&merged.Metadata, output.Metadata)
+		if output.Debug != nil {
+			if merged.Debug == nil {
+				merged.Debug = &ExtractionDebug{}
+			}
+			merged.Debug.PromptSchemas = append(merged.Debug.PromptSchemas, output.Debug.PromptSchemas...)
+			merged.Debug.LLMResponses = append(merged.Debug.LLMResponses, output.Debug.LLMResponses...)
+			merged.Debug.Prompts = append(merged.Debug.Prompts, output.Debug.Prompts...)
+		}
+		if output.Diagnostics != nil {
+			merged.Diagnostics = mergeDiagnostics(merged.Diagnostics, output.Diagnostics)
+		}
+	}
+	return merged, nil
+}
+
+// remapOccurrences converts rune positions within segment.text into rune
+// positions within text.
+func remapOccurrences(output *ExtractionOutput, segment textMapping, text string) {
+	if segment.offsets == nil {
+		return
+	}
+	// Byte offset of every rune index in the segment, plus its end
+	runeBytes := make([]int, 0, len(segment.text)+1)
+	for b := range segment.text {
+		runeBytes = append(runeBytes, b)
+	}
+	runeBytes = append(runeBytes, len(segment.text))
+
+	toText := func(runeIdx int) int {
+		runeIdx = max(0, min(runeIdx, len(runeBytes)-1))
+		return utf8.RuneCountInString(text[:segment.originalOffset(runeBytes[runeIdx])])
+	}
+	for _, occurrences := range output.Entities {
+		for i := range occurrences {
+			o := &occurrences[i]
+			o.Position = Position{Start: toText(o.Position.Start), End: toText(o.Position.End)}
+			o.Context.Position = Position{Start: toText(o.Context.Position.Start), End: toText(o.Context.Position.End)}
+			if o.ValueInContext != nil {
+				o.ValueInContext = valueInContext(o.Position, o.Context.Position)
+			}
+			for j, position := range o.Positions {
+				o.Positions[j] = Position{Start: toText(position.Start), End: toText(position.End)}
+			}
+		}
+	}
+}
+
+// mergeMetadata adds the LLM usage of one segment to the running total.
+func mergeMetadata(total *ExtractionMetadata, segment ExtractionMetadata) {
+	addLLMUsage(total, segment)
+	total.UnknownEntities = append(total.UnknownEntities, segment.UnknownEntities...)
+	total.AmbiguousContexts = append(total.AmbiguousContexts, segment.AmbiguousContexts...)
+	if total.Parse.Outcome != parseOutcomeRepaired {
+		total.Parse.Outcome = segment.Parse.Outcome
+	}
+	if segment.Timings != nil {
+		total.Timings = segment.Timings // Timings of the last LLM call
+	}
+}
+
+// addLLMUsage adds the tokens and parse attempts of other to total.
+func addLLMUsage(total *ExtractionMetadata, other ExtractionMetadata) {
+	total.TokenUsage.PromptTokens += other.TokenUsage.PromptTokens
+	total.TokenUsage.TokensEvaluated += other.TokenUsage.TokensEvaluated
+	total.TokenUsage.TokensPredicted += other.TokenUsage.TokensPredicted
+	total.TokenUsage.Total += other.TokenUsage.Total
+	total.Parse.Repairs += other.Parse.Repairs
+	total.Parse.Reprompts += other.Parse.Reprompts
+	total.Parse.EmptyRetries += other.Parse.EmptyRetries
+}
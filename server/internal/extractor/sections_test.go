@@ -0,0 +1,96 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProcessTextConfinesSchemaToSection(t *testing.T) {
+	text := "HPI: Took aspirin at home.\nPLAN: Start aspirin 81 mg daily."
+	// The model quotes the HPI line, which is not part of the excerpt it was sent
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "aspirin", "context": "Took aspirin at home"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"meds"}, text, ProcessOptions{
+		Sections: &SectionOptions{
+			Markers: []SectionMarker{
+				{Name: "hpi", Pattern: "HPI:"},
+				{Name: "plan", Pattern: "PLAN:"},
+			},
+			SchemaSections: map[string][]string{"meds": {"plan"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, _ := llm.Requests()[0]["prompt"].(string)
+	if strings.Contains(prompt, "Took aspirin at home") || !strings.Contains(prompt, "PLAN: Start aspirin") {
+		t.Error("prompt was not confined to the PLAN section")
+	}
+
+	planStart := len([]rune("HPI: Took aspirin at home.\n"))
+	meds := output.Entities["Medication"]
+	if len(meds) != 1 {
+		t.Fatalf("got %d medications, want only the one in PLAN", len(meds))
+	}
+	if meds[0].Position.Start < planStart {
+		t.Errorf("medication at %d is outside the PLAN section starting at %d", meds[0].Position.Start, planStart)
+	}
+	if got := string([]rune(text)[meds[0].Position.Start:meds[0].Position.End]); got != "aspirin" {
+		t.Errorf("position maps to %q in the full document, want aspirin", got)
+	}
+}
+
+func TestSectionOptionsValidate(t *testing.T) {
+	tests := map[string]struct {
+		opts    SectionOptions
+		wantErr bool
+	}{
+		"valid":           {SectionOptions{Markers: []SectionMarker{{Name: "plan", Pattern: "PLAN:"}}, SchemaSections: map[string][]string{"meds": {"plan"}}}, false},
+		"unknown section": {SectionOptions{Markers: []SectionMarker{{Name: "plan", Pattern: "PLAN:"}}, SchemaSections: map[string][]string{"meds": {"hpi"}}}, true},
+		"bad regex":       {SectionOptions{Markers: []SectionMarker{{Name: "plan", Pattern: "(", Regex: true}}}, true},
+		"missing pattern": {SectionOptions{Markers: []SectionMarker{{Name: "plan"}}}, true},
+	}
+	for name, tt := range tests {
+		if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProcessTextSectionGroupsKeepIDsUnique(t *testing.T) {
+	text := "HPI: Took aspirin at home.\nPLAN: Start aspirin 81 mg daily."
+	llm := newStubLLM(t,
+		completion(`{"Medication": [{"value": "aspirin", "context": "Took aspirin at home"}]}`),
+		completion(`{"Medication": [{"value": "aspirin", "context": "Start aspirin 81 mg"}]}`),
+	)
+	s := newTestService(t, llm.URL, map[string]string{
+		"history.yaml": "Medication:\n  type: string\n",
+		"plan.yaml":    "Medication:\n  type: string\n",
+	})
+
+	output, err := s.ProcessText(context.Background(), []string{"history", "plan"}, text, ProcessOptions{
+		Sections: &SectionOptions{
+			Markers: []SectionMarker{
+				{Name: "hpi", Pattern: "HPI:"},
+				{Name: "plan", Pattern: "PLAN:"},
+			},
+			SchemaSections: map[string][]string{"history": {"hpi"}, "plan": {"plan"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meds := output.Entities["Medication"]
+	if len(meds) != 2 {
+		t.Fatalf("got %d medications, want one per section", len(meds))
+	}
+	if meds[0].GroupID == meds[1].GroupID || meds[0].ID == meds[1].ID {
+		t.Errorf("section groups share IDs: %q/%q and %q/%q", meds[0].ID, meds[0].GroupID, meds[1].ID, meds[1].GroupID)
+	}
+	if _, err := OccurrencesByID(output); err != nil {
+		t.Errorf("OccurrencesByID: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package extractor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Longest context ExtractSentenceContext returns on either side of the span,
+// for text without sentence punctuation or line breaks
+const maxSentenceContextRunes = 250
+
+// Runes isAbbreviationPeriod may need to look back over; longer words are
+// never abbreviations or initials
+const sentenceWordLookback = 16
+
+// Words whose trailing period does not end a sentence, lowercased and without
+// that period
+var sentenceAbbreviations = map[string]bool{
+	"dr": true, "mr": true, "mrs": true, "ms": true, "prof": true, "st": true,
+	"jr": true, "sr": true, "vs": true, "etc": true, "e.g": true, "i.e": true,
+	"approx": true, "pt": true, "pts": true, "hx": true, "dx": true, "tx": true,
+	"rx": true, "mg": true, "mcg": true, "ml": true, "kg": true, "fig": true,
+	"p.o": true, "b.i.d": true, "t.i.d": true, "q.d": true,
+}
+
+// ExtractSentenceContext returns the sentence or sentences enclosing the span
+// [start, end) of text, with its rune position. Sentences end at a line break
+// or at '.', '!' or '?' followed by whitespace, except for the period of an
+// abbreviation such as "Dr." or "mg." or of a single-letter initial.
+func ExtractSentenceContext(text string, start, end int) (string, Position) {
+	runes := []rune(text)
+	sentenceStart, sentenceEnd := sentenceBounds(runes, start, end)
+	return string(runes[sentenceStart:sentenceEnd]), Position{Start: sentenceStart, End: sentenceEnd}
+}
+
+// sentenceContextAt is ExtractSentenceContext for a span given as byte
+// offsets, where runeStart is the rune index of byteStart. Only the runes
+// within reach of the span are decoded, so the cost does not grow with text.
+func sentenceContextAt(text string, byteStart, byteEnd, runeStart int) (string, Position) {
+	// Enough runes before the span to look back over an abbreviation at the
+	// far edge, and one after it to see what follows a final period
+	windowStart := byteStart
+	for n := 0; n < maxSentenceContextRunes+sentenceWordLookback && windowStart > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:windowStart])
+		windowStart -= size
+	}
+	windowEnd := byteEnd
+	for n := 0; n < maxSentenceContextRunes+1 && windowEnd < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[windowEnd:])
+		windowEnd += size
+	}
+
+	runes := []rune(text[windowStart:windowEnd])
+	offset := utf8.RuneCountInString(text[windowStart:byteStart])
+	spanEnd := offset + utf8.RuneCountInString(text[byteStart:byteEnd])
+	sentenceStart, sentenceEnd := sentenceBounds(runes, offset, spanEnd)
+	shift := runeStart - offset
+	return string(runes[sentenceStart:sentenceEnd]), Position{Start: sentenceStart + shift, End: sentenceEnd + shift}
+}
+
+// sentenceBounds returns the rune bounds of the sentences enclosing the span
+// [start, end) of runes.
+func sentenceBounds(runes []rune, start, end int) (int, int) {
+	start = max(0, min(start, len(runes)))
+	end = max(start, min(end, len(runes)))
+
+	sentenceStart := max(0, start-maxSentenceContextRunes)
+	for i := start - 1; i >= sentenceStart; i-- {
+		if runes[i] == '\n' || isSentenceEnd(runes, i) {
+			sentenceStart = i + 1
+			break
+		}
+	}
+	for sentenceStart < start && unicode.IsSpace(runes[sentenceStart]) {
+		sentenceStart++
+	}
+
+	sentenceEnd := min(len(runes), end+maxSentenceContextRunes)
+	for i := end; i < sentenceEnd; i++ {
+		if runes[i] == '\n' {
+			sentenceEnd = i
+			break
+		}
+		if isSentenceEnd(runes, i) {
+			sentenceEnd = i + 1
+			break
+		}
+	}
+	for sentenceEnd > end && unicode.IsSpace(runes[sentenceEnd-1]) {
+		sentenceEnd--
+	}
+	return sentenceStart, sentenceEnd
+}
+
+// isSentenceEnd reports whether the rune at i ends a sentence.
+func isSentenceEnd(runes []rune, i int) bool {
+	switch runes[i] {
+	case '!', '?':
+	case '.':
+		if isAbbreviationPeriod(runes, i) {
+			return false
+		}
+	default:
+		return false
+	}
+	return i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+}
+
+// isAbbreviationPeriod reports whether the period at i closes an
+// abbreviation or a single-letter initial.
+func isAbbreviationPeriod(runes []rune, i int) bool {
+	wordStart := i
+	for wordStart > 0 && (unicode.IsLetter(runes[wordStart-1]) || runes[wordStart-1] == '.') {
+		wordStart--
+	}
+	word := strings.ToLower(string(runes[wordStart:i]))
+	if word == "" {
+		return false
+	}
+	if len([]rune(word)) == 1 && unicode.IsLetter(runes[wordStart]) {
+		return true
+	}
+	return sentenceAbbreviations[word]
+}
@@ -0,0 +1,107 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractSentenceContext(t *testing.T) {
+	tests := map[string]struct {
+		text  string
+		value string
+		want  string
+	}{
+		"middle sentence": {
+			"BP stable. Started metoprolol today. Follow up in 2 weeks.",
+			"metoprolol", "Started metoprolol today.",
+		},
+		"first sentence": {
+			"Started metoprolol today. Follow up in 2 weeks.",
+			"metoprolol", "Started metoprolol today.",
+		},
+		"last sentence without period": {
+			"BP stable. Started metoprolol today",
+			"metoprolol", "Started metoprolol today",
+		},
+		"abbreviations do not split": {
+			"Seen by Dr. Smith. Gave aspirin 81 mg. daily with food. Recheck.",
+			"aspirin", "Gave aspirin 81 mg. daily with food.",
+		},
+		"title before the span": {
+			"Seen by Dr. Smith today. Recheck.",
+			"Smith", "Seen by Dr. Smith today.",
+		},
+		"initials do not split": {
+			"Referred to J. R. Jones for review. Recheck.",
+			"Jones", "Referred to J. R. Jones for review.",
+		},
+		"line break ends a sentence": {
+			"Medications:\naspirin daily\nAllergies: none",
+			"aspirin", "aspirin daily",
+		},
+		"question mark": {
+			"Any chest pain? Denies dyspnea. Recheck.",
+			"dyspnea", "Denies dyspnea.",
+		},
+		"multi-byte runes": {
+			"Café visit. Patient on métoprolol now. Done.",
+			"métoprolol", "Patient on métoprolol now.",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			start := len([]rune(tt.text[:strings.Index(tt.text, tt.value)]))
+			end := start + len([]rune(tt.value))
+			got, position := ExtractSentenceContext(tt.text, start, end)
+			if got != tt.want {
+				t.Errorf("sentence = %q, want %q", got, tt.want)
+			}
+			if covered := string([]rune(tt.text)[position.Start:position.End]); covered != got {
+				t.Errorf("position covers %q, want the returned sentence", covered)
+			}
+		})
+	}
+}
+
+func TestExtractSentenceContextBoundsLongText(t *testing.T) {
+	text := strings.Repeat("word ", 200) + "aspirin " + strings.Repeat("word ", 200)
+	start := len([]rune(strings.Repeat("word ", 200)))
+	_, position := ExtractSentenceContext(text, start, start+len("aspirin"))
+	if position.Start < start-maxSentenceContextRunes || position.End > start+len("aspirin")+maxSentenceContextRunes {
+		t.Errorf("context %+v exceeds %d runes on either side", position, maxSentenceContextRunes)
+	}
+}
+
+func TestSentenceContextAtMatchesWholeText(t *testing.T) {
+	filler := strings.Repeat("é ", 150)
+	texts := map[string]string{
+		"short":              "BP stable. Started métoprolol today. Follow up.",
+		"no punctuation":     filler + "aspirin " + filler,
+		"sentence in window": filler + "Dr. Smith. Gave aspirin 81 mg. daily. " + filler,
+	}
+	for name, text := range texts {
+		byteStart := strings.Index(text, "aspirin")
+		if byteStart < 0 {
+			byteStart = strings.Index(text, "métoprolol")
+		}
+		byteEnd := byteStart + strings.IndexByte(text[byteStart:], ' ')
+		start := len([]rune(text[:byteStart]))
+		end := len([]rune(text[:byteEnd]))
+
+		wantText, wantPosition := ExtractSentenceContext(text, start, end)
+		gotText, gotPosition := sentenceContextAt(text, byteStart, byteEnd, start)
+		if gotText != wantText || gotPosition != wantPosition {
+			t.Errorf("%s: sentenceContextAt = %q %+v, want %q %+v", name, gotText, gotPosition, wantText, wantPosition)
+		}
+	}
+}
+
+func BenchmarkSentenceContextAtLongText(b *testing.B) {
+	text := strings.Repeat("Patient seen on the ward today. ", 100000) + "Gave aspirin daily."
+	byteStart := strings.LastIndex(text, "aspirin")
+	start := len([]rune(text[:byteStart]))
+	b.ResetTimer()
+	for range b.N {
+		sentenceContextAt(text, byteStart, byteStart+len("aspirin"), start)
+	}
+}
@@ -0,0 +1,89 @@
+package extractor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestShortValueRejectsMidWordMatchInContext(t *testing.T) {
+	// "Na" first appears inside "Nausea" within the context
+	text := "Nausea resolved, Na 138 today."
+	raw := RawLLMExtraction{"Sodium": {{Value: "Na", Context: "Nausea resolved, Na 138"}}}
+
+	tests := []struct {
+		shortValueLength int
+		want             string
+		wantStart        int
+	}{
+		{0, "Na", 0},  // Guard disabled: the mid-word match wins
+		{3, "Na", 17}, // Guard enabled: the standalone "Na"
+	}
+	for _, tt := range tests {
+		s := newTestService(t, "http://127.0.0.1:1", nil)
+		s.match.shortValueLength = tt.shortValueLength
+
+		output, err := s.findEntityPositions(text, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		occs := output.Entities["Sodium"]
+		if len(occs) != 1 {
+			t.Fatalf("shortValueLength=%d: got %d occurrences, want 1", tt.shortValueLength, len(occs))
+		}
+		if occs[0].Position.Start != tt.wantStart {
+			t.Errorf("shortValueLength=%d: value starts at %d, want %d", tt.shortValueLength, occs[0].Position.Start, tt.wantStart)
+		}
+	}
+}
+
+func TestShortValueFallbackRejectsMidWordMatches(t *testing.T) {
+	// The context holds "Na" only inside "Nausea", so the value falls back
+	// to a search of the whole text
+	tests := []struct {
+		text       string
+		wantStarts []int
+	}{
+		{"Nausea resolved. Denies nausea.", nil},
+		{"Nausea resolved. Na 138, denies nausea.", []int{17}},
+	}
+	for _, tt := range tests {
+		s := newTestService(t, "http://127.0.0.1:1", nil)
+		raw := RawLLMExtraction{"Sodium": {{Value: "Na", Context: "Nausea resolved"}}}
+		output, err := s.findEntityPositions(tt.text, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var starts []int
+		for _, occurrence := range output.Entities["Sodium"] {
+			starts = append(starts, occurrence.Position.Start)
+		}
+		if !slices.Equal(starts, tt.wantStarts) {
+			t.Errorf("%q: value starts = %v, want %v", tt.text, starts, tt.wantStarts)
+		}
+	}
+}
+
+func TestNewExtractorServiceWiresShortValueLength(t *testing.T) {
+	s := newTestService(t, "http://127.0.0.1:1", nil)
+	if s.match.shortValueLength != 3 {
+		t.Errorf("shortValueLength = %d, want the configured default of 3", s.match.shortValueLength)
+	}
+}
+
+func TestIsWordBounded(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end int
+		want       bool
+	}{
+		{"Na 138", 0, 2, true},
+		{"Nausea", 0, 2, false},
+		{"(Na)", 1, 3, true},
+		{"DNa", 1, 3, false},
+	}
+	for _, tt := range tests {
+		if got := isWordBounded(tt.text, tt.start, tt.end); got != tt.want {
+			t.Errorf("isWordBounded(%q, %d, %d) = %v, want %v", tt.text, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestStrictSchemas(t *testing.T) {
+	broken := map[string]string{
+		"unparseable": "Medication: [unclosed\n",
+		"structural":  "Vitals:\n  type: object\n  properties: not a mapping\n",
+	}
+	for name, content := range broken {
+		for _, strict := range []bool{false, true} {
+			dir := t.TempDir()
+			files := map[string]string{"meds.yaml": "Medication:\n  type: string\n", "broken.yaml": content}
+			for file, data := range files {
+				if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg := config.NewDefaultConfig()
+			cfg.LLM.SchemaDirs = []string{dir}
+			cfg.LLM.StrictSchemas = strict
+
+			s, err := NewExtractorService(cfg, zap.NewNop(), dir)
+			if strict {
+				if err == nil {
+					t.Errorf("%s schema: strict loading succeeded", name)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s schema: lenient loading failed: %v", name, err)
+				continue
+			}
+			if _, ok := s.Schemas["meds"]; !ok {
+				t.Errorf("%s schema: lenient loading dropped the valid schema", name)
+			}
+		}
+	}
+}
+
+func TestStrictSchemasAcceptPlainValues(t *testing.T) {
+	dir := t.TempDir()
+	plain := "Smoker: \"yes or no\"\nVitals:\n  type: object\n  properties:\n    Temperature: number\n"
+	if err := os.WriteFile(filepath.Join(dir, "plain.yaml"), []byte(plain), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.LLM.SchemaDirs = []string{dir}
+	cfg.LLM.StrictSchemas = true
+
+	if _, err := NewExtractorService(cfg, zap.NewNop(), dir); err != nil {
+		t.Errorf("strict loading rejected plain value entities: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// Schema type marking an entity as a table whose `columns` are entities
+const tableEntityType = "table"
+
+// tableRowInstructions is added to the prompt when the schema declares tables.
+const tableRowInstructions = `
+- For entities of type 'table', do NOT return value/context objects directly. Return one JSON object per table row instead, where each row object maps every column name from the table's 'columns' to a '{"value": ..., "context": ...}' object, e.g. "Lab table": [ { "Date": { "value": "...", "context": "..." }, "WBC": { "value": "...", "context": "..." } } ]. Keep the cells of one row together in the same row object.`
+
+// tableColumns returns the column names of every table entity in the schema,
+// keyed by entity name with nested names joined by delimiter.
+func tableColumns(schema Schema, delimiter string) map[string][]string {
+	tables := make(map[string][]string)
+
+	var recurse func(defs map[string]any, prefix string)
+	recurse = func(defs map[string]any, prefix string) {
+		for name, value := range defs {
+			def, ok := asMap(value)
+			if !ok {
+				continue
+			}
+			fullName := name
+			if prefix != "" {
+				fullName = prefix + delimiter + name
+			}
+			if columns, ok := asMap(def["columns"]); ok && def["type"] == tableEntityType {
+				columnNames := make([]string, 0, len(columns))
+				for column := range columns {
+					columnNames = append(columnNames, column)
+				}
+				sort.Strings(columnNames)
+				tables[fullName] = columnNames
+				continue
+			}
+			if props, ok := asMap(def["properties"]); ok {
+				recurse(props, fullName)
+			}
+		}
+	}
+	recurse(schema, "")
+	return tables
+}
+
+// expandTableRows re-reads the table entities of the LLM response as row
+// objects and replaces them in rawExtraction with one entity per column
+// ("Table.Column" with the default delimiter), tagging each cell with the ID of the row it came from.
+func (s *ExtractorService) expandTableRows(llmResponseString string, rawExtraction RawLLMExtraction, tables map[string][]string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+
+	var rawEntities map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(llmResponseString), &rawEntities); err != nil {
+		return fmt.Errorf("failed to unmarshal LLM JSON for table rows: %w", err)
+	}
+
+	for tableName, columns := range tables {
+		delete(rawExtraction, tableName) // Rows don't fit the value/context shape
+		rawRows, exists := rawEntities[tableName]
+		if !exists {
+			continue
+		}
+
+		var rows []map[string]LLMOutputValueContext
+		if err := json.Unmarshal(rawRows, &rows); err != nil {
+			s.logger.Warn("Skipping table with malformed rows", zap.String("table", tableName), zap.Error(err))
+			continue
+		}
+
+		for rowIndex, row := range rows {
+			rowID := fmt.Sprintf("row-%s-%d", tableName, rowIndex)
+			for _, column := range columns {
+				cell, exists := row[column]
+				if !exists {
+					continue
+				}
+				cell.RowID = rowID
+				columnEntity := tableName + s.nestingDelimiter + column
+				rawExtraction[columnEntity] = append(rawExtraction[columnEntity], cell)
+			}
+		}
+		s.logger.Debug("Expanded table rows", zap.String("table", tableName), zap.Int("rows", len(rows)))
+	}
+	return nil
+}
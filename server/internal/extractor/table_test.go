@@ -0,0 +1,65 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const labTableSchema = `
+Labs:
+  type: table
+  columns:
+    Date:
+      type: string
+    WBC:
+      type: number
+`
+
+func TestProcessTextLinksTableRowCells(t *testing.T) {
+	text := "Labs: 03/01 WBC 7.2; 03/02 WBC 11.4."
+	llm := newStubLLM(t, completion(`{"Labs": [
+		{"Date": {"value": "03/01", "context": "03/01 WBC 7.2"}, "WBC": {"value": "7.2", "context": "03/01 WBC 7.2"}},
+		{"Date": {"value": "03/02", "context": "03/02 WBC 11.4"}, "WBC": {"value": "11.4", "context": "03/02 WBC 11.4"}}
+	]}`))
+	s := newTestService(t, llm.URL, map[string]string{"labs.yaml": labTableSchema})
+
+	prompt, err := s.formatExtractionPrompt(s.Schemas["labs"], text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "entities of type 'table'") {
+		t.Error("prompt is missing the table row instructions")
+	}
+
+	output, err := s.ProcessText(context.Background(), []string{"labs"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := output.Entities["Labs"]; exists {
+		t.Error("table entity kept alongside its columns")
+	}
+
+	dates, wbcs := output.Entities["Labs.Date"], output.Entities["Labs.WBC"]
+	if len(dates) != 2 || len(wbcs) != 2 {
+		t.Fatalf("got %d dates and %d WBCs, want 2 of each", len(dates), len(wbcs))
+	}
+	wbcByRow := map[string]EntityOccurrence{}
+	for _, wbc := range wbcs {
+		wbcByRow[wbc.RowID] = wbc
+	}
+	runes := []rune(text)
+	want := map[string]string{"03/01": "7.2", "03/02": "11.4"}
+	for _, date := range dates {
+		if date.RowID == "" {
+			t.Fatalf("date %v has no row ID", date.Value)
+		}
+		wbc, ok := wbcByRow[date.RowID]
+		if !ok {
+			t.Fatalf("no WBC shares row %q", date.RowID)
+		}
+		if got := string(runes[wbc.Position.Start:wbc.Position.End]); got != want[date.Value.(string)] {
+			t.Errorf("row %s: WBC span = %q, want %q", date.Value, got, want[date.Value.(string)])
+		}
+	}
+}
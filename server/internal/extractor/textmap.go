@@ -0,0 +1,153 @@
+package extractor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textMapping is a working copy of a text prepared for matching, together
+// with a map from byte offsets in the working copy back to byte offsets in the
+// text it was derived from.
+type textMapping struct {
+	text      string
+	offsets   []int               // len(text)+1 entries; nil when the copy is the text itself
+	normalize func(string) string // Applies the same transformation to search strings
+}
+
+// identityMapping returns a mapping that searches the text unchanged.
+func identityMapping(text string) textMapping {
+	return textMapping{text: text, normalize: func(s string) string { return s }}
+}
+
+// originalOffset maps a byte offset in the working copy to the source text.
+func (m textMapping) originalOffset(idx int) int {
+	if m.offsets == nil {
+		return idx
+	}
+	idx = max(0, min(idx, len(m.offsets)-1))
+	return m.offsets[idx]
+}
+
+// then derives a further working copy from this one with transform; offsets
+// of the result map back through both to the original text.
+func (m textMapping) then(transform func(string) textMapping) textMapping {
+	next := transform(m.text)
+	offsets := make([]int, len(next.text)+1)
+	for i := range offsets {
+		offsets[i] = m.originalOffset(next.originalOffset(i))
+	}
+	return textMapping{
+		text:      next.text,
+		offsets:   offsets,
+		normalize: func(s string) string { return next.normalize(m.normalize(s)) },
+	}
+}
+
+// ASCII equivalents of the typographic punctuation that word processors
+// substitute and models tend to undo, or the other way around
+var asciiPunctuation = map[rune]string{
+	'‘': "'", '’': "'", '‚': "'", '‛': "'", '′': "'",
+	'“': `"`, '”': `"`, '„': `"`, '‟': `"`, '″': `"`,
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "-", '―': "-", '−': "-",
+	'…': "...",
+}
+
+// normalizePunctuation replaces smart quotes, dashes and ellipses with their
+// ASCII equivalents. Every byte of a replacement maps back to the start of
+// the character it replaced.
+func normalizePunctuation(text string) textMapping {
+	var sb strings.Builder
+	sb.Grow(len(text))
+	offsets := make([]int, 0, len(text)+1)
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if ascii, ok := asciiPunctuation[r]; ok {
+			sb.WriteString(ascii)
+			for range len(ascii) {
+				offsets = append(offsets, i)
+			}
+		} else {
+			sb.WriteString(text[i : i+size])
+			for b := range size {
+				offsets = append(offsets, i+b)
+			}
+		}
+		i += size
+	}
+	offsets = append(offsets, len(text))
+
+	return textMapping{
+		text:      sb.String(),
+		offsets:   offsets,
+		normalize: func(s string) string { return normalizePunctuation(s).text },
+	}
+}
+
+// collapseWhitespace replaces every run of whitespace (spaces, tabs, newlines,
+// non-breaking spaces) with a single space. Each collapsed space maps back to
+// the start of its run, so matches map onto the real document.
+func collapseWhitespace(text string) textMapping {
+	var sb strings.Builder
+	sb.Grow(len(text))
+	offsets := make([]int, 0, len(text)+1)
+
+	inRun := false
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) { // Includes U+00A0 NO-BREAK SPACE
+			if !inRun {
+				sb.WriteByte(' ')
+				offsets = append(offsets, i)
+				inRun = true
+			}
+			i += size
+			continue
+		}
+		inRun = false
+		sb.WriteString(text[i : i+size])
+		for b := range size {
+			offsets = append(offsets, i+b)
+		}
+		i += size
+	}
+	offsets = append(offsets, len(text))
+
+	return textMapping{
+		text:      sb.String(),
+		offsets:   offsets,
+		normalize: func(s string) string { return collapseWhitespace(s).text },
+	}
+}
+
+// normalizeLineEndings replaces CRLF and standalone CR with LF. Each LF maps
+// back to the first byte of the line ending it replaced.
+func normalizeLineEndings(text string) textMapping {
+	if !strings.ContainsRune(text, '\r') {
+		return identityMapping(text)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(text))
+	offsets := make([]int, 0, len(text)+1)
+
+	for i := 0; i < len(text); i++ {
+		offsets = append(offsets, i)
+		if text[i] != '\r' {
+			sb.WriteByte(text[i])
+			continue
+		}
+		sb.WriteByte('\n')
+		if i+1 < len(text) && text[i+1] == '\n' {
+			i++
+		}
+	}
+	offsets = append(offsets, len(text))
+
+	return textMapping{
+		text:      sb.String(),
+		offsets:   offsets,
+		normalize: func(s string) string { return normalizeLineEndings(s).text },
+	}
+}
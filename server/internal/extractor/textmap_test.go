@@ -0,0 +1,102 @@
+package extractor
+
+import "testing"
+
+func TestCollapseWhitespaceMapsOffsets(t *testing.T) {
+	text := "BP\u00a0\u00a0120/80  \t mmHg"
+	m := collapseWhitespace(text)
+	if m.text != "BP 120/80 mmHg" {
+		t.Fatalf("collapsed text = %q", m.text)
+	}
+	// "120/80" starts at byte 3 in the copy and byte 6 in the original (NBSP is two bytes)
+	if got := m.originalOffset(3); got != 6 {
+		t.Errorf("originalOffset(3) = %d, want 6", got)
+	}
+	if got := m.originalOffset(len(m.text)); got != len(text) {
+		t.Errorf("end offset = %d, want %d", got, len(text))
+	}
+}
+
+func TestFindEntityPositionsCollapsesWhitespace(t *testing.T) {
+	tests := map[string]string{
+		"multiple spaces": "Vitals:   BP    120/80   on arrival.",
+		"tabs and NBSP":   "Vitals:\tBP\u00a0120/80\u00a0\u00a0on arrival.",
+	}
+	raw := RawLLMExtraction{"BP": {{Value: "120/80", Context: "BP 120/80 on arrival"}}}
+
+	for name, text := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestService(t, "http://127.0.0.1:1", nil)
+			s.match.collapseWhitespace = true
+			output, err := s.findEntityPositions(text, raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			occs := output.Entities["BP"]
+			if len(occs) != 1 {
+				t.Fatalf("got %d occurrences, want 1", len(occs))
+			}
+			runes := []rune(text)
+			if got := string(runes[occs[0].Position.Start:occs[0].Position.End]); got != "120/80" {
+				t.Errorf("value span = %q, want 120/80", got)
+			}
+			context := string(runes[occs[0].Context.Position.Start:occs[0].Context.Position.End])
+			if collapseWhitespace(context).text != "BP 120/80 on arrival" {
+				t.Errorf("context span = %q", context)
+			}
+		})
+	}
+}
+
+func TestNormalizePunctuationMapsOffsets(t *testing.T) {
+	text := "“Don’t” stop—ever…"
+	m := normalizePunctuation(text)
+	if m.text != `"Don't" stop-ever...` {
+		t.Fatalf("normalized text = %q", m.text)
+	}
+	// "stop" starts at byte 8 in the copy and byte 14 in the original (each curly quote is three bytes)
+	if got := m.originalOffset(8); got != 14 {
+		t.Errorf("originalOffset(8) = %d, want 14", got)
+	}
+	if got := m.originalOffset(len(m.text)); got != len(text) {
+		t.Errorf("end offset = %d, want %d", got, len(text))
+	}
+}
+
+func TestFindEntityPositionsNormalizesPunctuation(t *testing.T) {
+	text := "Pt says “I don’t smoke” – quit 2019.\tBP  120/80."
+	raw := RawLLMExtraction{
+		"Smoking": {{Value: "don't smoke", Context: `says "I don't smoke" - quit`}},
+		"BP":      {{Value: "120/80", Context: "BP 120/80"}},
+	}
+
+	s := newTestService(t, "http://127.0.0.1:1", nil)
+	s.match.normalizePunctuation = true
+	s.match.collapseWhitespace = true // Both copies map back to the text
+	output, err := s.findEntityPositions(text, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runes := []rune(text)
+	for entity, want := range map[string]string{"Smoking": "don’t smoke", "BP": "120/80"} {
+		occs := output.Entities[entity]
+		if len(occs) != 1 {
+			t.Fatalf("%s: got %d occurrences, want 1", entity, len(occs))
+		}
+		if got := string(runes[occs[0].Position.Start:occs[0].Position.End]); got != want {
+			t.Errorf("%s: value span = %q, want %q", entity, got, want)
+		}
+		if occs[0].Confidence != 1 {
+			t.Errorf("%s: confidence = %v, want a context match", entity, occs[0].Confidence)
+		}
+	}
+
+	s.match.normalizePunctuation = false
+	output, err = s.findEntityPositions(text, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if occs := output.Entities["Smoking"]; len(occs) != 0 {
+		t.Errorf("Smoking = %+v without normalization, want no match", occs)
+	}
+}
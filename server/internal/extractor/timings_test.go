@@ -0,0 +1,53 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+const sampleTimings = `{"cache_n": 3, "prompt_n": 120, "prompt_ms": 80.5, "prompt_per_token_ms": 0.67,
+	"prompt_per_second": 1490.7, "predicted_n": 42, "predicted_ms": 950.2,
+	"predicted_per_token_ms": 22.6, "predicted_per_second": 44.2}`
+
+func TestParseTimings(t *testing.T) {
+	r := &LLMResponse{Timings: json.RawMessage(sampleTimings)}
+	timings, err := r.ParseTimings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LLMTimings{CacheN: 3, PromptN: 120, PromptMS: 80.5, PromptPerTokenMS: 0.67, PromptPerSecond: 1490.7,
+		PredictedN: 42, PredictedMS: 950.2, PredictedPerTokenMS: 22.6, PredictedPerSecond: 44.2}
+	if *timings != want {
+		t.Errorf("ParseTimings = %+v, want %+v", *timings, want)
+	}
+
+	for _, raw := range []string{"", "null", " null "} {
+		r := &LLMResponse{Timings: json.RawMessage(raw)}
+		if timings, err := r.ParseTimings(); timings != nil || err != nil {
+			t.Errorf("ParseTimings(%q) = %v, %v, want nil, nil", raw, timings, err)
+		}
+	}
+
+	r = &LLMResponse{Timings: json.RawMessage(`{"prompt_n": "many"}`)}
+	if _, err := r.ParseTimings(); err == nil {
+		t.Error("ParseTimings accepted a malformed timings object")
+	}
+}
+
+func TestProcessTextReportsTimings(t *testing.T) {
+	body, _ := json.Marshal(map[string]any{
+		"content": `{"Age": [{"value": "62", "context": "a 62 year old"}]}`,
+		"timings": json.RawMessage(sampleTimings),
+	})
+	llm := newStubLLM(t, string(body))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Age:\n  type: number\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient is a 62 year old man.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Metadata.Timings == nil || output.Metadata.Timings.PromptN != 120 || output.Metadata.Timings.PredictedN != 42 {
+		t.Errorf("Metadata.Timings = %+v, want the stub's timings", output.Metadata.Timings)
+	}
+}
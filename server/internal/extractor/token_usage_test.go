@@ -0,0 +1,69 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestProcessTextReportsTokenUsage(t *testing.T) {
+	body, _ := json.Marshal(map[string]any{
+		"content":          `{"Age": [{"value": "62", "context": "62 year old"}]}`,
+		"tokens_evaluated": 310,
+		"tokens_predicted": 27,
+	})
+	llm := newStubLLM(t, string(body))
+	s := newTestService(t, llm.URL, map[string]string{"demo.yaml": "Age:\n  type: number\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"demo"}, "Patient is a 62 year old man.", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := output.Metadata.TokenUsage
+	if usage.TokensEvaluated != 310 || usage.TokensPredicted != 27 || usage.Total != 337 {
+		t.Errorf("usage = %+v, want 310 evaluated, 27 predicted, 337 total", usage)
+	}
+	prompt, _ := llm.Requests()[0]["prompt"].(string)
+	if prompt == "" || usage.PromptTokens != estimateTokens(prompt) {
+		t.Errorf("prompt tokens = %d, want the estimate of the prompt sent", usage.PromptTokens)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	for text, want := range map[string]int{"": 0, "abc": 1, "abcd": 1, "abcde": 2, "ßßßßß": 2} {
+		if got := estimateTokens(text); got != want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestCallLLMSendsOutputBudget(t *testing.T) {
+	for _, tt := range []struct {
+		maxOutputTokens int
+		wantNPredict    float64
+	}{
+		{512, 512},
+		{0, -1}, // Unbounded
+	} {
+		llm := newStubLLM(t, completion(`{}`))
+		s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+		s.maxOutputTokens = tt.maxOutputTokens
+		if _, err := s.ProcessText(context.Background(), []string{"meds"}, "Patient on aspirin.", ProcessOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if got := llm.Requests()[0]["n_predict"]; got != tt.wantNPredict {
+			t.Errorf("max output %d: n_predict = %v, want %v", tt.maxOutputTokens, got, tt.wantNPredict)
+		}
+	}
+}
+
+func TestDefaultConfigLeavesResponseUnbounded(t *testing.T) {
+	llm := newStubLLM(t, completion(`{}`))
+	s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+	if _, err := s.ProcessText(context.Background(), []string{"meds"}, "Patient on aspirin.", ProcessOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if request := llm.Requests()[0]; request["n_predict"] != float64(-1) || request["max_tokens"] != float64(16384) {
+		t.Errorf("n_predict/max_tokens = %v/%v, want the unbounded -1/16384", request["n_predict"], request["max_tokens"])
+	}
+}
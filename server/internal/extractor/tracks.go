@@ -0,0 +1,57 @@
+package extractor
+
+import (
+	"sort"
+)
+
+// AssignTracks sets Track on every occurrence so that occurrences sharing a
+// track never overlap, letting a UI draw each track as one row of highlights.
+//
+// Occurrences are placed in order of start position on the lowest-numbered
+// track that is already free, which uses the minimum possible number of
+// tracks (the largest number of spans overlapping at any point). It returns
+// that number.
+func AssignTracks(output *ExtractionOutput) int {
+	type ref struct {
+		entity string
+		index  int
+		pos    Position
+	}
+	refs := []ref{}
+	for entityName, occurrences := range output.Entities {
+		for i, occurrence := range occurrences {
+			refs = append(refs, ref{entityName, i, occurrence.Position})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.pos.Start != b.pos.Start {
+			return a.pos.Start < b.pos.Start
+		}
+		if a.pos.End != b.pos.End {
+			return a.pos.End > b.pos.End // Longer spans first, on the lower track
+		}
+		if a.entity != b.entity {
+			return a.entity < b.entity
+		}
+		return a.index < b.index
+	})
+
+	trackEnds := []int{} // End of the last span placed on each track
+	for _, r := range refs {
+		track := -1
+		for t, end := range trackEnds {
+			if end <= r.pos.Start {
+				track = t
+				break
+			}
+		}
+		if track < 0 {
+			track = len(trackEnds)
+			trackEnds = append(trackEnds, 0)
+		}
+		trackEnds[track] = r.pos.End
+		output.Entities[r.entity][r.index].Track = track
+	}
+	return len(trackEnds)
+}
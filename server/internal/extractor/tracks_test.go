@@ -0,0 +1,48 @@
+package extractor
+
+import "testing"
+
+func TestAssignTracksUsesMinimalTracks(t *testing.T) {
+	output := &ExtractionOutput{Entities: map[string][]EntityOccurrence{
+		"Medication": {
+			{Position: Position{Start: 0, End: 20}},
+			{Position: Position{Start: 30, End: 35}},
+		},
+		"Drug name": {{Position: Position{Start: 12, End: 20}}}, // Inside the first medication
+		"Dose":      {{Position: Position{Start: 5, End: 14}}},  // Overlaps both of the above
+		"Route":     {{Position: Position{Start: 20, End: 25}}}, // Touches but doesn't overlap
+	}}
+
+	// At position 12 three spans overlap, so three tracks are needed
+	if got := AssignTracks(output); got != 3 {
+		t.Fatalf("AssignTracks() = %d, want 3", got)
+	}
+
+	type placed struct {
+		name string
+		occ  EntityOccurrence
+	}
+	all := []placed{}
+	for name, occs := range output.Entities {
+		for _, occ := range occs {
+			all = append(all, placed{name, occ})
+		}
+	}
+	for i, a := range all {
+		for _, b := range all[i+1:] {
+			overlap := a.occ.Position.Start < b.occ.Position.End && b.occ.Position.Start < a.occ.Position.End
+			if overlap && a.occ.Track == b.occ.Track {
+				t.Errorf("%s %v and %s %v overlap on track %d", a.name, a.occ.Position, b.name, b.occ.Position, a.occ.Track)
+			}
+		}
+	}
+	if output.Entities["Medication"][0].Track != 0 || output.Entities["Route"][0].Track != 0 {
+		t.Error("non-overlapping spans should reuse the first track")
+	}
+}
+
+func TestAssignTracksEmpty(t *testing.T) {
+	if got := AssignTracks(&ExtractionOutput{}); got != 0 {
+		t.Errorf("AssignTracks() = %d on no entities, want 0", got)
+	}
+}
@@ -0,0 +1,97 @@
+package extractor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unitList holds the configured units, longest first so "mg/dL" wins over "mg".
+type unitList []string
+
+func newUnitList(units []string) unitList {
+	list := make(unitList, 0, len(units))
+	for _, unit := range units {
+		if unit = strings.TrimSpace(unit); unit != "" {
+			list = append(list, unit)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return len([]rune(list[i])) > len([]rune(list[j])) })
+	return list
+}
+
+// prefixOf returns the unit that runes start with, matched case-insensitively
+// and not followed by a letter or digit, and its length in runes.
+func (l unitList) prefixOf(runes []rune) (string, int) {
+	for _, unit := range l {
+		unitRunes := []rune(unit)
+		if len(unitRunes) > len(runes) || !strings.EqualFold(string(runes[:len(unitRunes)]), unit) {
+			continue
+		}
+		if len(unitRunes) < len(runes) && isWordRune(runes[len(unitRunes)]) && isWordRune(unitRunes[len(unitRunes)-1]) {
+			continue // "m" must not match the start of "min"
+		}
+		return unit, len(unitRunes)
+	}
+	return "", 0
+}
+
+// splitValueUnit separates a trailing unit from a value such as "98.7°F",
+// returning the number and the unit as configured.
+func (l unitList) splitValueUnit(value string) (float64, string, bool) {
+	value = strings.TrimSpace(value)
+	for _, unit := range l {
+		if len(value) <= len(unit) || !strings.EqualFold(value[len(value)-len(unit):], unit) {
+			continue
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(value[:len(value)-len(unit)]), 64)
+		if err == nil {
+			return number, unit, true
+		}
+	}
+	return 0, "", false
+}
+
+// splitUnits moves the unit of number entity values into Unit, leaving Value
+// numeric. A unit is taken from the value itself ("98.7°F") or, when the value
+// is a bare number, from the text right after the matched value, which the
+// highlight is then extended over. It returns how many units were split off.
+func (l unitList) splitUnits(output *ExtractionOutput, entities map[string]bool) int {
+	runes := []rune(output.Text)
+	split := 0
+	for entityName := range entities {
+		occurrences := output.Entities[entityName]
+		for i := range occurrences {
+			o := &occurrences[i]
+			if value, isString := o.Value.(string); isString {
+				if number, unit, ok := l.splitValueUnit(value); ok {
+					o.Value, o.Unit = number, unit
+					split++
+					continue
+				}
+				number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					continue // Not a number, leave it alone
+				}
+				o.Value = number
+			} else if _, isNumber := o.Value.(float64); !isNumber {
+				continue
+			}
+
+			// Bare number: look for a unit just after it in the text
+			after := o.Position.End
+			if after < len(runes) && (runes[after] == ' ' || runes[after] == '\u00A0') {
+				after++ // "120 mmHg"
+			}
+			if unit, length := l.prefixOf(runes[after:]); unit != "" {
+				o.Unit = unit
+				o.Position.End = after + length
+				if o.ValueInContext != nil {
+					o.ValueInContext = valueInContext(o.Position, o.Context.Position)
+				}
+				split++
+			}
+		}
+	}
+	return split
+}
@@ -0,0 +1,75 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSplitUnits(t *testing.T) {
+	text := "Temp 98.7°F, BP 120 mmHg, glucose 5.4 mmol/L, weight 70."
+	schema := `
+Temperature:
+  type: number
+Systolic:
+  type: number
+Glucose:
+  type: number
+Weight:
+  type: number
+`
+	llm := newStubLLM(t, completion(`{
+		"Temperature": [{"value": "98.7°F", "context": "Temp 98.7°F"}],
+		"Systolic": [{"value": "120", "context": "BP 120 mmHg"}],
+		"Glucose": [{"value": "5.4", "context": "glucose 5.4 mmol/L"}],
+		"Weight": [{"value": "70", "context": "weight 70"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"vitals.yaml": schema})
+	s.match.units = newUnitList([]string{"°F", "mmHg", "mmol/L", "mm"})
+
+	output, err := s.ProcessText(context.Background(), []string{"vitals"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		entity   string
+		value    float64
+		unit     string
+		position string // Text the highlight covers
+	}{
+		{"Temperature", 98.7, "°F", "98.7°F"},
+		{"Systolic", 120, "mmHg", "120 mmHg"},
+		{"Glucose", 5.4, "mmol/L", "5.4 mmol/L"}, // Not just "mm"
+		{"Weight", 70, "", "70"},
+	}
+	runes := []rune(output.Text)
+	for _, tt := range tests {
+		occurrences := output.Entities[tt.entity]
+		if len(occurrences) != 1 {
+			t.Errorf("%s = %+v, want one occurrence", tt.entity, occurrences)
+			continue
+		}
+		o := occurrences[0]
+		if o.Value != tt.value || o.Unit != tt.unit {
+			t.Errorf("%s = %v %q, want %v %q", tt.entity, o.Value, o.Unit, tt.value, tt.unit)
+		}
+		if got := string(runes[o.Position.Start:o.Position.End]); got != tt.position {
+			t.Errorf("%s highlights %q, want %q", tt.entity, got, tt.position)
+		}
+	}
+}
+
+func TestSplitUnitsDisabledByDefault(t *testing.T) {
+	llm := newStubLLM(t, completion(`{"Temperature": [{"value": "98.7°F", "context": "Temp 98.7°F"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"vitals.yaml": "Temperature:\n  type: number\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"vitals"}, "Temp 98.7°F", ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	temps := output.Entities["Temperature"]
+	if len(temps) != 1 || temps[0].Unit != "" || !strings.Contains(temps[0].Value.(string), "°F") {
+		t.Errorf("Temperature = %+v, want the value left as extracted", temps)
+	}
+}
@@ -0,0 +1,32 @@
+package extractor
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Punctuation the LLM tends to leave at the end of values ("98.6,")
+const trailingValuePunctuation = ",;:."
+
+// valueCleaning tidies the value strings the LLM returns before they are
+// searched for, as stray whitespace or punctuation defeats exact matching.
+type valueCleaning struct {
+	trim          bool // Drop whitespace around the value
+	stripTrailing bool // Drop trailing punctuation, and the whitespace around it
+	keepCleaned   bool // Return the cleaned string as the occurrence's value
+}
+
+func (vc valueCleaning) enabled() bool {
+	return vc.trim || vc.stripTrailing
+}
+
+// clean returns value with the configured cleaning applied.
+func (vc valueCleaning) clean(value string) string {
+	if vc.trim || vc.stripTrailing {
+		value = strings.TrimFunc(value, unicode.IsSpace)
+	}
+	if vc.stripTrailing {
+		value = strings.TrimRightFunc(strings.TrimRight(value, trailingValuePunctuation), unicode.IsSpace)
+	}
+	return value
+}
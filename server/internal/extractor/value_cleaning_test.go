@@ -0,0 +1,69 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValueCleaningClean(t *testing.T) {
+	tests := []struct {
+		cleaning valueCleaning
+		value    string
+		want     string
+	}{
+		{valueCleaning{trim: true}, "  aspirin ", "aspirin"},
+		{valueCleaning{trim: true}, "aspirin,", "aspirin,"},
+		{valueCleaning{stripTrailing: true}, " aspirin, ", "aspirin"},
+		{valueCleaning{stripTrailing: true}, "98.6;.", "98.6"},
+		{valueCleaning{}, " aspirin ", " aspirin "},
+	}
+	for _, tt := range tests {
+		if got := tt.cleaning.clean(tt.value); got != tt.want {
+			t.Errorf("%+v.clean(%q) = %q, want %q", tt.cleaning, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestProcessTextCleansValuesBeforeMatching(t *testing.T) {
+	llm := newStubLLM(t, completion(`{
+		"Medication": [{"value": "  aspirin ", "context": "on aspirin daily"}],
+		"Allergy": [{"value": "penicillin;", "context": "allergic to penicillin, sulfa"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"note.yaml": "Medication:\n  type: string\nAllergy:\n  type: string\n"})
+	text := "Patient on aspirin daily, allergic to penicillin, sulfa."
+
+	tests := []struct {
+		name       string
+		cleaning   valueCleaning
+		medication string // Value returned, "" when not found
+		allergy    string // "penicillin;" is not in the text until stripped
+	}{
+		{"trim only", valueCleaning{trim: true}, "  aspirin ", ""},
+		{"strip trailing", valueCleaning{trim: true, stripTrailing: true}, "  aspirin ", "penicillin;"},
+		{"keep cleaned", valueCleaning{trim: true, stripTrailing: true, keepCleaned: true}, "aspirin", "penicillin"},
+	}
+	for _, tt := range tests {
+		s.match.valueCleaning = tt.cleaning
+		output, err := s.ProcessText(context.Background(), []string{"note"}, text, ProcessOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		runes := []rune(output.Text)
+		medications := output.Entities["Medication"]
+		if len(medications) != 1 || medications[0].Value != tt.medication ||
+			string(runes[medications[0].Position.Start:medications[0].Position.End]) != "aspirin" {
+			t.Errorf("%s: Medication = %+v, want %q highlighting aspirin", tt.name, medications, tt.medication)
+		}
+		allergies := output.Entities["Allergy"]
+		if tt.allergy == "" {
+			if len(allergies) != 0 {
+				t.Errorf("%s: Allergy = %+v, want the unstripped value unmatched", tt.name, allergies)
+			}
+			continue
+		}
+		if len(allergies) != 1 || allergies[0].Value != tt.allergy ||
+			string(runes[allergies[0].Position.Start:allergies[0].Position.End]) != "penicillin" {
+			t.Errorf("%s: Allergy = %+v, want %q highlighting penicillin", tt.name, allergies, tt.allergy)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProcessTextValueInContext(t *testing.T) {
+	// Multi-byte runes before and inside the context catch byte/rune mix-ups
+	text := "Café visit — patient on métoprolol 25 mg daily."
+	llm := newStubLLM(t, completion(`{"Medication": [{"value": "métoprolol", "context": "patient on métoprolol 25 mg"}]}`))
+	s := newTestService(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"})
+
+	output, err := s.ProcessText(context.Background(), []string{"meds"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meds := output.Entities["Medication"]
+	if len(meds) != 1 || meds[0].ValueInContext == nil {
+		t.Fatalf("medications = %+v, want one with a value in context", meds)
+	}
+	runes := []rune(text)
+	contextRunes := runes[meds[0].Context.Position.Start:meds[0].Context.Position.End]
+	relative := meds[0].ValueInContext
+	if got := string(contextRunes[relative.Start:relative.End]); got != "métoprolol" {
+		t.Errorf("relative position covers %q in the context, want the value", got)
+	}
+}
+
+func TestValueInContextOutsideContext(t *testing.T) {
+	if got := valueInContext(Position{Start: 2, End: 6}, Position{Start: 4, End: 10}); got != nil {
+		t.Errorf("valueInContext = %+v, want nil for a value outside its context", got)
+	}
+}
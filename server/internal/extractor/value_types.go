@@ -0,0 +1,41 @@
+package extractor
+
+// JSON kinds reported in EntityOccurrence.ValueType
+const (
+	ValueTypeString  = "string"
+	ValueTypeNumber  = "number"
+	ValueTypeBoolean = "boolean"
+	ValueTypeArray   = "array"
+	ValueTypeObject  = "object"
+	ValueTypeNull    = "null"
+)
+
+// valueTypeOf names the JSON kind of a parsed value, so clients need not
+// guess it from the serialized form.
+func valueTypeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return ValueTypeNull
+	case string:
+		return ValueTypeString
+	case float64, float32, int, int64, int32, uint, uint64, uint32:
+		return ValueTypeNumber
+	case bool:
+		return ValueTypeBoolean
+	case []any:
+		return ValueTypeArray
+	case map[string]any:
+		return ValueTypeObject
+	default:
+		return ""
+	}
+}
+
+// annotateValueTypes sets ValueType on every occurrence of the output.
+func annotateValueTypes(output *ExtractionOutput) {
+	for _, occurrences := range output.Entities {
+		for i := range occurrences {
+			occurrences[i].ValueType = valueTypeOf(occurrences[i].Value)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValueTypeOf(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"aspirin", ValueTypeString},
+		{38.2, ValueTypeNumber},
+		{7, ValueTypeNumber},
+		{true, ValueTypeBoolean},
+		{[]any{"a", "b"}, ValueTypeArray},
+		{map[string]any{"dose": "5 mg"}, ValueTypeObject},
+		{nil, ValueTypeNull},
+	}
+	for _, tt := range tests {
+		if got := valueTypeOf(tt.value); got != tt.want {
+			t.Errorf("valueTypeOf(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestProcessTextValueTypes(t *testing.T) {
+	schema := `
+HeartRate:
+  type: number
+Medication:
+  type: string
+`
+	llm := newStubLLM(t, completion(`{
+		"HeartRate": [{"value": 80, "context": "HR 80"}],
+		"Medication": [{"value": "aspirin", "context": "on aspirin"}]
+	}`))
+	s := newTestService(t, llm.URL, map[string]string{"note.yaml": schema})
+	text := "HR 80 on aspirin."
+
+	output, err := s.ProcessText(context.Background(), []string{"note"}, text, ProcessOptions{ValueTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := output.Entities["HeartRate"][0].ValueType; got != ValueTypeNumber {
+		t.Errorf("HeartRate value type = %q, want number", got)
+	}
+	if got := output.Entities["Medication"][0].ValueType; got != ValueTypeString {
+		t.Errorf("Medication value type = %q, want string", got)
+	}
+
+	output, err = s.ProcessText(context.Background(), []string{"note"}, text, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := output.Entities["HeartRate"][0].ValueType; got != "" {
+		t.Errorf("value type = %q without value_types, want none", got)
+	}
+}
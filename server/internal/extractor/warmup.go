@@ -0,0 +1,64 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// warmUpPrompt is a tiny ChatML prompt used to get the model loaded before
+// the first real extraction.
+const warmUpPrompt = "<|im_start|>system\nYou are a medical information extraction system.\n<|im_end|>\n<|im_start|>user\nReply with {}.\n<|im_end|>\n<|im_start|>assistant\n"
+
+// warmUp sends a minimal completion request to the LLM server, bounded by
+// timeout. It doubles as a connectivity check; callers treat errors as
+// non-fatal.
+func (s *ExtractorService) warmUp(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	data, err := json.Marshal(map[string]any{
+		"prompt":       warmUpPrompt,
+		"n_predict":    1, // Only the prompt evaluation matters
+		"temperature":  0.01,
+		"stream":       false,
+		"cache_prompt": true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal warm-up payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.llmServerURL, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create warm-up request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send warm-up request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("llm server returned non-200 status: %d - %s", resp.StatusCode, limitString(string(body), 100))
+	}
+	return nil
+}
+
+// runWarmUp performs the warm-up and logs the outcome.
+func (s *ExtractorService) runWarmUp(timeout time.Duration) {
+	s.logger.Info("Warming up LLM server", zap.String("url", s.llmServerURL), zap.Duration("timeout", timeout))
+	start := time.Now()
+	if err := s.warmUp(timeout); err != nil {
+		s.logger.Warn("LLM warm-up failed; continuing without it", zap.Duration("elapsed", time.Since(start)), zap.Error(err))
+		return
+	}
+	s.logger.Info("LLM warm-up succeeded", zap.Duration("elapsed", time.Since(start)))
+}
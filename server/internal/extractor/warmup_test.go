@@ -0,0 +1,44 @@
+package extractor
+
+import (
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestNewExtractorServiceWarmsUp(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		llm := newStubLLM(t, completion("{}"))
+		cfg := config.NewDefaultConfig()
+		cfg.LLM.ServerURL = llm.URL
+		cfg.LLM.SchemaDirs = []string{t.TempDir()}
+		cfg.LLM.WarmUp = enabled
+
+		if _, err := NewExtractorService(cfg, zap.NewNop(), ""); err != nil {
+			t.Fatal(err)
+		}
+		requests := llm.Requests()
+		if !enabled {
+			if len(requests) != 0 {
+				t.Errorf("warm-up sent %d requests while disabled", len(requests))
+			}
+			continue
+		}
+		if len(requests) != 1 || requests[0]["prompt"] != warmUpPrompt {
+			t.Errorf("got requests %v, want one warm-up prompt", requests)
+		}
+	}
+}
+
+func TestWarmUpFailureIsNotFatal(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	cfg.LLM.ServerURL = "http://127.0.0.1:1" // Nothing listens here
+	cfg.LLM.SchemaDirs = []string{t.TempDir()}
+	cfg.LLM.WarmUp = true
+	cfg.LLM.WarmUpTimeoutSeconds = 1
+
+	if _, err := NewExtractorService(cfg, zap.NewNop(), ""); err != nil {
+		t.Errorf("NewExtractorService failed on an unreachable warm-up: %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Content type that switches the batch endpoint to streaming mode
+const ndjsonContentType = "application/x-ndjson"
+
+// BatchExtractRequest defines the expected JSON body for the /api/extract/batch endpoint.
+type BatchExtractRequest struct {
+	Documents   []string `json:"documents" binding:"required,min=1"`
+	SchemaNames []string `json:"schema_names"` // Falls back to llm.default_schemas when omitted
+	// Stop at the first failed document instead of extracting every one;
+	// documents left unfinished are reported as skipped
+	FailFast bool `json:"fail_fast"`
+}
+
+// BatchItemResult is the outcome of extracting a single document of a batch.
+type BatchItemResult struct {
+	Index  int                         `json:"index"` // Position of the document in the request
+	Result *extractor.ExtractionOutput `json:"result,omitempty"`
+	Error  string                      `json:"error,omitempty"`
+	// Not extracted because a fail_fast batch stopped at an earlier failure
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Error of the documents a fail_fast batch did not finish
+const batchSkippedError = "Skipped: batch stopped after a failed document"
+
+// BatchExtract handles POST /api/extract/batch
+//
+// By default the response is a JSON array ordered by document index. When the
+// client sends "Accept: application/x-ndjson", one result object per line is
+// streamed as each document finishes. Streamed results come in completion
+// order unless batch.preserve_order is set; the "order" query parameter
+// ("input" or "completion") overrides the configured default.
+//
+// Failed documents are reported in their result and the rest still run,
+// unless the request sets fail_fast: then the first failure cancels the
+// remaining work and every unfinished document is reported as skipped.
+func (h *ExtractHandler) BatchExtract(c *gin.Context) {
+	var req BatchExtractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Logger.Error("Failed to bind JSON request for batch extraction", zap.Error(err))
+		respondJSON(c, bindErrorStatus(err), gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	if !h.applyDefaultSchemas(&req.SchemaNames) {
+		h.Logger.Warn("Batch request without schema names and no defaults configured")
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "schema_names is required"})
+		return
+	}
+
+	if rejectTooManySchemas(c, h.Logger, h.Extractor.MaxRequestSchemas(), req.SchemaNames) {
+		return
+	}
+	if h.rejectHiddenSchemas(c, req.SchemaNames) {
+		return
+	}
+	if h.rejectWhileLLMDown(c) {
+		return
+	}
+	invalidSchemas := h.unknownSchemaNames(req.SchemaNames)
+	if len(invalidSchemas) > 0 {
+		h.Logger.Error("Invalid schema names requested for batch", zap.Strings("invalid", invalidSchemas), zap.Strings("requested", req.SchemaNames))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid schema name(s) provided: %v", invalidSchemas)})
+		return
+	}
+
+	ordered := h.BatchPreserveOrder
+	switch order := c.Query("order"); order {
+	case "":
+	case "input":
+		ordered = true
+	case "completion":
+		ordered = false
+	default:
+		h.Logger.Warn("Unsupported batch order requested", zap.String("order", order))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Unsupported order, expected 'input' or 'completion'"})
+		return
+	}
+
+	// The request context is cancelled when the client disconnects
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	// Cancelled on the first failure of a fail_fast batch
+	workCtx, stopWork := context.WithCancel(ctx)
+	defer stopWork()
+
+	finished := make([]bool, len(req.Documents))
+	settle := func(item BatchItemResult) {
+		finished[item.Index] = true
+		if req.FailFast && item.Error != "" && !item.Skipped && workCtx.Err() == nil {
+			h.Logger.Warn("Stopping fail-fast batch after failed document", zap.Int("index", item.Index))
+			stopWork()
+		}
+	}
+	// Results for the documents a stopped batch never started
+	unstarted := func() []BatchItemResult {
+		skipped := []BatchItemResult{}
+		for index, done := range finished {
+			if !done {
+				skipped = append(skipped, BatchItemResult{Index: index, Error: batchSkippedError, Skipped: true})
+			}
+		}
+		return skipped
+	}
+
+	h.Logger.Info("Starting batch extraction",
+		zap.Int("documents", len(req.Documents)),
+		zap.Strings("schemas", req.SchemaNames),
+		zap.Int("workers", h.BatchWorkers),
+	)
+
+	if strings.Contains(c.GetHeader("Accept"), ndjsonContentType) {
+		c.Header("Content-Type", ndjsonContentType)
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		stream := func(item BatchItemResult) {
+			if ctx.Err() != nil {
+				return // Client is gone, drop remaining results
+			}
+			if err := encoder.Encode(item); err != nil {
+				h.Logger.Warn("Failed to stream batch result, cancelling remaining work", zap.Int("index", item.Index), zap.Error(err))
+				cancel()
+				return
+			}
+			c.Writer.Flush()
+		}
+		h.runBatch(workCtx, req.SchemaNames, req.Documents, ordered, func(item BatchItemResult) {
+			settle(item)
+			stream(item)
+		})
+		for _, item := range unstarted() {
+			stream(item)
+		}
+		return
+	}
+
+	results := make([]BatchItemResult, len(req.Documents))
+	h.runBatch(workCtx, req.SchemaNames, req.Documents, false, func(item BatchItemResult) {
+		settle(item)
+		results[item.Index] = item
+	})
+	if ctx.Err() != nil {
+		h.Logger.Warn("Batch extraction cancelled", zap.Error(ctx.Err()))
+		return
+	}
+	for _, item := range unstarted() {
+		results[item.Index] = item
+	}
+	respondJSON(c, http.StatusOK, results)
+}
+
+// runBatch extracts the documents with a bounded pool of workers, calling emit
+// from a single goroutine as each document completes, or in document order
+// when ordered is set. Documents not yet started when ctx is cancelled are
+// skipped.
+//
+// At most one document per worker is dispatched but not yet emitted, so the
+// results held while waiting to be reordered stay bounded by the worker count.
+func (h *ExtractHandler) runBatch(ctx context.Context, schemaNames []string, documents []string, ordered bool, emit func(BatchItemResult)) {
+	workers := max(1, min(h.BatchWorkers, len(documents)))
+	jobs := make(chan int)
+	results := make(chan BatchItemResult)
+	slots := make(chan struct{}, workers) // Released when a result is emitted
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results <- h.extractBatchItem(ctx, schemaNames, index, documents[index])
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for index := range documents {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]BatchItemResult, workers)
+	next := 0
+	for item := range results {
+		if !ordered {
+			emit(item)
+			<-slots
+			continue
+		}
+		pending[item.Index] = item
+		for ready, ok := pending[next]; ok; ready, ok = pending[next] {
+			delete(pending, next)
+			emit(ready)
+			<-slots
+			next++
+		}
+	}
+}
+
+// extractBatchItem runs the extraction for one document of a batch.
+func (h *ExtractHandler) extractBatchItem(ctx context.Context, schemaNames []string, index int, text string) BatchItemResult {
+	result, err := h.Extractor.ProcessText(ctx, schemaNames, text, extractor.ProcessOptions{})
+	if err != nil && ctx.Err() != nil {
+		h.Logger.Info("Batch document cancelled", zap.Int("index", index), zap.Error(err))
+		return BatchItemResult{Index: index, Error: batchSkippedError, Skipped: true}
+	}
+	if err != nil {
+		h.Logger.Error("Batch document extraction failed", zap.Int("index", index), zap.Error(err))
+		return BatchItemResult{Index: index, Error: fmt.Sprintf("Extraction failed: %v", err)}
+	}
+	h.Logger.Info("Batch document extracted",
+		zap.Int("index", index),
+		zap.Int("entities_found", len(result.Entities)),
+	)
+	event := newExtractionEvent("batch", schemaNames, result)
+	event.Index = &index
+	h.Events.Publish(event)
+	return BatchItemResult{Index: index, Result: result}
+}
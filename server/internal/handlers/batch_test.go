@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// streamBatch posts the documents to the batch endpoint in NDJSON mode and
+// returns the document indexes in the order they were streamed.
+func streamBatch(t *testing.T, h *ExtractHandler, target string, documents []string) []int {
+	t.Helper()
+	body, _ := json.Marshal(BatchExtractRequest{Documents: documents, SchemaNames: []string{"meds"}})
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", ndjsonContentType)
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/api/extract/batch", h.BatchExtract)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var order []int
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var item BatchItemResult
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatal(err)
+		}
+		if item.Error != "" {
+			t.Fatalf("document %d failed: %s", item.Index, item.Error)
+		}
+		order = append(order, item.Index)
+	}
+	return order
+}
+
+func TestBatchExtractOrdering(t *testing.T) {
+	// The first document finishes last
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if strings.Contains(payload.Prompt, "slow document") {
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content": "{}"}`))
+	}))
+	t.Cleanup(llm.Close)
+
+	s := newTestExtractor(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"}, nil)
+	documents := []string{"slow document", "fast document one", "fast document two"}
+
+	tests := map[string]struct {
+		preserveOrder bool
+		target        string
+		wantInput     bool
+	}{
+		"configured input order":     {true, "/api/extract/batch", true},
+		"requested input order":      {false, "/api/extract/batch?order=input", true},
+		"completion order":           {false, "/api/extract/batch", false},
+		"requested completion order": {true, "/api/extract/batch?order=completion", false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := NewExtractHandler(s, zap.NewNop(), len(documents), tt.preserveOrder, nil, nil, nil)
+			order := streamBatch(t, h, tt.target, documents)
+			if len(order) != len(documents) {
+				t.Fatalf("streamed %d results, want %d", len(order), len(documents))
+			}
+			if tt.wantInput && (order[0] != 0 || order[1] != 1 || order[2] != 2) {
+				t.Errorf("streamed order = %v, want input order", order)
+			}
+			if !tt.wantInput && order[len(order)-1] != 0 {
+				t.Errorf("streamed order = %v, want the slow document last", order)
+			}
+		})
+	}
+}
+
+func TestBatchExtractFailurePolicies(t *testing.T) {
+	// The LLM server fails on the second document
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if strings.Contains(payload.Prompt, "broken document") {
+			http.Error(w, "model crashed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content": "{}"}`))
+	}))
+	t.Cleanup(llm.Close)
+
+	s := newTestExtractor(t, llm.URL, map[string]string{"meds.yaml": "Medication:\n  type: string\n"}, nil)
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil) // One worker, so documents run in order
+	documents := []string{"first document", "broken document", "third document", "fourth document"}
+
+	for _, failFast := range []bool{false, true} {
+		body, _ := json.Marshal(BatchExtractRequest{Documents: documents, SchemaNames: []string{"meds"}, FailFast: failFast})
+		w := serve(http.MethodPost, "/api/extract/batch", "/api/extract/batch", string(body), h.BatchExtract)
+		if w.Code != http.StatusOK {
+			t.Fatalf("fail_fast %v: status = %d: %s", failFast, w.Code, w.Body)
+		}
+		var results []BatchItemResult
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(documents) {
+			t.Fatalf("fail_fast %v: %d results, want one per document", failFast, len(results))
+		}
+
+		if results[0].Result == nil || results[1].Error == "" || results[1].Skipped {
+			t.Errorf("fail_fast %v: first two results = %+v, want a success then the failure", failFast, results[:2])
+		}
+		for _, item := range results[2:] {
+			if failFast && (!item.Skipped || item.Result != nil) {
+				t.Errorf("fail_fast: document %d = %+v, want it skipped", item.Index, item)
+			}
+			if !failFast && (item.Result == nil || item.Error != "") {
+				t.Errorf("collect: document %d = %+v, want it extracted", item.Index, item)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"go.uber.org/zap"
+)
+
+// CallbackPayload is the JSON body posted to a callback URL when an
+// extraction finishes.
+type CallbackPayload struct {
+	JobID  string                      `json:"jobId"`
+	Status string                      `json:"status"` // "done" or "failed"
+	Result *extractor.ExtractionOutput `json:"result,omitempty"`
+	Error  string                      `json:"error,omitempty"`
+}
+
+// CallbackClient posts finished extractions to client-supplied URLs. Only
+// hosts on the configured allow list are called, and redirects are not
+// followed, so a request cannot make the server reach arbitrary internal
+// addresses.
+type CallbackClient struct {
+	allowedHosts map[string]bool
+	maxAttempts  int
+	retryDelay   time.Duration // Doubled after every failed attempt
+	httpClient   *http.Client
+	logger       *zap.Logger
+}
+
+// NewCallbackClient creates a callback client for the given host[:port]
+// entries. With no allowed hosts every callback URL is rejected.
+func NewCallbackClient(allowedHosts []string, maxAttempts int, timeout time.Duration, logger *zap.Logger) *CallbackClient {
+	hosts := make(map[string]bool, len(allowedHosts))
+	for _, host := range allowedHosts {
+		hosts[strings.ToLower(host)] = true
+	}
+	return &CallbackClient{
+		allowedHosts: hosts,
+		maxAttempts:  max(1, maxAttempts),
+		retryDelay:   time.Second,
+		httpClient: &http.Client{
+			Timeout: timeout,
+			// An allowed host could otherwise redirect the POST anywhere
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		logger: logger.Named("CallbackClient"),
+	}
+}
+
+// Validate rejects callback URLs that are not http(s) or whose host is not
+// allowed.
+func (cc *CallbackClient) Validate(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback_url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("callback_url must be an http or https URL")
+	}
+	if u.User != nil || !cc.allowedHosts[strings.ToLower(u.Host)] {
+		return fmt.Errorf("callback_url host %q is not allowed", u.Host)
+	}
+	return nil
+}
+
+// Deliver posts the payload to callbackURL, retrying failed attempts with a
+// growing delay until one gets a 2xx response or the attempts run out.
+func (cc *CallbackClient) Deliver(ctx context.Context, callbackURL string, payload CallbackPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode callback payload: %w", err)
+	}
+
+	delay := cc.retryDelay
+	for attempt := 1; ; attempt++ {
+		err = cc.post(ctx, callbackURL, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= cc.maxAttempts {
+			return fmt.Errorf("callback failed after %d attempts: %w", attempt, err)
+		}
+		cc.logger.Warn("Callback attempt failed, retrying",
+			zap.String("jobId", payload.JobID), zap.Int("attempt", attempt), zap.Duration("delay", delay), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (cc *CallbackClient) post(ctx context.Context, callbackURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cc.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deliverCallback posts the finished job to callbackURL.
+func (h *ExtractHandler) deliverCallback(callbackURL string, job Job) {
+	payload := CallbackPayload{JobID: job.ID, Status: job.Status, Result: job.Result, Error: job.Error}
+	if err := h.Callbacks.Deliver(context.Background(), callbackURL, payload); err != nil {
+		h.Logger.Error("Failed to deliver extraction callback", zap.String("jobId", job.ID), zap.Error(err))
+		return
+	}
+	h.Logger.Info("Delivered extraction callback", zap.String("jobId", job.ID), zap.String("status", job.Status))
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// callbackReceiver records the payloads posted to it, failing the first
+// failures deliveries with a 503.
+func callbackReceiver(t *testing.T, failures int32) (*httptest.Server, <-chan CallbackPayload) {
+	t.Helper()
+	payloads := make(chan CallbackPayload, 4)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload CallbackPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	t.Cleanup(server.Close)
+	return server, payloads
+}
+
+func TestExtractEntitiesDeliversCallback(t *testing.T) {
+	receiver, payloads := callbackReceiver(t, 1)
+	receiverURL, _ := url.Parse(receiver.URL)
+
+	llm := newLLMStub(t, `{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	callbacks := NewCallbackClient([]string{receiverURL.Host}, 3, time.Second, zap.NewNop())
+	callbacks.retryDelay = time.Millisecond
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, callbacks, NewJobStore(1, 10, time.Hour, zap.NewNop()), nil)
+
+	body := `{"text": "Patient on aspirin.", "schema_names": ["meds"], "callback_url": "` + receiver.URL + `/done"}`
+	w := serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var queued struct {
+		JobID string `json:"jobId"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queued)
+
+	select {
+	case payload := <-payloads:
+		if payload.JobID != queued.JobID || payload.Status != "done" {
+			t.Errorf("payload = %+v, want job %s done", payload, queued.JobID)
+		}
+		if payload.Result == nil || len(payload.Result.Entities["Medication"]) != 1 {
+			t.Errorf("payload result = %+v, want the extracted medication", payload.Result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+}
+
+func TestExtractEntitiesRejectsDisallowedCallback(t *testing.T) {
+	llm := newLLMStub(t, `{}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	callbacks := NewCallbackClient([]string{"hooks.example.com"}, 1, time.Second, zap.NewNop())
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, callbacks, NewJobStore(1, 10, time.Hour, zap.NewNop()), nil)
+
+	for _, callbackURL := range []string{
+		"http://169.254.169.254/latest/meta-data",
+		"ftp://hooks.example.com/done",
+		"http://user@hooks.example.com/done",
+		"http://hooks.example.com.evil.test/done",
+	} {
+		body := `{"text": "Patient on aspirin.", "schema_names": ["meds"], "callback_url": "` + callbackURL + `"}`
+		w := serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", callbackURL, w.Code)
+		}
+	}
+	if len(llm.Prompts()) != 0 {
+		t.Error("extraction ran for a rejected callback")
+	}
+}
+
+func TestCallbackClientDoesNotFollowRedirects(t *testing.T) {
+	var reached atomic.Bool
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached.Store(true)
+	}))
+	t.Cleanup(internal.Close)
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/admin", http.StatusTemporaryRedirect)
+	}))
+	t.Cleanup(redirector.Close)
+	redirectorURL, _ := url.Parse(redirector.URL)
+
+	callbacks := NewCallbackClient([]string{redirectorURL.Host}, 1, time.Second, zap.NewNop())
+	if err := callbacks.Deliver(context.Background(), redirector.URL+"/done", CallbackPayload{JobID: "job"}); err == nil {
+		t.Error("Deliver() succeeded on a redirect, want an error")
+	}
+	if reached.Load() {
+		t.Error("callback followed the redirect to another host")
+	}
+}
+
+func TestSlowCallbackDoesNotHoldJobWorker(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(receiver.Close)
+	receiverURL, _ := url.Parse(receiver.URL)
+
+	llm := newLLMStub(t, `{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	callbacks := NewCallbackClient([]string{receiverURL.Host}, 1, time.Minute, zap.NewNop())
+	jobs := NewJobStore(1, 10, time.Hour, zap.NewNop()) // One worker for both jobs
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, callbacks, jobs, nil)
+
+	withCallback := `{"text": "Patient on aspirin.", "schema_names": ["meds"], "callback_url": "` + receiver.URL + `/done"}`
+	if w := serve(http.MethodPost, "/api/extract", "/api/extract", withCallback, h.ExtractEntities); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	w := serve(http.MethodPost, "/api/extract/async", "/api/extract/async", `{"text": "Patient on aspirin.", "schema_names": ["meds"]}`, h.ExtractAsync)
+	var queued struct {
+		JobID string `json:"jobId"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queued)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if job, _ := jobs.Get(queued.JobID); job.Status == JobDone {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second job did not finish while the first job's callback was pending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
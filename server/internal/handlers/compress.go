@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gzip compresses responses for clients that accept gzip. Bodies are held
+// back until they reach minBytes; shorter responses are sent as they are,
+// since compressing them costs more than it saves. Level runs from 1
+// (fastest) to 9 (smallest); a level of zero or less disables compression.
+func Gzip(level, minBytes int) gin.HandlerFunc {
+	if level > gzip.BestCompression {
+		level = gzip.BestCompression
+	}
+	return func(c *gin.Context) {
+		if level <= 0 {
+			c.Next()
+			return
+		}
+		// Whether or not this response ends up compressed, the encoding
+		// depends on the request header, so caches must key on it
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if c.Request.Header.Get("Range") != "" || !acceptsGzip(c.Request.Header.Get("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+		w := &gzipWriter{ResponseWriter: c.Writer, level: level, minBytes: minBytes}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, either
+// by name or through "*", with a q-value above zero.
+func acceptsGzip(acceptEncoding string) bool {
+	gzipQ, wildcardQ := -1.0, -1.0
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					parsed = 0 // A malformed weight does not opt in
+				}
+				q = parsed
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "*":
+			wildcardQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return wildcardQ > 0
+}
+
+// gzipWriter buffers the start of a response body to decide whether it is
+// worth compressing.
+type gzipWriter struct {
+	gin.ResponseWriter
+	level    int
+	minBytes int
+	buf      []byte
+	decided  bool         // Whether to compress is settled and buf was written out
+	gz       *gzip.Writer // nil when the body is sent uncompressed
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minBytes {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to set
+// write deadlines.
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush commits a streamed response to compression, whatever its size so far.
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.start(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// start settles whether the body is compressed and writes out what was
+// buffered. Bodies already encoded by the handler are left alone.
+func (w *gzipWriter) start(compress bool) error {
+	w.decided = true
+	if compress && w.Header().Get("Content-Encoding") == "" {
+		w.Header().Del("Content-Length") // Set for the uncompressed body, e.g. by static files
+		w.Header().Set("Content-Encoding", "gzip")
+		gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.level)
+		if err != nil {
+			return err
+		}
+		w.gz = gz
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// finish sends a body that stayed below minBytes uncompressed, or ends the
+// gzip stream.
+func (w *gzipWriter) finish() {
+	if !w.decided {
+		w.start(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveGzip requests body from a router compressing at level above minBytes.
+func serveGzip(level, minBytes int, body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(Gzip(level, minBytes))
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, body) })
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGzipAppliesConfiguredLevel(t *testing.T) {
+	body := strings.Repeat("Patient on aspirin 81 mg daily; HR 80, BP 120/80. ", 200)
+	for _, level := range []int{gzip.BestSpeed, gzip.BestCompression} {
+		w := serveGzip(level, 1024, body)
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("level %d: response not compressed", level)
+		}
+
+		var want bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&want, level)
+		gz.Write([]byte(body))
+		gz.Close()
+		if !bytes.Equal(w.Body.Bytes(), want.Bytes()) {
+			t.Errorf("level %d: body differs from gzip at that level", level)
+		}
+
+		r, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded, _ := io.ReadAll(r); string(decoded) != body {
+			t.Errorf("level %d: body does not decompress to the original", level)
+		}
+	}
+}
+
+func TestGzipLeavesSmallResponsesUncompressed(t *testing.T) {
+	w := serveGzip(gzip.BestSpeed, 1024, `{"status": "ready"}`)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != `{"status": "ready"}` {
+		t.Errorf("small response compressed: %q, %q", w.Header().Get("Content-Encoding"), w.Body)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"":                        false,
+		"gzip":                    true,
+		"deflate, gzip":           true,
+		"GZIP;q=0.5":              true,
+		"gzip;q=0":                false,
+		"gzip; q=0.000":           false,
+		"br;q=1.0, gzip;q=0":      false,
+		"*":                       true,
+		"*;q=0":                   false,
+		"gzip;q=0, *":             false,
+		"*, gzip;q=0":             false,
+		"identity":                false,
+		"gzip;q=abc":              false,
+		"x-gzip":                  true,
+		"identity, *;q=0.1, br":   true,
+		"notgzip, gzipped;q=1.0":  false,
+		"deflate;q=0.5, gzip;q=1": true,
+	}
+	for header, want := range tests {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestGzipSetsVaryOnEveryResponse(t *testing.T) {
+	body := strings.Repeat("Patient on aspirin 81 mg daily. ", 100)
+	for name, tt := range map[string]struct {
+		acceptEncoding string
+		body           string
+		compressed     bool
+	}{
+		"compressed":     {"gzip", body, true},
+		"below minBytes": {"gzip", "ok", false},
+		"gzip refused":   {"gzip;q=0", body, false},
+		"no gzip":        {"", body, false},
+	} {
+		router := gin.New()
+		router.Use(Gzip(gzip.BestSpeed, 1024))
+		router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, tt.body) })
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		router.ServeHTTP(w, req)
+
+		if compressed := w.Header().Get("Content-Encoding") == "gzip"; compressed != tt.compressed {
+			t.Errorf("%s: compressed = %v, want %v", name, compressed, tt.compressed)
+		}
+		if vary := w.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %q, want Accept-Encoding once", name, vary)
+		}
+	}
+}
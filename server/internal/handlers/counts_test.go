@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestExtractFormatCounts(t *testing.T) {
+	llm := newLLMStub(t, `{
+		"HeartRate": [{"value": "80", "context": "HR 80"}, {"value": "92", "context": "HR 92"}],
+		"Medication": [{"value": "aspirin", "context": "on aspirin"}]
+	}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+	body := `{"text": "HR 80 on aspirin, later HR 92.", "schema_names": ["vitals", "meds"]}`
+
+	w := serve(http.MethodPost, "/api/extract", "/api/extract?format=counts", body, h.ExtractEntities)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var counts map[string]int
+	if err := json.Unmarshal(w.Body.Bytes(), &counts); err != nil {
+		t.Fatalf("response %s: %v", w.Body, err)
+	}
+	if len(counts) != 2 || counts["HeartRate"] != 2 || counts["Medication"] != 1 {
+		t.Errorf("counts = %v, want HeartRate 2 and Medication 1", counts)
+	}
+
+	w = serve(http.MethodPost, "/api/extract", "/api/extract?format=counts&group_by=schema", body, h.ExtractEntities)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("counts with group_by: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestExtractRejectsSchemaWithoutEntities(t *testing.T) {
+	llm := newLLMStub(t, `{}`)
+	schemas := map[string]string{"empty.yaml": "_name: Empty\n_description: Only meta keys\n"}
+	s := newTestExtractor(t, llm.URL, schemas, nil)
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	w := serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "HR 80", "schema_names": ["empty"]}`, h.ExtractEntities)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "No entities selected") {
+		t.Errorf("extract: status = %d (%s), want 400 for no entities", w.Code, w.Body)
+	}
+	if prompts := llm.Prompts(); len(prompts) != 0 {
+		t.Errorf("LLM prompted %d times with an empty schema", len(prompts))
+	}
+
+	w = serve(http.MethodPost, "/api/prompt/estimate", "/api/prompt/estimate", `{"text": "HR 80", "schema_names": ["empty"]}`, h.EstimatePrompt)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("estimate: status = %d (%s), want 400", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"go.uber.org/zap"
+)
+
+// Event backends selectable with events.backend
+const (
+	EventBackendNone = ""     // Events are not published
+	EventBackendFile = "file" // Appended as JSON lines to events.path
+)
+
+// Type of the event published when an extraction succeeds
+const extractionCompletedEvent = "extraction.completed"
+
+// ExtractionEvent announces a finished extraction to downstream consumers.
+// It describes the result without any document text or extracted values,
+// so it carries no patient data.
+type ExtractionEvent struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	Time          time.Time `json:"time"`
+	Source        string    `json:"source"`          // "extract", "batch" or "job"
+	JobID         string    `json:"jobId,omitempty"` // Pollable at GET /api/jobs/:id while kept
+	Index         *int      `json:"index,omitempty"` // Document index within a batch
+	SchemaNames   []string  `json:"schemaNames"`
+	SchemaHash    string    `json:"schemaHash"`
+	TextHash      string    `json:"textHash,omitempty"` // Identifies the text without carrying it
+	PromptVersion string    `json:"promptVersion"`
+	Model         string    `json:"model,omitempty"`
+	// Occurrences found per entity name
+	EntityCounts map[string]int       `json:"entityCounts"`
+	TokenUsage   extractor.TokenUsage `json:"tokenUsage"`
+}
+
+// newExtractionEvent describes a successful extraction.
+func newExtractionEvent(source string, schemaNames []string, result *extractor.ExtractionOutput) ExtractionEvent {
+	return ExtractionEvent{
+		ID:            newJobID(),
+		Type:          extractionCompletedEvent,
+		Time:          time.Now().UTC(),
+		Source:        source,
+		SchemaNames:   schemaNames,
+		SchemaHash:    result.Metadata.SchemaHash,
+		TextHash:      result.Metadata.TextHash,
+		PromptVersion: result.Metadata.PromptVersion,
+		Model:         result.Metadata.Model,
+		EntityCounts:  extractor.CountEntities(result),
+		TokenUsage:    result.Metadata.TokenUsage,
+	}
+}
+
+// EventSink delivers events to a backend. Implementations for message
+// brokers such as NATS or Kafka only need to satisfy this interface.
+type EventSink interface {
+	Publish(event ExtractionEvent) error
+	Close() error
+}
+
+// NewEventSink opens the sink for the configured backend; nil when events are
+// disabled.
+func NewEventSink(backend, path string) (EventSink, error) {
+	switch backend {
+	case EventBackendNone:
+		return nil, nil
+	case EventBackendFile:
+		sink, err := NewFileEventSink(path)
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("unsupported event backend %q, expected %q", backend, EventBackendFile)
+	}
+}
+
+// FileEventSink appends events to a local file, one JSON object per line.
+type FileEventSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileEventSink opens path for appending, creating it and its directory
+// if needed.
+func NewFileEventSink(path string) (*FileEventSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create event log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	return &FileEventSink{file: file}, nil
+}
+
+func (fs *FileEventSink) Publish(event ExtractionEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	_, err = fs.file.Write(append(line, '\n')) // A single write keeps lines whole
+	return err
+}
+
+func (fs *FileEventSink) Close() error {
+	return fs.file.Close()
+}
+
+// EventPublisher hands events to a sink from a background goroutine, so
+// extractions never wait on the backend. Events that fail or do not fit in
+// the queue are logged and dropped. A nil publisher publishes nothing.
+type EventPublisher struct {
+	sink   EventSink
+	queue  chan ExtractionEvent
+	done   chan struct{}
+	logger *zap.Logger
+}
+
+// NewEventPublisher starts delivering events to sink, holding at most
+// queueSize events waiting for it. It returns nil for a nil sink.
+func NewEventPublisher(sink EventSink, queueSize int, logger *zap.Logger) *EventPublisher {
+	if sink == nil {
+		return nil
+	}
+	ep := &EventPublisher{
+		sink:   sink,
+		queue:  make(chan ExtractionEvent, max(1, queueSize)),
+		done:   make(chan struct{}),
+		logger: logger.Named("EventPublisher"),
+	}
+	go ep.run()
+	return ep
+}
+
+func (ep *EventPublisher) run() {
+	defer close(ep.done)
+	for event := range ep.queue {
+		if err := ep.sink.Publish(event); err != nil {
+			ep.logger.Error("Failed to publish event", zap.String("id", event.ID), zap.String("type", event.Type), zap.Error(err))
+		}
+	}
+}
+
+// Publish queues the event without blocking.
+func (ep *EventPublisher) Publish(event ExtractionEvent) {
+	if ep == nil {
+		return
+	}
+	select {
+	case ep.queue <- event:
+	default:
+		ep.logger.Warn("Event queue is full, dropping event", zap.String("id", event.ID), zap.String("type", event.Type))
+	}
+}
+
+// Close delivers the queued events and closes the sink. Nothing may be
+// published afterwards.
+func (ep *EventPublisher) Close() error {
+	if ep == nil {
+		return nil
+	}
+	close(ep.queue)
+	<-ep.done
+	return ep.sink.Close()
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// stubSink records published events, failing the first failures of them.
+type stubSink struct {
+	mu       sync.Mutex
+	events   []ExtractionEvent
+	failures int
+	closed   bool
+}
+
+func (s *stubSink) Publish(event ExtractionEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("backend unavailable")
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *stubSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *stubSink) Events() []ExtractionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ExtractionEvent(nil), s.events...)
+}
+
+func TestExtractionPublishesCompletedEvents(t *testing.T) {
+	llm := newLLMStub(t, `{"HeartRate": [{"value": "80", "context": "HR 80"}]}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	sink := &stubSink{}
+	events := NewEventPublisher(sink, 10, zap.NewNop())
+	jobs := NewJobStore(1, 10, time.Hour, zap.NewNop())
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, jobs, events)
+
+	if w := serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "HR 80", "schema_names": ["vitals"]}`, h.ExtractEntities); w.Code != http.StatusOK {
+		t.Fatalf("extract: status = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(http.MethodPost, "/api/extract/batch", "/api/extract/batch", `{"documents": ["HR 80", "HR 80 again"], "schema_names": ["vitals"]}`, h.BatchExtract); w.Code != http.StatusOK {
+		t.Fatalf("batch: status = %d: %s", w.Code, w.Body)
+	}
+	w := serve(http.MethodPost, "/api/extract/async", "/api/extract/async", `{"text": "HR 80", "schema_names": ["vitals"]}`, h.ExtractAsync)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("async: status = %d: %s", w.Code, w.Body)
+	}
+	var accepted struct {
+		JobID string `json:"jobId"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &accepted)
+	// The job's event is published after it finishes, so wait for all of them
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.Events()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := events.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	published := sink.Events()
+	if len(published) != 4 || !sink.closed {
+		t.Fatalf("published %d events (closed %v), want 4 then closed", len(published), sink.closed)
+	}
+	sources := map[string]int{}
+	for _, event := range published {
+		sources[event.Source]++
+		if event.Type != extractionCompletedEvent || event.EntityCounts["HeartRate"] != 1 || event.SchemaHash == "" {
+			t.Errorf("event = %+v, want a completed extraction with its counts", event)
+		}
+		if event.Source == "job" && event.JobID != accepted.JobID {
+			t.Errorf("job event ID = %q, want %q", event.JobID, accepted.JobID)
+		}
+		if event.Source == "batch" && event.Index == nil {
+			t.Error("batch event lacks its document index")
+		}
+	}
+	if sources["extract"] != 1 || sources["batch"] != 2 || sources["job"] != 1 {
+		t.Errorf("event sources = %v, want 1 extract, 2 batch, 1 job", sources)
+	}
+}
+
+func TestEventPublishFailureDoesNotFailExtraction(t *testing.T) {
+	llm := newLLMStub(t, `{"HeartRate": [{"value": "80", "context": "HR 80"}]}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	sink := &stubSink{failures: 1}
+	events := NewEventPublisher(sink, 10, zap.NewNop())
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, events)
+
+	for range 2 {
+		if w := serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "HR 80", "schema_names": ["vitals"]}`, h.ExtractEntities); w.Code != http.StatusOK {
+			t.Fatalf("status = %d with a failing sink: %s", w.Code, w.Body)
+		}
+	}
+	events.Close()
+	if published := sink.Events(); len(published) != 1 {
+		t.Errorf("published %d events, want only the one after the failure", len(published))
+	}
+}
+
+func TestFileEventSinkAppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "extractions.jsonl")
+	for _, id := range []string{"first", "second"} { // Reopened, as after a restart
+		sink, err := NewEventSink(EventBackendFile, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Publish(ExtractionEvent{ID: id, Type: extractionCompletedEvent}); err != nil {
+			t.Fatal(err)
+		}
+		sink.Close()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event ExtractionEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, event.ID)
+	}
+	if strings.Join(ids, ",") != "first,second" {
+		t.Errorf("event IDs = %v, want both appended in order", ids)
+	}
+
+	if sink, err := NewEventSink(EventBackendNone, path); sink != nil || err != nil {
+		t.Errorf("disabled backend: sink = %v, err = %v, want none", sink, err)
+	}
+	if _, err := NewEventSink("kafka", path); err == nil {
+		t.Error("unsupported backend accepted")
+	}
+}
@@ -1,64 +1,181 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"slices"
+	"time"
 
 	"github.com/andevellicus/med-ex/internal/extractor"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-// Maximum size for the uploaded file (5MB)
-const maxFileSize = 5 * 1024 * 1024
-
 // ExtractRequest defines the expected JSON body for the /api/extract endpoint.
 type ExtractRequest struct {
-	Text        string   `json:"text" binding:"required"`
-	SchemaNames []string `json:"schema_names" binding:"required,min=1"`
+	Text string `json:"text"`
+	// Alternative to text: the document's bytes, base64-encoded, for documents
+	// that may not be UTF-8; their encoding is detected and reported in metadata
+	TextBase64  string   `json:"text_base64"`
+	SchemaNames []string `json:"schema_names"` // Falls back to llm.default_schemas when omitted and there is no inline schema
+	// Optional schema used without saving it, as a JSON object or a YAML/JSON
+	// string; merged after the named schemas
+	Schema json.RawMessage `json:"schema"`
+	// Optional date (YYYY-MM-DD) the document was written, used to resolve relative dates
+	ReferenceDate string `json:"reference_date"`
+	// Highlight the whole context of occurrences the LLM returned without a value
+	HighlightContextWhenEmpty bool `json:"highlight_context_when_empty"`
+	// Optional section markers and the sections each schema may be extracted from
+	Sections *extractor.SectionOptions `json:"sections"`
+	// Report positions against the submitted text instead of the returned
+	// text, whose CRLF/CR line endings are normalized to LF
+	OriginalPositions bool `json:"original_positions"`
+	// Include the schema JSON sent to the LLM under "debug" in the JSON response
+	Debug bool `json:"debug"`
+	// Report which schema entities were placed, returned but not placed, or
+	// not returned by the LLM, under "diagnostics" in the JSON response
+	Diagnostics bool `json:"diagnostics"`
+	// Drop occurrences whose confidence is below this value (0 to 1)
+	MinConfidence float64 `json:"min_confidence"`
+	// Return a job ID at once and POST the result to this URL when done; its
+	// host must be in callbacks.allowed_hosts
+	CallbackURL string `json:"callback_url"`
+	// LLM server to use instead of the configured one; must be listed in
+	// llm.allowed_servers
+	LLMServer string `json:"llm_server"`
+	// Result for an earlier version of this text, e.g. as saved; only the
+	// sentences edited since are re-extracted
+	Previous *extractor.PriorExtraction `json:"previous"`
+	// Return the document's header lines (header.keys) under
+	// "document_metadata" and extract entities from the rest only; not
+	// combinable with sections or previous
+	ParseHeader bool `json:"parse_header"`
+	// Separator between several documents pasted into text; each is extracted
+	// on its own and returned under "documents"
+	Split *extractor.DocumentSplit `json:"split"`
+	// Label each occurrence with the JSON kind of its value under "value_type"
+	ValueTypes bool `json:"value_types"`
+	// Language of the prompt instructions (e.g. "fr"), overriding llm.prompt_lang
+	PromptLang string `json:"prompt_lang"`
+	// Report template to fill instead of returning the extraction: the name
+	// of one in output_templates.dir, or a JSON object of field names to
+	// strings with "{{Entity}}" or "{{Entity|default}}" placeholders
+	OutputTemplate json.RawMessage `json:"output_template"`
 }
 
 // ExtractHandler handles entity extraction requests
 type ExtractHandler struct {
-	Extractor *extractor.ExtractorService
-	Logger    *zap.Logger
+	Extractor          *extractor.ExtractorService
+	Logger             *zap.Logger
+	BatchWorkers       int  // Documents of a batch extracted concurrently
+	BatchPreserveOrder bool // Default ordering of streamed batch results
+	Callbacks          *CallbackClient
+	Jobs               *JobStore       // Runs async and callback extractions
+	Events             *EventPublisher // Announces finished extractions, nil when disabled
 }
 
 // NewExtractHandler creates a new extract handler
-func NewExtractHandler(extractor *extractor.ExtractorService, logger *zap.Logger) *ExtractHandler {
+func NewExtractHandler(extractor *extractor.ExtractorService, logger *zap.Logger, batchWorkers int, batchPreserveOrder bool, callbacks *CallbackClient, jobs *JobStore, events *EventPublisher) *ExtractHandler {
 	return &ExtractHandler{
-		Extractor: extractor,
-		Logger:    logger.Named("ExtractHandler"),
+		Extractor:          extractor,
+		Logger:             logger.Named("ExtractHandler"),
+		BatchWorkers:       max(1, batchWorkers),
+		BatchPreserveOrder: batchPreserveOrder,
+		Callbacks:          callbacks,
+		Jobs:               jobs,
+		Events:             events,
 	}
 }
 
 // ExtractEntities handles POST /api/extract
 func (h *ExtractHandler) ExtractEntities(c *gin.Context) {
-	var req ExtractRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.Logger.Error("Failed to bind JSON request for extraction", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+	req, opts, ok := h.bindExtractRequest(c)
+	if !ok {
+		return
+	}
+	if req.LLMServer == "" && h.rejectWhileLLMDown(c) {
 		return
 	}
 
-	// Check if schema exists
-	availableSchemas := h.Extractor.GetAvailableSchemas()
-	invalidSchemas := []string{}
-	for _, reqSchema := range req.SchemaNames {
-		if !slices.Contains(availableSchemas, reqSchema) {
-			invalidSchemas = append(invalidSchemas, reqSchema)
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "schema" && groupBy != "section" {
+		h.Logger.Warn("Unsupported group_by requested", zap.String("group_by", groupBy))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Unsupported group_by, expected 'schema' or 'section'"})
+		return
+	}
+	var sectionMarkers []extractor.SectionMarker
+	if groupBy == "section" {
+		if req.OriginalPositions {
+			// Sections are found in the returned text, so positions must match it
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "original_positions cannot be combined with group_by=section"})
+			return
+		}
+		sectionMarkers = h.Extractor.SectionMarkers()
+		if req.Sections != nil && len(req.Sections.Markers) > 0 {
+			sectionMarkers = req.Sections.Markers
+		}
+		if len(sectionMarkers) == 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "group_by=section needs sections.markers, none are configured"})
+			return
 		}
 	}
-	if len(invalidSchemas) > 0 {
-		h.Logger.Error("Invalid schema names requested", zap.Strings("invalid", invalidSchemas), zap.Strings("requested", req.SchemaNames))
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid schema name(s) provided: %v", invalidSchemas)})
+
+	format := c.Query("format")
+	if format != "" && format != "json" && format != "conll" && format != "nested" && format != "flat" && format != "counts" && format != "by-id" {
+		h.Logger.Warn("Unsupported format requested", zap.String("format", format))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Unsupported format, expected 'json', 'conll', 'nested', 'flat', 'counts' or 'by-id'"})
+		return
+	}
+	if (format == "nested" || format == "flat" || format == "counts" || format == "by-id") && groupBy != "" {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("format=%s cannot be combined with group_by", format)})
+		return
+	}
+	if req.Split != nil && ((format != "" && format != "json") || groupBy != "") {
+		// Both work on the top-level entities, which stay empty when split
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "split cannot be combined with format or group_by"})
+		return
+	}
+	if format == "conll" && req.OriginalPositions {
+		// CoNLL tokens are cut from the returned text, so positions must match it
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "original_positions cannot be combined with format=conll"})
+		return
+	}
+
+	outputTemplate, err := h.resolveOutputTemplate(req.OutputTemplate)
+	if err != nil {
+		h.Logger.Warn("Invalid output template", zap.Error(err))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if outputTemplate != nil && ((format != "" && format != "json") || groupBy != "" || req.Split != nil || req.CallbackURL != "") {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "output_template cannot be combined with format, group_by, split or callback_url"})
+		return
+	}
+
+	if req.CallbackURL != "" {
+		if format != "" || groupBy != "" {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "callback_url cannot be combined with format or group_by"})
+			return
+		}
+		h.submitJob(c, req, opts) // Also pollable at GET /api/jobs/:id
 		return
 	}
 
 	// Perform extraction using multiple schema names
-	result, err := h.Extractor.ProcessText(req.SchemaNames, req.Text) // Pass array
+	result, err := h.Extractor.ProcessText(c.Request.Context(), req.SchemaNames, req.Text, opts) // Pass array
+	if errors.Is(err, extractor.ErrNoEntitiesSelected) {
+		h.Logger.Warn("Extraction request selects no entities", zap.Strings("schemas", req.SchemaNames))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "No entities selected: the requested schemas define no entities to extract"})
+		return
+	}
+	if errors.Is(err, extractor.ErrConflictingModels) || errors.Is(err, extractor.ErrInvalidUTF8) {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil || result == nil {
 		h.Logger.Error("Multi-schema extraction failed", zap.Error(err), zap.Strings("schemas", req.SchemaNames))
 		// Provide a slightly more informative error if possible
@@ -66,7 +183,7 @@ func (h *ExtractHandler) ExtractEntities(c *gin.Context) {
 		if err != nil {
 			errMsg = fmt.Sprintf("Extraction failed: %v", err)
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": errMsg})
 		return
 	}
 
@@ -75,8 +192,277 @@ func (h *ExtractHandler) ExtractEntities(c *gin.Context) {
 		zap.Strings("schemas", req.SchemaNames),
 		zap.Int("text_length", len(req.Text)),
 		zap.Int("entities_found", len(result.Entities)),
+		zap.String("text_hash", result.Metadata.TextHash),
 	)
+	h.Events.Publish(newExtractionEvent("extract", req.SchemaNames, result))
 
 	// Return result
-	c.JSON(http.StatusOK, result)
+	if outputTemplate != nil {
+		respondJSON(c, http.StatusOK, outputTemplate.Fill(result))
+		return
+	}
+	if format == "conll" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(extractor.FormatCoNLL(result)))
+		return
+	}
+	if format == "nested" {
+		respondJSON(c, http.StatusOK, extractor.NestOutput(result))
+		return
+	}
+	if format == "flat" {
+		respondJSON(c, http.StatusOK, extractor.FlattenOutput(result))
+		return
+	}
+	if format == "by-id" {
+		byID, err := extractor.OccurrencesByID(result)
+		if err != nil {
+			h.Logger.Error("Failed to key occurrences by ID", zap.Error(err))
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Cannot key occurrences by ID: %v", err)})
+			return
+		}
+		respondJSON(c, http.StatusOK, byID)
+		return
+	}
+	if format == "counts" {
+		// Positions and contexts are left out, keeping the response small for large documents
+		respondJSON(c, http.StatusOK, extractor.CountEntities(result))
+		return
+	}
+	if groupBy == "schema" {
+		respondJSON(c, http.StatusOK, extractor.GroupEntitiesBySchema(result))
+		return
+	}
+	if groupBy == "section" {
+		respondJSON(c, http.StatusOK, extractor.GroupEntitiesBySection(result, sectionMarkers))
+		return
+	}
+	respondJSON(c, http.StatusOK, result)
+}
+
+// resolveOutputTemplate reads a request's output_template: a JSON string
+// names a configured template, an object is used as given. It returns nil
+// when there is none.
+func (h *ExtractHandler) resolveOutputTemplate(raw json.RawMessage) (*extractor.OutputTemplate, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		return h.Extractor.OutputTemplate(name)
+	}
+	template := &extractor.OutputTemplate{}
+	if err := json.Unmarshal(raw, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// bindExtractRequest decodes and validates an extraction request body into
+// the request and its extraction options. On failure it writes the error
+// response and returns false.
+func (h *ExtractHandler) bindExtractRequest(c *gin.Context) (*ExtractRequest, extractor.ProcessOptions, bool) {
+	var req ExtractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Logger.Error("Failed to bind JSON request for extraction", zap.Error(err))
+		respondJSON(c, bindErrorStatus(err), gin.H{"error": "Invalid request body: " + err.Error()})
+		return nil, extractor.ProcessOptions{}, false
+	}
+
+	if req.TextBase64 != "" {
+		if req.Text != "" {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "text and text_base64 cannot both be set"})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		data, err := base64.StdEncoding.DecodeString(req.TextBase64)
+		if err != nil {
+			h.Logger.Warn("Invalid text_base64 in extraction request", zap.Error(err))
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid text_base64: " + err.Error()})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		req.Text = string(data) // Decoded to UTF-8 by ProcessText
+	}
+	if req.Text == "" {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "text is required"})
+		return nil, extractor.ProcessOptions{}, false
+	}
+
+	inlineSchema, err := h.parseInlineSchema(req.Schema)
+	if err != nil {
+		h.Logger.Warn("Invalid inline schema in extraction request", zap.Error(err))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid schema: " + err.Error()})
+		return nil, extractor.ProcessOptions{}, false
+	}
+
+	if inlineSchema == nil && !h.applyDefaultSchemas(&req.SchemaNames) {
+		h.Logger.Warn("Extraction request without schema names and no defaults configured")
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "schema_names is required"})
+		return nil, extractor.ProcessOptions{}, false
+	}
+
+	if rejectTooManySchemas(c, h.Logger, h.Extractor.MaxRequestSchemas(), req.SchemaNames) {
+		return nil, extractor.ProcessOptions{}, false
+	}
+	// Check if schema exists
+	if h.rejectHiddenSchemas(c, req.SchemaNames) {
+		return nil, extractor.ProcessOptions{}, false
+	}
+	invalidSchemas := h.unknownSchemaNames(req.SchemaNames)
+	if len(invalidSchemas) > 0 {
+		h.Logger.Error("Invalid schema names requested", zap.Strings("invalid", invalidSchemas), zap.Strings("requested", req.SchemaNames))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid schema name(s) provided: %v", invalidSchemas)})
+		return nil, extractor.ProcessOptions{}, false
+	}
+
+	if req.LLMServer != "" {
+		if err := h.Extractor.ValidateLLMServer(req.LLMServer); err != nil {
+			h.Logger.Warn("Rejected LLM server override", zap.String("llm_server", req.LLMServer))
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, extractor.ProcessOptions{}, false
+		}
+	}
+	if err := h.Extractor.ValidatePromptLang(req.PromptLang); err != nil {
+		h.Logger.Warn("Rejected prompt language", zap.String("prompt_lang", req.PromptLang))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, extractor.ProcessOptions{}, false
+	}
+	if req.MinConfidence < 0 || req.MinConfidence > 1 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "min_confidence must be between 0 and 1"})
+		return nil, extractor.ProcessOptions{}, false
+	}
+	if inlineSchema != nil && req.Sections != nil && len(req.Sections.SchemaSections) > 0 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "sections cannot be combined with an inline schema"})
+		return nil, extractor.ProcessOptions{}, false
+	}
+	if req.Previous != nil {
+		if req.Previous.Text == "" {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "previous.text is required"})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		if req.Sections != nil && len(req.Sections.SchemaSections) > 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "sections cannot be combined with a previous result"})
+			return nil, extractor.ProcessOptions{}, false
+		}
+	}
+	if req.ParseHeader {
+		// Both send their own spans of the text to the LLM, header lines included
+		if req.Sections != nil && len(req.Sections.SchemaSections) > 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "parse_header cannot be combined with sections"})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		if req.Previous != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "parse_header cannot be combined with a previous result"})
+			return nil, extractor.ProcessOptions{}, false
+		}
+	}
+
+	opts := extractor.ProcessOptions{
+		HighlightContextWhenEmpty: req.HighlightContextWhenEmpty,
+		OriginalPositions:         req.OriginalPositions,
+		Debug:                     req.Debug,
+		Diagnostics:               req.Diagnostics,
+		InlineSchema:              inlineSchema,
+		MinConfidence:             req.MinConfidence,
+		LLMServer:                 req.LLMServer,
+		Previous:                  req.Previous,
+		ParseHeader:               req.ParseHeader,
+		ValueTypes:                req.ValueTypes,
+		PromptLang:                req.PromptLang,
+	}
+	if req.Split != nil {
+		if err := req.Split.Validate(); err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		if req.OriginalPositions {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "split cannot be combined with original_positions"})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		if req.Previous != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "split cannot be combined with a previous result"})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		opts.Split = req.Split
+	}
+	if req.Sections != nil {
+		if err := req.Sections.Validate(); err != nil {
+			h.Logger.Warn("Invalid sections in extraction request", zap.Error(err))
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid sections: " + err.Error()})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		opts.Sections = req.Sections
+	}
+	if req.ReferenceDate != "" {
+		referenceDate, err := time.Parse("2006-01-02", req.ReferenceDate)
+		if err != nil {
+			h.Logger.Warn("Invalid reference date in extraction request", zap.String("reference_date", req.ReferenceDate), zap.Error(err))
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid reference_date, expected YYYY-MM-DD"})
+			return nil, extractor.ProcessOptions{}, false
+		}
+		opts.ReferenceDate = &referenceDate
+	}
+
+	return &req, opts, true
+}
+
+// parseInlineSchema decodes the schema field of a request, which may hold the
+// schema itself or its YAML/JSON text. It returns nil when there is none.
+func (h *ExtractHandler) parseInlineSchema(raw json.RawMessage) (extractor.Schema, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	data := []byte(raw) // A JSON object is also valid YAML
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		data = []byte(text)
+	}
+	return h.Extractor.ParseInlineSchema(data)
+}
+
+// applyDefaultSchemas fills in the configured default schemas when no schema
+// names were requested. It reports false when there are still none.
+func (h *ExtractHandler) applyDefaultSchemas(schemaNames *[]string) bool {
+	if len(*schemaNames) == 0 {
+		*schemaNames = h.Extractor.DefaultSchemas()
+	}
+	return len(*schemaNames) > 0
+}
+
+// rejectTooManySchemas responds 400 when a request names more schemas than
+// llm.max_request_schemas allows.
+func rejectTooManySchemas(c *gin.Context, logger *zap.Logger, maxSchemas int, schemaNames []string) bool {
+	if maxSchemas <= 0 || len(schemaNames) <= maxSchemas {
+		return false
+	}
+	logger.Warn("Too many schema names requested", zap.Int("requested", len(schemaNames)), zap.Int("max", maxSchemas))
+	respondJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many schemas: %d requested, at most %d can be combined per request", len(schemaNames), maxSchemas)})
+	return true
+}
+
+// rejectHiddenSchemas responds 404 when any requested schema is hidden by
+// the allowed/denied schema settings, and reports whether it did.
+func (h *ExtractHandler) rejectHiddenSchemas(c *gin.Context, schemaNames []string) bool {
+	hidden := []string{}
+	for _, name := range schemaNames {
+		if h.Extractor.IsSchemaHidden(name) {
+			hidden = append(hidden, name)
+		}
+	}
+	if len(hidden) == 0 {
+		return false
+	}
+	h.Logger.Warn("Hidden schema names requested", zap.Strings("hidden", hidden))
+	respondJSON(c, http.StatusNotFound, gin.H{"error": fmt.Sprintf("Schema(s) not found: %v", hidden)})
+	return true
+}
+
+// unknownSchemaNames returns the requested schema names that are not loaded.
+func (h *ExtractHandler) unknownSchemaNames(schemaNames []string) []string {
+	availableSchemas := h.Extractor.GetAvailableSchemas()
+	invalidSchemas := []string{}
+	for _, reqSchema := range schemaNames {
+		if !slices.Contains(availableSchemas, reqSchema) {
+			invalidSchemas = append(invalidSchemas, reqSchema)
+		}
+	}
+	return invalidSchemas
 }
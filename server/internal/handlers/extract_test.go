@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+var defaultSchemaFiles = map[string]string{
+	"vitals.yaml": "HeartRate:\n  type: number\n",
+	"meds.yaml":   "Medication:\n  type: string\n",
+}
+
+func TestExtractEntitiesUsesDefaultSchemas(t *testing.T) {
+	tests := map[string]struct {
+		body       string
+		wantEntity string
+	}{
+		"omitted names use the defaults": {`{"text": "HR 80 on aspirin"}`, "HeartRate"},
+		"explicit names win":             {`{"text": "HR 80 on aspirin", "schema_names": ["meds"]}`, "Medication"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			llm := newLLMStub(t, `{}`)
+			s := newTestExtractor(t, llm.URL, defaultSchemaFiles, func(cfg *config.Config) {
+				cfg.LLM.DefaultSchemas = []string{"vitals"}
+			})
+			h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+			w := serve(http.MethodPost, "/api/extract", "/api/extract", tt.body, h.ExtractEntities)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			prompts := llm.Prompts()
+			if len(prompts) != 1 || !strings.Contains(prompts[0], `"`+tt.wantEntity+`"`) {
+				t.Errorf("prompt does not use the %s schema", tt.wantEntity)
+			}
+		})
+	}
+}
+
+func TestExtractEntitiesRequiresSchemaNamesWithoutDefaults(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, nil)
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	w := serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "HR 80"}`, h.ExtractEntities)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestExtractEntitiesRejectsSplitWithPrevious(t *testing.T) {
+	llm := newLLMStub(t, `{}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	body := `{"text": "Note 1\n---\nNote 2", "schema_names": ["meds"], "split": {"separator": "---"}, "previous": {"text": "Note 1"}}`
+	w := serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400: %s", w.Code, w.Body)
+	}
+	if len(llm.Prompts()) != 0 {
+		t.Error("extraction ran for a rejected request")
+	}
+}
+
+func TestExtractEntitiesRejectsParseHeaderWithSectionsOrPrevious(t *testing.T) {
+	llm := newLLMStub(t, `{}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	for name, extra := range map[string]string{
+		"sections": `"sections": {"schema_sections": {"meds": ["Medications"]}}`,
+		"previous": `"previous": {"text": "MRN: 1\nPatient on aspirin."}`,
+	} {
+		body := `{"text": "MRN: 1\nPatient on aspirin daily.", "schema_names": ["meds"], "parse_header": true, ` + extra + `}`
+		w := serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "parse_header") {
+			t.Errorf("%s: status = %d, want 400 naming parse_header: %s", name, w.Code, w.Body)
+		}
+	}
+	if len(llm.Prompts()) != 0 {
+		t.Error("extraction ran for a rejected request")
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestExtractor loads the schema files (name to YAML) into a service that
+// sends its prompts to llmURL. configure, if set, adjusts the config first.
+func newTestExtractor(t testing.TB, llmURL string, schemas map[string]string, configure func(*config.Config)) *extractor.ExtractorService {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range schemas {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.LLM.ServerURL = llmURL
+	cfg.LLM.SchemaDirs = []string{dir}
+	if configure != nil {
+		configure(cfg)
+	}
+	s, err := extractor.NewExtractorService(cfg, zap.NewNop(), dir)
+	if err != nil {
+		t.Fatalf("NewExtractorService: %v", err)
+	}
+	return s
+}
+
+// llmStub answers every completion request with content and records the
+// prompts it was sent.
+type llmStub struct {
+	*httptest.Server
+	mu      sync.Mutex
+	prompts []string
+}
+
+func newLLMStub(t testing.TB, content string) *llmStub {
+	t.Helper()
+	stub := &llmStub{}
+	body, _ := json.Marshal(map[string]any{"content": content})
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		stub.mu.Lock()
+		stub.prompts = append(stub.prompts, payload.Prompt)
+		stub.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// Prompts returns the prompts received so far.
+func (s *llmStub) Prompts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.prompts...)
+}
+
+// serve sends a request with a JSON body (if any) through handler mounted at
+// route and returns the recorded response.
+func serve(method, route, target, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, route, handler)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
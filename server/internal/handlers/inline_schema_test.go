@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"go.uber.org/zap"
+)
+
+func TestExtractEntitiesWithInlineSchemaOnly(t *testing.T) {
+	tests := map[string]string{
+		"JSON object": `{"Allergy": {"type": "string"}}`,
+		"YAML string": `"Allergy:\n  type: string\n"`,
+	}
+	for name, schema := range tests {
+		t.Run(name, func(t *testing.T) {
+			llm := newLLMStub(t, `{"Allergy": [{"value": "penicillin", "context": "allergic to penicillin"}]}`)
+			s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+			h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+			body := `{"text": "Patient is allergic to penicillin.", "schema": ` + schema + `}`
+			w := serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var output extractor.ExtractionOutput
+			if err := json.Unmarshal(w.Body.Bytes(), &output); err != nil {
+				t.Fatal(err)
+			}
+			allergies := output.Entities["Allergy"]
+			if len(allergies) != 1 || allergies[0].Schema != extractor.InlineSchemaName {
+				t.Errorf("allergies = %+v, want one from the inline schema", allergies)
+			}
+			prompt := llm.Prompts()[0]
+			if !strings.Contains(prompt, `"Allergy"`) || strings.Contains(prompt, `"Medication"`) || strings.Contains(prompt, `"HeartRate"`) {
+				t.Error("prompt does not hold only the inline schema")
+			}
+		})
+	}
+}
+
+func TestExtractEntitiesRejectsInvalidInlineSchema(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, nil)
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	for _, schema := range []string{`{}`, `["Allergy"]`, `{"Vitals": {"properties": "none"}}`} {
+		w := serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "x", "schema": `+schema+`}`, h.ExtractEntities)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("schema %s: status = %d, want 400", schema, w.Code)
+		}
+	}
+}
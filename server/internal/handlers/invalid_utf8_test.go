@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestExtractRejectsInvalidUTF8(t *testing.T) {
+	llm := newLLMStub(t, `{}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, func(cfg *config.Config) {
+		cfg.Input.InvalidUTF8 = "reject"
+	})
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	body := `{"text_base64": "` + base64.StdEncoding.EncodeToString([]byte("HR \xff80")) + `", "schema_names": ["vitals"]}`
+	w := serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d (%s), want 400 for invalid UTF-8", w.Code, w.Body)
+	}
+}
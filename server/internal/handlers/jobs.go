@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Job states reported by GET /api/jobs/:id
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// errJobQueueFull is returned by JobStore.Submit when no more jobs can wait.
+var errJobQueueFull = errors.New("job queue is full")
+
+// Errors of JobStore.Cancel
+var (
+	errJobNotFound = errors.New("job not found or expired")
+	errJobFinished = errors.New("job already finished")
+)
+
+// Job is the state of an asynchronous extraction.
+type Job struct {
+	ID         string                      `json:"id"`
+	Status     string                      `json:"status"`
+	CreatedAt  time.Time                   `json:"createdAt"`
+	FinishedAt *time.Time                  `json:"finishedAt,omitempty"`
+	Result     *extractor.ExtractionOutput `json:"result,omitempty"` // Set once done
+	Error      string                      `json:"error,omitempty"`  // Set once failed
+}
+
+type jobTask struct {
+	id   string
+	ctx  context.Context // Cancelled by JobStore.Cancel
+	run  func(ctx context.Context) (*extractor.ExtractionOutput, error)
+	done func(job Job) // Called with the finished job, may be nil
+}
+
+// JobStore runs extractions on a fixed number of background workers and keeps
+// each outcome in memory until ttl after it finished.
+type JobStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc // Of the jobs not yet finished
+	queue   chan jobTask
+	ttl     time.Duration
+	logger  *zap.Logger
+}
+
+// NewJobStore starts workers that run submitted jobs. At most queueSize jobs
+// wait for a worker; further submissions are refused. Expired jobs are swept
+// every ttl, so outcomes nobody polls do not accumulate.
+func NewJobStore(workers, queueSize int, ttl time.Duration, logger *zap.Logger) *JobStore {
+	js := &JobStore{
+		jobs:    make(map[string]*Job),
+		cancels: make(map[string]context.CancelFunc),
+		queue:   make(chan jobTask, max(0, queueSize)),
+		ttl:     ttl,
+		logger:  logger.Named("JobStore"),
+	}
+	for range max(1, workers) {
+		go js.work()
+	}
+	if ttl > 0 {
+		go js.sweep()
+	}
+	return js
+}
+
+// sweep periodically drops expired jobs.
+func (js *JobStore) sweep() {
+	ticker := time.NewTicker(js.ttl)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		js.mu.Lock()
+		js.expireLocked(now)
+		js.mu.Unlock()
+	}
+}
+
+// Submit queues run and returns the queued job. done, if set, is called
+// with the finished job from the worker that ran it.
+func (js *JobStore) Submit(run func(ctx context.Context) (*extractor.ExtractionOutput, error), done func(job Job)) (Job, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.expireLocked(time.Now())
+
+	job := &Job{ID: newJobID(), Status: JobQueued, CreatedAt: time.Now()}
+	ctx, cancel := context.WithCancel(context.Background()) // Jobs outlive the request that submitted them
+	select {
+	case js.queue <- jobTask{id: job.ID, ctx: ctx, run: run, done: done}:
+	default:
+		cancel()
+		return Job{}, errJobQueueFull
+	}
+	js.jobs[job.ID] = job
+	js.cancels[job.ID] = cancel
+	return *job, nil
+}
+
+// Cancel stops a queued or running job, which then reports JobCancelled and
+// skips its done callback.
+func (js *JobStore) Cancel(id string) (Job, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.expireLocked(time.Now())
+	job, ok := js.jobs[id]
+	if !ok {
+		return Job{}, errJobNotFound
+	}
+	cancel, pending := js.cancels[id]
+	if !pending {
+		return *job, errJobFinished
+	}
+	cancel()
+	delete(js.cancels, id)
+	finishedAt := time.Now()
+	job.Status = JobCancelled
+	job.FinishedAt = &finishedAt
+	js.logger.Info("Job cancelled", zap.String("jobId", id))
+	return *job, nil
+}
+
+// Get returns a snapshot of the job, reporting false for unknown or expired IDs.
+func (js *JobStore) Get(id string) (Job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.expireLocked(time.Now())
+	job, ok := js.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (js *JobStore) work() {
+	for task := range js.queue {
+		if !js.start(task.id) {
+			continue // Cancelled while queued
+		}
+		result, err := task.run(task.ctx)
+
+		js.mu.Lock()
+		job := js.jobs[task.id]
+		if _, pending := js.cancels[task.id]; !pending {
+			js.mu.Unlock()
+			continue // Cancelled while running
+		}
+		js.cancels[task.id]()
+		delete(js.cancels, task.id)
+		finishedAt := time.Now()
+		job.FinishedAt = &finishedAt
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = "Extraction failed: " + err.Error()
+			js.logger.Error("Job failed", zap.String("jobId", task.id), zap.Error(err))
+		} else {
+			job.Status = JobDone
+			job.Result = result
+			js.logger.Info("Job finished", zap.String("jobId", task.id), zap.Duration("duration", finishedAt.Sub(job.CreatedAt)))
+		}
+		snapshot := *job
+		js.mu.Unlock()
+
+		if task.done != nil {
+			task.done(snapshot)
+		}
+	}
+}
+
+// start marks the job running, reporting false if it was cancelled.
+func (js *JobStore) start(id string) bool {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if _, pending := js.cancels[id]; !pending {
+		return false
+	}
+	js.jobs[id].Status = JobRunning
+	return true
+}
+
+// expireLocked drops jobs that finished more than ttl before now. The caller
+// must hold js.mu.
+func (js *JobStore) expireLocked(now time.Time) {
+	for id, job := range js.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > js.ttl {
+			delete(js.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random identifier for a background extraction.
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b) // Never returns an error
+	return hex.EncodeToString(b)
+}
+
+// ExtractAsync handles POST /api/extract/async
+//
+// It takes the same body as POST /api/extract, queues the extraction and
+// answers 202 with the job ID to poll at GET /api/jobs/:id.
+func (h *ExtractHandler) ExtractAsync(c *gin.Context) {
+	req, opts, ok := h.bindExtractRequest(c)
+	if !ok {
+		return
+	}
+	if req.LLMServer == "" && h.rejectWhileLLMDown(c) {
+		return
+	}
+	h.submitJob(c, req, opts)
+}
+
+// submitJob queues the extraction, posting the outcome to the request's
+// callback URL if it has one, and answers 202 with the job ID.
+func (h *ExtractHandler) submitJob(c *gin.Context, req *ExtractRequest, opts extractor.ProcessOptions) {
+	if req.CallbackURL != "" {
+		if err := h.Callbacks.Validate(req.CallbackURL); err != nil {
+			h.Logger.Warn("Rejected callback URL", zap.String("callback_url", req.CallbackURL), zap.Error(err))
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	callbackURL := req.CallbackURL
+	done := func(job Job) {
+		if job.Status == JobDone {
+			event := newExtractionEvent("job", req.SchemaNames, job.Result)
+			event.JobID = job.ID
+			h.Events.Publish(event)
+		}
+		if callbackURL != "" {
+			go h.deliverCallback(callbackURL, job) // Retries must not hold up the job worker
+		}
+	}
+
+	job, err := h.Jobs.Submit(func(ctx context.Context) (*extractor.ExtractionOutput, error) {
+		return h.Extractor.ProcessText(ctx, req.SchemaNames, req.Text, opts)
+	}, done)
+	if errors.Is(err, errJobQueueFull) {
+		h.Logger.Warn("Rejected extraction job, queue is full")
+		respondJSON(c, http.StatusServiceUnavailable, gin.H{"error": "Too many queued extractions, try again later"})
+		return
+	}
+	h.Logger.Info("Queued extraction job", zap.String("jobId", job.ID), zap.Strings("schemas", req.SchemaNames))
+	respondJSON(c, http.StatusAccepted, gin.H{"jobId": job.ID, "status": job.Status})
+}
+
+// GetJob handles GET /api/jobs/:id
+func (h *ExtractHandler) GetJob(c *gin.Context) {
+	job, ok := h.Jobs.Get(c.Param("id"))
+	if !ok {
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Job not found or expired"})
+		return
+	}
+	respondJSON(c, http.StatusOK, job)
+}
+
+// CancelJob handles DELETE /api/jobs/:id
+func (h *ExtractHandler) CancelJob(c *gin.Context) {
+	job, err := h.Jobs.Cancel(c.Param("id"))
+	switch {
+	case errors.Is(err, errJobNotFound):
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Job not found or expired"})
+	case errors.Is(err, errJobFinished):
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Job already finished", "status": job.Status})
+	default:
+		respondJSON(c, http.StatusOK, job)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"go.uber.org/zap"
+)
+
+func TestExtractAsyncLifecycle(t *testing.T) {
+	llm := newLLMStub(t, `{"Medication": [{"value": "aspirin", "context": "on aspirin"}]}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, nil)
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, NewJobStore(1, 10, time.Hour, zap.NewNop()), nil)
+
+	w := serve(http.MethodPost, "/api/extract/async", "/api/extract/async", `{"text": "Patient on aspirin.", "schema_names": ["meds"]}`, h.ExtractAsync)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("submit status = %d: %s", w.Code, w.Body)
+	}
+	var submitted struct {
+		JobID  string `json:"jobId"`
+		Status string `json:"status"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &submitted)
+	if submitted.JobID == "" || submitted.Status != JobQueued {
+		t.Fatalf("submitted = %+v, want a queued job", submitted)
+	}
+
+	var job Job
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w = serve(http.MethodGet, "/api/jobs/:id", "/api/jobs/"+submitted.JobID, "", h.GetJob)
+		if w.Code != http.StatusOK {
+			t.Fatalf("poll status = %d: %s", w.Code, w.Body)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == JobDone || job.Status == JobFailed || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != JobDone || job.FinishedAt == nil {
+		t.Fatalf("job = %+v, want done", job)
+	}
+	if job.Result == nil || len(job.Result.Entities["Medication"]) != 1 {
+		t.Errorf("job result = %+v, want the extracted medication", job.Result)
+	}
+
+	w = serve(http.MethodGet, "/api/jobs/:id", "/api/jobs/unknown", "", h.GetJob)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown job: status = %d, want 404", w.Code)
+	}
+}
+
+func TestJobStoreExpiresFinishedJobs(t *testing.T) {
+	js := NewJobStore(1, 10, 10*time.Millisecond, zap.NewNop())
+	finished := make(chan Job, 1)
+	job, err := js.Submit(func(ctx context.Context) (*extractor.ExtractionOutput, error) {
+		return &extractor.ExtractionOutput{}, nil
+	}, func(job Job) { finished <- job })
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-finished
+	if _, ok := js.Get(job.ID); !ok {
+		t.Fatal("finished job is gone before its TTL")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := js.Get(job.ID); ok {
+		t.Error("job is still available after its TTL")
+	}
+}
+
+func TestJobStoreRefusesWhenQueueIsFull(t *testing.T) {
+	js := NewJobStore(1, 1, time.Hour, zap.NewNop())
+	release := make(chan struct{})
+	defer close(release)
+	block := func(ctx context.Context) (*extractor.ExtractionOutput, error) {
+		<-release
+		return nil, nil
+	}
+
+	running := make(chan struct{})
+	if _, err := js.Submit(func(ctx context.Context) (*extractor.ExtractionOutput, error) {
+		close(running)
+		return block(ctx)
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	<-running // The worker is busy, so the next job waits in the queue
+	if _, err := js.Submit(block, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.Submit(block, nil); err != errJobQueueFull {
+		t.Errorf("Submit error = %v, want a full queue", err)
+	}
+}
+
+func TestJobStoreSweepsExpiredJobs(t *testing.T) {
+	js := NewJobStore(1, 10, 10*time.Millisecond, zap.NewNop())
+	finished := make(chan Job, 1)
+	if _, err := js.Submit(func(ctx context.Context) (*extractor.ExtractionOutput, error) {
+		return &extractor.ExtractionOutput{}, nil
+	}, func(job Job) { finished <- job }); err != nil {
+		t.Fatal(err)
+	}
+	<-finished
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		js.mu.Lock()
+		remaining := len(js.jobs)
+		js.mu.Unlock()
+		if remaining == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired job was never swept")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobStoreCancel(t *testing.T) {
+	js := NewJobStore(1, 10, time.Hour, zap.NewNop())
+	running := make(chan struct{})
+	stopped := make(chan error, 1)
+	runningJob, err := js.Submit(func(ctx context.Context) (*extractor.ExtractionOutput, error) {
+		close(running)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return nil, ctx.Err()
+	}, func(Job) { t.Error("done called for a cancelled job") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-running
+	queuedJob, err := js.Submit(func(ctx context.Context) (*extractor.ExtractionOutput, error) {
+		t.Error("cancelled queued job ran")
+		return nil, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{queuedJob.ID, runningJob.ID} {
+		job, err := js.Cancel(id)
+		if err != nil || job.Status != JobCancelled || job.FinishedAt == nil {
+			t.Errorf("Cancel(%s) = %+v, %v; want a cancelled job", id, job, err)
+		}
+	}
+	select {
+	case err := <-stopped:
+		if err != context.Canceled {
+			t.Errorf("running job context error = %v, want cancelled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("running job was not cancelled")
+	}
+
+	if _, err := js.Cancel(runningJob.ID); err != errJobFinished {
+		t.Errorf("second Cancel error = %v, want a finished job", err)
+	}
+	if _, err := js.Cancel("unknown"); err != errJobNotFound {
+		t.Errorf("Cancel(unknown) error = %v, want not found", err)
+	}
+	if job, _ := js.Get(runningJob.ID); job.Status != JobCancelled {
+		t.Errorf("status after the run returned = %q, want cancelled", job.Status)
+	}
+}
+
+func TestCancelJobHandler(t *testing.T) {
+	jobs := NewJobStore(1, 10, time.Hour, zap.NewNop())
+	h := NewExtractHandler(nil, zap.NewNop(), 1, false, nil, jobs, nil)
+	release := make(chan struct{})
+	defer close(release)
+	job, err := jobs.Submit(func(ctx context.Context) (*extractor.ExtractionOutput, error) {
+		select {
+		case <-ctx.Done():
+		case <-release:
+		}
+		return nil, ctx.Err()
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		id         string
+		wantStatus int
+	}{
+		{job.ID, http.StatusOK},
+		{job.ID, http.StatusConflict},
+		{"unknown", http.StatusNotFound},
+	} {
+		w := serve(http.MethodDelete, "/api/jobs/:id", "/api/jobs/"+tt.id, "", h.CancelJob)
+		if w.Code != tt.wantStatus {
+			t.Errorf("DELETE %s: status = %d, want %d: %s", tt.id, w.Code, tt.wantStatus, w.Body)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LimitRequestBody caps the size of request bodies. Reading past maxBytes
+// fails, which the JSON handlers report as 413 Request Entity Too Large.
+// A limit of zero or less disables the cap.
+func LimitRequestBody(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes > 0 && c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// bindErrorStatus returns the status for a failed JSON bind: 413 when the
+// body exceeded the size limit, 400 otherwise.
+func bindErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestLimitRequestBodyRejectsOversizedBody(t *testing.T) {
+	baseDir := t.TempDir()
+	writeSavedResult(t, baseDir, "note", SaveResultsResponse{Text: "Patient is 62."})
+	h := NewResultsHandler(baseDir, nil, zap.NewNop())
+
+	router := gin.New()
+	router.PATCH("/api/results/:folder/entities", LimitRequestBody(64), h.PatchResultEntities)
+
+	for size, want := range map[int]int{10: http.StatusOK, 1000: http.StatusRequestEntityTooLarge} {
+		body := `{"Age": [{"value": "` + strings.Repeat("x", size) + `"}]}`
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/api/results/note/entities", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("body of %d bytes: status = %d, want %d", len(body), w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSaveResultsWritesLLMRawOnlyWhenAsked(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", map[string]string{"demo.yaml": "Age:\n  type: number\n"}, nil)
+	const debug = `"debug": {"prompt_schemas": [{"Age": {"type": "number"}}], "llm_responses": ["{\"Age\": []}"]}`
+
+	tests := []struct {
+		name       string
+		configured bool
+		requested  string
+		wantRaw    bool
+	}{
+		{"off", false, "", false},
+		{"requested", false, `"saveLlmRaw": true, `, true},
+		{"configured", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			h := NewSaveResultsHandler(baseDir, DefaultResultsPathTemplate, tt.configured, s, zap.NewNop())
+			body := `{"schemaNames": ["demo"], "text": "Age 62", "originalFilename": "note.txt", ` + tt.requested + debug + `}`
+			if w := serve(http.MethodPost, "/api/save-results", "/api/save-results", body, h.SaveResults); w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			raw, err := os.ReadFile(filepath.Join(baseDir, "note", llmRawFileName))
+			if !tt.wantRaw {
+				if !os.IsNotExist(err) {
+					t.Errorf("%s written without being asked for (err = %v)", llmRawFileName, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var responses []string
+			if err := json.Unmarshal(raw, &responses); err != nil || len(responses) != 1 || responses[0] != `{"Age": []}` {
+				t.Errorf("%s = %s (%v), want the LLM response verbatim", llmRawFileName, raw, err)
+			}
+
+			resultsJSON, err := os.ReadFile(filepath.Join(baseDir, "note", "results.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var results SaveResultsResponse
+			if err := json.Unmarshal(resultsJSON, &results); err != nil {
+				t.Fatal(err)
+			}
+			if results.Debug == nil || len(results.Debug.LLMResponses) != 0 {
+				t.Errorf("results.json debug = %+v, want the responses kept out", results.Debug)
+			}
+		})
+	}
+}
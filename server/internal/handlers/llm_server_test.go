@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestExtractEntitiesLLMServerOverride(t *testing.T) {
+	configured := newLLMStub(t, `{}`)
+	fast := newLLMStub(t, `{}`)
+	s := newTestExtractor(t, configured.URL, defaultSchemaFiles, func(cfg *config.Config) {
+		cfg.LLM.AllowedServers = []string{fast.URL}
+	})
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	body := fmt.Sprintf(`{"text": "HR 80", "schema_names": ["vitals"], "llm_server": %q}`, fast.URL)
+	w := serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+	if w.Code != http.StatusOK {
+		t.Fatalf("allowed server: status = %d: %s", w.Code, w.Body)
+	}
+	if len(fast.Prompts()) != 1 || len(configured.Prompts()) != 0 {
+		t.Errorf("prompts sent: override %d, configured %d; want the override only", len(fast.Prompts()), len(configured.Prompts()))
+	}
+
+	body = `{"text": "HR 80", "schema_names": ["vitals"], "llm_server": "http://169.254.169.254/latest"}`
+	w = serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unlisted server: status = %d, want 400", w.Code)
+	}
+	if len(fast.Prompts()) != 1 || len(configured.Prompts()) != 0 {
+		t.Error("a prompt was sent for the rejected request")
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestExtractFillsOutputTemplate(t *testing.T) {
+	templateDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templateDir, "vitals_report.json"), []byte(`{"hr": "{{HeartRate}}", "drug": "{{Medication|none}}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	llm := newLLMStub(t, `{"HeartRate": [{"value": "80", "context": "HR 80"}]}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, func(cfg *config.Config) {
+		cfg.OutputTemplates.Dir = templateDir
+	})
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	tests := []struct {
+		name     string
+		template string
+		status   int
+		want     string
+	}{
+		{"named", `"vitals_report"`, http.StatusOK, `{"hr":"80","drug":"none"}`},
+		{"inline", `{"Heart rate": "{{HeartRate}} bpm"}`, http.StatusOK, `{"Heart rate":"80 bpm"}`},
+		{"unknown name", `"missing"`, http.StatusBadRequest, ""},
+		{"not an object", `["{{HeartRate}}"]`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		body := `{"text": "HR 80", "schema_names": ["vitals"], "output_template": ` + tt.template + `}`
+		w := serve(http.MethodPost, "/api/extract", "/api/extract", body, h.ExtractEntities)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d (%s), want %d", tt.name, w.Code, w.Body, tt.status)
+			continue
+		}
+		if tt.want != "" && w.Body.String() != tt.want {
+			t.Errorf("%s: report = %s, want %s", tt.name, w.Body, tt.want)
+		}
+	}
+
+	w := serve(http.MethodPost, "/api/extract", "/api/extract?format=counts", `{"text": "HR 80", "schema_names": ["vitals"], "output_template": "vitals_report"}`, h.ExtractEntities)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("template with format: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// PromptEstimateRequest defines the expected JSON body for the /api/prompt/estimate endpoint.
+type PromptEstimateRequest struct {
+	SchemaNames []string `json:"schema_names"` // Falls back to llm.default_schemas when omitted
+	Text        string   `json:"text"`         // Optional sample document
+}
+
+// EstimatePrompt handles POST /api/prompt/estimate
+//
+// It renders the prompt the schemas would produce for the sample text and
+// reports its size against the configured context, without calling the LLM.
+func (h *ExtractHandler) EstimatePrompt(c *gin.Context) {
+	var req PromptEstimateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Logger.Error("Failed to bind JSON request for prompt estimate", zap.Error(err))
+		respondJSON(c, bindErrorStatus(err), gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if !h.applyDefaultSchemas(&req.SchemaNames) {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "schema_names is required"})
+		return
+	}
+	if rejectTooManySchemas(c, h.Logger, h.Extractor.MaxRequestSchemas(), req.SchemaNames) {
+		return
+	}
+	if h.rejectHiddenSchemas(c, req.SchemaNames) {
+		return
+	}
+	invalidSchemas := h.unknownSchemaNames(req.SchemaNames)
+	if len(invalidSchemas) > 0 {
+		h.Logger.Error("Invalid schema names requested for prompt estimate", zap.Strings("invalid", invalidSchemas))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid schema name(s) provided: %v", invalidSchemas)})
+		return
+	}
+
+	estimate, err := h.Extractor.EstimatePrompt(req.SchemaNames, req.Text)
+	if errors.Is(err, extractor.ErrNoEntitiesSelected) {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "No entities selected: the requested schemas define no entities to extract"})
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to estimate prompt", zap.Strings("schemas", req.SchemaNames), zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to estimate prompt: " + err.Error()})
+		return
+	}
+
+	response := gin.H{"estimate": estimate}
+	if estimate.ExceedsContext {
+		response["warning"] = fmt.Sprintf("Estimated %d prompt tokens plus %d response tokens exceed the configured context of %d", estimate.EstimatedTokens, estimate.OutputTokens, estimate.ContextSize)
+	}
+	h.Logger.Info("Estimated prompt size",
+		zap.Strings("schemas", req.SchemaNames),
+		zap.Int("estimated_tokens", estimate.EstimatedTokens),
+		zap.Bool("exceeds_context", estimate.ExceedsContext),
+	)
+	respondJSON(c, http.StatusOK, response)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+// estimate posts the schema names to the estimate endpoint and decodes the response.
+func estimate(t *testing.T, h *ExtractHandler, schemaNames string) (tokens int, warning string) {
+	t.Helper()
+	body := fmt.Sprintf(`{"schema_names": %s, "text": "Patient seen in clinic."}`, schemaNames)
+	w := serve(http.MethodPost, "/api/prompt/estimate", "/api/prompt/estimate", body, h.EstimatePrompt)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Estimate struct {
+			EstimatedTokens int `json:"estimated_tokens"`
+		} `json:"estimate"`
+		Warning string `json:"warning"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Estimate.EstimatedTokens, resp.Warning
+}
+
+func TestEstimatePromptScalesWithSchemaSize(t *testing.T) {
+	var large strings.Builder
+	for i := range 100 {
+		fmt.Fprintf(&large, "Finding%d:\n  type: string\n  description: A finding described at some length.\n", i)
+	}
+	schemas := map[string]string{
+		"small.yaml": "Medication:\n  type: string\n",
+		"large.yaml": large.String(),
+	}
+	llm := newLLMStub(t, `{}`)
+	s := newTestExtractor(t, llm.URL, schemas, func(cfg *config.Config) {
+		cfg.LLM.ContextSize = 2000
+		cfg.LLM.ResponseReserveTokens = 0
+	})
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	smallTokens, smallWarning := estimate(t, h, `["small"]`)
+	largeTokens, largeWarning := estimate(t, h, `["large"]`)
+	bothTokens, _ := estimate(t, h, `["small", "large"]`)
+
+	if !(smallTokens < largeTokens && largeTokens < bothTokens) {
+		t.Errorf("estimates small=%d large=%d both=%d do not grow with the schemas", smallTokens, largeTokens, bothTokens)
+	}
+	if smallWarning != "" {
+		t.Errorf("unexpected warning for the small schema: %s", smallWarning)
+	}
+	if largeWarning == "" {
+		t.Errorf("no warning although %d tokens exceed the context of 2000", largeTokens)
+	}
+	if len(llm.Prompts()) != 0 {
+		t.Error("estimating the prompt called the LLM")
+	}
+}
+
+func TestEstimatePromptReservesResponseBudget(t *testing.T) {
+	schemas := map[string]string{"small.yaml": "Medication:\n  type: string\n"}
+	llm := newLLMStub(t, `{}`)
+	unbounded := newTestExtractor(t, llm.URL, schemas, func(cfg *config.Config) {
+		cfg.LLM.ResponseReserveTokens = 0
+	})
+	promptTokens, _ := estimate(t, NewExtractHandler(unbounded, zap.NewNop(), 1, false, nil, nil, nil), `["small"]`)
+
+	for _, tt := range []struct {
+		outputTokens  int
+		reserveTokens int
+		wantWarning   bool
+	}{
+		{0, 0, false},
+		{0, 50, false},
+		{0, 100, true}, // Unbounded responses are estimated at the reserve
+		{50, 100, false},
+		{100, 0, true},
+	} {
+		s := newTestExtractor(t, llm.URL, schemas, func(cfg *config.Config) {
+			cfg.LLM.ContextSize = promptTokens + 50
+			cfg.LLM.MaxOutputTokens = tt.outputTokens
+			cfg.LLM.ResponseReserveTokens = tt.reserveTokens
+		})
+		_, warning := estimate(t, NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil), `["small"]`)
+		if (warning != "") != tt.wantWarning {
+			t.Errorf("%d prompt tokens, max output %d, reserve %d in a context of %d: warning = %q, want warning %v",
+				promptTokens, tt.outputTokens, tt.reserveTokens, promptTokens+50, warning, tt.wantWarning)
+		}
+	}
+}
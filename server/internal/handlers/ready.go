@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"github.com/gin-gonic/gin"
+)
+
+// Ready handles GET /api/ready
+//
+// It answers 200 while the LLM server passes health checks (or they are
+// disabled) and 503 while it does not, for load balancer readiness probes.
+func Ready(extractorService *extractor.ExtractorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		health := extractorService.LLMHealth()
+		if !health.Healthy {
+			respondJSON(c, http.StatusServiceUnavailable, gin.H{"status": "degraded", "llm": health})
+			return
+		}
+		respondJSON(c, http.StatusOK, gin.H{"status": "ready", "llm": health})
+	}
+}
+
+// rejectWhileLLMDown answers 503 when health checks found the LLM server
+// down, instead of letting the request wait for the LLM call to time out.
+func (h *ExtractHandler) rejectWhileLLMDown(c *gin.Context) bool {
+	if h.Extractor.LLMHealthy() {
+		return false
+	}
+	h.Logger.Warn("Rejecting extraction while the LLM server is unhealthy")
+	respondJSON(c, http.StatusServiceUnavailable, gin.H{"error": "LLM server is unavailable, try again later"})
+	return true
+}
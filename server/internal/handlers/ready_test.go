@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestReadyAndExtractWhileLLMDown(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, func(cfg *config.Config) {
+		cfg.LLM.HealthCheckIntervalSeconds = 60 // The first check runs at startup
+		cfg.LLM.HealthCheckTimeoutSeconds = 1
+	})
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.LLMHealthy() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w := serve(http.MethodGet, "/api/ready", "/api/ready", "", Ready(s))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("ready: status = %d, want 503", w.Code)
+	}
+	w = serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "HR 80", "schema_names": ["vitals"]}`, h.ExtractEntities)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("extract: status = %d, want 503", w.Code)
+	}
+}
+
+func TestReadyWithoutHealthChecks(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, nil)
+	w := serve(http.MethodGet, "/api/ready", "/api/ready", "", Ready(s))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 while health checks are disabled", w.Code)
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondJSON writes obj as JSON, indented when the request carries
+// ?pretty=1 (or true) so responses are readable with curl. Responses are
+// compact otherwise.
+func respondJSON(c *gin.Context, status int, obj any) {
+	if pretty, _ := strconv.ParseBool(c.Query("pretty")); pretty {
+		c.IndentedJSON(status, obj)
+		return
+	}
+	c.JSON(status, obj)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestGetSchemasPretty(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, nil)
+	h := NewSchemaHandler(s, zap.NewNop(), nil, LeafCollisionsReport)
+
+	compact := serve(http.MethodGet, "/api/schemas", "/api/schemas", "", h.GetSchemas)
+	pretty := serve(http.MethodGet, "/api/schemas", "/api/schemas?pretty=1", "", h.GetSchemas)
+	if pretty.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", pretty.Code, pretty.Body)
+	}
+	if !json.Valid(pretty.Body.Bytes()) {
+		t.Fatalf("pretty response is not valid JSON: %s", pretty.Body)
+	}
+	var indented bytes.Buffer
+	json.Indent(&indented, compact.Body.Bytes(), "", "    ")
+	if pretty.Body.String() != indented.String() {
+		t.Errorf("pretty response = %s, want %s", pretty.Body, indented.String())
+	}
+	if bytes.Contains(compact.Body.Bytes(), []byte("\n")) {
+		t.Errorf("default response is indented: %s", compact.Body)
+	}
+}
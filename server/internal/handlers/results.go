@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ResultsHandler serves previously saved extraction results.
+type ResultsHandler struct {
+	ResultsBaseDir string
+	Extractor      *extractor.ExtractorService
+	Logger         *zap.Logger
+}
+
+// NewResultsHandler creates a new results handler.
+func NewResultsHandler(resultsBaseDir string, extractor *extractor.ExtractorService, logger *zap.Logger) *ResultsHandler {
+	return &ResultsHandler{
+		ResultsBaseDir: resultsBaseDir,
+		Extractor:      extractor,
+		Logger:         logger.Named("ResultsHandler"),
+	}
+}
+
+// GetResultText handles GET /api/results/:folder/text
+func (h *ResultsHandler) GetResultText(c *gin.Context) {
+	folderPath, ok := h.resolveFolder(c)
+	if !ok {
+		return
+	}
+
+	textPath := filepath.Join(folderPath, "text.txt")
+	text, err := os.ReadFile(textPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			h.Logger.Warn("Saved text file not found", zap.String("path", textPath))
+			respondJSON(c, http.StatusNotFound, gin.H{"error": "Saved text not found"})
+			return
+		}
+		h.Logger.Error("Failed to read saved text file", zap.String("path", textPath), zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to read saved text"})
+		return
+	}
+
+	h.Logger.Info("Returning saved text", zap.String("path", textPath), zap.Int("size", len(text)))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", text)
+}
+
+// GetResultSchema handles GET /api/results/:folder/schema
+//
+// It returns the combined schema the results were saved with, which may
+// differ from the current definitions: as YAML when the Accept header asks
+// for it, as JSON otherwise.
+func (h *ResultsHandler) GetResultSchema(c *gin.Context) {
+	folderPath, ok := h.resolveFolder(c)
+	if !ok {
+		return
+	}
+
+	schemaPath := filepath.Join(folderPath, "schema.yaml")
+	data, err := os.ReadFile(schemaPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			h.Logger.Warn("Saved schema file not found", zap.String("path", schemaPath))
+			respondJSON(c, http.StatusNotFound, gin.H{"error": "Saved schema not found"})
+			return
+		}
+		h.Logger.Error("Failed to read saved schema file", zap.String("path", schemaPath), zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to read saved schema"})
+		return
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), "yaml") {
+		h.Logger.Info("Returning saved schema as YAML", zap.String("path", schemaPath), zap.Int("size", len(data)))
+		c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+		return
+	}
+
+	var schema map[string]any
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		h.Logger.Error("Failed to parse saved schema file", zap.String("path", schemaPath), zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Saved schema is not valid YAML"})
+		return
+	}
+	// YAML may decode nested maps as map[any]any, which JSON cannot encode
+	converted, ok := convertToMapStringInterface(schema)
+	if !ok {
+		h.Logger.Error("Failed to convert saved schema for JSON", zap.String("path", schemaPath))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to convert saved schema"})
+		return
+	}
+	h.Logger.Info("Returning saved schema", zap.String("path", schemaPath))
+	respondJSON(c, http.StatusOK, converted)
+}
+
+// GetResultCoNLL handles GET /api/results/:folder/conll
+//
+// It exports the saved (reviewed) entities as BIO-tagged tokens for training.
+func (h *ResultsHandler) GetResultCoNLL(c *gin.Context) {
+	folderPath, ok := h.resolveFolder(c)
+	if !ok {
+		return
+	}
+
+	results, status, err := h.readResults(filepath.Join(folderPath, "results.json"))
+	if err != nil {
+		respondJSON(c, status, gin.H{"error": err.Error()})
+		return
+	}
+
+	conll := extractor.FormatCoNLL(&extractor.ExtractionOutput{Text: results.Text, Entities: results.Entities})
+	h.Logger.Info("Exporting saved results as CoNLL", zap.String("folder", c.Param("folder")), zap.Int("size", len(conll)))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(conll))
+}
+
+// GetResultStaleness handles GET /api/results/:folder/staleness
+//
+// It compares the schema hash and prompt version stored with the results
+// against the currently loaded definitions.
+func (h *ResultsHandler) GetResultStaleness(c *gin.Context) {
+	folderPath, ok := h.resolveFolder(c)
+	if !ok {
+		return
+	}
+
+	results, status, err := h.readResults(filepath.Join(folderPath, "results.json"))
+	if err != nil {
+		respondJSON(c, status, gin.H{"error": err.Error()})
+		return
+	}
+
+	reasons := []string{}
+	currentSchemaHash := ""
+	if len(results.SchemaNames) == 0 || results.SchemaHash == "" {
+		reasons = append(reasons, "results were saved without version information")
+	} else if combined, err := h.Extractor.CombineSchemas(results.SchemaNames); err != nil {
+		reasons = append(reasons, fmt.Sprintf("schemas are no longer available: %v", err))
+	} else if currentSchemaHash, err = extractor.HashSchema(combined); err != nil {
+		h.Logger.Error("Failed to hash current schema", zap.Strings("schemas", results.SchemaNames), zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to hash current schema"})
+		return
+	} else if currentSchemaHash != results.SchemaHash {
+		reasons = append(reasons, "schema definitions have changed")
+	}
+	currentPromptVersion := h.Extractor.CurrentPromptVersion(results.PromptVersion)
+	if results.PromptVersion != currentPromptVersion {
+		reasons = append(reasons, "prompt template has changed")
+	}
+
+	h.Logger.Info("Checked result staleness", zap.String("folder", c.Param("folder")), zap.Strings("reasons", reasons))
+	respondJSON(c, http.StatusOK, gin.H{
+		"stale":                len(reasons) > 0,
+		"reasons":              reasons,
+		"schemaNames":          results.SchemaNames,
+		"storedSchemaHash":     results.SchemaHash,
+		"currentSchemaHash":    currentSchemaHash,
+		"storedPromptVersion":  results.PromptVersion,
+		"currentPromptVersion": currentPromptVersion,
+	})
+}
+
+// readResults loads a saved results.json. On failure it returns the HTTP
+// status and a client-facing error, having logged the details.
+func (h *ResultsHandler) readResults(resultsPath string) (*SaveResultsResponse, int, error) {
+	resultsJSON, err := os.ReadFile(resultsPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			h.Logger.Warn("Saved results file not found", zap.String("path", resultsPath))
+			return nil, http.StatusNotFound, errors.New("Saved results not found")
+		}
+		h.Logger.Error("Failed to read saved results file", zap.String("path", resultsPath), zap.Error(err))
+		return nil, http.StatusInternalServerError, errors.New("Failed to read saved results")
+	}
+	var results SaveResultsResponse
+	if err := json.Unmarshal(resultsJSON, &results); err != nil {
+		h.Logger.Error("Failed to decode saved results file", zap.String("path", resultsPath), zap.Error(err))
+		return nil, http.StatusInternalServerError, errors.New("Saved results are corrupt")
+	}
+	return &results, http.StatusOK, nil
+}
+
+// PatchResultEntities handles PATCH /api/results/:folder/entities
+//
+// The body maps entity names to their complete list of occurrences. Named
+// entities are replaced (or added); all others, text.txt and schema.yaml are
+// left untouched.
+func (h *ResultsHandler) PatchResultEntities(c *gin.Context) {
+	folderPath, ok := h.resolveFolder(c)
+	if !ok {
+		return
+	}
+
+	var patch map[string][]extractor.EntityOccurrence
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		h.Logger.Error("Failed to bind entity patch JSON", zap.Error(err))
+		respondJSON(c, bindErrorStatus(err), gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if len(patch) == 0 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "No entities provided"})
+		return
+	}
+
+	unlock := lockResultFolder(folderPath)
+	defer unlock()
+
+	resultsPath := filepath.Join(folderPath, "results.json")
+	results, status, err := h.readResults(resultsPath)
+	if err != nil {
+		respondJSON(c, status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateEntityPatch(patch, utf8.RuneCountInString(results.Text)); err != nil {
+		h.Logger.Warn("Rejected invalid entity patch", zap.String("path", resultsPath), zap.Error(err))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if results.Entities == nil {
+		results.Entities = make(map[string][]extractor.EntityOccurrence)
+	}
+	updated := make([]string, 0, len(patch))
+	for entityName, occurrences := range patch {
+		if occurrences == nil {
+			occurrences = []extractor.EntityOccurrence{}
+		}
+		results.Entities[entityName] = occurrences
+		updated = append(updated, entityName)
+	}
+	sort.Strings(updated)
+
+	patchedJSON, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		h.Logger.Error("Failed to marshal patched results", zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to prepare results data"})
+		return
+	}
+	if err := writeFileAtomic(resultsPath, patchedJSON, 0644); err != nil {
+		h.Logger.Error("Failed to write patched results file", zap.String("path", resultsPath), zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save results JSON file"})
+		return
+	}
+
+	h.Logger.Info("Patched saved entities", zap.String("path", resultsPath), zap.Strings("entities", updated))
+	respondJSON(c, http.StatusOK, gin.H{"updated": updated, "entities": results.Entities})
+}
+
+// validateEntityPatch checks that every occurrence has a value and positions
+// that fall inside a text of textLength runes.
+func validateEntityPatch(patch map[string][]extractor.EntityOccurrence, textLength int) error {
+	for entityName, occurrences := range patch {
+		if entityName == "" {
+			return fmt.Errorf("entity names must not be empty")
+		}
+		for i, occurrence := range occurrences {
+			if occurrence.Value == nil {
+				return fmt.Errorf("entity '%s' occurrence %d has no value", entityName, i)
+			}
+			for _, position := range []extractor.Position{occurrence.Position, occurrence.Context.Position} {
+				if position.Start < 0 || position.End < position.Start || position.End > textLength {
+					return fmt.Errorf("entity '%s' occurrence %d has an invalid position", entityName, i)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// folderLock is the mutex of one results folder, with the number of
+// handlers holding or waiting for it.
+type folderLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// resultFolderLocks holds the lock of every results folder currently being
+// written, shared by every handler that writes into results folders. Entries
+// are removed once no handler holds or waits for them.
+var (
+	resultFolderLocksMu sync.Mutex
+	resultFolderLocks   = make(map[string]*folderLock)
+)
+
+// lockResultFolder serializes writers of one results folder and returns the
+// unlock function. Renames keep each file consistent for readers; the lock
+// keeps the files of one save from interleaving with another's.
+func lockResultFolder(folderPath string) func() {
+	key := filepath.Clean(folderPath)
+	resultFolderLocksMu.Lock()
+	lock, ok := resultFolderLocks[key]
+	if !ok {
+		lock = &folderLock{}
+		resultFolderLocks[key] = lock
+	}
+	lock.refs++
+	resultFolderLocksMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		resultFolderLocksMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(resultFolderLocks, key)
+		}
+		resultFolderLocksMu.Unlock()
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resolveFolder validates the :folder parameter and returns the folder's path.
+// Results saved under a nested results.path_template are addressed by their
+// subpath with its slashes URL-encoded ("2024-05-01%2Fmeds%2Fnote"). It
+// writes an error response and returns false when the folder is invalid.
+func (h *ResultsHandler) resolveFolder(c *gin.Context) (string, bool) {
+	folder := c.Param("folder")
+	if !isValidResultFolder(folder) {
+		h.Logger.Warn("Invalid results folder requested", zap.String("folder", folder))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid results folder name"})
+		return "", false
+	}
+	return filepath.Join(h.ResultsBaseDir, filepath.FromSlash(folder)), true
+}
+
+// isValidResultFolder accepts only slash-separated segments that
+// sanitizeFilenameForFolder could have produced, which rules out traversal.
+func isValidResultFolder(folder string) bool {
+	for _, segment := range strings.Split(folder, "/") {
+		if segment == "" || sanitizeFilenameForFolder(segment) != segment {
+			return false
+		}
+	}
+	return true
+}
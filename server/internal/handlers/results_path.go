@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultResultsPathTemplate saves each result in a folder named after its
+// file directly under the results directory.
+const DefaultResultsPathTemplate = "{filename}"
+
+var resultsPathVariable = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// resultsPathVariables are the placeholders a results path template may use.
+var resultsPathVariables = map[string]bool{"date": true, "schema": true, "filename": true}
+
+// validateResultsPathTemplate rejects templates with unknown placeholders,
+// stray braces or empty segments.
+func validateResultsPathTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("results path template is empty")
+	}
+	for _, segment := range strings.Split(template, "/") {
+		if segment == "" {
+			return fmt.Errorf("results path template %q has an empty segment", template)
+		}
+		for _, match := range resultsPathVariable.FindAllStringSubmatch(segment, -1) {
+			if !resultsPathVariables[match[1]] {
+				return fmt.Errorf("results path template %q uses unknown variable {%s}", template, match[1])
+			}
+		}
+		if strings.ContainsAny(resultsPathVariable.ReplaceAllString(segment, ""), "{}") {
+			return fmt.Errorf("results path template %q has unmatched braces", template)
+		}
+	}
+	return nil
+}
+
+// resolveResultsPath fills in a validated template and returns the save
+// subpath relative to the results directory. Every segment is sanitized on
+// its own, so neither the template nor the request can climb out of it.
+func resolveResultsPath(template string, schemaNames []string, filename string, now time.Time) string {
+	values := map[string]string{
+		"date":     now.Format("2006-01-02"),
+		"schema":   strings.Join(schemaNames, "_"),
+		"filename": sanitizeFilenameForFolder(filename), // Drops the extension, as the flat layout always has
+	}
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		resolved := resultsPathVariable.ReplaceAllStringFunc(segment, func(variable string) string {
+			return values[strings.Trim(variable, "{}")]
+		})
+		segments[i] = sanitizePathComponent(resolved)
+	}
+	return filepath.Join(segments...)
+}
+
+// sanitizePathComponent keeps the characters sanitizeFilenameForFolder
+// allows, without treating anything after a period as an extension.
+func sanitizePathComponent(component string) string {
+	var sanitized strings.Builder
+	for _, r := range strings.ReplaceAll(component, " ", "_") {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			sanitized.WriteRune(r)
+		}
+	}
+	if sanitized.Len() == 0 {
+		return "untitled_result"
+	}
+	return sanitized.String()
+}
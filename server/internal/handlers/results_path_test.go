@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestSaveResultsPathTemplate(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, nil)
+	baseDir := t.TempDir()
+	h := NewSaveResultsHandler(baseDir, "{date}/{schema}/{filename}", false, s, zap.NewNop())
+
+	body := `{"schemaNames": ["meds", "vitals"], "text": "HR 80", "originalFilename": "ward round.txt"}`
+	w := serve(http.MethodPost, "/api/save-results", "/api/save-results", body, h.SaveResults)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	dir := filepath.Join(baseDir, time.Now().Format("2006-01-02"), "meds_vitals", "ward_round")
+	for _, name := range []string{"schema.yaml", "text.txt", "results.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not saved under the templated path: %v", name, err)
+		}
+	}
+}
+
+func TestResolveResultsPath(t *testing.T) {
+	now := time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		template    string
+		schemaNames []string
+		filename    string
+		want        string
+	}{
+		{DefaultResultsPathTemplate, []string{"meds"}, "note.txt", "note"},
+		{"{date}/{schema}/{filename}", []string{"meds"}, "note.txt", "2026-03-14/meds/note"},
+		{"archive/{schema}-{date}", []string{"meds", "vitals"}, "note.txt", "archive/meds_vitals-2026-03-14"},
+		{"{schema}/{filename}", []string{"meds"}, "../../etc/passwd", "meds/etcpasswd"},
+		{"{filename}", []string{"meds"}, "..", "untitled_result"},
+	}
+	for _, tt := range tests {
+		got := filepath.ToSlash(resolveResultsPath(tt.template, tt.schemaNames, tt.filename, now))
+		if got != tt.want {
+			t.Errorf("resolveResultsPath(%q, %v, %q) = %q, want %q", tt.template, tt.schemaNames, tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestValidateResultsPathTemplate(t *testing.T) {
+	for _, template := range []string{"", "{date}//{filename}", "{user}/{filename}", "{filename"} {
+		if err := validateResultsPathTemplate(template); err == nil {
+			t.Errorf("template %q accepted", template)
+		}
+	}
+}
+
+func TestResultsReachSavedTemplatedFolder(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, nil)
+	baseDir := t.TempDir()
+	save := NewSaveResultsHandler(baseDir, "{date}/{schema}/{filename}", false, s, zap.NewNop())
+	w := serve(http.MethodPost, "/api/save-results", "/api/save-results", `{"schemaNames": ["meds"], "text": "HR 80", "originalFilename": "note.txt"}`, save.SaveResults)
+	if w.Code != http.StatusOK {
+		t.Fatalf("save status = %d: %s", w.Code, w.Body)
+	}
+	var saved struct {
+		Folder string `json:"folder"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &saved)
+	if want := time.Now().Format("2006-01-02") + "/meds/note"; saved.Folder != want {
+		t.Fatalf("folder = %q, want %q", saved.Folder, want)
+	}
+
+	results := NewResultsHandler(baseDir, s, zap.NewNop())
+	router := gin.New()
+	router.UseRawPath = true
+	router.GET("/api/results/:folder/text", results.GetResultText)
+	for folder, want := range map[string]int{
+		url.PathEscape(saved.Folder):   http.StatusOK,
+		url.PathEscape("meds/../note"): http.StatusBadRequest,
+		url.PathEscape("/meds/note"):   http.StatusBadRequest,
+		url.PathEscape("missing/note"): http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/results/"+folder+"/text", nil))
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d", folder, w.Code, want)
+		}
+		if want == http.StatusOK && w.Body.String() != "HR 80" {
+			t.Errorf("%s: text = %q, want the saved text", folder, w.Body)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+func getResultSchema(h *ResultsHandler, folder, accept string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/api/results/:folder/schema", h.GetResultSchema)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/results/"+folder+"/schema", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetResultSchemaReadsSavedSchema(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, nil)
+	baseDir := t.TempDir()
+	save := NewSaveResultsHandler(baseDir, DefaultResultsPathTemplate, false, s, zap.NewNop())
+	body := `{"schemaNames": ["meds", "vitals"], "text": "HR 80 on aspirin", "originalFilename": "note.txt"}`
+	if w := serve(http.MethodPost, "/api/save-results", "/api/save-results", body, save.SaveResults); w.Code != http.StatusOK {
+		t.Fatalf("save: status = %d: %s", w.Code, w.Body)
+	}
+	h := NewResultsHandler(baseDir, s, zap.NewNop())
+
+	w := getResultSchema(h, "note", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var schema map[string]map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema["HeartRate"]["type"] != "number" || schema["Medication"]["type"] != "string" {
+		t.Errorf("schema = %v, want both saved schemas combined", schema)
+	}
+
+	w = getResultSchema(h, "note", "application/yaml")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/yaml; charset=utf-8" {
+		t.Fatalf("YAML: status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var fromYAML map[string]map[string]any
+	if err := yaml.Unmarshal(w.Body.Bytes(), &fromYAML); err != nil || fromYAML["HeartRate"]["type"] != "number" {
+		t.Errorf("YAML schema = %s (%v), want the saved schema", w.Body, err)
+	}
+}
+
+func TestGetResultSchemaErrors(t *testing.T) {
+	h := NewResultsHandler(t.TempDir(), nil, zap.NewNop())
+	if w := getResultSchema(h, "missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("missing folder: status = %d, want 404", w.Code)
+	}
+	if w := getResultSchema(h, "note.txt", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid folder: status = %d, want 400", w.Code)
+	}
+}
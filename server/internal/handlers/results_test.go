@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// writeSavedResult creates a results folder as SaveResults would.
+func writeSavedResult(t *testing.T, baseDir, folder string, results SaveResultsResponse) string {
+	t.Helper()
+	folderPath := filepath.Join(baseDir, folder)
+	if err := os.MkdirAll(folderPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(results)
+	for name, content := range map[string][]byte{
+		"results.json": data,
+		"text.txt":     []byte(results.Text),
+		"schema.yaml":  []byte("Age:\n  type: number\n"),
+	} {
+		if err := os.WriteFile(filepath.Join(folderPath, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return folderPath
+}
+
+func patchEntities(t *testing.T, h *ResultsHandler, folder, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.PATCH("/api/results/:folder/entities", h.PatchResultEntities)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/api/results/"+folder+"/entities", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPatchResultEntitiesAddsAndReplaces(t *testing.T) {
+	baseDir := t.TempDir()
+	folderPath := writeSavedResult(t, baseDir, "note", SaveResultsResponse{
+		Text: "Patient is 62, on aspirin.",
+		Entities: map[string][]extractor.EntityOccurrence{
+			"Age":        {{Value: "26", Position: extractor.Position{Start: 11, End: 13}, ID: "a"}},
+			"Medication": {{Value: "aspirin", Position: extractor.Position{Start: 18, End: 25}, ID: "m"}},
+		},
+	})
+	h := NewResultsHandler(baseDir, nil, zap.NewNop())
+
+	w := patchEntities(t, h, "note", `{
+		"Age": [{"value": "62", "position": {"start": 11, "end": 13}, "context": {"text": "Patient is 62", "position": {"start": 0, "end": 13}}, "id": "a"}],
+		"Sex": [{"value": "unknown", "position": {"start": 0, "end": 7}, "context": {"text": "Patient", "position": {"start": 0, "end": 7}}, "id": "s"}]
+	}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	data, err := os.ReadFile(filepath.Join(folderPath, "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved SaveResultsResponse
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Entities["Age"][0].Value != "62" {
+		t.Errorf("Age = %v, want the replacement", saved.Entities["Age"])
+	}
+	if len(saved.Entities["Sex"]) != 1 {
+		t.Errorf("Sex = %v, want the added entity", saved.Entities["Sex"])
+	}
+	if len(saved.Entities["Medication"]) != 1 {
+		t.Error("untouched entity was dropped")
+	}
+	if saved.Text != "Patient is 62, on aspirin." {
+		t.Errorf("text = %q, want it unchanged", saved.Text)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(folderPath, "*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestPatchResultEntitiesRejectsInvalidInput(t *testing.T) {
+	baseDir := t.TempDir()
+	writeSavedResult(t, baseDir, "note", SaveResultsResponse{Text: "short"})
+	h := NewResultsHandler(baseDir, nil, zap.NewNop())
+
+	tests := map[string]struct {
+		folder, body string
+		want         int
+	}{
+		"bad folder":      {"..", `{"Age": []}`, http.StatusBadRequest},
+		"missing folder":  {"other", `{"Age": []}`, http.StatusNotFound},
+		"empty patch":     {"note", `{}`, http.StatusBadRequest},
+		"null value":      {"note", `{"Age": [{"value": null}]}`, http.StatusBadRequest},
+		"out of range":    {"note", `{"Age": [{"value": "1", "position": {"start": 0, "end": 50}}]}`, http.StatusBadRequest},
+		"wrong body type": {"note", `{"Age": "62"}`, http.StatusBadRequest},
+	}
+	for name, tt := range tests {
+		if w := patchEntities(t, h, tt.folder, tt.body); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestLockResultFolderReleasesEntries(t *testing.T) {
+	baseDir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lockResultFolder(filepath.Join(baseDir, strconv.Itoa(i%5)))()
+		}()
+	}
+	wg.Wait()
+
+	resultFolderLocksMu.Lock()
+	defer resultFolderLocksMu.Unlock()
+	if len(resultFolderLocks) != 0 {
+		t.Errorf("%d folder locks left after every writer unlocked", len(resultFolderLocks))
+	}
+}
+
+func TestLockResultFolderSerializesOneFolder(t *testing.T) {
+	folder := filepath.Join(t.TempDir(), "run")
+	unlock := lockResultFolder(folder)
+	acquired := make(chan struct{})
+	go func() {
+		defer lockResultFolder(folder + "/")() // Same folder once cleaned
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second writer acquired a held folder lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second writer not released by unlock")
+	}
+}
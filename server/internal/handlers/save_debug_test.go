@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSaveResultsPersistsDebug(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", map[string]string{"demo.yaml": "Age:\n  type: number\n"}, nil)
+	baseDir := t.TempDir()
+	h := NewSaveResultsHandler(baseDir, DefaultResultsPathTemplate, false, s, zap.NewNop())
+
+	body := `{"schemaNames": ["demo"], "text": "Age 62", "originalFilename": "note.txt",
+		"debug": {"prompt_schemas": [{"Age": {"type": "number"}}]}}`
+	w := serve(http.MethodPost, "/api/save-results", "/api/save-results", body, h.SaveResults)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	resultsJSON, err := os.ReadFile(filepath.Join(baseDir, "note", "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results SaveResultsResponse
+	if err := json.Unmarshal(resultsJSON, &results); err != nil {
+		t.Fatal(err)
+	}
+	if results.Debug == nil || len(results.Debug.PromptSchemas) != 1 {
+		t.Errorf("saved debug = %+v, want the prompt schema", results.Debug)
+	}
+}
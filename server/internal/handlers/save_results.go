@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/andevellicus/med-ex/internal/extractor" // Assuming types are here
 	"github.com/gin-gonic/gin"
@@ -21,27 +22,56 @@ type SaveResultsRequest struct {
 	Text             string                                  `json:"text" binding:"required"`
 	Entities         map[string][]extractor.EntityOccurrence `json:"entities"`
 	OriginalFilename string                                  `json:"originalFilename" binding:"required"`
+	Debug            *extractor.ExtractionDebug              `json:"debug"` // As returned by a debug extraction, if any
+	// Also write the LLM's JSON from debug.llm_responses to llm_raw.json
+	SaveLLMRaw bool `json:"saveLlmRaw"`
+	// metadata.prompt_version of the extraction; the configured version if unset
+	PromptVersion string `json:"promptVersion"`
 }
 
 // SaveResultsResponse defines the JSON structure for the results file.
 type SaveResultsResponse struct {
 	Text     string                                  `json:"text"`
 	Entities map[string][]extractor.EntityOccurrence `json:"entities"`
+	// Definitions the results were saved against; compared by the staleness endpoint
+	SchemaNames   []string `json:"schemaNames,omitempty"`
+	SchemaHash    string   `json:"schemaHash,omitempty"`
+	PromptVersion string   `json:"promptVersion,omitempty"`
+	// What the LLM was given, when the extraction was run with debug set
+	Debug *extractor.ExtractionDebug `json:"debug,omitempty"`
 }
 
+// File of a results folder holding the LLM's JSON answers verbatim, one
+// string per prompt, so they can be re-parsed when the parser changes
+const llmRawFileName = "llm_raw.json"
+
 // SaveResultsHandler handles saving results requests.
 type SaveResultsHandler struct {
 	ResultsBaseDir string
-	Extractor      *extractor.ExtractorService
-	Logger         *zap.Logger
+	// Save subpath under ResultsBaseDir, e.g. "{date}/{schema}/{filename}"
+	PathTemplate string
+	// Write llm_raw.json for every save carrying LLM responses, not only on request
+	SaveLLMRaw bool
+	Extractor  *extractor.ExtractorService
+	Logger     *zap.Logger
 }
 
 // NewSaveResultsHandler creates a new save handler.
-func NewSaveResultsHandler(resultsBaseDir string, extractor *extractor.ExtractorService, logger *zap.Logger) *SaveResultsHandler {
+func NewSaveResultsHandler(resultsBaseDir, pathTemplate string, saveLLMRaw bool, extractor *extractor.ExtractorService, logger *zap.Logger) *SaveResultsHandler {
+	logger = logger.Named("SaveResultsHandler")
+	if pathTemplate == "" {
+		pathTemplate = DefaultResultsPathTemplate
+	}
+	if err := validateResultsPathTemplate(pathTemplate); err != nil {
+		logger.Warn("Invalid results path template, saving to one folder per file", zap.Error(err))
+		pathTemplate = DefaultResultsPathTemplate
+	}
 	return &SaveResultsHandler{
 		ResultsBaseDir: resultsBaseDir,
+		PathTemplate:   pathTemplate,
+		SaveLLMRaw:     saveLLMRaw,
 		Extractor:      extractor,
-		Logger:         logger.Named("SaveResultsHandler"),
+		Logger:         logger,
 	}
 }
 
@@ -51,14 +81,17 @@ func (h *SaveResultsHandler) SaveResults(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.Logger.Error("Failed to bind request JSON", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		respondJSON(c, bindErrorStatus(err), gin.H{"error": "Invalid request body: " + err.Error()})
 		return
 	}
 
 	// --- Input Validation ---
 	if req.Text == "" || req.OriginalFilename == "" {
 		h.Logger.Warn("Save request missing text or original filename")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Text content and original filename are required"})
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Text content and original filename are required"})
+		return
+	}
+	if rejectTooManySchemas(c, h.Logger, h.Extractor.MaxRequestSchemas(), req.SchemaNames) {
 		return
 	}
 	// Validate schema names exist
@@ -79,12 +112,12 @@ func (h *SaveResultsHandler) SaveResults(c *gin.Context) {
 	}
 	if len(invalidSchemas) > 0 {
 		h.Logger.Error("Invalid or missing schema names requested for save", zap.Strings("invalid_or_missing", invalidSchemas), zap.Strings("requested", req.SchemaNames))
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid or missing schema name(s): %v", invalidSchemas)})
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid or missing schema name(s): %v", invalidSchemas)})
 		return
 	}
 	if len(validSchemaNames) == 0 { // Should be caught by binding:"min=1" but double-check
 		h.Logger.Error("No valid schema names provided after filtering", zap.Strings("requested", req.SchemaNames))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No valid schema names provided"})
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "No valid schema names provided"})
 		return
 	}
 
@@ -93,14 +126,18 @@ func (h *SaveResultsHandler) SaveResults(c *gin.Context) {
 	}
 	// --- End Validation ---
 
-	// Sanitize original filename for folder name
-	folderName := sanitizeFilenameForFolder(req.OriginalFilename)
+	// Resolve the save subpath; each segment is sanitized, the filename as a folder name
+	folderName := resolveResultsPath(h.PathTemplate, validSchemaNames, req.OriginalFilename, time.Now())
 	targetDir := filepath.Join(h.ResultsBaseDir, folderName)
 
+	// Saves to the same folder run one at a time
+	unlock := lockResultFolder(targetDir)
+	defer unlock()
+
 	// Create the directory
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		h.Logger.Error("Failed to create results directory", zap.String("path", targetDir), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create save directory"})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create save directory"})
 		return
 	}
 
@@ -109,7 +146,7 @@ func (h *SaveResultsHandler) SaveResults(c *gin.Context) {
 	combinedSchemaData, err := h.Extractor.CombineSchemas(validSchemaNames) // Use valid names
 	if err != nil {
 		h.Logger.Error("Failed to combine schemas for saving", zap.Strings("schemas", validSchemaNames), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to combine schemas: " + err.Error()})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to combine schemas: " + err.Error()})
 		return
 	}
 
@@ -117,15 +154,15 @@ func (h *SaveResultsHandler) SaveResults(c *gin.Context) {
 	combinedYamlBytes, err := yaml.Marshal(combinedSchemaData)
 	if err != nil {
 		h.Logger.Error("Failed to marshal combined schema to YAML", zap.Strings("schemas", validSchemaNames), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate combined schema file content"})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to generate combined schema file content"})
 		return
 	}
 
 	// Write the combined YAML to schema.yaml
 	schemaTargetPath := filepath.Join(targetDir, "schema.yaml")
-	if err := os.WriteFile(schemaTargetPath, combinedYamlBytes, 0644); err != nil {
+	if err := writeFileAtomic(schemaTargetPath, combinedYamlBytes, 0644); err != nil {
 		h.Logger.Error("Failed to write combined schema file", zap.String("path", schemaTargetPath), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save combined schema file"})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save combined schema file"})
 		return
 	}
 	h.Logger.Info("Saved combined schema file", zap.String("path", schemaTargetPath), zap.Strings("source_schemas", validSchemaNames))
@@ -133,39 +170,84 @@ func (h *SaveResultsHandler) SaveResults(c *gin.Context) {
 
 	// --- Save text.txt (logic remains the same) ---
 	textTargetPath := filepath.Join(targetDir, "text.txt")
-	if err := os.WriteFile(textTargetPath, []byte(req.Text), 0644); err != nil {
+	if err := writeFileAtomic(textTargetPath, []byte(req.Text), 0644); err != nil {
 		h.Logger.Error("Failed to write text file", zap.String("path", textTargetPath), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save text file"})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save text file"})
 		return
 	}
 	h.Logger.Info("Saved text file", zap.String("path", textTargetPath))
 
 	// --- Save results.json (logic remains the same) ---
+	schemaHash, err := extractor.HashSchema(combinedSchemaData)
+	if err != nil {
+		h.Logger.Error("Failed to hash combined schema", zap.Strings("schemas", validSchemaNames), zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to prepare results data"})
+		return
+	}
+	// The LLM's answers are only kept in llm_raw.json, and only when asked for
+	var llmResponses []string
+	debug := req.Debug
+	if debug != nil {
+		llmResponses = debug.LLMResponses
+		debug = &extractor.ExtractionDebug{PromptSchemas: debug.PromptSchemas}
+	}
+	promptVersion := req.PromptVersion
+	if promptVersion == "" {
+		promptVersion = h.Extractor.PromptVersion()
+	}
 	resultsData := SaveResultsResponse{
-		Text:     req.Text,
-		Entities: req.Entities,
+		Text:          req.Text,
+		Entities:      req.Entities,
+		SchemaNames:   validSchemaNames,
+		SchemaHash:    schemaHash,
+		PromptVersion: promptVersion,
+		Debug:         debug,
 	}
 	resultsJSON, err := json.MarshalIndent(resultsData, "", "  ")
 	if err != nil {
 		h.Logger.Error("Failed to marshal results data to JSON", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare results data"})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to prepare results data"})
 		return
 	}
 	resultsTargetPath := filepath.Join(targetDir, "results.json")
-	if err := os.WriteFile(resultsTargetPath, resultsJSON, 0644); err != nil {
+	if err := writeFileAtomic(resultsTargetPath, resultsJSON, 0644); err != nil {
 		h.Logger.Error("Failed to write results JSON file", zap.String("path", resultsTargetPath), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save results JSON file"})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save results JSON file"})
 		return
 	}
 	h.Logger.Info("Saved results JSON file", zap.String("path", resultsTargetPath))
 
+	// --- Save llm_raw.json ---
+	if h.SaveLLMRaw || req.SaveLLMRaw {
+		if len(llmResponses) == 0 {
+			h.Logger.Warn("No LLM responses to save, extract with debug set to keep them", zap.String("folder_name", filepath.ToSlash(folderName)))
+		} else {
+			rawJSON, err := json.MarshalIndent(llmResponses, "", "  ")
+			if err != nil {
+				h.Logger.Error("Failed to marshal LLM responses", zap.Error(err))
+				respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to prepare raw LLM output"})
+				return
+			}
+			rawTargetPath := filepath.Join(targetDir, llmRawFileName)
+			if err := writeFileAtomic(rawTargetPath, rawJSON, 0644); err != nil {
+				h.Logger.Error("Failed to write raw LLM output file", zap.String("path", rawTargetPath), zap.Error(err))
+				respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save raw LLM output file"})
+				return
+			}
+			h.Logger.Info("Saved raw LLM output file", zap.String("path", rawTargetPath), zap.Int("responses", len(llmResponses)))
+		}
+	}
+
 	// --- Success Response ---
 	h.Logger.Info("Successfully saved results with combined schema",
 		zap.Strings("schemas", validSchemaNames),
 		zap.String("original_filename", req.OriginalFilename),
-		zap.String("folder_name", folderName),
+		zap.String("folder_name", filepath.ToSlash(folderName)),
 	)
-	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Results saved successfully to folder '%s' using combined schema.", folderName)})
+	respondJSON(c, http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Results saved successfully to folder '%s' using combined schema.", filepath.ToSlash(folderName)),
+		"folder":  filepath.ToSlash(folderName), // The :folder of /api/results, URL-encoded when nested
+	})
 }
 
 // --- Function to sanitize filename for folder name ---
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSaveResultsConcurrentSavesStayConsistent(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", map[string]string{"demo.yaml": "Age:\n  type: number\n"}, nil)
+	baseDir := t.TempDir()
+	h := NewSaveResultsHandler(baseDir, DefaultResultsPathTemplate, false, s, zap.NewNop())
+
+	const writers = 16
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each writer's text and entities identify it, and are large enough to take a while to write
+			text := fmt.Sprintf("writer %02d ", i) + strings.Repeat("x", 64*1024)
+			body, _ := json.Marshal(map[string]any{
+				"schemaNames":      []string{"demo"},
+				"text":             text,
+				"originalFilename": "note.txt",
+				"entities": map[string]any{
+					"Age": []map[string]any{{"value": fmt.Sprint(i), "position": map[string]int{"start": 0, "end": 9}}},
+				},
+			})
+			w := serve(http.MethodPost, "/api/save-results", "/api/save-results", string(body), h.SaveResults)
+			if w.Code != http.StatusOK {
+				t.Errorf("writer %d: status = %d: %s", i, w.Code, w.Body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	folderPath := filepath.Join(baseDir, "note")
+	resultsJSON, err := os.ReadFile(filepath.Join(folderPath, "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results SaveResultsResponse
+	if err := json.Unmarshal(resultsJSON, &results); err != nil {
+		t.Fatalf("results.json is corrupt: %v", err)
+	}
+	text, err := os.ReadFile(filepath.Join(folderPath, "text.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The files must all come from the same, last, save
+	if string(text) != results.Text {
+		t.Error("text.txt and results.json come from different saves")
+	}
+	writer := strings.TrimPrefix(strings.Fields(results.Text)[1], "0")
+	if got := results.Entities["Age"][0].Value; got != writer {
+		t.Errorf("entities come from writer %v, text from writer %s", got, writer)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(folderPath, "*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"path/filepath"
+	"reflect"
 	"slices"
 	"sort"
 	"strings"
@@ -15,16 +17,34 @@ import (
 )
 
 type SchemaHandler struct {
-	Extractor     *extractor.ExtractorService
-	Logger        *zap.Logger
-	SchemaBaseDir string
+	Extractor  *extractor.ExtractorService
+	Logger     *zap.Logger
+	SchemaDirs []string
+	// How schema details treat entities sharing a leaf name; see LeafCollisionsReport
+	LeafCollisionPolicy string
 }
 
-func NewSchemaHandler(extractor *extractor.ExtractorService, logger *zap.Logger, schemaBaseDir string) *SchemaHandler {
+// Ways schema details handle entities that share a leaf name at different
+// paths, such as "Temperature" and "Vital signs.Temperature"
+const (
+	LeafCollisionsReport       = "report"        // List them under leafCollisions and keep them all
+	LeafCollisionsPreferNested = "prefer_nested" // Also keep only the most deeply nested of them
+	LeafCollisionsPreferFlat   = "prefer_flat"   // Also keep only the least nested of them
+)
+
+func NewSchemaHandler(extractor *extractor.ExtractorService, logger *zap.Logger, schemaDirs []string, leafCollisionPolicy string) *SchemaHandler {
+	logger = logger.Named("SchemaHandler")
+	switch leafCollisionPolicy {
+	case LeafCollisionsReport, LeafCollisionsPreferNested, LeafCollisionsPreferFlat:
+	default:
+		logger.Warn("Unknown leaf name collision policy, reporting collisions only", zap.String("policy", leafCollisionPolicy))
+		leafCollisionPolicy = LeafCollisionsReport
+	}
 	return &SchemaHandler{
-		Extractor:     extractor,
-		Logger:        logger.Named("SchemaHandler"),
-		SchemaBaseDir: schemaBaseDir,
+		Extractor:           extractor,
+		Logger:              logger,
+		SchemaDirs:          schemaDirs,
+		LeafCollisionPolicy: leafCollisionPolicy,
 	}
 }
 
@@ -32,7 +52,7 @@ func NewSchemaHandler(extractor *extractor.ExtractorService, logger *zap.Logger,
 func (h *SchemaHandler) GetSchemas(c *gin.Context) {
 	schemaNames := h.Extractor.GetAvailableSchemas()
 	h.Logger.Info("Responding with available schema names", zap.Int("count", len(schemaNames)))
-	c.JSON(http.StatusOK, gin.H{"schemas": schemaNames})
+	respondJSON(c, http.StatusOK, gin.H{"schemas": schemaNames})
 }
 
 // GetSchemaDetails handles GET /api/schemas/:schemaName/details
@@ -40,11 +60,12 @@ func (h *SchemaHandler) GetSchemaDetails(c *gin.Context) {
 	schemaNames := c.QueryArray("schemas")
 	if len(schemaNames) == 0 {
 		h.Logger.Error("GetSchemaDetails called without 'schemas' parameter")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'schemas' in query"})
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Missing 'schemas' in query"})
 		return
 	}
 
 	combinedEntityNames := make(map[string]bool)
+	schemaMeta := make(map[string]map[string]any) // Meta keys (_name, _description, ...) per schema
 	availableSchemas := h.Extractor.GetAvailableSchemas()
 
 	for _, schemaName := range schemaNames {
@@ -73,7 +94,8 @@ func (h *SchemaHandler) GetSchemaDetails(c *gin.Context) {
 		schemaInterfaceMap := make(map[string]any, len(schemaData))
 		maps.Copy(schemaInterfaceMap, schemaData) // Convert extractor.Schema to map[string]any
 
-		entityNames := flattenSchemaEntityNames(schemaInterfaceMap, "") // Pass the converted map
+		entityNames := flattenSchemaEntityNames(schemaInterfaceMap, "", h.Extractor.NestingDelimiter()) // Pass the converted map
+		schemaMeta[cleanSchemaName] = extractor.SchemaMeta(schemaData)
 
 		// Add to combined map (ensures uniqueness)
 		for _, entityName := range entityNames {
@@ -89,20 +111,298 @@ func (h *SchemaHandler) GetSchemaDetails(c *gin.Context) {
 
 	sort.Strings(finalEntityList) // Sort for consistent order
 
+	delimiter := h.Extractor.NestingDelimiter()
+	collisions := leafNameCollisions(finalEntityList, delimiter)
+	if len(collisions) > 0 {
+		h.Logger.Info("Entities share leaf names at different paths", zap.Any("collisions", collisions), zap.String("policy", h.LeafCollisionPolicy))
+		finalEntityList = collapseLeafCollisions(finalEntityList, collisions, delimiter, h.LeafCollisionPolicy)
+	}
+
 	h.Logger.Info("Returning combined entity names", zap.Int("count", len(finalEntityList)), zap.Strings("schemas", schemaNames))
-	c.JSON(http.StatusOK, gin.H{"entityNames": finalEntityList})
+	respondJSON(c, http.StatusOK, gin.H{"entityNames": finalEntityList, "meta": schemaMeta, "leafCollisions": collisions})
+}
+
+// GetRawSchema handles GET /api/schemas/:schemaName/raw
+//
+// It returns the full parsed definition of one schema as JSON.
+func (h *SchemaHandler) GetRawSchema(c *gin.Context) {
+	name := c.Param("schemaName")
+	if !isValidSchemaName(name) {
+		h.Logger.Warn("Invalid schema name in raw schema request", zap.String("schemaName", name))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid schema name"})
+		return
+	}
+
+	schema, found := h.getSchemaByName(name)
+	if !found {
+		h.Logger.Warn("Requested raw schema not found", zap.String("schemaName", name))
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Schema not found"})
+		return
+	}
+
+	// YAML may decode nested maps as map[any]any, which JSON cannot encode
+	converted, ok := convertToMapStringInterface(schema)
+	if !ok {
+		h.Logger.Error("Failed to convert schema for JSON", zap.String("schemaName", name))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to convert schema"})
+		return
+	}
+	respondJSON(c, http.StatusOK, converted)
+}
+
+// SchemaFieldDiff holds the differing values of one definition field.
+type SchemaFieldDiff struct {
+	A any `json:"a"`
+	B any `json:"b"`
+}
+
+// SchemaEntityDiff describes an entity defined in both compared schemas.
+type SchemaEntityDiff struct {
+	Name        string                     `json:"name"`
+	Differences map[string]SchemaFieldDiff `json:"differences,omitempty"` // Keyed by field (type, description)
+}
+
+// CompareSchemas handles GET /api/schemas/compare?a=x&b=y
+func (h *SchemaHandler) CompareSchemas(c *gin.Context) {
+	nameA, nameB := c.Query("a"), c.Query("b")
+	for _, name := range []string{nameA, nameB} {
+		if !isValidSchemaName(name) {
+			h.Logger.Warn("Invalid schema name in compare request", zap.String("schemaName", name))
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "Query parameters 'a' and 'b' must be valid schema names"})
+			return
+		}
+	}
+
+	schemaA, foundA := h.getSchemaByName(nameA)
+	schemaB, foundB := h.getSchemaByName(nameB)
+	if !foundA || !foundB {
+		h.Logger.Warn("Requested schema for compare not found", zap.String("a", nameA), zap.String("b", nameB))
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Schema not found"})
+		return
+	}
+
+	entitiesA := flattenSchemaEntities(schemaA, "", h.Extractor.NestingDelimiter())
+	entitiesB := flattenSchemaEntities(schemaB, "", h.Extractor.NestingDelimiter())
+
+	onlyInA := []string{}
+	onlyInB := []string{}
+	inBoth := []SchemaEntityDiff{}
+	for entityName, defA := range entitiesA {
+		defB, shared := entitiesB[entityName]
+		if !shared {
+			onlyInA = append(onlyInA, entityName)
+			continue
+		}
+		diff := SchemaEntityDiff{Name: entityName}
+		for _, field := range []string{"type", "description"} {
+			valueA, valueB := defA[field], defB[field] // Indexing a nil definition is safe
+			if !reflect.DeepEqual(valueA, valueB) {
+				if diff.Differences == nil {
+					diff.Differences = make(map[string]SchemaFieldDiff)
+				}
+				diff.Differences[field] = SchemaFieldDiff{A: valueA, B: valueB}
+			}
+		}
+		inBoth = append(inBoth, diff)
+	}
+	for entityName := range entitiesB {
+		if _, shared := entitiesA[entityName]; !shared {
+			onlyInB = append(onlyInB, entityName)
+		}
+	}
+
+	sort.Strings(onlyInA)
+	sort.Strings(onlyInB)
+	sort.Slice(inBoth, func(i, j int) bool { return inBoth[i].Name < inBoth[j].Name })
+
+	h.Logger.Info("Compared schemas",
+		zap.String("a", nameA), zap.String("b", nameB),
+		zap.Int("only_in_a", len(onlyInA)), zap.Int("only_in_b", len(onlyInB)), zap.Int("in_both", len(inBoth)),
+	)
+	respondJSON(c, http.StatusOK, gin.H{
+		"a":       nameA,
+		"b":       nameB,
+		"onlyInA": onlyInA,
+		"onlyInB": onlyInB,
+		"inBoth":  inBoth,
+	})
+}
+
+// SchemaLintRequest defines the expected JSON body for the /api/schemas/lint endpoint.
+type SchemaLintRequest struct {
+	SchemaNames []string `json:"schema_names" binding:"required,min=1"`
+}
+
+// SchemaEntityCollision lists the schemas that each define the same entity.
+type SchemaEntityCollision struct {
+	Entity  string   `json:"entity"`
+	Schemas []string `json:"schemas"`
+}
+
+// IncompleteSchemaEntity is an entity of the combined schema missing definition fields.
+type IncompleteSchemaEntity struct {
+	Entity  string   `json:"entity"`
+	Missing []string `json:"missing"`
+}
+
+// LintSchemas handles POST /api/schemas/lint
+//
+// It reports the problems CombineSchemas would otherwise only log: top-level
+// keys shadowed by a later schema, entities defined by several schemas, and
+// entities of the combined schema lacking a type or description.
+func (h *SchemaHandler) LintSchemas(c *gin.Context) {
+	var req SchemaLintRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Logger.Error("Failed to bind JSON request for schema lint", zap.Error(err))
+		respondJSON(c, bindErrorStatus(err), gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	invalidSchemas := []string{}
+	for _, schemaName := range req.SchemaNames {
+		if _, found := h.getSchemaByName(schemaName); !found {
+			invalidSchemas = append(invalidSchemas, schemaName)
+		}
+	}
+	if len(invalidSchemas) > 0 {
+		h.Logger.Error("Invalid schema names requested for lint", zap.Strings("invalid", invalidSchemas))
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid schema name(s) provided: %v", invalidSchemas)})
+		return
+	}
+
+	combined, report, err := h.Extractor.CombineSchemasWithReport(req.SchemaNames)
+	if err != nil {
+		h.Logger.Error("Failed to combine schemas for lint", zap.Strings("schemas", req.SchemaNames), zap.Error(err))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to combine schemas: " + err.Error()})
+		return
+	}
+
+	// Entities defined by more than one of the requested schemas
+	definedBy := make(map[string][]string)
+	for _, schemaName := range slices.Compact(slices.Clone(req.SchemaNames)) {
+		schema, _ := h.getSchemaByName(schemaName)
+		for entityName := range flattenSchemaEntities(schema, "", h.Extractor.NestingDelimiter()) {
+			if !slices.Contains(definedBy[entityName], schemaName) {
+				definedBy[entityName] = append(definedBy[entityName], schemaName)
+			}
+		}
+	}
+	collisions := []SchemaEntityCollision{}
+	for entityName, schemaNames := range definedBy {
+		if len(schemaNames) > 1 {
+			collisions = append(collisions, SchemaEntityCollision{Entity: entityName, Schemas: schemaNames})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Entity < collisions[j].Entity })
+
+	// Entities of the effective schema without a type or description
+	incomplete := []IncompleteSchemaEntity{}
+	for entityName, definition := range flattenSchemaEntities(combined, "", h.Extractor.NestingDelimiter()) {
+		missing := []string{}
+		for _, field := range []string{"type", "description"} {
+			if _, has := definition[field]; !has {
+				missing = append(missing, field)
+			}
+		}
+		if len(missing) > 0 {
+			incomplete = append(incomplete, IncompleteSchemaEntity{Entity: entityName, Missing: missing})
+		}
+	}
+	sort.Slice(incomplete, func(i, j int) bool { return incomplete[i].Entity < incomplete[j].Entity })
+
+	shadowed := report.Conflicts
+	if shadowed == nil {
+		shadowed = []extractor.SchemaConflict{}
+	}
+
+	h.Logger.Info("Linted schemas",
+		zap.Strings("schemas", req.SchemaNames),
+		zap.Int("shadowed", len(shadowed)),
+		zap.Int("collisions", len(collisions)),
+		zap.Int("incomplete", len(incomplete)),
+	)
+	respondJSON(c, http.StatusOK, gin.H{
+		"shadowed":   shadowed,
+		"collisions": collisions,
+		"incomplete": incomplete,
+	})
+}
+
+// isValidSchemaName rejects empty names and names that try to escape the schema directory.
+func isValidSchemaName(name string) bool {
+	return name != "" && filepath.Base(name) == name && !strings.Contains(name, "..")
 }
 
 func (h *SchemaHandler) getSchemaByName(name string) (extractor.Schema, bool) {
+	if h.Extractor.IsSchemaHidden(name) {
+		return nil, false
+	}
 	schema, found := h.Extractor.Schemas[name]
 	// Return a copy? Deep copy might be needed if modifications are possible elsewhere
 	// For now, returning direct reference assuming read-only usage in handler.
 	return schema, found
 }
 
-func flattenSchemaEntityNames(data map[string]any, prefix string) []string {
-	entityNames := []string{}
-	seen := make(map[string]bool) // Track seen keys
+func flattenSchemaEntityNames(data map[string]any, prefix, delimiter string) []string {
+	entities := flattenSchemaEntities(data, prefix, delimiter)
+	entityNames := make([]string, 0, len(entities))
+	for entityName := range entities {
+		entityNames = append(entityNames, entityName)
+	}
+	return entityNames
+}
+
+// leafNameCollisions groups the entity names whose last segment is shared
+// with another name at a different path, keyed by that leaf name.
+func leafNameCollisions(entityNames []string, delimiter string) map[string][]string {
+	byLeaf := make(map[string][]string)
+	for _, entityName := range entityNames {
+		leaf := entityName
+		if i := strings.LastIndex(entityName, delimiter); i >= 0 {
+			leaf = entityName[i+len(delimiter):]
+		}
+		byLeaf[leaf] = append(byLeaf[leaf], entityName)
+	}
+	collisions := make(map[string][]string)
+	for leaf, names := range byLeaf {
+		if len(names) > 1 {
+			sort.Strings(names)
+			collisions[leaf] = names
+		}
+	}
+	return collisions
+}
+
+// collapseLeafCollisions drops colliding names per the policy, keeping the
+// most (prefer_nested) or least (prefer_flat) nested names of each group.
+func collapseLeafCollisions(entityNames []string, collisions map[string][]string, delimiter, policy string) []string {
+	if policy != LeafCollisionsPreferNested && policy != LeafCollisionsPreferFlat {
+		return entityNames
+	}
+	dropped := make(map[string]bool)
+	for _, names := range collisions {
+		depths := make([]int, len(names))
+		for i, name := range names {
+			depths[i] = strings.Count(name, delimiter)
+		}
+		keep := slices.Max(depths)
+		if policy == LeafCollisionsPreferFlat {
+			keep = slices.Min(depths)
+		}
+		for i, name := range names {
+			if depths[i] != keep {
+				dropped[name] = true
+			}
+		}
+	}
+	return slices.DeleteFunc(entityNames, func(name string) bool { return dropped[name] })
+}
+
+// flattenSchemaEntities maps each flattened entity name to its definition map,
+// joining nested names with delimiter. The definition is nil for nested
+// entities declared as plain values.
+func flattenSchemaEntities(data map[string]any, prefix, delimiter string) map[string]map[string]any {
+	entities := make(map[string]map[string]any)
 
 	var recurse func(subData map[string]any, currentPrefix string)
 	recurse = func(subData map[string]any, currentPrefix string) {
@@ -115,7 +415,7 @@ func flattenSchemaEntityNames(data map[string]any, prefix string) []string {
 
 			fullKey := stringKey
 			if currentPrefix != "" {
-				fullKey = currentPrefix + "." + stringKey
+				fullKey = currentPrefix + delimiter + stringKey
 			}
 
 			// Check if the value is a map
@@ -124,6 +424,7 @@ func flattenSchemaEntityNames(data map[string]any, prefix string) []string {
 			isPotentiallyAnEntity := false
 			shouldRecurseIntoProperties := false
 			var propertiesMap map[string]any
+			var itemPropertiesMap map[string]any // Of arrays of objects, beside any properties
 
 			if isMap {
 				// Check for 'properties' key specifically
@@ -137,6 +438,20 @@ func flattenSchemaEntityNames(data map[string]any, prefix string) []string {
 					}
 				}
 
+				// Item properties of arrays of objects are entities of each item ("Medications[].name")
+				if items, hasItems := convertToMapStringInterface(valueMap["items"]); hasItems {
+					if props, propsIsMap := items["properties"].(map[string]any); propsIsMap {
+						shouldRecurseIntoProperties = true
+						itemPropertiesMap = props
+					}
+				}
+
+				// Table columns are entities in their own right ("Table.Column")
+				if columns, hasColumns := valueMap["columns"].(map[string]any); hasColumns && valueMap["type"] == "table" {
+					shouldRecurseIntoProperties = true
+					propertiesMap = columns
+				}
+
 				// Check for standard definition keys (type, description, items)
 				_, hasType := valueMap["type"]
 				_, hasDescription := valueMap["description"]
@@ -161,11 +476,15 @@ func flattenSchemaEntityNames(data map[string]any, prefix string) []string {
 
 			// --- Decision ---
 			if shouldRecurseIntoProperties {
-				recurse(propertiesMap, fullKey)
+				if propertiesMap != nil {
+					recurse(propertiesMap, fullKey)
+				}
+				if itemPropertiesMap != nil {
+					recurse(itemPropertiesMap, fullKey+extractor.ArrayItemMarker)
+				}
 			} else if isPotentiallyAnEntity {
-				if !seen[fullKey] {
-					entityNames = append(entityNames, fullKey)
-					seen[fullKey] = true
+				if _, seen := entities[fullKey]; !seen {
+					entities[fullKey] = valueMap
 				}
 			}
 			// else: Skip structural maps without properties, skip top-level non-entities
@@ -173,7 +492,7 @@ func flattenSchemaEntityNames(data map[string]any, prefix string) []string {
 	}
 
 	recurse(data, prefix)
-	return entityNames
+	return entities
 }
 
 // Recursive helper function to handle various map types from YAML
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestRequestsOverSchemaCapAreRejected(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", defaultSchemaFiles, func(cfg *config.Config) {
+		cfg.LLM.MaxRequestSchemas = 1
+	})
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+	save := NewSaveResultsHandler(t.TempDir(), DefaultResultsPathTemplate, false, s, zap.NewNop())
+
+	w := serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "HR 80", "schema_names": ["vitals", "meds"]}`, h.ExtractEntities)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Too many schemas") {
+		t.Errorf("extract: status = %d (%s), want 400 for too many schemas", w.Code, w.Body)
+	}
+	w = serve(http.MethodPost, "/api/extract/batch", "/api/extract/batch", `{"documents": ["HR 80"], "schema_names": ["vitals", "meds"]}`, h.BatchExtract)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("batch: status = %d, want 400", w.Code)
+	}
+	w = serve(http.MethodPost, "/api/save-results", "/api/save-results", `{"schemaNames": ["meds", "vitals"], "text": "HR 80", "originalFilename": "note.txt"}`, save.SaveResults)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("save: status = %d, want 400", w.Code)
+	}
+	w = serve(http.MethodPost, "/api/save-results", "/api/save-results", `{"schemaNames": ["vitals"], "text": "HR 80", "originalFilename": "note.txt"}`, save.SaveResults)
+	if w.Code != http.StatusOK {
+		t.Errorf("save within the cap: status = %d (%s), want 200", w.Code, w.Body)
+	}
+}
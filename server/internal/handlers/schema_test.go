@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFlattenSchemaEntityNamesDelimiter(t *testing.T) {
+	schema := map[string]any{
+		"Labs": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"Vit. D": map[string]any{"type": "number"},
+			},
+		},
+	}
+	for _, delimiter := range []string{".", "/", ">"} {
+		names := flattenSchemaEntityNames(schema, "", delimiter)
+		want := "Labs" + delimiter + "Vit. D"
+		if !slices.Contains(names, want) {
+			t.Errorf("delimiter %q: names = %v, want %q", delimiter, names, want)
+		}
+	}
+}
+
+func TestFlattenSchemaEntityNamesArrayItems(t *testing.T) {
+	schema := map[string]any{
+		"Medications": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{"type": "string"},
+					"dose": map[string]any{"type": "string"},
+				},
+			},
+		},
+		// Properties of the array itself and of its items are both entities
+		"Allergies": map[string]any{
+			"type":       "array",
+			"properties": map[string]any{"reviewed": map[string]any{"type": "boolean"}},
+			"items": map[string]any{
+				"properties": map[string]any{"substance": map[string]any{"type": "string"}},
+			},
+		},
+		"Diagnoses": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	}
+	names := flattenSchemaEntityNames(schema, "", ".")
+	slices.Sort(names)
+	want := []string{"Allergies.reviewed", "Allergies[].substance", "Diagnoses", "Medications[].dose", "Medications[].name"}
+	if !slices.Equal(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestGetSchemaDetailsReturnsMeta(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", map[string]string{
+		"cardio.yaml": "_name: Cardiology\n_version: 3\nHeartRate:\n  type: number\n",
+	}, nil)
+	h := NewSchemaHandler(s, zap.NewNop(), nil, LeafCollisionsReport)
+
+	w := serve(http.MethodGet, "/api/schemas/details", "/api/schemas/details?schemas=cardio", "", h.GetSchemaDetails)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		EntityNames []string                  `json:"entityNames"`
+		Meta        map[string]map[string]any `json:"meta"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Meta["cardio"]["_name"] != "Cardiology" {
+		t.Errorf("meta = %v, want the schema's meta keys", response.Meta)
+	}
+	if slices.Contains(response.EntityNames, "_name") {
+		t.Error("meta key listed as an entity")
+	}
+}
+
+func TestGetSchemaDetailsLeafCollisions(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", map[string]string{
+		"vitals.yaml": `
+Temperature:
+  type: number
+Vital signs:
+  type: object
+  properties:
+    Temperature:
+      type: number
+    Pulse:
+      type: number
+`,
+	}, nil)
+
+	tests := []struct {
+		policy    string
+		wantNames []string
+	}{
+		{LeafCollisionsReport, []string{"Temperature", "Vital signs.Pulse", "Vital signs.Temperature"}},
+		{LeafCollisionsPreferNested, []string{"Vital signs.Pulse", "Vital signs.Temperature"}},
+		{LeafCollisionsPreferFlat, []string{"Temperature", "Vital signs.Pulse"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			h := NewSchemaHandler(s, zap.NewNop(), nil, tt.policy)
+			w := serve(http.MethodGet, "/api/schemas/details", "/api/schemas/details?schemas=vitals", "", h.GetSchemaDetails)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var response struct {
+				EntityNames    []string            `json:"entityNames"`
+				LeafCollisions map[string][]string `json:"leafCollisions"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(response.EntityNames, tt.wantNames) {
+				t.Errorf("entityNames = %v, want %v", response.EntityNames, tt.wantNames)
+			}
+			wantCollision := []string{"Temperature", "Vital signs.Temperature"}
+			if got := response.LeafCollisions["Temperature"]; !slices.Equal(got, wantCollision) || len(response.LeafCollisions) != 1 {
+				t.Errorf("leafCollisions = %v, want Temperature: %v", response.LeafCollisions, wantCollision)
+			}
+		})
+	}
+}
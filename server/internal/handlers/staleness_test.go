@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"github.com/andevellicus/med-ex/internal/extractor"
+	"go.uber.org/zap"
+)
+
+func TestGetResultStaleness(t *testing.T) {
+	s := newTestExtractor(t, "http://127.0.0.1:1", map[string]string{"vitals.yaml": "HeartRate:\n  type: number\n"}, nil)
+	combined, err := s.CombineSchemas([]string{"vitals"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentHash, err := extractor.HashSchema(combined)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		saved     SaveResultsResponse
+		wantStale bool
+	}{
+		"matching":       {SaveResultsResponse{SchemaNames: []string{"vitals"}, SchemaHash: currentHash, PromptVersion: extractor.PromptTemplateVersion}, false},
+		"schema changed": {SaveResultsResponse{SchemaNames: []string{"vitals"}, SchemaHash: "outdated", PromptVersion: extractor.PromptTemplateVersion}, true},
+		"prompt changed": {SaveResultsResponse{SchemaNames: []string{"vitals"}, SchemaHash: currentHash, PromptVersion: "0"}, true},
+		"unversioned":    {SaveResultsResponse{}, true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			writeSavedResult(t, baseDir, "note", tt.saved)
+			h := NewResultsHandler(baseDir, s, zap.NewNop())
+
+			w := serve(http.MethodGet, "/api/results/:folder/staleness", "/api/results/note/staleness", "", h.GetResultStaleness)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var response struct {
+				Stale   bool     `json:"stale"`
+				Reasons []string `json:"reasons"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Stale != tt.wantStale {
+				t.Errorf("stale = %v (reasons %v), want %v", response.Stale, response.Reasons, tt.wantStale)
+			}
+		})
+	}
+}
+
+// frenchPrompt is a minimal complete translated prompt template.
+const frenchPrompt = `Schéma : {schema}
+Texte : {text}
+{instructions}
+{section:reference_date}
+Date : {date}.
+{section:table}
+- Tableaux.
+{section:array_items}
+- Éléments {entities} {marker}.
+{section:alternatives}
+- Candidats {entities}.
+`
+
+func TestSavedTranslatedResultIsCurrentUntilItsTemplateChanges(t *testing.T) {
+	promptDir := t.TempDir()
+	writeTemplate := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(promptDir, "fr.txt"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTemplate(frenchPrompt)
+	llm := newLLMStub(t, `{"HeartRate": [{"value": "80", "context": "FC 80"}]}`)
+	withFrench := func(cfg *config.Config) { cfg.LLM.PromptDir = promptDir }
+	s := newTestExtractor(t, llm.URL, map[string]string{"vitals.yaml": "HeartRate:\n  type: number\n"}, withFrench)
+
+	extract := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+	w := serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "Patient stable, FC 80.", "schema_names": ["vitals"], "prompt_lang": "fr"}`, extract.ExtractEntities)
+	if w.Code != http.StatusOK {
+		t.Fatalf("extract status = %d: %s", w.Code, w.Body)
+	}
+	var extracted struct {
+		Metadata struct {
+			PromptVersion string `json:"prompt_version"`
+		} `json:"metadata"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &extracted)
+	if extracted.Metadata.PromptVersion == s.PromptVersion() {
+		t.Fatalf("French prompt version %q equals the configured English one", extracted.Metadata.PromptVersion)
+	}
+
+	baseDir := t.TempDir()
+	save := NewSaveResultsHandler(baseDir, "", false, s, zap.NewNop())
+	body := `{"schemaNames": ["vitals"], "text": "Patient stable, FC 80.", "originalFilename": "note.txt", "promptVersion": "` + extracted.Metadata.PromptVersion + `"}`
+	if w := serve(http.MethodPost, "/api/save-results", "/api/save-results", body, save.SaveResults); w.Code != http.StatusOK {
+		t.Fatalf("save status = %d: %s", w.Code, w.Body)
+	}
+
+	stale := func(s *extractor.ExtractorService) bool {
+		t.Helper()
+		w := serve(http.MethodGet, "/api/results/:folder/staleness", "/api/results/note/staleness", "", NewResultsHandler(baseDir, s, zap.NewNop()).GetResultStaleness)
+		var response struct {
+			Stale bool `json:"stale"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Stale
+	}
+	if stale(s) {
+		t.Error("result saved with the current French template reported stale")
+	}
+	writeTemplate(strings.Replace(frenchPrompt, "Texte :", "Texte médical :", 1))
+	if !stale(newTestExtractor(t, llm.URL, map[string]string{"vitals.yaml": "HeartRate:\n  type: number\n"}, withFrench)) {
+		t.Error("result not reported stale after its French template changed")
+	}
+}
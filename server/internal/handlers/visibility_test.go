@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/andevellicus/med-ex/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestDeniedSchemaIsHidden(t *testing.T) {
+	llm := newLLMStub(t, `{}`)
+	s := newTestExtractor(t, llm.URL, defaultSchemaFiles, func(cfg *config.Config) {
+		cfg.LLM.DeniedSchemas = []string{"meds"}
+	})
+
+	w := serve(http.MethodGet, "/api/schemas", "/api/schemas", "", NewSchemaHandler(s, zap.NewNop(), nil, LeafCollisionsReport).GetSchemas)
+	var listing struct {
+		Schemas []string `json:"schemas"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Schemas) != 1 || listing.Schemas[0] != "vitals" {
+		t.Errorf("listed schemas = %v, want only vitals", listing.Schemas)
+	}
+
+	h := NewExtractHandler(s, zap.NewNop(), 1, false, nil, nil, nil)
+	w = serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "on aspirin", "schema_names": ["meds"]}`, h.ExtractEntities)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("extraction with the denied schema: status = %d, want 404", w.Code)
+	}
+	w = serve(http.MethodPost, "/api/extract", "/api/extract", `{"text": "HR 80", "schema_names": ["vitals"]}`, h.ExtractEntities)
+	if w.Code != http.StatusOK {
+		t.Errorf("extraction with a visible schema: status = %d: %s", w.Code, w.Body)
+	}
+	if len(llm.Prompts()) != 1 {
+		t.Errorf("LLM called %d times, want only for the visible schema", len(llm.Prompts()))
+	}
+}